@@ -0,0 +1,286 @@
+// Package apierror は全てのAPIで共通のエラーレスポンスを返します。
+//
+// ハンドラーは Abort でエラーを登録して処理を終えるだけにし、レスポンスの組み立ては Middleware が行います。
+// レスポンスは次の形式です。Accept に application/problem+json を含むリクエストには RFC 7807 の形式で返します。
+// メッセージは日本語で書いておけば、返す直前にリクエストの言語に翻訳します (yoyaku/i18n)。
+//
+//	{
+//	  "status": "error",
+//	  "code": "validation_failed",
+//	  "message": "タイトルを入力してください",
+//	  "details": [{"field": "title", "message": "タイトルを入力してください"}],
+//	  "request_id": "3f2a..."
+//	}
+package apierror
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"yoyaku/i18n"
+
+	"github.com/gin-gonic/gin"
+)
+
+// エラーコード。クライアントはメッセージではなくこのコードで処理を分けてください。
+const (
+	CodeBadRequest   = "bad_request"
+	CodeValidation   = "validation_failed"
+	CodeUnauthorized = "unauthorized"
+	CodeForbidden    = "forbidden"
+	CodeNotFound     = "not_found"
+	CodeConflict     = "conflict"
+	CodeInternal     = "internal_error"
+
+	// 予約が見つからない (他のユーザーの予約の場合も含む)
+	CodeReservationNotFound = "reservation_not_found"
+	// 他の予約と時間帯が重なる
+	CodeReservationConflict = "reservation_conflict"
+	// 利用中の予約ではないため、終了・延長できない
+	CodeReservationNotOngoing = "reservation_not_ongoing"
+	// no-show が続いたため新しい予約ができない
+	CodeAccountSuspended = "account_suspended"
+	// チェックインの受付時間外、またはチェックインできない予約
+	CodeCheckinUnavailable = "checkin_unavailable"
+	// 管理者のみ利用できる
+	CodeAdminOnly = "admin_only"
+	// 備品が見つからない
+	CodeEquipmentNotFound = "equipment_not_found"
+	// 同じ時間帯の他の予約が借りているため、備品の数が足りない
+	CodeEquipmentUnavailable = "equipment_unavailable"
+)
+
+// RequestIDHeader はリクエストIDを受け渡すヘッダーです。
+const RequestIDHeader = "X-Request-ID"
+
+const problemContentType = "application/problem+json"
+
+// FieldError は入力項目ごとのエラーです。
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error はクライアントに返すエラーです。
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	Details []FieldError
+	// Err はログに残す元のエラーです。クライアントには返しません。
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// New は任意のステータスとコードのエラーを返します。
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// WithDetails は入力項目ごとのエラーを追加します。
+func (e *Error) WithDetails(details ...FieldError) *Error {
+	e.Details = append(e.Details, details...)
+	return e
+}
+
+func BadRequest(message string) *Error {
+	return New(http.StatusBadRequest, CodeBadRequest, message)
+}
+
+func Validation(message string, details ...FieldError) *Error {
+	return New(http.StatusBadRequest, CodeValidation, message).WithDetails(details...)
+}
+
+func Unauthorized(message string) *Error {
+	return New(http.StatusUnauthorized, CodeUnauthorized, message)
+}
+
+func Forbidden(message string) *Error {
+	return New(http.StatusForbidden, CodeForbidden, message)
+}
+
+func NotFound(message string) *Error {
+	return New(http.StatusNotFound, CodeNotFound, message)
+}
+
+func Conflict(message string) *Error {
+	return New(http.StatusConflict, CodeConflict, message)
+}
+
+// Internal はサーバー内部のエラーを返します。err はログにだけ残ります。
+func Internal(message string, err error) *Error {
+	e := New(http.StatusInternalServerError, CodeInternal, message)
+	e.Err = err
+	return e
+}
+
+// Abort はエラーを登録して後続のハンドラーを中断します。レスポンスは Middleware が返します。
+func Abort(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+}
+
+// Mapper はハンドラーが返したエラーをクライアントに返すエラーに変換します。
+// 変換できない場合は nil を返してください (500 として扱います)。
+type Mapper func(err error) *Error
+
+// Middleware はリクエストIDを割り当て、ハンドラーが Abort したエラーを共通の形式で返します。
+// *Error 以外のエラーは mapper で変換し、変換できなければ内部エラーとしてログに残します。
+func Middleware(mapper Mapper) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Set("request_id", id)
+		c.Header(RequestIDHeader, id)
+
+		c.Next()
+
+		if len(c.Errors) == 0 {
+			return
+		}
+		e := resolve(c.Errors.Last().Err, mapper)
+		if e.Status >= http.StatusInternalServerError {
+			log.Printf("[%s] %s %s: %v", id, c.Request.Method, c.Request.URL.Path, e)
+		}
+		if c.Writer.Written() {
+			// ストリーミング中など、既にレスポンスを返し始めている
+			return
+		}
+		Write(c, e)
+	}
+}
+
+// Recovery は gin.CustomRecovery に渡し、パニックを内部エラーとして返します。
+// パニックは Middleware の外側で回復するため、ここで直接レスポンスを書き込みます。
+func Recovery(c *gin.Context, recovered any) {
+	if !c.Writer.Written() {
+		Write(c, Internal("サーバー内部エラーが発生しました", fmt.Errorf("panic: %v", recovered)))
+	}
+	c.Abort()
+}
+
+// RequestID は Middleware が割り当てたリクエストIDを返します。
+func RequestID(c *gin.Context) string {
+	return c.GetString("request_id")
+}
+
+// Write はエラーレスポンスを書き込みます。通常は Abort を使ってください。
+// メッセージはリクエストの言語 (i18n.FromContext) に翻訳します。
+func Write(c *gin.Context, e *Error) {
+	lang := i18n.FromContext(c)
+	e = translate(e, lang)
+	c.Header("Content-Language", string(lang))
+	if acceptsProblem(c.GetHeader("Accept")) {
+		// gin の JSON は Content-Type が設定済みの場合は上書きしない
+		c.Header("Content-Type", problemContentType)
+		c.JSON(e.Status, problem{
+			Type:      "about:blank",
+			Title:     http.StatusText(e.Status),
+			Status:    e.Status,
+			Detail:    e.Message,
+			Instance:  c.Request.URL.Path,
+			Code:      e.Code,
+			Details:   e.Details,
+			RequestID: RequestID(c),
+		})
+		return
+	}
+	c.JSON(e.Status, envelope{
+		Status:    "error",
+		Code:      e.Code,
+		Message:   e.Message,
+		Details:   e.Details,
+		RequestID: RequestID(c),
+	})
+}
+
+// translate は e のメッセージを lang に翻訳したコピーを返します。
+func translate(e *Error, lang i18n.Language) *Error {
+	translated := *e
+	translated.Message = i18n.T(lang, e.Message)
+	translated.Details = make([]FieldError, len(e.Details))
+	for i, d := range e.Details {
+		translated.Details[i] = FieldError{Field: d.Field, Message: i18n.T(lang, d.Message)}
+	}
+	return &translated
+}
+
+func resolve(err error, mapper Mapper) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	if mapper != nil {
+		if e := mapper(err); e != nil {
+			return e
+		}
+	}
+	return Internal("サーバー内部エラーが発生しました", err)
+}
+
+// envelope は通常のエラーレスポンスです。
+type envelope struct {
+	Status    string       `json:"status"`
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	Details   []FieldError `json:"details,omitempty"`
+	RequestID string       `json:"request_id"`
+}
+
+// problem は RFC 7807 のエラーレスポンスです。code 以降は拡張メンバーです。
+type problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail"`
+	Instance  string       `json:"instance"`
+	Code      string       `json:"code"`
+	Details   []FieldError `json:"details,omitempty"`
+	RequestID string       `json:"request_id"`
+}
+
+func acceptsProblem(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, _ := strings.Cut(part, ";")
+		if strings.TrimSpace(mediaType) == problemContentType {
+			return true
+		}
+	}
+	return false
+}
+
+// validRequestID はクライアントが指定したリクエストIDをそのまま使えるか判定します (ログを汚さないよう英数字と - _ のみ)。
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,89 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errDomain = errors.New("domain error")
+
+func newTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(gin.CustomRecovery(Recovery), Middleware(func(err error) *Error {
+		if errors.Is(err, errDomain) {
+			return Conflict("mapped")
+		}
+		return nil
+	}))
+	r.GET("/mapped", func(c *gin.Context) { Abort(c, errDomain) })
+	r.GET("/unknown", func(c *gin.Context) { Abort(c, errors.New("password=secret")) })
+	r.GET("/panic", func(c *gin.Context) { panic("boom") })
+	r.GET("/ok", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"status": "success"}) })
+	return r
+}
+
+func get(t *testing.T, r *gin.Engine, target, requestID string) (*httptest.ResponseRecorder, envelope) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if requestID != "" {
+		req.Header.Set(RequestIDHeader, requestID)
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	var res envelope
+	if rec.Code != http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+			t.Fatalf("レスポンスを読み込めません: %v (%s)", err, rec.Body)
+		}
+	}
+	return rec, res
+}
+
+func TestMiddleware(t *testing.T) {
+	r := newTestRouter()
+
+	tests := []struct {
+		target     string
+		wantStatus int
+		wantCode   string
+	}{
+		{"/mapped", http.StatusConflict, CodeConflict},
+		{"/unknown", http.StatusInternalServerError, CodeInternal},
+		{"/panic", http.StatusInternalServerError, CodeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			rec, res := get(t, r, tt.target, "")
+			if rec.Code != tt.wantStatus || res.Code != tt.wantCode {
+				t.Fatalf("status = %d, code = %q, want %d %q", rec.Code, res.Code, tt.wantStatus, tt.wantCode)
+			}
+			// 元のエラーの内容はクライアントに返さない
+			if strings.Contains(rec.Body.String(), "secret") || strings.Contains(rec.Body.String(), "boom") {
+				t.Errorf("内部エラーの内容が返されました: %s", rec.Body)
+			}
+		})
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	r := newTestRouter()
+
+	rec, _ := get(t, r, "/ok", "")
+	if id := rec.Header().Get(RequestIDHeader); len(id) != 16 {
+		t.Errorf("生成されたリクエストID = %q", id)
+	}
+	if rec, _ := get(t, r, "/ok", "abc-123_DEF"); rec.Header().Get(RequestIDHeader) != "abc-123_DEF" {
+		t.Errorf("指定したリクエストIDが使われていません: %q", rec.Header().Get(RequestIDHeader))
+	}
+	// ログを汚す可能性のある値は使わずに生成し直す
+	if rec, _ := get(t, r, "/ok", "bad id\nline"); rec.Header().Get(RequestIDHeader) == "bad id\nline" {
+		t.Error("不正なリクエストIDがそのまま使われました")
+	}
+}
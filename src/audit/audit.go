@@ -0,0 +1,92 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"yoyaku/db"
+	"yoyaku/store"
+)
+
+// 操作の種類 (reservation_events.action)
+const (
+	ActionCreated    = "created"
+	ActionUpdated    = "updated"
+	ActionCanceled   = "canceled"
+	ActionCheckedIn  = "checked_in"
+	ActionNoShow     = "no_show"
+	ActionEndedEarly = "ended_early"
+	ActionExtended   = "extended"
+	// ActionResponded は参加者が招待に返答したことを表します (before と after は返答した参加者の返答前後です)。
+	ActionResponded = "responded"
+)
+
+// 認証方法 (reservation_events.auth_method)
+const (
+	MethodSession        = "session"
+	MethodCalDAV         = "caldav"
+	MethodGoogleCalendar = "google_calendar"
+	MethodSystem         = "system"
+)
+
+// Actor は操作を行った主体です。
+type Actor struct {
+	// UserID はログインユーザーのIDです。システムによる変更の場合は0です。
+	UserID   uint64
+	ClientIP string
+	Method   string
+}
+
+// System はバックグラウンドジョブなど、ユーザーによらない操作の Actor を返します。
+func System(method string) Actor {
+	return Actor{Method: method}
+}
+
+// Record は予約の変更履歴を追加します。
+// 変更と履歴が必ず一緒に残るよう、qtx には変更と同じトランザクションの Queries を渡してください。
+// before は作成時、after は削除時に nil になります。
+func Record(ctx context.Context, qtx store.ReservationStore, actor Actor, action string, reservationID uint64, before, after *db.Reservation) error {
+	beforeData, err := marshal(before)
+	if err != nil {
+		return err
+	}
+	afterData, err := marshal(after)
+	if err != nil {
+		return err
+	}
+	return create(ctx, qtx, actor, action, reservationID, beforeData, afterData)
+}
+
+// RecordResponse は参加者 (before.UserID) の招待への返答を ActionResponded として履歴に追加します。
+// Record と同じく、qtx には返答と同じトランザクションの Queries を渡してください。
+func RecordResponse(ctx context.Context, qtx store.ReservationStore, actor Actor, reservationID uint64, before, after *db.ListReservationAttendeesRow) error {
+	beforeData, err := marshal(before)
+	if err != nil {
+		return err
+	}
+	afterData, err := marshal(after)
+	if err != nil {
+		return err
+	}
+	return create(ctx, qtx, actor, ActionResponded, reservationID, beforeData, afterData)
+}
+
+func create(ctx context.Context, qtx store.ReservationStore, actor Actor, action string, reservationID uint64, beforeData, afterData json.RawMessage) error {
+	return qtx.CreateReservationEvent(ctx, db.CreateReservationEventParams{
+		ReservationID: reservationID,
+		ActorUserID:   sql.NullInt64{Int64: int64(actor.UserID), Valid: actor.UserID != 0},
+		Action:        action,
+		BeforeData:    beforeData,
+		AfterData:     afterData,
+		ClientIp:      sql.NullString{String: actor.ClientIP, Valid: actor.ClientIP != ""},
+		AuthMethod:    actor.Method,
+	})
+}
+
+func marshal[T any](v *T) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
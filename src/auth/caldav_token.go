@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// GenerateCalDAVToken は CalDAV クライアント用のトークンを生成し、トークンとそのハッシュを返します。
+// データベースにはハッシュのみを保存し、トークンは発行時に一度だけユーザーに表示します。
+func GenerateCalDAVToken() (string, string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashCalDAVToken(token), nil
+}
+
+// HashCalDAVToken はトークンのSHA-256を16進数で返します。
+func HashCalDAVToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyCalDAVToken はトークンが保存されたハッシュと一致するか確認します。
+func VerifyCalDAVToken(token, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashCalDAVToken(token)), []byte(hash)) == 1
+}
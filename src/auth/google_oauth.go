@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
+	"yoyaku/config"
 
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
@@ -18,25 +18,15 @@ var (
 	OauthStateString = "random" // 本番環境ではセッションごとにランダムな文字列を生成することを推奨します。
 )
 
-func Setup() error {
-	clientID := os.Getenv("GOOGLE_CLIENT_ID")
-	if clientID == "" {
-		return fmt.Errorf("環境変数 GOOGLE_CLIENT_ID が設定されていません")
-	}
-
-	clientSecret := os.Getenv("GOOGLE_CLIENT_SECRET")
-	if clientSecret == "" {
-		return fmt.Errorf("環境変数 GOOGLE_CLIENT_SECRET が設定されていません")
-	}
-
+// Setup は OAuth の設定を初期化します。cfg は config.Config.Validate で確認した値を渡してください。
+func Setup(cfg config.Google) {
 	GoogleOauthConfig = &oauth2.Config{
-		RedirectURL:  "http://localhost:8080/callback",
-		ClientID:     clientID,
-		ClientSecret: clientSecret,
+		RedirectURL:  cfg.RedirectURL,
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
 		Scopes:       []string{"https://www.googleapis.com/auth/userinfo.email", "https://www.googleapis.com/auth/userinfo.profile"},
 		Endpoint:     google.Endpoint,
 	}
-	return nil
 }
 
 func GetUserInfo(state string, code string) ([]byte, error) {
@@ -0,0 +1,268 @@
+package availability
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Interval は [Start, End) の時間帯です。
+type Interval struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Rules は予約可能な時間帯のルールです。
+type Rules struct {
+	Location *time.Location
+	// OpenAt, CloseAt は営業時間の開始・終了時刻です (9*time.Hour で9:00)。
+	// 夏時間の切り替え日も0時からの経過時間ではなく時計の時刻として扱います。
+	OpenAt  time.Duration
+	CloseAt time.Duration
+	// Weekdays は予約可能な曜日です。
+	Weekdays map[time.Weekday]bool
+	// Step は空き枠の開始時刻の刻み幅です。営業開始時刻を基準に揃えます。
+	Step time.Duration
+}
+
+// DefaultRules は平日9:00〜21:00、15分刻みのルールを返します。
+func DefaultRules(loc *time.Location) Rules {
+	return Rules{
+		Location: loc,
+		OpenAt:   9 * time.Hour,
+		CloseAt:  21 * time.Hour,
+		Weekdays: map[time.Weekday]bool{
+			time.Monday: true, time.Tuesday: true, time.Wednesday: true, time.Thursday: true, time.Friday: true,
+		},
+		Step: 15 * time.Minute,
+	}
+}
+
+// ParseBusinessHours は "09:00-21:00" の形式の営業時間を、開始・終了 (0時からの経過時間) にします。
+func ParseBusinessHours(s string) (open, close time.Duration, err error) {
+	openAt, closeAt, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("営業時間の形式が正しくありません (例: 09:00-21:00): %s", s)
+	}
+	var err1, err2 error
+	open, err1 = parseClock(openAt)
+	close, err2 = parseClock(closeAt)
+	if err1 != nil || err2 != nil || close <= open {
+		return 0, 0, fmt.Errorf("営業時間の形式が正しくありません (例: 09:00-21:00): %s", s)
+	}
+	return open, close, nil
+}
+
+// ParseWeekdays は "mon" などの曜日の名前 (大文字・小文字は区別しない) を予約可能な曜日にします。
+func ParseWeekdays(names []string) (map[time.Weekday]bool, error) {
+	days := map[string]time.Weekday{
+		"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+		"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+	}
+	weekdays := make(map[time.Weekday]bool)
+	for _, name := range names {
+		day, ok := days[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("曜日が正しくありません (sun から sat で指定してください): %s", name)
+		}
+		weekdays[day] = true
+	}
+	return weekdays, nil
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		// "24:00" は time.Parse では扱えないため個別に許可する
+		if strings.TrimSpace(s) == "24:00" {
+			return 24 * time.Hour, nil
+		}
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// businessHours は day を含む日の営業時間を返します。予約できない曜日の場合は false を返します。
+func (r Rules) businessHours(day time.Time) (Interval, bool) {
+	day = day.In(r.Location)
+	if !r.Weekdays[day.Weekday()] {
+		return Interval{}, false
+	}
+	return Interval{Start: r.clock(day, r.OpenAt), End: r.clock(day, r.CloseAt)}, true
+}
+
+// clock は day の日付の時刻 d を返します。d が24時間の場合は翌日の0時です。
+func (r Rules) clock(day time.Time, d time.Duration) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(),
+		int(d/time.Hour), int(d%time.Hour/time.Minute), int(d%time.Minute/time.Second), 0, r.Location)
+}
+
+// Merge は重なり合う・隣接する時間帯を結合し、開始時刻順に並べます。
+func Merge(intervals []Interval) []Interval {
+	sorted := make([]Interval, 0, len(intervals))
+	for _, iv := range intervals {
+		if iv.End.After(iv.Start) {
+			sorted = append(sorted, iv)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	var merged []Interval
+	for _, iv := range sorted {
+		if n := len(merged); n > 0 && !iv.Start.After(merged[n-1].End) {
+			if iv.End.After(merged[n-1].End) {
+				merged[n-1].End = iv.End
+			}
+			continue
+		}
+		merged = append(merged, iv)
+	}
+	return merged
+}
+
+// Usage は備品など数に限りのある資源を、時間帯 Interval に Quantity 個使うことを表します。
+type Usage struct {
+	Interval
+	Quantity int
+}
+
+// Exhausted は capacity 個の資源が usages で全て使われている時間帯を、Merge 済みで返します。
+// capacity が0以下の場合は、使われている時間帯によらず全く借りられないため呼び出し側で扱ってください。
+func Exhausted(usages []Usage, capacity int) []Interval {
+	var points []time.Time
+	for _, u := range usages {
+		if u.End.After(u.Start) {
+			points = append(points, u.Start, u.End)
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Before(points[j]) })
+
+	var exhausted []Interval
+	for i := 0; i+1 < len(points); i++ {
+		segment := Interval{Start: points[i], End: points[i+1]}
+		if !segment.End.After(segment.Start) {
+			continue
+		}
+		used := 0
+		for _, u := range usages {
+			if !u.Start.After(segment.Start) && !u.End.Before(segment.End) {
+				used += u.Quantity
+			}
+		}
+		if used >= capacity {
+			exhausted = append(exhausted, segment)
+		}
+	}
+	return Merge(exhausted)
+}
+
+// Clip は時間帯を [start, end) の範囲に切り詰めます。
+func Clip(intervals []Interval, start, end time.Time) []Interval {
+	var clipped []Interval
+	for _, iv := range intervals {
+		if iv.Start.Before(start) {
+			iv.Start = start
+		}
+		if iv.End.After(end) {
+			iv.End = end
+		}
+		if iv.End.After(iv.Start) {
+			clipped = append(clipped, iv)
+		}
+	}
+	return clipped
+}
+
+// Free は [start, end) のうち営業時間内で busy と重ならない時間帯を返します。
+// busy は Merge 済みである必要があります。
+func Free(busy []Interval, start, end time.Time, rules Rules) []Interval {
+	var free []Interval
+	for day := start.In(rules.Location); day.Before(end); day = nextDay(day, rules.Location) {
+		hours, ok := rules.businessHours(day)
+		if !ok {
+			continue
+		}
+		window := Clip([]Interval{hours}, start, end)
+		if len(window) == 0 {
+			continue
+		}
+		cursor := window[0].Start
+		for _, b := range busy {
+			if !b.End.After(cursor) || !b.Start.Before(window[0].End) {
+				continue
+			}
+			if b.Start.After(cursor) {
+				free = append(free, Interval{Start: cursor, End: b.Start})
+			}
+			cursor = b.End
+		}
+		if window[0].End.After(cursor) {
+			free = append(free, Interval{Start: cursor, End: window[0].End})
+		}
+	}
+	return free
+}
+
+// NextSlots は after 以降 until までの間で、営業時間内に収まり busy と重ならない
+// duration の長さの枠を早い順に最大 limit 件返します。返す枠同士は重なりません。
+// busy は Merge 済みである必要があります。
+func NextSlots(busy []Interval, after, until time.Time, duration time.Duration, limit int, rules Rules) []Interval {
+	var slots []Interval
+	if duration <= 0 || limit <= 0 {
+		return slots
+	}
+
+	for day := after.In(rules.Location); day.Before(until) && len(slots) < limit; day = nextDay(day, rules.Location) {
+		hours, ok := rules.businessHours(day)
+		if !ok {
+			continue
+		}
+		window := Clip([]Interval{hours}, after, until)
+		if len(window) == 0 {
+			continue
+		}
+
+		candidate := align(window[0].Start, hours.Start, rules.Step)
+		for len(slots) < limit {
+			slot := Interval{Start: candidate, End: candidate.Add(duration)}
+			if slot.End.After(window[0].End) {
+				break
+			}
+			if b, ok := firstOverlap(busy, slot); ok {
+				candidate = align(b.End, hours.Start, rules.Step)
+				continue
+			}
+			slots = append(slots, slot)
+			candidate = align(slot.End, hours.Start, rules.Step)
+		}
+	}
+	return slots
+}
+
+func firstOverlap(busy []Interval, slot Interval) (Interval, bool) {
+	for _, b := range busy {
+		if b.Start.Before(slot.End) && b.End.After(slot.Start) {
+			return b, true
+		}
+	}
+	return Interval{}, false
+}
+
+// align は t を base から step 刻みの時刻に切り上げます。
+func align(t, base time.Time, step time.Duration) time.Time {
+	if step <= 0 || !t.After(base) {
+		return t
+	}
+	offset := t.Sub(base)
+	if rem := offset % step; rem != 0 {
+		offset += step - rem
+	}
+	return base.Add(offset)
+}
+
+// nextDay は翌日の0時を返します。夏時間の切り替わりがあっても日付単位で進めます。
+func nextDay(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+}
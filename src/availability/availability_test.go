@@ -0,0 +1,173 @@
+package availability
+
+import (
+	"reflect"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+var jst = time.FixedZone("Asia/Tokyo", 9*60*60)
+
+// at は 2030年1月7日 (月) を0日目とした day 日目の hour:minute (JST) を返します。
+func at(day, hour, minute int) time.Time {
+	return time.Date(2030, 1, 7+day, hour, minute, 0, 0, jst)
+}
+
+func iv(start, end time.Time) Interval {
+	return Interval{Start: start, End: end}
+}
+
+// dstRules は夏時間のあるタイムゾーンで毎日予約できるルールを返します。
+// America/New_York は2025年3月9日 (日) 2:00 に夏時間が始まり、11月2日 (日) 2:00 に終わります。
+func dstRules(t *testing.T) (Rules, *time.Location) {
+	t.Helper()
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := DefaultRules(ny)
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		rules.Weekdays[d] = true
+	}
+	return rules, ny
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []Interval
+		want []Interval
+	}{
+		{"空", nil, nil},
+		{"重ならない", []Interval{iv(at(0, 13, 0), at(0, 14, 0)), iv(at(0, 10, 0), at(0, 11, 0))},
+			[]Interval{iv(at(0, 10, 0), at(0, 11, 0)), iv(at(0, 13, 0), at(0, 14, 0))}},
+		{"重なる", []Interval{iv(at(0, 10, 0), at(0, 12, 0)), iv(at(0, 11, 0), at(0, 13, 0))},
+			[]Interval{iv(at(0, 10, 0), at(0, 13, 0))}},
+		{"隣接する", []Interval{iv(at(0, 10, 0), at(0, 11, 0)), iv(at(0, 11, 0), at(0, 12, 0))},
+			[]Interval{iv(at(0, 10, 0), at(0, 12, 0))}},
+		{"含まれる", []Interval{iv(at(0, 10, 0), at(0, 14, 0)), iv(at(0, 11, 0), at(0, 12, 0))},
+			[]Interval{iv(at(0, 10, 0), at(0, 14, 0))}},
+		{"長さ0を除く", []Interval{iv(at(0, 10, 0), at(0, 10, 0)), iv(at(0, 12, 0), at(0, 11, 0))}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Merge(tt.in); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Merge = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFree(t *testing.T) {
+	rules := DefaultRules(jst)
+	tests := []struct {
+		name       string
+		busy       []Interval
+		start, end time.Time
+		want       []Interval
+	}{
+		{"予約なし", nil, at(0, 0, 0), at(1, 0, 0),
+			[]Interval{iv(at(0, 9, 0), at(0, 21, 0))}},
+		{"予約の前後", []Interval{iv(at(0, 10, 0), at(0, 11, 0)), iv(at(0, 13, 0), at(0, 14, 0))}, at(0, 0, 0), at(1, 0, 0),
+			[]Interval{iv(at(0, 9, 0), at(0, 10, 0)), iv(at(0, 11, 0), at(0, 13, 0)), iv(at(0, 14, 0), at(0, 21, 0))}},
+		{"営業時間をまたぐ予約", []Interval{iv(at(0, 8, 0), at(0, 10, 0)), iv(at(0, 20, 0), at(0, 22, 0))}, at(0, 0, 0), at(1, 0, 0),
+			[]Interval{iv(at(0, 10, 0), at(0, 20, 0))}},
+		{"終日埋まっている", []Interval{iv(at(0, 9, 0), at(0, 21, 0))}, at(0, 0, 0), at(1, 0, 0), nil},
+		{"期間の途中から", nil, at(0, 15, 0), at(1, 0, 0),
+			[]Interval{iv(at(0, 15, 0), at(0, 21, 0))}},
+		{"週末は除く", nil, at(4, 0, 0), at(7, 0, 0),
+			[]Interval{iv(at(4, 9, 0), at(4, 21, 0))}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Free(tt.busy, tt.start, tt.end, rules); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Free = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	rules, ny := dstRules(t)
+	for _, day := range []int{9, 10} {
+		start := time.Date(2025, 3, day, 0, 0, 0, 0, ny)
+		want := []Interval{iv(time.Date(2025, 3, day, 9, 0, 0, 0, ny), time.Date(2025, 3, day, 21, 0, 0, 0, ny))}
+		if got := Free(nil, start, start.AddDate(0, 0, 1), rules); !reflect.DeepEqual(got, want) {
+			t.Errorf("夏時間 3月%d日 Free = %v, want %v", day, got, want)
+		}
+	}
+	start := time.Date(2025, 11, 2, 0, 0, 0, 0, ny)
+	want := []Interval{iv(time.Date(2025, 11, 2, 9, 0, 0, 0, ny), time.Date(2025, 11, 2, 21, 0, 0, 0, ny))}
+	if got := Free(nil, start, start.AddDate(0, 0, 1), rules); !reflect.DeepEqual(got, want) {
+		t.Errorf("夏時間終了日 Free = %v, want %v", got, want)
+	}
+}
+
+func TestNextSlots(t *testing.T) {
+	rules := DefaultRules(jst)
+	tests := []struct {
+		name     string
+		busy     []Interval
+		after    time.Time
+		duration time.Duration
+		limit    int
+		want     []Interval
+	}{
+		{"営業開始から", nil, at(0, 0, 0), time.Hour, 2,
+			[]Interval{iv(at(0, 9, 0), at(0, 10, 0)), iv(at(0, 10, 0), at(0, 11, 0))}},
+		{"刻み幅に切り上げる", nil, at(0, 9, 7), 30 * time.Minute, 1,
+			[]Interval{iv(at(0, 9, 15), at(0, 9, 45))}},
+		{"予約の後", []Interval{iv(at(0, 9, 0), at(0, 10, 10))}, at(0, 0, 0), time.Hour, 1,
+			[]Interval{iv(at(0, 10, 15), at(0, 11, 15))}},
+		{"短い隙間は飛ばす", []Interval{iv(at(0, 9, 0), at(0, 10, 0)), iv(at(0, 10, 30), at(0, 12, 0))}, at(0, 0, 0), time.Hour, 1,
+			[]Interval{iv(at(0, 12, 0), at(0, 13, 0))}},
+		{"閉店間際は翌営業日", nil, at(4, 20, 30), time.Hour, 1,
+			[]Interval{iv(at(7, 9, 0), at(7, 10, 0))}},
+		{"limit が0", nil, at(0, 0, 0), time.Hour, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NextSlots(tt.busy, tt.after, tt.after.AddDate(0, 0, 14), tt.duration, tt.limit, rules)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NextSlots = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	rules, ny := dstRules(t)
+	after := time.Date(2025, 3, 8, 20, 30, 0, 0, ny)
+	want := []Interval{
+		iv(time.Date(2025, 3, 9, 9, 0, 0, 0, ny), time.Date(2025, 3, 9, 10, 0, 0, 0, ny)),
+		iv(time.Date(2025, 3, 9, 10, 0, 0, 0, ny), time.Date(2025, 3, 9, 11, 0, 0, 0, ny)),
+	}
+	if got := NextSlots(nil, after, after.AddDate(0, 0, 14), time.Hour, 2, rules); !reflect.DeepEqual(got, want) {
+		t.Errorf("夏時間 NextSlots = %v, want %v", got, want)
+	}
+}
+
+func TestExhausted(t *testing.T) {
+	use := func(start, end time.Time, quantity int) Usage {
+		return Usage{Interval: iv(start, end), Quantity: quantity}
+	}
+	tests := []struct {
+		name     string
+		usages   []Usage
+		capacity int
+		want     []Interval
+	}{
+		{"使われていない", nil, 2, nil},
+		{"一部だけ使われている", []Usage{use(at(0, 10, 0), at(0, 12, 0), 1)}, 2, nil},
+		{"全て使われている", []Usage{use(at(0, 10, 0), at(0, 12, 0), 2)}, 2,
+			[]Interval{iv(at(0, 10, 0), at(0, 12, 0))}},
+		{"重なる時間帯だけ", []Usage{use(at(0, 10, 0), at(0, 12, 0), 1), use(at(0, 11, 0), at(0, 13, 0), 1)}, 2,
+			[]Interval{iv(at(0, 11, 0), at(0, 12, 0))}},
+		{"続けて使われている", []Usage{use(at(0, 10, 0), at(0, 11, 0), 2), use(at(0, 11, 0), at(0, 12, 0), 3)}, 2,
+			[]Interval{iv(at(0, 10, 0), at(0, 12, 0))}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Exhausted(tt.usages, tt.capacity); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Exhausted = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
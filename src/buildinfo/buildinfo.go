@@ -0,0 +1,59 @@
+// Package buildinfo はビルドしたコミットと日時を返します (GET /version)。
+//
+// Commit と BuildTime はビルド時に -ldflags で埋め込みます (build/go/Dockerfile を参照)。
+//
+//	go build -ldflags "-X yoyaku/buildinfo.Commit=$(git rev-parse HEAD) -X yoyaku/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// 埋め込まなかった場合は、go build が記録した VCS の情報 (git のリポジトリ内でビルドした場合のみ) を使います。
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// ビルド時に -ldflags "-X ..." で設定する値
+var (
+	Commit    string
+	BuildTime string
+)
+
+// unknown は情報が無い場合の値です。
+const unknown = "unknown"
+
+// Info はビルドの情報です。
+type Info struct {
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+	// Modified はコミットしていない変更を含んでビルドしたことを表します (VCS の情報がある場合のみ分かります)。
+	Modified bool `json:"modified"`
+}
+
+// Get はビルドの情報を返します。
+func Get() Info {
+	info := Info{Commit: Commit, BuildTime: BuildTime, GoVersion: runtime.Version()}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildTime == "" {
+					info.BuildTime = setting.Value
+				}
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+	if info.Commit == "" {
+		info.Commit = unknown
+	}
+	if info.BuildTime == "" {
+		info.BuildTime = unknown
+	}
+	return info
+}
@@ -0,0 +1,488 @@
+package caldav
+
+import (
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"yoyaku/audit"
+	"yoyaku/auth"
+	"yoyaku/db"
+	"yoyaku/i18n"
+	"yoyaku/reservation"
+	"yoyaku/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CalDAV (RFC 4791) で401号室の予約を読み書きするためのエンドポイントです。
+//
+//	/caldav/principals/{user_id}/   ユーザーのプリンシパル
+//	/caldav/calendars/              カレンダーホーム
+//	/caldav/calendars/room-401/     401号室のカレンダー
+//	/caldav/calendars/room-401/{name}.ics
+//
+// 認証は Basic 認証で、ユーザー名にメールアドレス、パスワードに /api/me/caldav-token で発行したトークンを使用します。
+
+const (
+	basePath     = "/caldav"
+	homePath     = basePath + "/calendars/"
+	roomName     = "room-401"
+	roomPath     = homePath + roomName + "/"
+	roomTitle    = "401号室"
+	maxEventSize = 1 << 20
+)
+
+// Methods は CalDAV のルーティングに登録する HTTP メソッドです。
+var Methods = []string{
+	http.MethodOptions, http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, "PROPFIND", "REPORT",
+}
+
+// Handle は /caldav 以下への全てのリクエストを処理します。
+func Handle(c *gin.Context, s store.Store, svc *reservation.Service) {
+	if c.Request.Method == http.MethodOptions {
+		c.Header("Allow", strings.Join(Methods, ", "))
+		c.Header("DAV", "1, 3, calendar-access")
+		c.Status(http.StatusOK)
+		return
+	}
+
+	user, ok := authenticate(c, s)
+	if !ok {
+		return
+	}
+	// カレンダー名やエラーメッセージはユーザーが選んだ表示言語で返す
+	i18n.Set(c, i18n.Resolve(user.Language.String, c.GetHeader("Accept-Language")))
+
+	p := c.Request.URL.Path
+	switch {
+	case strings.HasPrefix(p, roomPath) && p != roomPath:
+		name := strings.TrimPrefix(p, roomPath)
+		if strings.Contains(name, "/") || !strings.HasSuffix(name, ".ics") {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		handleObject(c, s, svc, user, name)
+	case p == roomPath || p == strings.TrimSuffix(roomPath, "/"):
+		handleCollection(c, s, user)
+	default:
+		handleContainer(c, user)
+	}
+}
+
+// HandleWellKnown はクライアントの自動検出 (RFC 6764) のために /caldav/ へリダイレクトします。
+func HandleWellKnown(c *gin.Context) {
+	c.Redirect(http.StatusMovedPermanently, basePath+"/")
+}
+
+// authenticate は Basic 認証のメールアドレスとトークンを検証します。
+func authenticate(c *gin.Context, s store.Store) (db.User, bool) {
+	email, token, ok := c.Request.BasicAuth()
+	if ok {
+		user, err := s.GetUserByEmail(c.Request.Context(), email)
+		if err == nil && user.CaldavTokenHash.Valid && auth.VerifyCalDAVToken(token, user.CaldavTokenHash.String) {
+			return user, true
+		}
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Println("CalDAVユーザー検索エラー:", err)
+			c.Status(http.StatusInternalServerError)
+			return db.User{}, false
+		}
+	}
+	c.Header("WWW-Authenticate", `Basic realm="yoyaku", charset="UTF-8"`)
+	c.Status(http.StatusUnauthorized)
+	return db.User{}, false
+}
+
+// writeMessage は利用者向けのメッセージをリクエストの言語に翻訳して返します。
+func writeMessage(c *gin.Context, status int, message string) {
+	c.String(status, i18n.T(i18n.FromContext(c), message))
+}
+
+// actor は CalDAV クライアントからの操作を表す Actor を返します。
+func actor(c *gin.Context, user db.User) audit.Actor {
+	return audit.Actor{UserID: user.ID, ClientIP: c.ClientIP(), Method: audit.MethodCalDAV}
+}
+
+func principalPath(user db.User) string {
+	return fmt.Sprintf("%s/principals/%d/", basePath, user.ID)
+}
+
+// handleContainer はルート・プリンシパル・カレンダーホームへの PROPFIND を処理します。
+func handleContainer(c *gin.Context, user db.User) {
+	if c.Request.Method != "PROPFIND" {
+		c.Status(http.StatusMethodNotAllowed)
+		return
+	}
+	req, err := parseDAVRequest(c.Request.Body)
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	names := requestedProps(req)
+
+	href := c.Request.URL.Path
+	common := map[xml.Name]propValue{
+		{Space: nsDAV, Local: "current-user-principal"}: hrefProp(principalPath(user)),
+		{Space: nsDAV, Local: "principal-URL"}:          hrefProp(principalPath(user)),
+		{Space: nsCalDAV, Local: "calendar-home-set"}:   hrefProp(homePath),
+		{Space: nsDAV, Local: "resourcetype"}:           rawProp("<d:collection/>"),
+		{Space: nsDAV, Local: "displayname"}:            textProp(user.Name),
+	}
+	if strings.HasPrefix(href, basePath+"/principals/") {
+		common[xml.Name{Space: nsDAV, Local: "resourcetype"}] = rawProp("<d:collection/><d:principal/>")
+		common[xml.Name{Space: nsCalDAV, Local: "calendar-user-address-set"}] = hrefProp("mailto:" + user.Email)
+	}
+	responses := []davResponse{newDAVResponse(href, common, names)}
+
+	// カレンダーホームの子要素として401号室のカレンダーを返す
+	if href == homePath && c.GetHeader("Depth") == "1" {
+		responses = append(responses, newDAVResponse(roomPath, collectionProps(i18n.FromContext(c), nil), names))
+	}
+
+	c.Status(http.StatusMultiStatus)
+	c.Header("Content-Type", "application/xml; charset=utf-8")
+	writeMultistatus(c.Writer, responses)
+}
+
+// handleCollection は401号室のカレンダーへの PROPFIND と REPORT を処理します。
+func handleCollection(c *gin.Context, s store.Store, user db.User) {
+	if c.Request.Method != "PROPFIND" && c.Request.Method != "REPORT" {
+		c.Status(http.StatusMethodNotAllowed)
+		return
+	}
+	req, err := parseDAVRequest(c.Request.Body)
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	names := requestedProps(req)
+
+	reservations, err := s.ListConfirmedReservations(c.Request.Context())
+	if err == nil {
+		// 他のユーザーの非公開の予約は、参加者でなければ「予約済み」として返す
+		reservations, err = reservation.MaskHidden(c.Request.Context(), s, user.ID, reservations)
+	}
+	if err != nil {
+		log.Println("CalDAV予約一覧取得エラー:", err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	var responses []davResponse
+	if c.Request.Method == "PROPFIND" {
+		responses = append(responses, newDAVResponse(roomPath, collectionProps(i18n.FromContext(c), reservations), names))
+		if c.GetHeader("Depth") == "1" {
+			for _, r := range reservations {
+				responses = append(responses, newDAVResponse(roomPath+objectName(r), objectProps(r, false), names))
+			}
+		}
+	} else {
+		switch req.XMLName.Local {
+		case "calendar-query":
+			start, end := req.Filter.timeRange()
+			for _, r := range reservations {
+				if !start.IsZero() && !r.EndTime.After(start) {
+					continue
+				}
+				if !end.IsZero() && !r.StartTime.Before(end) {
+					continue
+				}
+				responses = append(responses, newDAVResponse(roomPath+objectName(r), objectProps(r, true), names))
+			}
+		case "calendar-multiget":
+			byName := make(map[string]db.Reservation, len(reservations))
+			for _, r := range reservations {
+				byName[objectName(r)] = r
+			}
+			for _, href := range req.Hrefs {
+				r, ok := byName[strings.TrimPrefix(href, roomPath)]
+				if !ok {
+					responses = append(responses, davResponse{href: href})
+					continue
+				}
+				responses = append(responses, newDAVResponse(href, objectProps(r, true), names))
+			}
+		default:
+			c.Status(http.StatusForbidden)
+			return
+		}
+	}
+
+	c.Status(http.StatusMultiStatus)
+	c.Header("Content-Type", "application/xml; charset=utf-8")
+	writeMultistatus(c.Writer, responses)
+}
+
+// handleObject は個々の予定への GET, PUT, DELETE, PROPFIND を処理します。
+func handleObject(c *gin.Context, s store.Store, svc *reservation.Service, user db.User, name string) {
+	ctx := c.Request.Context()
+	existing, found, err := findReservation(ctx, s, name)
+	if err != nil {
+		log.Println("CalDAV予約取得エラー:", err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead:
+		if !found {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		visible, ok := maskObject(c, s, user, existing)
+		if !ok {
+			return
+		}
+		data := formatCalendar(toVEvent(visible))
+		// PROPFIND・REPORT と同じく、見せる内容から ETag を計算する
+		c.Header("ETag", etag(visible))
+		c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(data))
+
+	case "PROPFIND":
+		if !found {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		req, err := parseDAVRequest(c.Request.Body)
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		visible, ok := maskObject(c, s, user, existing)
+		if !ok {
+			return
+		}
+		c.Status(http.StatusMultiStatus)
+		c.Header("Content-Type", "application/xml; charset=utf-8")
+		writeMultistatus(c.Writer, []davResponse{newDAVResponse(c.Request.URL.Path, objectProps(visible, false), requestedProps(req))})
+
+	case http.MethodPut:
+		if !checkPreconditions(c, existing, found) {
+			return
+		}
+		putObject(c, svc, user, name, existing, found)
+
+	case http.MethodDelete:
+		if !found {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		if !checkPreconditions(c, existing, found) {
+			return
+		}
+		if _, err := svc.CancelFromCalendar(ctx, actor(c, user), existing.ID); err != nil {
+			writeError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+
+	default:
+		c.Status(http.StatusMethodNotAllowed)
+	}
+}
+
+// maskObject は user が詳細を見られない予約を「予約済み」にして返します。
+// 失敗した場合はレスポンスを書き込み、false を返します。
+func maskObject(c *gin.Context, s store.Store, user db.User, r db.Reservation) (db.Reservation, bool) {
+	masked, err := reservation.MaskHidden(c.Request.Context(), s, user.ID, []db.Reservation{r})
+	if err != nil {
+		log.Println("CalDAV予約参加者取得エラー:", err)
+		c.Status(http.StatusInternalServerError)
+		return db.Reservation{}, false
+	}
+	return masked[0], true
+}
+
+// putObject は予定の作成・更新を行います。検証・重複チェック・予約停止などのルールは API と同じく reservation.Service が適用します。
+func putObject(c *gin.Context, svc *reservation.Service, user db.User, name string, existing db.Reservation, found bool) {
+	ctx := c.Request.Context()
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxEventSize))
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	ev, err := parseVEvent(string(body))
+	if err != nil {
+		writeMessage(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	uid := ev.UID
+	if uid == "" {
+		uid = strings.TrimSuffix(name, ".ics")
+	}
+	event := reservation.CalendarEvent{Title: ev.Summary, StartTime: ev.Start, EndTime: ev.End, UID: uid, Name: name}
+
+	if _, ok := apiObjectID(name); ok && !found {
+		// APIから作成した予約の名前で作ると、後からその ID の予約ができたときに同じ名前の予定が2つになる
+		writeMessage(c, http.StatusForbidden, "reservation-{番号}.ics はAPIから作成した予約の名前のため、別の名前で作成してください")
+		return
+	}
+
+	if found {
+		updated, err := svc.UpdateFromCalendar(ctx, actor(c, user), existing.ID, event)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.Header("ETag", etag(updated))
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	created, err := svc.CreateFromCalendar(ctx, actor(c, user), event)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Header("ETag", etag(created))
+	c.Status(http.StatusCreated)
+}
+
+// writeError は予約サービスのエラーを HTTP のステータスとメッセージにして返します。
+func writeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, reservation.ErrValidation):
+		writeMessage(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, reservation.ErrNotFound):
+		c.Status(http.StatusNotFound)
+	case errors.Is(err, reservation.ErrForbidden), errors.Is(err, reservation.ErrSuspended):
+		writeMessage(c, http.StatusForbidden, err.Error())
+	case errors.Is(err, reservation.ErrConflict), errors.Is(err, reservation.ErrEquipmentUnavailable):
+		writeMessage(c, http.StatusConflict, err.Error())
+	default:
+		log.Println("CalDAV予約更新エラー:", err)
+		c.Status(http.StatusInternalServerError)
+	}
+}
+
+// checkPreconditions は If-Match / If-None-Match を検証し、満たさない場合は 412 を返します。
+func checkPreconditions(c *gin.Context, existing db.Reservation, found bool) bool {
+	if ifMatch := c.GetHeader("If-Match"); ifMatch != "" {
+		if !found || (ifMatch != "*" && ifMatch != etag(existing)) {
+			c.Status(http.StatusPreconditionFailed)
+			return false
+		}
+	}
+	if c.GetHeader("If-None-Match") == "*" && found {
+		c.Status(http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+// findReservation はリソース名に対応する確定済みの予約を探します。
+// APIから作成された予約は reservation-{id}.ics、CalDAVから作成された予約はクライアントが指定した名前です。
+func findReservation(ctx context.Context, s store.Store, name string) (db.Reservation, bool, error) {
+	if id, ok := apiObjectID(name); ok {
+		r, err := s.GetReservationByID(ctx, id)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return db.Reservation{}, false, err
+		}
+		if err == nil && r.Status == "confirmed" && !r.CaldavName.Valid {
+			return r, true, nil
+		}
+		// 名前の確認を入れる前に CalDAV から同じ形式の名前で作成された予定は caldav_name で探す
+	}
+
+	r, err := s.GetReservationByCaldavName(ctx, sql.NullString{String: name, Valid: true})
+	if errors.Is(err, sql.ErrNoRows) {
+		return db.Reservation{}, false, nil
+	}
+	return r, err == nil, err
+}
+
+// apiObjectID は APIから作成された予約のリソース名 (reservation-{id}.ics) の予約IDを返します。
+func apiObjectID(name string) (uint64, bool) {
+	idStr, ok := strings.CutPrefix(strings.TrimSuffix(name, ".ics"), "reservation-")
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	return id, err == nil
+}
+
+func objectName(r db.Reservation) string {
+	if r.CaldavName.Valid {
+		return r.CaldavName.String
+	}
+	return fmt.Sprintf("reservation-%d.ics", r.ID)
+}
+
+func toVEvent(r db.Reservation) vevent {
+	uid := fmt.Sprintf("reservation-%d@yoyaku", r.ID)
+	if r.IcalUid.Valid {
+		uid = r.IcalUid.String
+	}
+	return vevent{
+		UID:          uid,
+		Summary:      r.Title,
+		Start:        r.StartTime,
+		End:          r.EndTime,
+		LastModified: r.UpdatedAt,
+	}
+}
+
+func etag(r db.Reservation) string {
+	sum := sha1.Sum([]byte(formatCalendar(toVEvent(r))))
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// ctag は予約一覧が変わるたびに変化する値です。クライアントはこれを見て再同期の要否を判断します。
+func ctag(reservations []db.Reservation) string {
+	h := sha1.New()
+	for _, r := range reservations {
+		fmt.Fprintf(h, "%d:%s;", r.ID, etag(r))
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+func collectionProps(lang i18n.Language, reservations []db.Reservation) map[xml.Name]propValue {
+	props := map[xml.Name]propValue{
+		{Space: nsDAV, Local: "resourcetype"}:                        rawProp("<d:collection/><c:calendar/>"),
+		{Space: nsDAV, Local: "displayname"}:                         textProp(i18n.T(lang, roomTitle)),
+		{Space: nsCalDAV, Local: "supported-calendar-component-set"}: rawProp(`<c:comp name="VEVENT"/>`),
+		{Space: nsDAV, Local: "supported-report-set"}: rawProp(
+			"<d:supported-report><d:report><c:calendar-query/></d:report></d:supported-report>" +
+				"<d:supported-report><d:report><c:calendar-multiget/></d:report></d:supported-report>"),
+		{Space: nsDAV, Local: "current-user-privilege-set"}: rawProp(
+			"<d:privilege><d:read/></d:privilege><d:privilege><d:write/></d:privilege>"),
+	}
+	if reservations != nil {
+		props[xml.Name{Space: nsCalendarServer, Local: "getctag"}] = textProp(ctag(reservations))
+	}
+	return props
+}
+
+func objectProps(r db.Reservation, withData bool) map[xml.Name]propValue {
+	props := map[xml.Name]propValue{
+		{Space: nsDAV, Local: "resourcetype"}:    rawProp(""),
+		{Space: nsDAV, Local: "getetag"}:         textProp(etag(r)),
+		{Space: nsDAV, Local: "getcontenttype"}:  textProp("text/calendar; charset=utf-8; component=vevent"),
+		{Space: nsDAV, Local: "getlastmodified"}: textProp(r.UpdatedAt.UTC().Format(http.TimeFormat)),
+		{Space: nsDAV, Local: "displayname"}:     textProp(r.Title),
+	}
+	if withData {
+		props[xml.Name{Space: nsCalDAV, Local: "calendar-data"}] = textProp(formatCalendar(toVEvent(r)))
+	}
+	return props
+}
+
+// requestedProps は要求されたプロパティ名を返します。allprop の場合は nil を返します。
+func requestedProps(req *davRequest) []xml.Name {
+	if req.AllProp != nil || req.Prop == nil {
+		return nil
+	}
+	return req.Prop.Names
+}
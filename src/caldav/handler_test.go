@@ -0,0 +1,345 @@
+package caldav
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"yoyaku/audit"
+	"yoyaku/auth"
+	"yoyaku/checkin"
+	"yoyaku/db"
+	"yoyaku/events"
+	"yoyaku/reservation"
+	"yoyaku/store"
+	"yoyaku/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testToken = "caldav-token"
+
+// testServer はメモリ上の Store を使って CalDAV のエンドポイントを立ち上げます。
+type testServer struct {
+	t      *testing.T
+	router *gin.Engine
+	store  *store.Memory
+	svc    *reservation.Service
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	s := &testServer{t: t, router: gin.New(), store: store.NewMemory()}
+	s.svc = reservation.NewService(s.store, events.NewMemoryBus(16), checkin.DefaultPolicy(), reservation.DefaultRoom())
+	for _, method := range Methods {
+		s.router.Handle(method, "/caldav/*path", func(c *gin.Context) { Handle(c, s.store, s.svc) })
+	}
+	return s
+}
+
+// createUser は CalDAV のトークン (testToken) を発行したユーザーを作成します。
+func (s *testServer) createUser(name string) uint64 {
+	s.t.Helper()
+	ctx := context.Background()
+	result, err := s.store.CreateUser(ctx, db.CreateUserParams{
+		Name: name, Email: name + "@example.com", GoogleID: "google-" + name, Role: "user",
+	})
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	id, _ := result.LastInsertId()
+	if err := s.store.SetUserCaldavTokenHash(ctx, db.SetUserCaldavTokenHashParams{
+		CaldavTokenHash: sql.NullString{String: auth.HashCalDAVToken(testToken), Valid: true},
+		ID:              uint64(id),
+	}); err != nil {
+		s.t.Fatal(err)
+	}
+	return uint64(id)
+}
+
+// do は name のユーザーとしてリクエストを送信します。headers は "If-Match" などの追加のヘッダーです。
+func (s *testServer) do(method, path, name, body string, headers ...string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.SetBasicAuth(name+"@example.com", testToken)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func at(hour int) time.Time {
+	return time.Date(2030, 1, 7, hour, 0, 0, 0, time.UTC)
+}
+
+func calendar(uid, summary string, start, end time.Time) string {
+	return strings.Join([]string{
+		"BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:-//test//EN",
+		"BEGIN:VEVENT",
+		"UID:" + uid,
+		"SUMMARY:" + summary,
+		"DTSTART:" + start.Format(icalTimeLayout),
+		"DTEND:" + end.Format(icalTimeLayout),
+		"END:VEVENT", "END:VCALENDAR", "",
+	}, "\r\n")
+}
+
+func TestObjectRoundTrip(t *testing.T) {
+	s := newTestServer(t)
+	s.createUser("alice")
+	s.createUser("bob")
+	path := roomPath + "abc.ics"
+
+	// 作成
+	rec := s.do(http.MethodPut, path, "alice", calendar("abc@client", "輪講", at(10), at(11)), "If-None-Match", "*")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("PUT (作成) = %d %s", rec.Code, rec.Body)
+	}
+	created := rec.Header().Get("ETag")
+	if created == "" {
+		t.Fatal("作成した予定の ETag がありません")
+	}
+	if rec := s.do(http.MethodPut, path, "alice", calendar("abc@client", "輪講", at(10), at(11)), "If-None-Match", "*"); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("既にある名前への If-None-Match: * = %d, want 412", rec.Code)
+	}
+
+	// 取得
+	rec = s.do(http.MethodGet, path, "bob", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET = %d %s", rec.Code, rec.Body)
+	}
+	if rec.Header().Get("ETag") != created || !strings.Contains(rec.Body.String(), "SUMMARY:輪講") || !strings.Contains(rec.Body.String(), "UID:abc@client") {
+		t.Errorf("GET = %s %s", rec.Header().Get("ETag"), rec.Body)
+	}
+
+	// 更新 (ETag が古い場合は 412)
+	rec = s.do(http.MethodPut, path, "alice", calendar("abc@client", "輪講 (延長)", at(10), at(12)), "If-Match", created)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("PUT (更新) = %d %s", rec.Code, rec.Body)
+	}
+	updated := rec.Header().Get("ETag")
+	if updated == "" || updated == created {
+		t.Errorf("更新後の ETag = %q, 更新前 = %q", updated, created)
+	}
+	if rec := s.do(http.MethodPut, path, "alice", calendar("abc@client", "輪講", at(10), at(11)), "If-Match", created); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("古い ETag での PUT = %d, want 412", rec.Code)
+	}
+	if rec := s.do(http.MethodGet, path, "alice", ""); rec.Header().Get("ETag") != updated || !strings.Contains(rec.Body.String(), "DTEND:"+at(12).Format(icalTimeLayout)) {
+		t.Errorf("更新後の GET = %s %s", rec.Header().Get("ETag"), rec.Body)
+	}
+
+	// 他のユーザーは編集・削除できない
+	if rec := s.do(http.MethodPut, path, "bob", calendar("abc@client", "乗っ取り", at(10), at(11)), "If-Match", updated); rec.Code != http.StatusForbidden {
+		t.Errorf("他のユーザーの PUT = %d, want 403", rec.Code)
+	}
+	if rec := s.do(http.MethodDelete, path, "bob", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("他のユーザーの DELETE = %d, want 403", rec.Code)
+	}
+
+	// 削除 (ETag が古い場合は 412)
+	if rec := s.do(http.MethodDelete, path, "alice", "", "If-Match", created); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("古い ETag での DELETE = %d, want 412", rec.Code)
+	}
+	if rec := s.do(http.MethodDelete, path, "alice", "", "If-Match", updated); rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE = %d %s", rec.Code, rec.Body)
+	}
+	if rec := s.do(http.MethodGet, path, "alice", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("削除後の GET = %d, want 404", rec.Code)
+	}
+	if rec := s.do(http.MethodDelete, path, "alice", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("削除後の DELETE = %d, want 404", rec.Code)
+	}
+
+	methods := map[string]bool{}
+	for _, e := range s.store.Events() {
+		methods[e.Action] = e.AuthMethod == audit.MethodCalDAV
+	}
+	if !methods[audit.ActionCreated] || !methods[audit.ActionUpdated] || !methods[audit.ActionCanceled] {
+		t.Errorf("CalDAV からの変更履歴 = %+v", s.store.Events())
+	}
+}
+
+func TestObjectErrors(t *testing.T) {
+	s := newTestServer(t)
+	s.createUser("alice")
+
+	if rec := s.do(http.MethodPut, roomPath+"first.ics", "alice", calendar("first", "輪講", at(10), at(12)), "If-None-Match", "*"); rec.Code != http.StatusCreated {
+		t.Fatalf("PUT = %d %s", rec.Code, rec.Body)
+	}
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"overlapping", calendar("second", "ゼミ", at(11), at(13)), http.StatusConflict},
+		{"end before start", calendar("second", "ゼミ", at(13), at(12)), http.StatusBadRequest},
+		{"no event", "BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := s.do(http.MethodPut, roomPath+"second.ics", "alice", tt.body); rec.Code != tt.want {
+				t.Errorf("PUT = %d %s, want %d", rec.Code, rec.Body, tt.want)
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, roomPath+"first.ics", nil)
+	req.SetBasicAuth("alice@example.com", "wrong-token")
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("間違ったトークンでの GET = %d, want 401", rec.Code)
+	}
+}
+
+// APIから作成した予約は reservation-{id}.ics で読み書きでき、その形式の名前では新しい予定を作れません。
+func TestAPIObjectNames(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+	aliceID := s.createUser("alice")
+	alice := audit.Actor{UserID: aliceID, Method: audit.MethodSession}
+
+	fromAPI, err := s.svc.Create(ctx, alice, types.ReservationsRequest{Title: "定例", StartTime: at(9), EndTime: at(10)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := fmt.Sprintf("%sreservation-%d.ics", roomPath, fromAPI.ID)
+	rec := s.do(http.MethodGet, path, "alice", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "SUMMARY:定例") {
+		t.Fatalf("APIから作成した予約の GET = %d %s", rec.Code, rec.Body)
+	}
+	if rec := s.do(http.MethodPut, path, "alice", calendar("x", "定例 (変更)", at(9), at(10)), "If-Match", rec.Header().Get("ETag")); rec.Code != http.StatusNoContent {
+		t.Errorf("APIから作成した予約の PUT = %d %s", rec.Code, rec.Body)
+	}
+
+	canceled, err := s.svc.Create(ctx, alice, types.ReservationsRequest{Title: "中止", StartTime: at(15), EndTime: at(16)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.svc.Cancel(ctx, alice, canceled.ID); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{
+		fmt.Sprintf("reservation-%d.ics", canceled.ID),     // キャンセル済み
+		fmt.Sprintf("reservation-%d.ics", canceled.ID+100), // まだ無い
+	} {
+		rec := s.do(http.MethodPut, roomPath+name, "alice", calendar("y", "ゼミ", at(17), at(18)))
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("PUT %s = %d %s, want 403", name, rec.Code, rec.Body)
+		}
+		if rec := s.do(http.MethodGet, roomPath+name, "alice", ""); rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s = %d, want 404", name, rec.Code)
+		}
+	}
+
+	// 以前に CalDAV から reservation-{id}.ics の名前で作成された予定も読み書きできる
+	legacy, err := s.svc.CreateFromCalendar(ctx, alice, reservation.CalendarEvent{
+		Title: "古いクライアント", StartTime: at(19), EndTime: at(20), UID: "legacy", Name: "reservation-999.ics",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec = s.do(http.MethodGet, roomPath+"reservation-999.ics", "alice", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "SUMMARY:古いクライアント") {
+		t.Fatalf("CalDAV から作成した reservation-999.ics の GET = %d %s", rec.Code, rec.Body)
+	}
+	if rec := s.do(http.MethodDelete, roomPath+"reservation-999.ics", "alice", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("CalDAV から作成した reservation-999.ics の DELETE = %d %s", rec.Code, rec.Body)
+	}
+	if r, _ := s.store.GetReservationByID(ctx, legacy.ID); r.Status != "canceled" {
+		t.Errorf("削除後の予約 = %+v", r)
+	}
+}
+
+func TestCollection(t *testing.T) {
+	s := newTestServer(t)
+	s.createUser("alice")
+	for i, name := range []string{"a.ics", "b.ics"} {
+		if rec := s.do(http.MethodPut, roomPath+name, "alice", calendar(name, "予定", at(10+2*i), at(11+2*i))); rec.Code != http.StatusCreated {
+			t.Fatalf("PUT %s = %d %s", name, rec.Code, rec.Body)
+		}
+	}
+
+	rec := s.do("PROPFIND", roomPath, "alice", `<?xml version="1.0"?><d:propfind xmlns:d="DAV:"><d:prop><d:getetag/></d:prop></d:propfind>`, "Depth", "1")
+	if rec.Code != http.StatusMultiStatus {
+		t.Fatalf("PROPFIND = %d %s", rec.Code, rec.Body)
+	}
+	for _, name := range []string{"a.ics", "b.ics"} {
+		if !strings.Contains(rec.Body.String(), roomPath+name) {
+			t.Errorf("PROPFIND の結果に %s がありません: %s", name, rec.Body)
+		}
+	}
+
+	query := fmt.Sprintf(`<?xml version="1.0"?>
+<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><d:getetag/><c:calendar-data/></d:prop>
+  <c:filter><c:comp-filter name="VCALENDAR"><c:comp-filter name="VEVENT">
+    <c:time-range start="%s" end="%s"/>
+  </c:comp-filter></c:comp-filter></c:filter>
+</c:calendar-query>`, at(12).Format(icalTimeLayout), at(13).Format(icalTimeLayout))
+	rec = s.do("REPORT", roomPath, "alice", query, "Depth", "1")
+	if rec.Code != http.StatusMultiStatus {
+		t.Fatalf("REPORT = %d %s", rec.Code, rec.Body)
+	}
+	if strings.Contains(rec.Body.String(), roomPath+"a.ics") || !strings.Contains(rec.Body.String(), roomPath+"b.ics") {
+		t.Errorf("calendar-query の結果 = %s, want b.ics だけ", rec.Body)
+	}
+}
+
+func TestPrivateObjectETag(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+	aliceID := s.createUser("alice")
+	s.createUser("bob")
+	alice := audit.Actor{UserID: aliceID, Method: audit.MethodSession}
+
+	req := types.ReservationsRequest{Title: "面談", Description: "非公開の内容", StartTime: at(10), EndTime: at(11), Visibility: reservation.VisibilityPrivate}
+	private, err := s.svc.Create(ctx, alice, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := fmt.Sprintf("%sreservation-%d.ics", roomPath, private.ID)
+
+	rec := s.do(http.MethodGet, path, "bob", "")
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "面談") {
+		t.Fatalf("他のユーザーの非公開の予約の GET = %d %s", rec.Code, rec.Body)
+	}
+	tag := rec.Header().Get("ETag")
+	if tag == etag(private.Reservation) {
+		t.Errorf("ETag = %s, want 伏せた予約の ETag", tag)
+	}
+	propfind := s.do("PROPFIND", roomPath, "bob", `<?xml version="1.0"?><d:propfind xmlns:d="DAV:"><d:prop><d:getetag/></d:prop></d:propfind>`, "Depth", "1")
+	if !strings.Contains(propfind.Body.String(), html.EscapeString(tag)) {
+		t.Errorf("PROPFIND の getetag が GET の ETag %s と一致しません: %s", tag, propfind.Body)
+	}
+	multiget := fmt.Sprintf(`<?xml version="1.0"?>
+<c:calendar-multiget xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><d:getetag/><c:calendar-data/></d:prop>
+  <d:href>%s</d:href>
+</c:calendar-multiget>`, path)
+	if report := s.do("REPORT", roomPath, "bob", multiget); !strings.Contains(report.Body.String(), html.EscapeString(tag)) {
+		t.Errorf("REPORT の getetag が GET の ETag %s と一致しません: %s", tag, report.Body)
+	}
+
+	// 伏せている項目を変更しても、詳細を見られないユーザーの ETag は変わらない
+	req.Title, req.Description = "面談 (変更)", "変更した内容"
+	if _, err := s.svc.Update(ctx, alice, private.ID, req); err != nil {
+		t.Fatal(err)
+	}
+	if rec := s.do(http.MethodGet, path, "bob", ""); rec.Header().Get("ETag") != tag {
+		t.Errorf("非公開の項目を変更した後の ETag = %s, want %s", rec.Header().Get("ETag"), tag)
+	}
+	if rec := s.do(http.MethodGet, path, "alice", ""); rec.Header().Get("ETag") == tag {
+		t.Errorf("予約者の ETag = %s, want 伏せた予約とは別の ETag", tag)
+	}
+}
@@ -0,0 +1,252 @@
+package caldav
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// iCalendar (RFC 5545) のうち、予約の読み書きに必要な最小限の処理を実装しています。
+
+const icalTimeLayout = "20060102T150405Z"
+
+var (
+	errNoEvent     = errors.New("VEVENTが含まれていません")
+	errAllDayEvent = errors.New("終日の予定は予約できません")
+)
+
+// vevent は予約に対応する VEVENT の内容です。
+type vevent struct {
+	UID          string
+	Summary      string
+	Start        time.Time
+	End          time.Time
+	LastModified time.Time
+}
+
+// parseVEvent は iCalendar データから最初の VEVENT を取り出します。
+func parseVEvent(data string) (vevent, error) {
+	var ev vevent
+	var duration time.Duration
+	inEvent, found := false, false
+
+	for _, line := range unfoldLines(data) {
+		name, params, value, ok := splitContentLine(line)
+		if !ok {
+			continue
+		}
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VEVENT"):
+			if found {
+				// 繰り返しの例外などの2件目以降は扱わない
+				return ev, nil
+			}
+			inEvent = true
+			continue
+		case name == "END" && strings.EqualFold(value, "VEVENT"):
+			inEvent, found = false, true
+			continue
+		}
+		if !inEvent {
+			continue
+		}
+
+		var err error
+		switch name {
+		case "UID":
+			ev.UID = value
+		case "SUMMARY":
+			ev.Summary = unescapeText(value)
+		case "DTSTART":
+			ev.Start, err = parseDateTime(params, value)
+		case "DTEND":
+			ev.End, err = parseDateTime(params, value)
+		case "DURATION":
+			duration, err = parseDuration(value)
+		}
+		if err != nil {
+			return ev, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	if !found {
+		return ev, errNoEvent
+	}
+	if ev.End.IsZero() && duration > 0 {
+		ev.End = ev.Start.Add(duration)
+	}
+	if ev.Start.IsZero() || ev.End.IsZero() {
+		return ev, errors.New("DTSTARTとDTENDは必須です")
+	}
+	return ev, nil
+}
+
+// formatCalendar は VEVENT をひとつ含む VCALENDAR を生成します。
+func formatCalendar(ev vevent) string {
+	var b strings.Builder
+	writeLine := func(line string) {
+		b.WriteString(foldLine(line))
+		b.WriteString("\r\n")
+	}
+	writeLine("BEGIN:VCALENDAR")
+	writeLine("VERSION:2.0")
+	writeLine("PRODID:-//yoyaku//Room 401//JA")
+	writeLine("BEGIN:VEVENT")
+	writeLine("UID:" + ev.UID)
+	writeLine("DTSTAMP:" + ev.LastModified.UTC().Format(icalTimeLayout))
+	writeLine("LAST-MODIFIED:" + ev.LastModified.UTC().Format(icalTimeLayout))
+	writeLine("DTSTART:" + ev.Start.UTC().Format(icalTimeLayout))
+	writeLine("DTEND:" + ev.End.UTC().Format(icalTimeLayout))
+	writeLine("SUMMARY:" + escapeText(ev.Summary))
+	writeLine("END:VEVENT")
+	writeLine("END:VCALENDAR")
+	return b.String()
+}
+
+// unfoldLines は折り返された行 (CRLFの後に空白) を結合します。
+func unfoldLines(data string) []string {
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	var lines []string
+	for _, line := range strings.Split(data, "\n") {
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// foldLine は75オクテットを超える行を折り返します。マルチバイト文字の途中では分割しません。
+func foldLine(line string) string {
+	const limit = 75
+	if len(line) <= limit {
+		return line
+	}
+	var b strings.Builder
+	width := 0
+	for _, r := range line {
+		size := len(string(r))
+		if width+size > limit {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	return b.String()
+}
+
+// splitContentLine は "NAME;PARAM=VALUE:value" 形式の行を分解します。
+func splitContentLine(line string) (string, map[string]string, string, bool) {
+	colon := -1
+	inQuote := false
+	for i, r := range line {
+		if r == '"' {
+			inQuote = !inQuote
+		} else if r == ':' && !inQuote {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return "", nil, "", false
+	}
+
+	parts := strings.Split(line[:colon], ";")
+	params := make(map[string]string)
+	for _, p := range parts[1:] {
+		if k, v, ok := strings.Cut(p, "="); ok {
+			params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), params, line[colon+1:], true
+}
+
+func parseDateTime(params map[string]string, value string) (time.Time, error) {
+	if strings.EqualFold(params["VALUE"], "DATE") || len(value) == len("20060102") {
+		return time.Time{}, errAllDayEvent
+	}
+	if strings.HasSuffix(value, "Z") {
+		return time.Parse(icalTimeLayout, value)
+	}
+
+	// TZIDが無い場合はサーバーのタイムゾーンの時刻 (floating time) として扱う
+	loc := time.Local
+	if tzid := params["TZID"]; tzid != "" {
+		l, err := time.LoadLocation(tzid)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("不明なタイムゾーンです: %s", tzid)
+		}
+		loc = l
+	}
+	return time.ParseInLocation("20060102T150405", value, loc)
+}
+
+// parseDuration は "PT1H30M" のような期間を解釈します。
+func parseDuration(value string) (time.Duration, error) {
+	s := strings.TrimPrefix(strings.ToUpper(value), "+")
+	if !strings.HasPrefix(s, "P") {
+		return 0, fmt.Errorf("不正な期間です: %s", value)
+	}
+	s = s[1:]
+
+	var d time.Duration
+	inTime := false
+	num := 0
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			num = num*10 + int(r-'0')
+			continue
+		case r == 'T':
+			inTime = true
+			continue
+		case r == 'W' && !inTime:
+			d += time.Duration(num) * 7 * 24 * time.Hour
+		case r == 'D' && !inTime:
+			d += time.Duration(num) * 24 * time.Hour
+		case r == 'H' && inTime:
+			d += time.Duration(num) * time.Hour
+		case r == 'M' && inTime:
+			d += time.Duration(num) * time.Minute
+		case r == 'S' && inTime:
+			d += time.Duration(num) * time.Second
+		default:
+			return 0, fmt.Errorf("不正な期間です: %s", value)
+		}
+		num = 0
+	}
+	return d, nil
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+func unescapeText(s string) string {
+	var b strings.Builder
+	escaped := false
+	for _, r := range s {
+		if escaped {
+			if r == 'n' || r == 'N' {
+				b.WriteRune('\n')
+			} else {
+				b.WriteRune(r)
+			}
+			escaped = false
+			continue
+		}
+		if r == '\\' {
+			escaped = true
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
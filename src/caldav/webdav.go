@@ -0,0 +1,177 @@
+package caldav
+
+import (
+	"encoding/xml"
+	"html"
+	"io"
+	"strings"
+	"time"
+)
+
+// WebDAV / CalDAV の名前空間
+const (
+	nsDAV            = "DAV:"
+	nsCalDAV         = "urn:ietf:params:xml:ns:caldav"
+	nsCalendarServer = "http://calendarserver.org/ns/"
+)
+
+// レスポンスで使う名前空間の接頭辞
+var nsPrefixes = map[string]string{
+	nsDAV:            "d",
+	nsCalDAV:         "c",
+	nsCalendarServer: "cs",
+}
+
+// davRequest は PROPFIND と REPORT のリクエストボディです。
+// REPORT の種類はルート要素の名前 (calendar-query, calendar-multiget) で判別します。
+type davRequest struct {
+	XMLName xml.Name
+	AllProp *struct{}  `xml:"DAV: allprop"`
+	Prop    *davProps  `xml:"DAV: prop"`
+	Hrefs   []string   `xml:"DAV: href"`
+	Filter  *davFilter `xml:"urn:ietf:params:xml:ns:caldav filter"`
+}
+
+type davProps struct {
+	Names []xml.Name `xml:",any"`
+}
+
+// UnmarshalXML は prop 要素の子要素の名前だけを取り出します。
+func (p *davProps) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			p.Names = append(p.Names, t.Name)
+			if err := d.Skip(); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+type davFilter struct {
+	CompFilter davCompFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+type davCompFilter struct {
+	Name        string          `xml:"name,attr"`
+	CompFilters []davCompFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+	TimeRange   *davTimeRange   `xml:"urn:ietf:params:xml:ns:caldav time-range"`
+}
+
+type davTimeRange struct {
+	Start string `xml:"start,attr"`
+	End   string `xml:"end,attr"`
+}
+
+// timeRange は VEVENT の comp-filter に指定された期間を返します。指定が無い場合は零値です。
+func (f *davFilter) timeRange() (time.Time, time.Time) {
+	if f == nil {
+		return time.Time{}, time.Time{}
+	}
+	for _, comp := range f.CompFilter.CompFilters {
+		if comp.Name != "VEVENT" || comp.TimeRange == nil {
+			continue
+		}
+		start, _ := time.Parse(icalTimeLayout, comp.TimeRange.Start)
+		end, _ := time.Parse(icalTimeLayout, comp.TimeRange.End)
+		return start, end
+	}
+	return time.Time{}, time.Time{}
+}
+
+// parseDAVRequest はリクエストボディを解釈します。ボディが空の場合は allprop として扱います。
+func parseDAVRequest(body io.Reader) (*davRequest, error) {
+	var req davRequest
+	if err := xml.NewDecoder(body).Decode(&req); err != nil {
+		if err == io.EOF {
+			return &davRequest{AllProp: &struct{}{}}, nil
+		}
+		return nil, err
+	}
+	return &req, nil
+}
+
+// propValue はプロパティの値です。xml には要素の中身をそのまま書き込みます。
+type propValue struct {
+	xml string
+}
+
+func textProp(s string) propValue {
+	return propValue{xml: html.EscapeString(s)}
+}
+
+func rawProp(s string) propValue {
+	return propValue{xml: s}
+}
+
+func hrefProp(href string) propValue {
+	return rawProp("<d:href>" + html.EscapeString(href) + "</d:href>")
+}
+
+// davResponse は multistatus の response 要素ひとつ分です。
+type davResponse struct {
+	href     string
+	found    []string
+	notFound []string
+}
+
+// newDAVResponse は要求されたプロパティを props から探し、見つかったものと見つからなかったものに分けます。
+// names が nil (allprop) の場合は props を全て返します。
+func newDAVResponse(href string, props map[xml.Name]propValue, names []xml.Name) davResponse {
+	res := davResponse{href: href}
+	if names == nil {
+		for name := range props {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		tag := qualifiedName(name)
+		if v, ok := props[name]; ok {
+			if v.xml == "" {
+				res.found = append(res.found, "<"+tag+"/>")
+			} else {
+				res.found = append(res.found, "<"+tag+">"+v.xml+"</"+tag+">")
+			}
+		} else {
+			res.notFound = append(res.notFound, "<"+tag+"/>")
+		}
+	}
+	return res
+}
+
+// writeMultistatus は 207 Multi-Status のボディを書き込みます。
+func writeMultistatus(w io.Writer, responses []davResponse) {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?>`)
+	b.WriteString(`<d:multistatus xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav" xmlns:cs="http://calendarserver.org/ns/">`)
+	for _, res := range responses {
+		b.WriteString("<d:response><d:href>" + html.EscapeString(res.href) + "</d:href>")
+		if len(res.found) > 0 {
+			b.WriteString("<d:propstat><d:prop>" + strings.Join(res.found, "") + "</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat>")
+		}
+		if len(res.notFound) > 0 {
+			b.WriteString("<d:propstat><d:prop>" + strings.Join(res.notFound, "") + "</d:prop><d:status>HTTP/1.1 404 Not Found</d:status></d:propstat>")
+		}
+		if len(res.found) == 0 && len(res.notFound) == 0 {
+			b.WriteString("<d:status>HTTP/1.1 404 Not Found</d:status>")
+		}
+		b.WriteString("</d:response>")
+	}
+	b.WriteString("</d:multistatus>")
+	io.WriteString(w, b.String())
+}
+
+// qualifiedName は要素名に接頭辞を付けます。未知の名前空間の場合は xmlns を埋め込みます。
+func qualifiedName(name xml.Name) string {
+	if prefix, ok := nsPrefixes[name.Space]; ok {
+		return prefix + ":" + name.Local
+	}
+	return name.Local + ` xmlns="` + html.EscapeString(name.Space) + `"`
+}
@@ -0,0 +1,86 @@
+package checkin
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"yoyaku/db"
+	"yoyaku/store"
+)
+
+var (
+	// ErrTooEarly はチェックインの受付開始前であることを表します。
+	ErrTooEarly = errors.New("チェックインは開始時刻の少し前から受け付けます")
+	// ErrTooLate はチェックインの受付時間を過ぎたことを表します。
+	ErrTooLate = errors.New("チェックインの受付時間を過ぎています")
+	// ErrNotConfirmed はキャンセル済み・no-showなどでチェックインできない予約であることを表します。
+	ErrNotConfirmed = errors.New("この予約はチェックインできません")
+)
+
+// Policy はチェックインと no-show に関するルールです。
+type Policy struct {
+	// OpenBefore は開始時刻の何分前からチェックインできるかです。
+	OpenBefore time.Duration
+	// GracePeriod は開始時刻から何分以内にチェックインしなければ no-show として解放するかです。
+	GracePeriod time.Duration
+	// SuspendThreshold 回以上 SuspendWindow の間に no-show があると新規予約を停止します。0の場合は停止しません。
+	SuspendThreshold int64
+	SuspendWindow    time.Duration
+}
+
+// DefaultPolicy は開始10分前から開始15分後までチェックインを受け付け、
+// 30日間に3回 no-show したユーザーの予約を停止するルールを返します。
+func DefaultPolicy() Policy {
+	return Policy{
+		OpenBefore:       10 * time.Minute,
+		GracePeriod:      15 * time.Minute,
+		SuspendThreshold: 3,
+		SuspendWindow:    30 * 24 * time.Hour,
+	}
+}
+
+// CanCheckIn は now に予約へチェックインできるかを判定します。
+func (p Policy) CanCheckIn(r db.Reservation, now time.Time) error {
+	if r.Status != "confirmed" {
+		return ErrNotConfirmed
+	}
+	if now.Before(r.StartTime.Add(-p.OpenBefore)) {
+		return ErrTooEarly
+	}
+	if now.After(r.StartTime.Add(p.GracePeriod)) || !now.Before(r.EndTime) {
+		return ErrTooLate
+	}
+	return nil
+}
+
+// NoShowCount は直近 SuspendWindow の間のユーザーの no-show の回数を返します。
+func (p Policy) NoShowCount(ctx context.Context, queries store.ReservationStore, userID uint64) (int64, error) {
+	return queries.CountNoShowsByUserID(ctx, db.CountNoShowsByUserIDParams{
+		UserID:    userID,
+		StartTime: time.Now().Add(-p.SuspendWindow),
+	})
+}
+
+// IsSuspended は no-show が多いために新規予約を停止しているかを返します。
+func (p Policy) IsSuspended(ctx context.Context, queries store.ReservationStore, userID uint64) (bool, error) {
+	if p.SuspendThreshold == 0 {
+		return false, nil
+	}
+	count, err := p.NoShowCount(ctx, queries, userID)
+	if err != nil {
+		return false, err
+	}
+	return count >= p.SuspendThreshold, nil
+}
+
+// NewToken はQRコードに埋め込むチェックイン用のトークンを生成します。
+func NewToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,91 @@
+package checkin
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"yoyaku/audit"
+	"yoyaku/db"
+	"yoyaku/events"
+	"yoyaku/store"
+)
+
+// 解放対象とする予約の開始時刻の範囲。機能の導入以前の予約を no-show にしないよう、直近のものに限る
+const releaseLookback = 24 * time.Hour
+
+// errAlreadyHandled は解放しようとした予約が既にチェックイン・キャンセルされていたことを表します。
+var errAlreadyHandled = errors.New("already handled")
+
+// Releaser は猶予時間内にチェックインされなかった予約を no-show として解放するバックグラウンドジョブです。
+type Releaser struct {
+	store    store.Store
+	bus      events.Bus
+	policy   Policy
+	interval time.Duration
+}
+
+// NewReleaser は Releaser を生成します。
+func NewReleaser(s store.Store, bus events.Bus, policy Policy, interval time.Duration) *Releaser {
+	return &Releaser{store: s, bus: bus, policy: policy, interval: interval}
+}
+
+// Run は ctx がキャンセルされるまで interval ごとに解放処理を行います。
+func (r *Releaser) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.ReleaseNoShows(ctx, time.Now()); err != nil {
+			log.Println("no-show の解放に失敗しました:", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ReleaseNoShows は now の時点で猶予時間を過ぎた未チェックインの予約を no-show にします。
+func (r *Releaser) ReleaseNoShows(ctx context.Context, now time.Time) error {
+	cutoff := now.Add(-r.policy.GracePeriod)
+	candidates, err := r.store.ListNoShowCandidates(ctx, db.ListNoShowCandidatesParams{
+		StartedBefore: cutoff,
+		StartedAfter:  cutoff.Add(-releaseLookback),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, candidate := range candidates {
+		var released db.Reservation
+		err := r.store.InTx(ctx, func(tx store.Store) error {
+			result, err := tx.MarkReservationNoShow(ctx, candidate.ID)
+			if err != nil {
+				return err
+			}
+			// 直前にチェックインされた場合は更新されない
+			if n, err := result.RowsAffected(); err != nil || n == 0 {
+				return errAlreadyHandled
+			}
+			released, err = tx.GetReservationByID(ctx, candidate.ID)
+			if err != nil {
+				return err
+			}
+			return audit.Record(ctx, tx, audit.System(audit.MethodSystem), audit.ActionNoShow, candidate.ID, &candidate, &released)
+		})
+		if errors.Is(err, errAlreadyHandled) {
+			continue
+		}
+		if err != nil {
+			log.Printf("予約 %d を no-show にできませんでした: %v", candidate.ID, err)
+			continue
+		}
+		log.Printf("予約 %d をチェックインが無かったため解放しました", candidate.ID)
+
+		r.bus.Publish(events.Event{Type: events.TypeReservationNoShow, Reservation: released})
+	}
+	return nil
+}
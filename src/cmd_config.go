@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"yoyaku/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+const configUsage = `使い方: app config check [-config path]
+
+  check       設定ファイルと環境変数から読み込んだ設定を確認し、秘密の値を伏せて表示する
+              (設定ファイルは -config または CONFIG_FILE で指定する)`
+
+// runConfig は "config" サブコマンドを実行します。
+// 設定に問題がある場合は全ての問題を標準エラー出力に書き出し、終了コード1で終了します。
+func runConfig(args []string) {
+	if len(args) == 0 || args[0] != "check" {
+		fmt.Fprintln(os.Stderr, configUsage)
+		os.Exit(2)
+	}
+	flags := flag.NewFlagSet("config check", flag.ExitOnError)
+	configFile := flags.String("config", os.Getenv("CONFIG_FILE"), "設定ファイル (YAML) のパス")
+	flags.Parse(args[1:])
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "設定を読み込めませんでした:\n%v\n", err)
+		os.Exit(1)
+	}
+	// 問題があっても、どの値を読み込んだか分かるよう先に設定を表示する
+	validateErr := cfg.Validate()
+
+	enc := yaml.NewEncoder(os.Stdout)
+	enc.SetIndent(2)
+	if err := enc.Encode(cfg.Redacted()); err != nil {
+		fmt.Fprintf(os.Stderr, "設定を書き出せませんでした: %v\n", err)
+		os.Exit(1)
+	}
+	enc.Close()
+
+	if validateErr != nil {
+		fmt.Fprintf(os.Stderr, "設定が正しくありません:\n%v\n", validateErr)
+		os.Exit(1)
+	}
+	fmt.Fprintln(os.Stderr, "設定に問題はありません")
+}
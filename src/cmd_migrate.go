@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"yoyaku/config"
+	"yoyaku/migrate"
+	"yoyaku/store"
+)
+
+const migrateUsage = `使い方: app migrate <command>
+
+  up          未適用のマイグレーションを全て適用する
+  down [N]    適用済みのマイグレーションを N 個 (デフォルト1) 取り消す
+  status      現在のバージョンと未適用のマイグレーションを表示する
+  force V     マイグレーションを実行せずにバージョンを V に設定する (dirty 状態の解除)`
+
+// runMigrate は "migrate" サブコマンドを実行します。
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+
+	// マイグレーションにはデータベースの設定だけを使う (SECRET_KEY などが無くても実行できる)
+	cfg, err := config.Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		log.Fatalf("設定を読み込めませんでした: %v", err)
+	}
+	backend, err := store.Open(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("データベースに接続できませんでした: %v", err)
+	}
+	defer backend.Close()
+
+	migrator, err := backend.Migrator()
+	if err != nil {
+		log.Fatalf("マイグレーションを読み込めませんでした: %v", err)
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		err = migrator.Up(ctx)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				log.Fatalf("取り消す数の形式が正しくありません: %s", args[1])
+			}
+		}
+		err = migrator.Down(ctx, steps)
+	case "status":
+		var status migrate.Status
+		status, err = migrator.Status(ctx)
+		if err == nil {
+			fmt.Printf("version: %d\n", status.Version)
+			fmt.Printf("dirty: %t\n", status.Dirty)
+			for _, migration := range status.Pending {
+				fmt.Printf("pending: %04d_%s\n", migration.Version, migration.Name)
+			}
+		}
+	case "force":
+		if len(args) < 2 {
+			log.Fatal("バージョンを指定してください")
+		}
+		version, parseErr := strconv.ParseUint(args[1], 10, 64)
+		if parseErr != nil {
+			log.Fatalf("バージョンの形式が正しくありません: %s", args[1])
+		}
+		err = migrator.Force(ctx, version)
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("マイグレーションに失敗しました: %v", err)
+	}
+}
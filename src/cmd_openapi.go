@@ -0,0 +1,19 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+
+	"yoyaku/openapi"
+)
+
+// runOpenAPI は "openapi" サブコマンドを実行し、OpenAPI 仕様を標準出力に書き出します。
+// サーバーを起動せずにフロントエンドの型付きクライアントを生成するために使います (make client)。
+func runOpenAPI() {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(openapi.Spec()); err != nil {
+		log.Fatalf("OpenAPI 仕様を書き出せませんでした: %v", err)
+	}
+}
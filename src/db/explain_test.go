@@ -0,0 +1,195 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"yoyaku/migrate"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// テスト用のデータベースに接続してマイグレーションを適用します。
+// TEST_DATABASE_URL が設定されていない場合はスキップします。
+// テーブルの中身は削除されるため、必ずテスト専用のデータベースを指定してください。
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL が設定されていないためスキップします")
+	}
+	sqlDB, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	migrator, err := migrate.New(sqlDB, migrate.MySQL, Migrations, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := migrator.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return sqlDB
+}
+
+// seedReservations は20人のユーザーと2000件の1時間ずつの予約を作成します。
+func seedReservations(t *testing.T, sqlDB *sql.DB, first time.Time) {
+	t.Helper()
+	ctx := context.Background()
+	for _, table := range []string{"reservation_events", "reservation_checkin_tokens", "reservations", "users"} {
+		if _, err := sqlDB.ExecContext(ctx, "DELETE FROM "+table); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 1; i <= 20; i++ {
+		if _, err := sqlDB.ExecContext(ctx,
+			"INSERT INTO users (id, name, email, google_id) VALUES (?, ?, ?, ?)",
+			i, fmt.Sprintf("user%d", i), fmt.Sprintf("user%d@example.com", i), fmt.Sprintf("google-%d", i),
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	const total, batch = 2000, 500
+	for offset := 0; offset < total; offset += batch {
+		var placeholders []string
+		var args []any
+		for i := offset; i < offset+batch; i++ {
+			status := "confirmed"
+			switch i % 10 {
+			case 0:
+				status = "canceled"
+			case 1:
+				status = "no_show"
+			}
+			start := first.Add(time.Duration(i) * time.Hour)
+			placeholders = append(placeholders, "(?, ?, ?, ?, ?, ?, ?)")
+			args = append(args, i%20+1, fmt.Sprintf("予約%d", i), start, start.Add(time.Hour), status,
+				fmt.Sprintf("event-%d", i), fmt.Sprintf("reservation-%d.ics", i))
+		}
+		if _, err := sqlDB.ExecContext(ctx,
+			"INSERT INTO reservations (user_id, title, start_time, end_time, status, google_event_id, caldav_name) VALUES "+strings.Join(placeholders, ", "),
+			args...,
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := sqlDB.ExecContext(ctx,
+		"INSERT INTO reservation_events (reservation_id, action, auth_method) SELECT id, 'created', 'system' FROM reservations",
+	); err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range []string{"users", "reservations", "reservation_events"} {
+		if _, err := sqlDB.ExecContext(ctx, "ANALYZE TABLE "+table); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// explainKey はクエリの実行計画で table に使われるインデックスと候補のインデックスを返します。
+func explainKey(t *testing.T, sqlDB *sql.DB, table, query string, args ...any) (key string, possibleKeys string) {
+	t.Helper()
+	rows, err := sqlDB.Query("EXPLAIN "+query, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		dest := make([]any, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			t.Fatal(err)
+		}
+		row := map[string]string{}
+		for i, column := range columns {
+			row[column] = values[i].String
+		}
+		if row["table"] == table {
+			return row["key"], row["possible_keys"]
+		}
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	t.Fatalf("実行計画にテーブル %s がありません", table)
+	return "", ""
+}
+
+func TestQueriesUseIndexes(t *testing.T) {
+	sqlDB := openTestDB(t)
+	first := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	seedReservations(t, sqlDB, first)
+
+	// 重複チェックなどは start_time < 終了 の範囲で検索するため、データの先頭付近の期間で確認する
+	dayStart := first.Add(24 * time.Hour)
+	dayEnd := dayStart.Add(24 * time.Hour)
+
+	tests := []struct {
+		name  string
+		table string
+		query string
+		args  []any
+		want  string
+	}{
+		{"ListReservationsByDate", "r", listReservationsByDate, []any{dayEnd, dayStart}, "idx_reservations_status_time"},
+		{"ListOverlappingReservationIDsForUpdate", "reservations", listOverlappingReservationIDsForUpdate, []any{dayEnd, dayStart, 1}, "idx_reservations_status_time"},
+		{"ListBusyIntervals", "reservations", listBusyIntervals, []any{dayEnd, dayStart}, "idx_reservations_status_time"},
+		{"ListNoShowCandidates", "reservations", listNoShowCandidates, []any{dayEnd, dayStart}, "idx_reservations_status_time"},
+		{"ListReservationsByUserID", "reservations", listReservationsByUserID, []any{3}, "idx_reservations_user_status_time"},
+		{"CountNoShowsByUserID", "reservations", countNoShowsByUserID, []any{3, dayStart}, "idx_reservations_user_status_time"},
+		{"GetReservationByGoogleEventID", "reservations", getReservationByGoogleEventID, []any{"event-42"}, "idx_reservations_google_event_id"},
+		{"GetReservationByCaldavName", "reservations", getReservationByCaldavName, []any{"reservation-42.ics"}, "idx_reservations_caldav_name"},
+		{"ListReservationEventsByReservationID", "e", listReservationEventsByReservationID, []any{42}, "idx_reservation_events_reservation"},
+		{"SearchReservationsFullText", "r", searchReservationsFullText, []any{"予約", "予約", 3, 3, 3, 10, 0}, "ftx_reservations_title_description"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, possibleKeys := explainKey(t, sqlDB, tt.table, tt.query, tt.args...)
+			if key != tt.want {
+				t.Errorf("key = %q (possible_keys = %q), want %q", key, possibleKeys, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckConstraints(t *testing.T) {
+	sqlDB := openTestDB(t)
+	seedReservations(t, sqlDB, time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC))
+
+	start := time.Date(2030, 1, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		query string
+		args  []any
+	}{
+		{"end_time <= start_time", "INSERT INTO reservations (user_id, title, start_time, end_time) VALUES (1, 'x', ?, ?)", []any{start, start}},
+		{"unknown status", "INSERT INTO reservations (user_id, title, start_time, end_time, status) VALUES (1, 'x', ?, ?, 'pending')", []any{start, start.Add(time.Hour)}},
+		{"unknown origin", "INSERT INTO reservations (user_id, title, start_time, end_time, origin) VALUES (1, 'x', ?, ?, 'fax')", []any{start, start.Add(time.Hour)}},
+		{"unknown user", "INSERT INTO reservations (user_id, title, start_time, end_time) VALUES (999999, 'x', ?, ?)", []any{start, start.Add(time.Hour)}},
+		{"unknown role", "UPDATE users SET role = 'owner' WHERE id = 1", nil},
+		{"unknown action", "INSERT INTO reservation_events (reservation_id, action, auth_method) SELECT MIN(id), 'deleted', 'system' FROM reservations", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := sqlDB.Exec(tt.query, tt.args...); err == nil {
+				t.Error("制約違反のデータが保存されました")
+			}
+		})
+	}
+}
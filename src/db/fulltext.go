@@ -0,0 +1,103 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// SearchReservationsFullText は予約のタイトルと説明を FULLTEXT インデックス (ngram) で検索し、関連度の高い順に返します。
+// sqlc の MySQL のパーサーは MATCH ... AGAINST の中のパラメータを扱えないため、このクエリだけ手書きしています。
+// SQLite と PostgreSQL では sqlc が生成した同名のクエリ (部分一致) を使います。
+// (このファイルは sqlc の生成対象ではありません)
+const searchReservationsFullText = `-- name: SearchReservationsFullText :many
+SELECT r.id, r.user_id, r.title, r.start_time, r.end_time, r.status, r.checked_in_at, r.actual_end_time, r.booked_end_time, r.origin, r.google_event_id, r.ical_uid, r.caldav_name, r.created_at, r.updated_at, r.description, r.headcount, r.visibility, u.name as user_name,
+  MATCH (r.title, r.description) AGAINST (? IN NATURAL LANGUAGE MODE) AS score
+FROM reservations AS r
+JOIN users AS u ON r.user_id = u.id AND u.deleted_at IS NULL
+WHERE
+  MATCH (r.title, r.description) AGAINST (? IN NATURAL LANGUAGE MODE)
+  -- キャンセルした予約は予約者本人だけが検索できる
+  AND (r.status <> 'canceled' OR r.user_id = ?)
+  -- 非公開の予約は予約者と参加者だけが検索できる
+  AND (r.visibility <> 'private' OR r.user_id = ? OR EXISTS (
+    SELECT 1 FROM reservation_attendees AS a
+    WHERE a.reservation_id = r.id AND a.user_id = ?
+  ))
+ORDER BY score DESC, r.start_time DESC, r.id DESC
+LIMIT ? OFFSET ?
+`
+
+type SearchReservationsFullTextParams struct {
+	Query    string `json:"query"`
+	ViewerID uint64 `json:"viewer_id"`
+	Offset   int32  `json:"offset"`
+	Limit    int32  `json:"limit"`
+}
+
+type SearchReservationsFullTextRow struct {
+	ID            uint64         `json:"id"`
+	UserID        uint64         `json:"user_id"`
+	Title         string         `json:"title"`
+	StartTime     time.Time      `json:"start_time"`
+	EndTime       time.Time      `json:"end_time"`
+	Status        string         `json:"status"`
+	CheckedInAt   sql.NullTime   `json:"checked_in_at"`
+	ActualEndTime sql.NullTime   `json:"actual_end_time"`
+	BookedEndTime sql.NullTime   `json:"booked_end_time"`
+	Origin        string         `json:"origin"`
+	GoogleEventID sql.NullString `json:"google_event_id"`
+	IcalUid       sql.NullString `json:"ical_uid"`
+	CaldavName    sql.NullString `json:"caldav_name"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	Description   string         `json:"description"`
+	Headcount     int32          `json:"headcount"`
+	Visibility    string         `json:"visibility"`
+	UserName      string         `json:"user_name"`
+	Score         float64        `json:"score"`
+}
+
+func (q *Queries) SearchReservationsFullText(ctx context.Context, arg SearchReservationsFullTextParams) ([]SearchReservationsFullTextRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchReservationsFullText, arg.Query, arg.Query, arg.ViewerID, arg.ViewerID, arg.ViewerID, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchReservationsFullTextRow
+	for rows.Next() {
+		var i SearchReservationsFullTextRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Title,
+			&i.StartTime,
+			&i.EndTime,
+			&i.Status,
+			&i.CheckedInAt,
+			&i.ActualEndTime,
+			&i.BookedEndTime,
+			&i.Origin,
+			&i.GoogleEventID,
+			&i.IcalUid,
+			&i.CaldavName,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Description,
+			&i.Headcount,
+			&i.Visibility,
+			&i.UserName,
+			&i.Score,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,9 @@
+package db
+
+import "embed"
+
+// Migrations はバイナリに埋め込んだマイグレーションファイル (migrations/NNNN_name.{up,down}.sql) です。
+// sqlc も同じディレクトリからスキーマを読み込みます (*.down.sql は無視されます)。
+//
+//go:embed migrations/*.sql
+var Migrations embed.FS
@@ -0,0 +1,64 @@
+-- users テーブル
+CREATE TABLE users(
+  id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  name VARCHAR(255) NOT NULL,
+  email VARCHAR(255) NOT NULL UNIQUE,
+  google_id VARCHAR(255) NOT NULL UNIQUE,
+  avatar_url VARCHAR(4069),
+  role VARCHAR(50) NOT NULL DEFAULT 'user',
+  caldav_token_hash CHAR(64), -- CalDAVクライアント用トークンのSHA-256
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  deleted_at TIMESTAMP
+);
+
+
+-- reservations テーブル
+CREATE TABLE reservations (
+  id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  user_id BIGINT UNSIGNED NOT NULL,
+  title VARCHAR(255) NOT NULL,
+  start_time TIMESTAMP NOT NULL,
+  end_time TIMESTAMP NOT NULL,
+  status VARCHAR(50) NOT NULL DEFAULT 'confirmed', -- 'confirmed', 'canceled', 'no_show'
+  checked_in_at TIMESTAMP NULL, -- 実際の利用開始 (チェックイン) 時刻
+  actual_end_time TIMESTAMP NULL, -- 「今すぐ終了」で記録した実際の利用終了時刻
+  booked_end_time TIMESTAMP NULL, -- 早期終了・延長する前に予約していた終了時刻
+  origin VARCHAR(50) NOT NULL DEFAULT 'api', -- 'api', 'google_calendar', 'caldav'
+  google_event_id VARCHAR(1024),
+  ical_uid VARCHAR(255), -- CalDAVクライアントが作成した予定のUID
+  caldav_name VARCHAR(255), -- CalDAVクライアントが作成した予定のリソース名 (xxx.ics)
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+
+-- calendar_sync_states テーブル (Googleカレンダーの差分同期トークン)
+CREATE TABLE calendar_sync_states (
+  calendar_id VARCHAR(255) NOT NULL PRIMARY KEY,
+  sync_token TEXT,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+
+-- reservation_checkin_tokens テーブル (ドアに掲示するQRコード用のトークン)
+-- 予約一覧のレスポンスに含まれないよう reservations とは別のテーブルに保存する
+CREATE TABLE reservation_checkin_tokens (
+  reservation_id BIGINT UNSIGNED NOT NULL PRIMARY KEY,
+  token CHAR(32) NOT NULL UNIQUE,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+
+-- reservation_events テーブル (予約の変更履歴、追記のみ)
+CREATE TABLE reservation_events (
+  id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  reservation_id BIGINT UNSIGNED NOT NULL,
+  actor_user_id BIGINT UNSIGNED, -- システムによる変更の場合は NULL
+  action VARCHAR(50) NOT NULL, -- 'created', 'updated', 'canceled', 'checked_in', 'no_show', 'ended_early', 'extended'
+  before_data JSON,
+  after_data JSON,
+  client_ip VARCHAR(45),
+  auth_method VARCHAR(50) NOT NULL, -- 'session', 'caldav', 'google_calendar', 'system'
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
\ No newline at end of file
@@ -0,0 +1,25 @@
+ALTER TABLE reservation_events
+  DROP CHECK chk_reservation_events_auth_method,
+  DROP CHECK chk_reservation_events_action,
+  DROP FOREIGN KEY fk_reservation_events_actor,
+  DROP FOREIGN KEY fk_reservation_events_reservation;
+ALTER TABLE reservation_events
+  DROP INDEX idx_reservation_events_actor,
+  DROP INDEX idx_reservation_events_reservation;
+
+ALTER TABLE reservation_checkin_tokens
+  DROP FOREIGN KEY fk_reservation_checkin_tokens_reservation;
+
+ALTER TABLE reservations
+  DROP CHECK chk_reservations_origin,
+  DROP CHECK chk_reservations_status,
+  DROP CHECK chk_reservations_time,
+  DROP FOREIGN KEY fk_reservations_user;
+ALTER TABLE reservations
+  DROP INDEX idx_reservations_caldav_name,
+  DROP INDEX idx_reservations_google_event_id,
+  DROP INDEX idx_reservations_user_status_time,
+  DROP INDEX idx_reservations_status_time;
+
+ALTER TABLE users
+  DROP CHECK chk_users_role;
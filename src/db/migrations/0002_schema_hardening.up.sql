@@ -0,0 +1,34 @@
+-- 外部キー・インデックス・CHECK制約の追加
+-- 既存のデータが制約に違反している場合 (存在しないユーザーの予約、終了時刻が開始時刻以前の予約など) は失敗するため、
+-- 先にデータを修正してから migrate force 1 で dirty 状態を解除し、再度 migrate up を実行してください。
+
+-- users
+ALTER TABLE users
+  ADD CONSTRAINT chk_users_role CHECK (role IN ('user', 'admin', 'system'));
+
+-- reservations
+-- idx_reservations_status_time: 日・週・月ごとの一覧、重複チェック、空き状況、no-show の検索
+-- idx_reservations_user_status_time: ユーザーごとの予約一覧と no-show の集計 (user_id の外部キーも兼ねる)
+ALTER TABLE reservations
+  ADD INDEX idx_reservations_status_time (status, start_time, end_time),
+  ADD INDEX idx_reservations_user_status_time (user_id, status, start_time),
+  ADD INDEX idx_reservations_google_event_id (google_event_id(255)),
+  ADD INDEX idx_reservations_caldav_name (caldav_name),
+  ADD CONSTRAINT fk_reservations_user FOREIGN KEY (user_id) REFERENCES users (id),
+  ADD CONSTRAINT chk_reservations_time CHECK (end_time > start_time),
+  ADD CONSTRAINT chk_reservations_status CHECK (status IN ('confirmed', 'canceled', 'no_show')),
+  ADD CONSTRAINT chk_reservations_origin CHECK (origin IN ('api', 'google_calendar', 'caldav'));
+
+-- reservation_checkin_tokens
+ALTER TABLE reservation_checkin_tokens
+  ADD CONSTRAINT fk_reservation_checkin_tokens_reservation FOREIGN KEY (reservation_id) REFERENCES reservations (id) ON DELETE CASCADE;
+
+-- reservation_events
+-- 変更履歴は予約より先に消えないよう ON DELETE は指定しない (予約の削除は変更履歴がある限り失敗する)
+ALTER TABLE reservation_events
+  ADD INDEX idx_reservation_events_reservation (reservation_id, id),
+  ADD INDEX idx_reservation_events_actor (actor_user_id, id),
+  ADD CONSTRAINT fk_reservation_events_reservation FOREIGN KEY (reservation_id) REFERENCES reservations (id),
+  ADD CONSTRAINT fk_reservation_events_actor FOREIGN KEY (actor_user_id) REFERENCES users (id),
+  ADD CONSTRAINT chk_reservation_events_action CHECK (action IN ('created', 'updated', 'canceled', 'checked_in', 'no_show', 'ended_early', 'extended')),
+  ADD CONSTRAINT chk_reservation_events_auth_method CHECK (auth_method IN ('session', 'caldav', 'google_calendar', 'system'));
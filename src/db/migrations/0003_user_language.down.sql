@@ -0,0 +1,4 @@
+ALTER TABLE users
+  DROP CHECK chk_users_language;
+ALTER TABLE users
+  DROP COLUMN language;
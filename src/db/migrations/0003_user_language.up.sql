@@ -0,0 +1,4 @@
+-- ユーザーが選んだ表示言語 ('ja' または 'en')。NULL の場合は Accept-Language から決める
+ALTER TABLE users
+  ADD COLUMN language VARCHAR(10),
+  ADD CONSTRAINT chk_users_language CHECK (language IN ('ja', 'en'));
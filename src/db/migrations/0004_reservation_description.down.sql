@@ -0,0 +1,4 @@
+ALTER TABLE reservations
+  DROP INDEX ftx_reservations_title_description;
+ALTER TABLE reservations
+  DROP COLUMN description;
@@ -0,0 +1,6 @@
+-- 予約の説明と、タイトル・説明の全文検索 (GET /api/reservations/search)
+-- 日本語は単語の区切りが無いため、ngram パーサー (既定では2文字ずつ) で索引を作る
+ALTER TABLE reservations
+  ADD COLUMN description VARCHAR(2000) NOT NULL DEFAULT '';
+ALTER TABLE reservations
+  ADD FULLTEXT INDEX ftx_reservations_title_description (title, description) WITH PARSER ngram;
@@ -0,0 +1,7 @@
+DROP TABLE reservation_attendees;
+
+ALTER TABLE reservations
+  DROP CHECK chk_reservations_visibility,
+  DROP CHECK chk_reservations_headcount,
+  DROP COLUMN visibility,
+  DROP COLUMN headcount;
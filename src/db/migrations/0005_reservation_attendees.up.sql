@@ -0,0 +1,20 @@
+-- 予約の参加予定人数・公開範囲と参加者
+-- headcount: 参加予定人数 (0 は未指定)。部屋の定員はアプリケーションの設定 (ROOM_CAPACITY) で検証する
+-- visibility: 'public' (ログインしていなくても見える)、'members' (ログインしたメンバーのみ)、
+--             'private' (予約者と参加者以外には「予約済み」とだけ表示する)
+ALTER TABLE reservations
+  ADD COLUMN headcount INT NOT NULL DEFAULT 0,
+  ADD COLUMN visibility VARCHAR(16) NOT NULL DEFAULT 'public',
+  ADD CONSTRAINT chk_reservations_headcount CHECK (headcount >= 0),
+  ADD CONSTRAINT chk_reservations_visibility CHECK (visibility IN ('public', 'members', 'private'));
+
+-- reservation_attendees テーブル (予約の参加者。予約者本人は含めない)
+CREATE TABLE reservation_attendees (
+  reservation_id BIGINT UNSIGNED NOT NULL,
+  user_id BIGINT UNSIGNED NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (reservation_id, user_id),
+  INDEX idx_reservation_attendees_user (user_id),
+  CONSTRAINT fk_reservation_attendees_reservation FOREIGN KEY (reservation_id) REFERENCES reservations (id) ON DELETE CASCADE,
+  CONSTRAINT fk_reservation_attendees_user FOREIGN KEY (user_id) REFERENCES users (id)
+);
@@ -0,0 +1,7 @@
+-- 参加者への招待の返答 (RSVP)
+-- response: 'needs_action' (未回答)、'accepted' (参加)、'declined' (不参加)、'tentative' (未定)
+-- responded_at: 最後に返答した日時 (未回答の場合は NULL)
+ALTER TABLE reservation_attendees
+  ADD COLUMN response VARCHAR(16) NOT NULL DEFAULT 'needs_action',
+  ADD COLUMN responded_at TIMESTAMP NULL DEFAULT NULL,
+  ADD CONSTRAINT chk_reservation_attendees_response CHECK (response IN ('needs_action', 'accepted', 'declined', 'tentative'));
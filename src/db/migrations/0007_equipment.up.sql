@@ -0,0 +1,22 @@
+-- equipment テーブル (部屋と一緒に予約できる備品。他の部屋と共用のため、数量はこのシステムで貸し出せる数)
+CREATE TABLE equipment (
+  id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+  name VARCHAR(255) NOT NULL,
+  quantity INT NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  UNIQUE KEY uq_equipment_name (name),
+  CONSTRAINT chk_equipment_quantity CHECK (quantity >= 0)
+);
+
+-- reservation_equipment テーブル (予約ごとに借りる備品と数量)
+CREATE TABLE reservation_equipment (
+  reservation_id BIGINT UNSIGNED NOT NULL,
+  equipment_id BIGINT UNSIGNED NOT NULL,
+  quantity INT NOT NULL,
+  PRIMARY KEY (reservation_id, equipment_id),
+  INDEX idx_reservation_equipment_equipment (equipment_id),
+  CONSTRAINT fk_reservation_equipment_reservation FOREIGN KEY (reservation_id) REFERENCES reservations (id) ON DELETE CASCADE,
+  CONSTRAINT fk_reservation_equipment_equipment FOREIGN KEY (equipment_id) REFERENCES equipment (id),
+  CONSTRAINT chk_reservation_equipment_quantity CHECK (quantity > 0)
+);
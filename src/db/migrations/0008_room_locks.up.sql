@@ -0,0 +1,6 @@
+-- room_locks テーブル (部屋ごとに1行。予約の重複チェックから登録までの間この行をロックし、同じ時間帯の予約を同時に登録できないようにする)
+CREATE TABLE room_locks (
+  id VARCHAR(64) NOT NULL PRIMARY KEY
+);
+
+INSERT INTO room_locks (id) VALUES ('room-401');
@@ -0,0 +1,12 @@
+// Package pgdb は PostgreSQL 用に sqlc で生成したクエリです。
+//
+// query.sql は db/query.sql と同じ名前・同じ引数の順番のクエリを定義し、生成される構造体を db パッケージと同じ形にしています
+// (store パッケージで db の型に変換するため)。
+package pgdb
+
+import "embed"
+
+// Migrations はバイナリに埋め込んだ PostgreSQL 用のマイグレーションファイルです。
+//
+//go:embed migrations/*.sql
+var Migrations embed.FS
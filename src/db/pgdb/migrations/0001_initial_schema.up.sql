@@ -0,0 +1,65 @@
+-- PostgreSQL 用のスキーマ (MySQL の db/migrations/0001_initial_schema.up.sql と同じ構成)
+
+-- users テーブル
+CREATE TABLE users (
+  id BIGSERIAL PRIMARY KEY,
+  name VARCHAR(255) NOT NULL,
+  email VARCHAR(255) NOT NULL UNIQUE,
+  google_id VARCHAR(255) NOT NULL UNIQUE,
+  avatar_url VARCHAR(4069),
+  role VARCHAR(50) NOT NULL DEFAULT 'user',
+  caldav_token_hash CHAR(64), -- CalDAVクライアント用トークンのSHA-256
+  created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  deleted_at TIMESTAMPTZ
+);
+
+
+-- reservations テーブル
+CREATE TABLE reservations (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  title VARCHAR(255) NOT NULL,
+  start_time TIMESTAMPTZ NOT NULL,
+  end_time TIMESTAMPTZ NOT NULL,
+  status VARCHAR(50) NOT NULL DEFAULT 'confirmed', -- 'confirmed', 'canceled', 'no_show'
+  checked_in_at TIMESTAMPTZ, -- 実際の利用開始 (チェックイン) 時刻
+  actual_end_time TIMESTAMPTZ, -- 「今すぐ終了」で記録した実際の利用終了時刻
+  booked_end_time TIMESTAMPTZ, -- 早期終了・延長する前に予約していた終了時刻
+  origin VARCHAR(50) NOT NULL DEFAULT 'api', -- 'api', 'google_calendar', 'caldav'
+  google_event_id VARCHAR(1024),
+  ical_uid VARCHAR(255), -- CalDAVクライアントが作成した予定のUID
+  caldav_name VARCHAR(255), -- CalDAVクライアントが作成した予定のリソース名 (xxx.ics)
+  created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+
+-- calendar_sync_states テーブル (Googleカレンダーの差分同期トークン)
+CREATE TABLE calendar_sync_states (
+  calendar_id VARCHAR(255) NOT NULL PRIMARY KEY,
+  sync_token TEXT,
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+
+-- reservation_checkin_tokens テーブル (ドアに掲示するQRコード用のトークン)
+CREATE TABLE reservation_checkin_tokens (
+  reservation_id BIGINT NOT NULL PRIMARY KEY,
+  token CHAR(32) NOT NULL UNIQUE,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+
+-- reservation_events テーブル (予約の変更履歴、追記のみ)
+CREATE TABLE reservation_events (
+  id BIGSERIAL PRIMARY KEY,
+  reservation_id BIGINT NOT NULL,
+  actor_user_id BIGINT, -- システムによる変更の場合は NULL
+  action VARCHAR(50) NOT NULL,
+  before_data JSONB,
+  after_data JSONB,
+  client_ip VARCHAR(45),
+  auth_method VARCHAR(50) NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,23 @@
+ALTER TABLE reservation_events
+  DROP CONSTRAINT chk_reservation_events_auth_method,
+  DROP CONSTRAINT chk_reservation_events_action,
+  DROP CONSTRAINT fk_reservation_events_actor,
+  DROP CONSTRAINT fk_reservation_events_reservation;
+DROP INDEX idx_reservation_events_actor;
+DROP INDEX idx_reservation_events_reservation;
+
+ALTER TABLE reservation_checkin_tokens
+  DROP CONSTRAINT fk_reservation_checkin_tokens_reservation;
+
+ALTER TABLE reservations
+  DROP CONSTRAINT chk_reservations_origin,
+  DROP CONSTRAINT chk_reservations_status,
+  DROP CONSTRAINT chk_reservations_time,
+  DROP CONSTRAINT fk_reservations_user;
+DROP INDEX idx_reservations_caldav_name;
+DROP INDEX idx_reservations_google_event_id;
+DROP INDEX idx_reservations_user_status_time;
+DROP INDEX idx_reservations_status_time;
+
+ALTER TABLE users
+  DROP CONSTRAINT chk_users_role;
@@ -0,0 +1,29 @@
+-- 外部キー・インデックス・CHECK制約の追加 (MySQL の 0002_schema_hardening と同じ内容)
+
+-- users
+ALTER TABLE users
+  ADD CONSTRAINT chk_users_role CHECK (role IN ('user', 'admin', 'system'));
+
+-- reservations
+CREATE INDEX idx_reservations_status_time ON reservations (status, start_time, end_time);
+CREATE INDEX idx_reservations_user_status_time ON reservations (user_id, status, start_time);
+CREATE INDEX idx_reservations_google_event_id ON reservations (google_event_id);
+CREATE INDEX idx_reservations_caldav_name ON reservations (caldav_name);
+ALTER TABLE reservations
+  ADD CONSTRAINT fk_reservations_user FOREIGN KEY (user_id) REFERENCES users (id),
+  ADD CONSTRAINT chk_reservations_time CHECK (end_time > start_time),
+  ADD CONSTRAINT chk_reservations_status CHECK (status IN ('confirmed', 'canceled', 'no_show')),
+  ADD CONSTRAINT chk_reservations_origin CHECK (origin IN ('api', 'google_calendar', 'caldav'));
+
+-- reservation_checkin_tokens
+ALTER TABLE reservation_checkin_tokens
+  ADD CONSTRAINT fk_reservation_checkin_tokens_reservation FOREIGN KEY (reservation_id) REFERENCES reservations (id) ON DELETE CASCADE;
+
+-- reservation_events
+CREATE INDEX idx_reservation_events_reservation ON reservation_events (reservation_id, id);
+CREATE INDEX idx_reservation_events_actor ON reservation_events (actor_user_id, id);
+ALTER TABLE reservation_events
+  ADD CONSTRAINT fk_reservation_events_reservation FOREIGN KEY (reservation_id) REFERENCES reservations (id),
+  ADD CONSTRAINT fk_reservation_events_actor FOREIGN KEY (actor_user_id) REFERENCES users (id),
+  ADD CONSTRAINT chk_reservation_events_action CHECK (action IN ('created', 'updated', 'canceled', 'checked_in', 'no_show', 'ended_early', 'extended')),
+  ADD CONSTRAINT chk_reservation_events_auth_method CHECK (auth_method IN ('session', 'caldav', 'google_calendar', 'system'));
@@ -0,0 +1,3 @@
+ALTER TABLE users
+  DROP CONSTRAINT chk_users_language,
+  DROP COLUMN language;
@@ -0,0 +1,4 @@
+-- ユーザーが選んだ表示言語 (MySQL の 0003_user_language と同じ内容)
+ALTER TABLE users
+  ADD COLUMN language VARCHAR(10),
+  ADD CONSTRAINT chk_users_language CHECK (language IN ('ja', 'en'));
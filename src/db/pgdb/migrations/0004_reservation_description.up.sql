@@ -0,0 +1,4 @@
+-- 予約の説明 (MySQL の 0004_reservation_description と同じ内容)
+-- PostgreSQL の全文検索は日本語を単語に分けられないため、検索は部分一致で行う (索引は作らない)
+ALTER TABLE reservations
+  ADD COLUMN description VARCHAR(2000) NOT NULL DEFAULT '';
@@ -0,0 +1,15 @@
+-- 予約の参加予定人数・公開範囲と参加者 (MySQL の 0005_reservation_attendees と同じ内容)
+ALTER TABLE reservations
+  ADD COLUMN headcount INTEGER NOT NULL DEFAULT 0,
+  ADD COLUMN visibility VARCHAR(16) NOT NULL DEFAULT 'public',
+  ADD CONSTRAINT chk_reservations_headcount CHECK (headcount >= 0),
+  ADD CONSTRAINT chk_reservations_visibility CHECK (visibility IN ('public', 'members', 'private'));
+
+-- reservation_attendees テーブル (予約の参加者。予約者本人は含めない)
+CREATE TABLE reservation_attendees (
+  reservation_id BIGINT NOT NULL REFERENCES reservations (id) ON DELETE CASCADE,
+  user_id BIGINT NOT NULL REFERENCES users (id),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (reservation_id, user_id)
+);
+CREATE INDEX idx_reservation_attendees_user ON reservation_attendees (user_id);
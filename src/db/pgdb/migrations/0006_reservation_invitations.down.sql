@@ -0,0 +1,4 @@
+ALTER TABLE reservation_attendees
+  DROP CONSTRAINT chk_reservation_attendees_response,
+  DROP COLUMN responded_at,
+  DROP COLUMN response;
@@ -0,0 +1,5 @@
+-- 参加者への招待の返答 (MySQL の 0006_reservation_invitations と同じ内容)
+ALTER TABLE reservation_attendees
+  ADD COLUMN response VARCHAR(16) NOT NULL DEFAULT 'needs_action',
+  ADD COLUMN responded_at TIMESTAMPTZ,
+  ADD CONSTRAINT chk_reservation_attendees_response CHECK (response IN ('needs_action', 'accepted', 'declined', 'tentative'));
@@ -0,0 +1,19 @@
+-- 部屋と一緒に予約できる備品 (MySQL の 0007_equipment と同じ内容)
+CREATE TABLE equipment (
+  id BIGSERIAL PRIMARY KEY,
+  name VARCHAR(255) NOT NULL,
+  quantity INTEGER NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  CONSTRAINT uq_equipment_name UNIQUE (name),
+  CONSTRAINT chk_equipment_quantity CHECK (quantity >= 0)
+);
+
+CREATE TABLE reservation_equipment (
+  reservation_id BIGINT NOT NULL REFERENCES reservations (id) ON DELETE CASCADE,
+  equipment_id BIGINT NOT NULL REFERENCES equipment (id),
+  quantity INTEGER NOT NULL,
+  PRIMARY KEY (reservation_id, equipment_id),
+  CONSTRAINT chk_reservation_equipment_quantity CHECK (quantity > 0)
+);
+CREATE INDEX idx_reservation_equipment_equipment ON reservation_equipment (equipment_id);
@@ -0,0 +1,6 @@
+-- 予約の重複チェックから登録までの間ロックする部屋ごとの行 (MySQL の 0008_room_locks と同じ内容)
+CREATE TABLE room_locks (
+  id VARCHAR(64) NOT NULL PRIMARY KEY
+);
+
+INSERT INTO room_locks (id) VALUES ('room-401');
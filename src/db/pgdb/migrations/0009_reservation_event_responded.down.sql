@@ -0,0 +1,5 @@
+-- 元の CHECK制約に戻すため、返答の変更履歴は削除する
+DELETE FROM reservation_events WHERE action = 'responded';
+ALTER TABLE reservation_events
+  DROP CONSTRAINT chk_reservation_events_action,
+  ADD CONSTRAINT chk_reservation_events_action CHECK (action IN ('created', 'updated', 'canceled', 'checked_in', 'no_show', 'ended_early', 'extended'));
@@ -0,0 +1,4 @@
+-- 招待への返答 (RSVP) を変更履歴に残せるよう、reservation_events.action に 'responded' を追加する
+ALTER TABLE reservation_events
+  DROP CONSTRAINT chk_reservation_events_action,
+  ADD CONSTRAINT chk_reservation_events_action CHECK (action IN ('created', 'updated', 'canceled', 'checked_in', 'no_show', 'ended_early', 'extended', 'responded'));
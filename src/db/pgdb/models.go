@@ -0,0 +1,96 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+
+package pgdb
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+type CalendarSyncState struct {
+	CalendarID string         `json:"calendar_id"`
+	SyncToken  sql.NullString `json:"sync_token"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+}
+
+type Equipment struct {
+	ID        uint64    `json:"id"`
+	Name      string    `json:"name"`
+	Quantity  int32     `json:"quantity"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type Reservation struct {
+	ID            uint64         `json:"id"`
+	UserID        uint64         `json:"user_id"`
+	Title         string         `json:"title"`
+	StartTime     time.Time      `json:"start_time"`
+	EndTime       time.Time      `json:"end_time"`
+	Status        string         `json:"status"`
+	CheckedInAt   sql.NullTime   `json:"checked_in_at"`
+	ActualEndTime sql.NullTime   `json:"actual_end_time"`
+	BookedEndTime sql.NullTime   `json:"booked_end_time"`
+	Origin        string         `json:"origin"`
+	GoogleEventID sql.NullString `json:"google_event_id"`
+	IcalUid       sql.NullString `json:"ical_uid"`
+	CaldavName    sql.NullString `json:"caldav_name"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	Description   string         `json:"description"`
+	Headcount     int32          `json:"headcount"`
+	Visibility    string         `json:"visibility"`
+}
+
+type ReservationAttendee struct {
+	ReservationID uint64       `json:"reservation_id"`
+	UserID        uint64       `json:"user_id"`
+	CreatedAt     time.Time    `json:"created_at"`
+	Response      string       `json:"response"`
+	RespondedAt   sql.NullTime `json:"responded_at"`
+}
+
+type ReservationCheckinToken struct {
+	ReservationID uint64    `json:"reservation_id"`
+	Token         string    `json:"token"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type ReservationEquipment struct {
+	ReservationID uint64 `json:"reservation_id"`
+	EquipmentID   uint64 `json:"equipment_id"`
+	Quantity      int32  `json:"quantity"`
+}
+
+type ReservationEvent struct {
+	ID            uint64          `json:"id"`
+	ReservationID uint64          `json:"reservation_id"`
+	ActorUserID   sql.NullInt64   `json:"actor_user_id"`
+	Action        string          `json:"action"`
+	BeforeData    json.RawMessage `json:"before_data"`
+	AfterData     json.RawMessage `json:"after_data"`
+	ClientIp      sql.NullString  `json:"client_ip"`
+	AuthMethod    string          `json:"auth_method"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+type RoomLock struct {
+	ID string `json:"id"`
+}
+
+type User struct {
+	ID              uint64         `json:"id"`
+	Name            string         `json:"name"`
+	Email           string         `json:"email"`
+	GoogleID        string         `json:"google_id"`
+	AvatarUrl       sql.NullString `json:"avatar_url"`
+	Role            string         `json:"role"`
+	CaldavTokenHash sql.NullString `json:"caldav_token_hash"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       sql.NullTime   `json:"deleted_at"`
+	Language        sql.NullString `json:"language"`
+}
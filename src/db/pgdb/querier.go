@@ -0,0 +1,80 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+
+package pgdb
+
+import (
+	"context"
+	"database/sql"
+)
+
+type Querier interface {
+	AddReservationAttendee(ctx context.Context, arg AddReservationAttendeeParams) error
+	AddReservationEquipment(ctx context.Context, arg AddReservationEquipmentParams) error
+	CanceledReservationByID(ctx context.Context, arg CanceledReservationByIDParams) error
+	CheckInReservation(ctx context.Context, id uint64) error
+	CountNoShowsByUserID(ctx context.Context, arg CountNoShowsByUserIDParams) (int64, error)
+	CreateCheckinToken(ctx context.Context, arg CreateCheckinTokenParams) error
+	CreateEquipment(ctx context.Context, arg CreateEquipmentParams) (uint64, error)
+	CreateReservation(ctx context.Context, arg CreateReservationParams) (uint64, error)
+	CreateReservationEvent(ctx context.Context, arg CreateReservationEventParams) error
+	CreateReservationFromCaldav(ctx context.Context, arg CreateReservationFromCaldavParams) (uint64, error)
+	CreateReservationFromCalendar(ctx context.Context, arg CreateReservationFromCalendarParams) (uint64, error)
+	// PostgreSQL 用のクエリ (db/query.sql と同じ名前・同じ引数の順番にしてください)
+	// LAST_INSERT_ID() の代わりに RETURNING id で作成した行のIDを返します。
+	CreateUser(ctx context.Context, arg CreateUserParams) (uint64, error)
+	DeleteReservationAttendee(ctx context.Context, arg DeleteReservationAttendeeParams) error
+	DeleteReservationEquipment(ctx context.Context, reservationID uint64) error
+	EndReservationEarly(ctx context.Context, arg EndReservationEarlyParams) error
+	ExtendReservation(ctx context.Context, arg ExtendReservationParams) error
+	GetCalendarSyncToken(ctx context.Context, calendarID string) (sql.NullString, error)
+	GetCheckinTokenByReservationID(ctx context.Context, reservationID uint64) (string, error)
+	GetEquipmentByID(ctx context.Context, id uint64) (Equipment, error)
+	// 同じ備品を同時に予約して数量を超えないよう、空きの確認から登録までの間ロックする
+	GetEquipmentByIDForUpdate(ctx context.Context, id uint64) (Equipment, error)
+	GetReservationByCaldavName(ctx context.Context, caldavName sql.NullString) (Reservation, error)
+	GetReservationByCheckinToken(ctx context.Context, token string) (Reservation, error)
+	GetReservationByGoogleEventID(ctx context.Context, googleEventID sql.NullString) (Reservation, error)
+	GetReservationByID(ctx context.Context, id uint64) (Reservation, error)
+	GetReservationByIDForUpdate(ctx context.Context, id uint64) (Reservation, error)
+	GetUserByEmail(ctx context.Context, email string) (User, error)
+	GetUserByGoogleID(ctx context.Context, googleID string) (User, error)
+	GetUserByID(ctx context.Context, id uint64) (User, error)
+	ListBusyIntervals(ctx context.Context, arg ListBusyIntervalsParams) ([]ListBusyIntervalsRow, error)
+	ListConfirmedReservations(ctx context.Context) ([]Reservation, error)
+	ListEquipment(ctx context.Context) ([]Equipment, error)
+	// 期間に重なる確定済みの予約が借りている備品 (exclude_id の予約を除く)
+	ListEquipmentUsage(ctx context.Context, arg ListEquipmentUsageParams) ([]ListEquipmentUsageRow, error)
+	// 終了していない確定済みの予約への招待 (予約者の名前と返答付き)
+	ListInvitationsByUserID(ctx context.Context, arg ListInvitationsByUserIDParams) ([]ListInvitationsByUserIDRow, error)
+	ListNoShowCandidates(ctx context.Context, arg ListNoShowCandidatesParams) ([]Reservation, error)
+	// 時間帯が重なる確定済みの予約の行をロックして ID を返す (exclude_id の予約を除く)
+	// PostgreSQL は集約関数と FOR UPDATE を組み合わせられないため、件数は呼び出し側で数える
+	ListOverlappingReservationIDsForUpdate(ctx context.Context, arg ListOverlappingReservationIDsForUpdateParams) ([]uint64, error)
+	// lib/pq を使わずに配列を渡すため、IDはカンマ区切りの文字列で受け取る
+	ListReservationAttendees(ctx context.Context, reservationIds string) ([]ListReservationAttendeesRow, error)
+	// lib/pq を使わずに配列を渡すため、IDはカンマ区切りの文字列で受け取る
+	ListReservationEquipment(ctx context.Context, reservationIds string) ([]ListReservationEquipmentRow, error)
+	ListReservationEventsByReservationID(ctx context.Context, reservationID uint64) ([]ListReservationEventsByReservationIDRow, error)
+	ListReservationsByDate(ctx context.Context, arg ListReservationsByDateParams) ([]ListReservationsByDateRow, error)
+	ListReservationsByUserID(ctx context.Context, userID uint64) ([]Reservation, error)
+	ListUsers(ctx context.Context) ([]User, error)
+	// 予約の重複チェックから登録までの間、部屋の行をロックする (同じ部屋の予約を同時に登録すると、どちらも重複なしと判定して二重に予約されるため)
+	LockRoom(ctx context.Context, id string) (string, error)
+	MarkReservationNoShow(ctx context.Context, id uint64) (sql.Result, error)
+	SearchReservationEvents(ctx context.Context, arg SearchReservationEventsParams) ([]SearchReservationEventsRow, error)
+	SearchReservations(ctx context.Context, arg SearchReservationsParams) ([]SearchReservationsRow, error)
+	SearchReservationsDesc(ctx context.Context, arg SearchReservationsDescParams) ([]SearchReservationsDescRow, error)
+	SearchReservationsFullText(ctx context.Context, arg SearchReservationsFullTextParams) ([]SearchReservationsFullTextRow, error)
+	SetReservationGoogleEventID(ctx context.Context, arg SetReservationGoogleEventIDParams) error
+	SetUserCaldavTokenHash(ctx context.Context, arg SetUserCaldavTokenHashParams) error
+	SetUserLanguage(ctx context.Context, arg SetUserLanguageParams) error
+	SoftDeleteUser(ctx context.Context, id uint64) error
+	UpdateEquipment(ctx context.Context, arg UpdateEquipmentParams) error
+	UpdateReservationAttendeeResponse(ctx context.Context, arg UpdateReservationAttendeeResponseParams) error
+	UpdateReservationByID(ctx context.Context, arg UpdateReservationByIDParams) error
+	UpsertCalendarSyncToken(ctx context.Context, arg UpsertCalendarSyncTokenParams) error
+}
+
+var _ Querier = (*Queries)(nil)
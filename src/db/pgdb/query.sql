@@ -0,0 +1,435 @@
+-- PostgreSQL 用のクエリ (db/query.sql と同じ名前・同じ引数の順番にしてください)
+-- LAST_INSERT_ID() の代わりに RETURNING id で作成した行のIDを返します。
+
+-- name: CreateUser :one
+INSERT INTO users (
+    name, email, google_id, avatar_url, role
+) VALUES (
+    $1, $2, $3, $4, $5
+)
+RETURNING id;
+
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = $1
+  AND deleted_at IS NULL;
+
+-- name: GetUserByEmail :one
+SELECT * FROM users
+WHERE email = $1
+  AND deleted_at IS NULL;
+
+-- name: GetUserByGoogleID :one
+SELECT * FROM users
+WHERE google_id = $1
+  AND deleted_at IS NULL;
+
+-- name: ListUsers :many
+SELECT * FROM users
+WHERE deleted_at IS NULL
+ORDER BY id;
+
+-- name: SetUserCaldavTokenHash :exec
+UPDATE users
+SET caldav_token_hash = $1, updated_at = CURRENT_TIMESTAMP
+WHERE id = $2;
+
+-- name: SetUserLanguage :exec
+UPDATE users
+SET language = $1, updated_at = CURRENT_TIMESTAMP
+WHERE id = $2;
+
+-- name: SoftDeleteUser :exec
+UPDATE users
+SET deleted_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+
+-- name: CreateReservation :one
+INSERT INTO reservations (
+    user_id, title, description, headcount, visibility, start_time, end_time, status
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, 'confirmed'
+)
+RETURNING id;
+
+-- name: GetReservationByID :one
+SELECT * FROM reservations
+WHERE id = $1;
+
+-- name: ListReservationsByUserID :many
+SELECT * FROM reservations
+WHERE status = 'confirmed'
+  AND user_id = $1
+ORDER BY start_time;
+
+-- name: ListReservationsByDate :many
+SELECT r.*, u.name as user_name
+FROM reservations AS r
+JOIN users AS u ON r.user_id = u.id AND u.deleted_at IS NULL
+WHERE
+  r.status = 'confirmed'
+  AND r.start_time < $1 
+  AND r.end_time >= $2 
+ORDER BY
+  r.start_time;
+
+-- name: SearchReservations :many
+SELECT r.*, u.name as user_name
+FROM reservations AS r
+JOIN users AS u ON r.user_id = u.id AND u.deleted_at IS NULL
+WHERE
+  (sqlc.narg(user_id) IS NULL OR r.user_id = sqlc.narg(user_id))
+  -- 予約者か、参加を承諾 (未定を含む) した参加者の予約
+  AND (sqlc.narg(member_id) IS NULL OR r.user_id = sqlc.narg(member_id) OR EXISTS (
+    SELECT 1 FROM reservation_attendees AS m
+    WHERE m.reservation_id = r.id AND m.user_id = sqlc.narg(member_id) AND m.response IN ('accepted', 'tentative')
+  ))
+  AND (sqlc.narg(status) IS NULL OR r.status = sqlc.narg(status))
+  AND (sqlc.narg(title) IS NULL OR (strpos(lower(r.title), lower(sqlc.narg(title))) > 0 AND (
+    -- タイトルで絞り込む場合は、閲覧者がタイトルを見られる予約だけを対象にする
+    r.visibility = 'public'
+    OR (sqlc.narg(viewer_id) IS NOT NULL AND r.visibility = 'members')
+    OR r.user_id = sqlc.narg(viewer_id)
+    OR EXISTS (
+      SELECT 1 FROM reservation_attendees AS a
+      WHERE a.reservation_id = r.id AND a.user_id = sqlc.narg(viewer_id)
+    )
+  )))
+  AND (sqlc.narg(created_by) IS NULL OR EXISTS (
+    SELECT 1 FROM reservation_events AS e
+    WHERE e.reservation_id = r.id AND e.action = 'created' AND e.actor_user_id = sqlc.narg(created_by)
+  ))
+  AND (sqlc.narg(range_start) IS NULL OR r.end_time >= sqlc.narg(range_start))
+  AND (sqlc.narg(range_end) IS NULL OR r.start_time < sqlc.narg(range_end))
+  AND (sqlc.narg(cursor_start_time) IS NULL
+    OR r.start_time > sqlc.narg(cursor_start_time)
+    OR (r.start_time = sqlc.narg(cursor_start_time) AND r.id > sqlc.narg(cursor_id)))
+ORDER BY r.start_time, r.id
+LIMIT sqlc.arg('limit')::int;
+
+-- name: SearchReservationsDesc :many
+SELECT r.*, u.name as user_name
+FROM reservations AS r
+JOIN users AS u ON r.user_id = u.id AND u.deleted_at IS NULL
+WHERE
+  (sqlc.narg(user_id) IS NULL OR r.user_id = sqlc.narg(user_id))
+  -- 予約者か、参加を承諾 (未定を含む) した参加者の予約
+  AND (sqlc.narg(member_id) IS NULL OR r.user_id = sqlc.narg(member_id) OR EXISTS (
+    SELECT 1 FROM reservation_attendees AS m
+    WHERE m.reservation_id = r.id AND m.user_id = sqlc.narg(member_id) AND m.response IN ('accepted', 'tentative')
+  ))
+  AND (sqlc.narg(status) IS NULL OR r.status = sqlc.narg(status))
+  AND (sqlc.narg(title) IS NULL OR (strpos(lower(r.title), lower(sqlc.narg(title))) > 0 AND (
+    -- タイトルで絞り込む場合は、閲覧者がタイトルを見られる予約だけを対象にする
+    r.visibility = 'public'
+    OR (sqlc.narg(viewer_id) IS NOT NULL AND r.visibility = 'members')
+    OR r.user_id = sqlc.narg(viewer_id)
+    OR EXISTS (
+      SELECT 1 FROM reservation_attendees AS a
+      WHERE a.reservation_id = r.id AND a.user_id = sqlc.narg(viewer_id)
+    )
+  )))
+  AND (sqlc.narg(created_by) IS NULL OR EXISTS (
+    SELECT 1 FROM reservation_events AS e
+    WHERE e.reservation_id = r.id AND e.action = 'created' AND e.actor_user_id = sqlc.narg(created_by)
+  ))
+  AND (sqlc.narg(range_start) IS NULL OR r.end_time >= sqlc.narg(range_start))
+  AND (sqlc.narg(range_end) IS NULL OR r.start_time < sqlc.narg(range_end))
+  AND (sqlc.narg(cursor_start_time) IS NULL
+    OR r.start_time < sqlc.narg(cursor_start_time)
+    OR (r.start_time = sqlc.narg(cursor_start_time) AND r.id < sqlc.narg(cursor_id)))
+ORDER BY r.start_time DESC, r.id DESC
+LIMIT sqlc.arg('limit')::int;
+
+-- name: SearchReservationsFullText :many
+SELECT r.*, u.name as user_name, (
+    (CASE WHEN strpos(lower(r.title), lower(sqlc.arg(query))) > 0 THEN 2 ELSE 0 END)
+    + (CASE WHEN strpos(lower(r.description), lower(sqlc.arg(query))) > 0 THEN 1 ELSE 0 END)
+  )::float8 AS score
+FROM reservations AS r
+JOIN users AS u ON r.user_id = u.id AND u.deleted_at IS NULL
+WHERE
+  (strpos(lower(r.title), lower(sqlc.arg(query))) > 0 OR strpos(lower(r.description), lower(sqlc.arg(query))) > 0)
+  -- キャンセルした予約は予約者本人だけが検索できる
+  AND (r.status <> 'canceled' OR r.user_id = sqlc.arg(viewer_id))
+  -- 非公開の予約は予約者と参加者だけが検索できる
+  AND (r.visibility <> 'private' OR r.user_id = sqlc.arg(viewer_id) OR EXISTS (
+    SELECT 1 FROM reservation_attendees AS a
+    WHERE a.reservation_id = r.id AND a.user_id = sqlc.arg(viewer_id)
+  ))
+ORDER BY score DESC, r.start_time DESC, r.id DESC
+LIMIT sqlc.arg('limit')::int OFFSET sqlc.arg('offset')::int;
+
+-- name: UpdateReservationByID :exec
+UPDATE reservations
+SET title = $1, description = $2, headcount = $3, visibility = $4, start_time = $5, end_time = $6, updated_at = CURRENT_TIMESTAMP
+WHERE id = $7;
+
+-- name: CanceledReservationByID :exec
+UPDATE reservations
+SET
+  status = 'canceled',
+  updated_at = CURRENT_TIMESTAMP
+WHERE
+  user_id = $1 AND id = $2
+  AND status = 'confirmed';
+
+-- name: CreateReservationFromCalendar :one
+INSERT INTO reservations (
+    user_id, title, start_time, end_time, status, origin, google_event_id
+) VALUES (
+    $1, $2, $3, $4, 'confirmed', 'google_calendar', $5
+)
+RETURNING id;
+
+-- name: GetReservationByGoogleEventID :one
+SELECT * FROM reservations
+WHERE google_event_id = $1;
+
+-- name: SetReservationGoogleEventID :exec
+UPDATE reservations
+SET google_event_id = $1
+WHERE id = $2;
+
+-- name: GetCalendarSyncToken :one
+SELECT sync_token FROM calendar_sync_states
+WHERE calendar_id = $1;
+
+-- name: UpsertCalendarSyncToken :exec
+INSERT INTO calendar_sync_states (
+    calendar_id, sync_token
+) VALUES (
+    $1, $2
+)
+ON CONFLICT (calendar_id) DO UPDATE SET
+  sync_token = excluded.sync_token,
+  updated_at = CURRENT_TIMESTAMP;
+
+-- name: ListConfirmedReservations :many
+SELECT * FROM reservations
+WHERE status = 'confirmed'
+ORDER BY start_time;
+
+-- name: GetReservationByCaldavName :one
+SELECT * FROM reservations
+WHERE status = 'confirmed'
+  AND caldav_name = $1;
+
+-- name: CreateReservationFromCaldav :one
+INSERT INTO reservations (
+    user_id, title, start_time, end_time, status, origin, ical_uid, caldav_name
+) VALUES (
+    $1, $2, $3, $4, 'confirmed', 'caldav', $5, $6
+)
+RETURNING id;
+
+-- name: ListBusyIntervals :many
+SELECT start_time, end_time FROM reservations
+WHERE status = 'confirmed'
+  AND start_time < sqlc.arg(range_end)
+  AND end_time > sqlc.arg(range_start)
+ORDER BY start_time;
+
+-- name: GetReservationByCheckinToken :one
+SELECT r.* FROM reservations AS r
+JOIN reservation_checkin_tokens AS t ON t.reservation_id = r.id
+WHERE t.token = $1;
+
+-- name: GetCheckinTokenByReservationID :one
+SELECT token FROM reservation_checkin_tokens
+WHERE reservation_id = $1;
+
+-- name: CreateCheckinToken :exec
+INSERT INTO reservation_checkin_tokens (
+    reservation_id, token
+) VALUES (
+    $1, $2
+);
+
+-- name: CheckInReservation :exec
+UPDATE reservations
+SET checked_in_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+  AND status = 'confirmed'
+  AND checked_in_at IS NULL;
+
+-- name: ListNoShowCandidates :many
+SELECT * FROM reservations
+WHERE status = 'confirmed'
+  AND checked_in_at IS NULL
+  AND actual_end_time IS NULL
+  AND start_time <= sqlc.arg(started_before)
+  AND start_time > sqlc.arg(started_after)
+ORDER BY start_time;
+
+-- name: MarkReservationNoShow :execresult
+UPDATE reservations
+SET status = 'no_show', updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+  AND status = 'confirmed'
+  AND checked_in_at IS NULL
+  AND actual_end_time IS NULL;
+
+-- name: CountNoShowsByUserID :one
+SELECT COUNT(*) FROM reservations
+WHERE user_id = $1
+  AND status = 'no_show'
+  AND start_time >= $2;
+
+-- name: GetReservationByIDForUpdate :one
+SELECT * FROM reservations
+WHERE id = $1
+FOR UPDATE;
+
+-- name: LockRoom :one
+-- 予約の重複チェックから登録までの間、部屋の行をロックする (同じ部屋の予約を同時に登録すると、どちらも重複なしと判定して二重に予約されるため)
+SELECT id FROM room_locks
+WHERE id = $1
+FOR UPDATE;
+
+-- name: ListOverlappingReservationIDsForUpdate :many
+-- 時間帯が重なる確定済みの予約の行をロックして ID を返す (exclude_id の予約を除く)
+-- PostgreSQL は集約関数と FOR UPDATE を組み合わせられないため、件数は呼び出し側で数える
+SELECT id FROM reservations
+WHERE status = 'confirmed'
+  AND start_time < sqlc.arg(range_end)
+  AND end_time > sqlc.arg(range_start)
+  AND id <> sqlc.arg(exclude_id)
+ORDER BY id
+FOR UPDATE;
+
+-- name: EndReservationEarly :exec
+UPDATE reservations
+SET
+  booked_end_time = COALESCE(booked_end_time, end_time),
+  end_time = $1,
+  actual_end_time = $2,
+  updated_at = CURRENT_TIMESTAMP
+WHERE id = $3;
+
+-- name: ExtendReservation :exec
+UPDATE reservations
+SET
+  booked_end_time = COALESCE(booked_end_time, end_time),
+  end_time = $1,
+  updated_at = CURRENT_TIMESTAMP
+WHERE id = $2;
+
+-- name: CreateReservationEvent :exec
+INSERT INTO reservation_events (
+    reservation_id, actor_user_id, action, before_data, after_data, client_ip, auth_method
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7
+);
+
+-- name: ListReservationEventsByReservationID :many
+SELECT e.*, u.name as actor_name
+FROM reservation_events AS e
+LEFT JOIN users AS u ON e.actor_user_id = u.id
+WHERE e.reservation_id = $1
+ORDER BY e.id;
+
+-- name: SearchReservationEvents :many
+SELECT e.*, u.name as actor_name
+FROM reservation_events AS e
+LEFT JOIN users AS u ON e.actor_user_id = u.id
+WHERE
+  (sqlc.narg(actor_user_id) IS NULL OR e.actor_user_id = sqlc.narg(actor_user_id))
+  AND (sqlc.narg(reservation_id) IS NULL OR e.reservation_id = sqlc.narg(reservation_id))
+  AND (sqlc.narg(action) IS NULL OR e.action = sqlc.narg(action))
+  AND (sqlc.narg(auth_method) IS NULL OR e.auth_method = sqlc.narg(auth_method))
+  AND (sqlc.narg(created_from) IS NULL OR e.created_at >= sqlc.narg(created_from))
+  AND (sqlc.narg(created_to) IS NULL OR e.created_at < sqlc.narg(created_to))
+ORDER BY e.id DESC
+LIMIT sqlc.arg('limit')::int OFFSET sqlc.arg('offset')::int;
+
+-- name: AddReservationAttendee :exec
+INSERT INTO reservation_attendees (
+    reservation_id, user_id
+) VALUES (
+    $1, $2
+);
+
+-- name: DeleteReservationAttendee :exec
+DELETE FROM reservation_attendees
+WHERE reservation_id = $1 AND user_id = $2;
+
+-- name: UpdateReservationAttendeeResponse :exec
+UPDATE reservation_attendees
+SET response = $1, responded_at = $2
+WHERE reservation_id = $3 AND user_id = $4;
+
+-- name: ListReservationAttendees :many
+-- lib/pq を使わずに配列を渡すため、IDはカンマ区切りの文字列で受け取る
+SELECT a.reservation_id, a.user_id, u.name, a.response, a.responded_at
+FROM reservation_attendees AS a
+JOIN users AS u ON a.user_id = u.id AND u.deleted_at IS NULL
+WHERE a.reservation_id = ANY(string_to_array(sqlc.arg(reservation_ids)::text, ',')::bigint[])
+ORDER BY a.reservation_id, a.user_id;
+
+-- name: ListInvitationsByUserID :many
+-- 終了していない確定済みの予約への招待 (予約者の名前と返答付き)
+SELECT r.*, u.name AS user_name, a.response, a.responded_at
+FROM reservation_attendees AS a
+JOIN reservations AS r ON a.reservation_id = r.id
+JOIN users AS u ON r.user_id = u.id AND u.deleted_at IS NULL
+WHERE a.user_id = sqlc.arg(user_id) AND r.status = 'confirmed' AND r.end_time >= sqlc.arg(after)
+ORDER BY r.start_time, r.id;
+
+-- name: CreateEquipment :one
+INSERT INTO equipment (
+    name, quantity
+) VALUES (
+    $1, $2
+)
+RETURNING id;
+
+-- name: UpdateEquipment :exec
+UPDATE equipment
+SET name = $1, quantity = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $3;
+
+-- name: GetEquipmentByID :one
+SELECT * FROM equipment
+WHERE id = $1;
+
+-- name: GetEquipmentByIDForUpdate :one
+-- 同じ備品を同時に予約して数量を超えないよう、空きの確認から登録までの間ロックする
+SELECT * FROM equipment
+WHERE id = $1
+FOR UPDATE;
+
+-- name: ListEquipment :many
+SELECT * FROM equipment
+ORDER BY id;
+
+-- name: ListEquipmentUsage :many
+-- 期間に重なる確定済みの予約が借りている備品 (exclude_id の予約を除く)
+SELECT re.reservation_id, re.equipment_id, re.quantity, r.start_time, r.end_time
+FROM reservation_equipment AS re
+JOIN reservations AS r ON re.reservation_id = r.id
+WHERE r.status = 'confirmed'
+  AND r.start_time < sqlc.arg(range_end)
+  AND r.end_time > sqlc.arg(range_start)
+  AND r.id <> sqlc.arg(exclude_id)
+ORDER BY re.equipment_id, r.start_time, re.reservation_id;
+
+-- name: AddReservationEquipment :exec
+INSERT INTO reservation_equipment (
+    reservation_id, equipment_id, quantity
+) VALUES (
+    $1, $2, $3
+);
+
+-- name: DeleteReservationEquipment :exec
+DELETE FROM reservation_equipment
+WHERE reservation_id = $1;
+
+-- name: ListReservationEquipment :many
+-- lib/pq を使わずに配列を渡すため、IDはカンマ区切りの文字列で受け取る
+SELECT re.reservation_id, re.equipment_id, e.name, re.quantity
+FROM reservation_equipment AS re
+JOIN equipment AS e ON re.equipment_id = e.id
+WHERE re.reservation_id = ANY(string_to_array(sqlc.arg(reservation_ids)::text, ',')::bigint[])
+ORDER BY re.reservation_id, re.equipment_id;
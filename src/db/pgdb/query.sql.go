@@ -0,0 +1,1946 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: query.sql
+
+package pgdb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+const addReservationAttendee = `-- name: AddReservationAttendee :exec
+INSERT INTO reservation_attendees (
+    reservation_id, user_id
+) VALUES (
+    $1, $2
+)
+`
+
+type AddReservationAttendeeParams struct {
+	ReservationID uint64 `json:"reservation_id"`
+	UserID        uint64 `json:"user_id"`
+}
+
+func (q *Queries) AddReservationAttendee(ctx context.Context, arg AddReservationAttendeeParams) error {
+	_, err := q.db.ExecContext(ctx, addReservationAttendee, arg.ReservationID, arg.UserID)
+	return err
+}
+
+const addReservationEquipment = `-- name: AddReservationEquipment :exec
+INSERT INTO reservation_equipment (
+    reservation_id, equipment_id, quantity
+) VALUES (
+    $1, $2, $3
+)
+`
+
+type AddReservationEquipmentParams struct {
+	ReservationID uint64 `json:"reservation_id"`
+	EquipmentID   uint64 `json:"equipment_id"`
+	Quantity      int32  `json:"quantity"`
+}
+
+func (q *Queries) AddReservationEquipment(ctx context.Context, arg AddReservationEquipmentParams) error {
+	_, err := q.db.ExecContext(ctx, addReservationEquipment, arg.ReservationID, arg.EquipmentID, arg.Quantity)
+	return err
+}
+
+const canceledReservationByID = `-- name: CanceledReservationByID :exec
+UPDATE reservations
+SET
+  status = 'canceled',
+  updated_at = CURRENT_TIMESTAMP
+WHERE
+  user_id = $1 AND id = $2
+  AND status = 'confirmed'
+`
+
+type CanceledReservationByIDParams struct {
+	UserID uint64 `json:"user_id"`
+	ID     uint64 `json:"id"`
+}
+
+func (q *Queries) CanceledReservationByID(ctx context.Context, arg CanceledReservationByIDParams) error {
+	_, err := q.db.ExecContext(ctx, canceledReservationByID, arg.UserID, arg.ID)
+	return err
+}
+
+const checkInReservation = `-- name: CheckInReservation :exec
+UPDATE reservations
+SET checked_in_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+  AND status = 'confirmed'
+  AND checked_in_at IS NULL
+`
+
+func (q *Queries) CheckInReservation(ctx context.Context, id uint64) error {
+	_, err := q.db.ExecContext(ctx, checkInReservation, id)
+	return err
+}
+
+const countNoShowsByUserID = `-- name: CountNoShowsByUserID :one
+SELECT COUNT(*) FROM reservations
+WHERE user_id = $1
+  AND status = 'no_show'
+  AND start_time >= $2
+`
+
+type CountNoShowsByUserIDParams struct {
+	UserID    uint64    `json:"user_id"`
+	StartTime time.Time `json:"start_time"`
+}
+
+func (q *Queries) CountNoShowsByUserID(ctx context.Context, arg CountNoShowsByUserIDParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countNoShowsByUserID, arg.UserID, arg.StartTime)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createCheckinToken = `-- name: CreateCheckinToken :exec
+INSERT INTO reservation_checkin_tokens (
+    reservation_id, token
+) VALUES (
+    $1, $2
+)
+`
+
+type CreateCheckinTokenParams struct {
+	ReservationID uint64 `json:"reservation_id"`
+	Token         string `json:"token"`
+}
+
+func (q *Queries) CreateCheckinToken(ctx context.Context, arg CreateCheckinTokenParams) error {
+	_, err := q.db.ExecContext(ctx, createCheckinToken, arg.ReservationID, arg.Token)
+	return err
+}
+
+const createEquipment = `-- name: CreateEquipment :one
+INSERT INTO equipment (
+    name, quantity
+) VALUES (
+    $1, $2
+)
+RETURNING id
+`
+
+type CreateEquipmentParams struct {
+	Name     string `json:"name"`
+	Quantity int32  `json:"quantity"`
+}
+
+func (q *Queries) CreateEquipment(ctx context.Context, arg CreateEquipmentParams) (uint64, error) {
+	row := q.db.QueryRowContext(ctx, createEquipment, arg.Name, arg.Quantity)
+	var id uint64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const createReservation = `-- name: CreateReservation :one
+INSERT INTO reservations (
+    user_id, title, description, headcount, visibility, start_time, end_time, status
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, 'confirmed'
+)
+RETURNING id
+`
+
+type CreateReservationParams struct {
+	UserID      uint64    `json:"user_id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Headcount   int32     `json:"headcount"`
+	Visibility  string    `json:"visibility"`
+	StartTime   time.Time `json:"start_time"`
+	EndTime     time.Time `json:"end_time"`
+}
+
+func (q *Queries) CreateReservation(ctx context.Context, arg CreateReservationParams) (uint64, error) {
+	row := q.db.QueryRowContext(ctx, createReservation,
+		arg.UserID,
+		arg.Title,
+		arg.Description,
+		arg.Headcount,
+		arg.Visibility,
+		arg.StartTime,
+		arg.EndTime,
+	)
+	var id uint64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const createReservationEvent = `-- name: CreateReservationEvent :exec
+INSERT INTO reservation_events (
+    reservation_id, actor_user_id, action, before_data, after_data, client_ip, auth_method
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7
+)
+`
+
+type CreateReservationEventParams struct {
+	ReservationID uint64          `json:"reservation_id"`
+	ActorUserID   sql.NullInt64   `json:"actor_user_id"`
+	Action        string          `json:"action"`
+	BeforeData    json.RawMessage `json:"before_data"`
+	AfterData     json.RawMessage `json:"after_data"`
+	ClientIp      sql.NullString  `json:"client_ip"`
+	AuthMethod    string          `json:"auth_method"`
+}
+
+func (q *Queries) CreateReservationEvent(ctx context.Context, arg CreateReservationEventParams) error {
+	_, err := q.db.ExecContext(ctx, createReservationEvent,
+		arg.ReservationID,
+		arg.ActorUserID,
+		arg.Action,
+		arg.BeforeData,
+		arg.AfterData,
+		arg.ClientIp,
+		arg.AuthMethod,
+	)
+	return err
+}
+
+const createReservationFromCaldav = `-- name: CreateReservationFromCaldav :one
+INSERT INTO reservations (
+    user_id, title, start_time, end_time, status, origin, ical_uid, caldav_name
+) VALUES (
+    $1, $2, $3, $4, 'confirmed', 'caldav', $5, $6
+)
+RETURNING id
+`
+
+type CreateReservationFromCaldavParams struct {
+	UserID     uint64         `json:"user_id"`
+	Title      string         `json:"title"`
+	StartTime  time.Time      `json:"start_time"`
+	EndTime    time.Time      `json:"end_time"`
+	IcalUid    sql.NullString `json:"ical_uid"`
+	CaldavName sql.NullString `json:"caldav_name"`
+}
+
+func (q *Queries) CreateReservationFromCaldav(ctx context.Context, arg CreateReservationFromCaldavParams) (uint64, error) {
+	row := q.db.QueryRowContext(ctx, createReservationFromCaldav,
+		arg.UserID,
+		arg.Title,
+		arg.StartTime,
+		arg.EndTime,
+		arg.IcalUid,
+		arg.CaldavName,
+	)
+	var id uint64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const createReservationFromCalendar = `-- name: CreateReservationFromCalendar :one
+INSERT INTO reservations (
+    user_id, title, start_time, end_time, status, origin, google_event_id
+) VALUES (
+    $1, $2, $3, $4, 'confirmed', 'google_calendar', $5
+)
+RETURNING id
+`
+
+type CreateReservationFromCalendarParams struct {
+	UserID        uint64         `json:"user_id"`
+	Title         string         `json:"title"`
+	StartTime     time.Time      `json:"start_time"`
+	EndTime       time.Time      `json:"end_time"`
+	GoogleEventID sql.NullString `json:"google_event_id"`
+}
+
+func (q *Queries) CreateReservationFromCalendar(ctx context.Context, arg CreateReservationFromCalendarParams) (uint64, error) {
+	row := q.db.QueryRowContext(ctx, createReservationFromCalendar,
+		arg.UserID,
+		arg.Title,
+		arg.StartTime,
+		arg.EndTime,
+		arg.GoogleEventID,
+	)
+	var id uint64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const createUser = `-- name: CreateUser :one
+
+INSERT INTO users (
+    name, email, google_id, avatar_url, role
+) VALUES (
+    $1, $2, $3, $4, $5
+)
+RETURNING id
+`
+
+type CreateUserParams struct {
+	Name      string         `json:"name"`
+	Email     string         `json:"email"`
+	GoogleID  string         `json:"google_id"`
+	AvatarUrl sql.NullString `json:"avatar_url"`
+	Role      string         `json:"role"`
+}
+
+// PostgreSQL 用のクエリ (db/query.sql と同じ名前・同じ引数の順番にしてください)
+// LAST_INSERT_ID() の代わりに RETURNING id で作成した行のIDを返します。
+func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (uint64, error) {
+	row := q.db.QueryRowContext(ctx, createUser,
+		arg.Name,
+		arg.Email,
+		arg.GoogleID,
+		arg.AvatarUrl,
+		arg.Role,
+	)
+	var id uint64
+	err := row.Scan(&id)
+	return id, err
+}
+
+const deleteReservationAttendee = `-- name: DeleteReservationAttendee :exec
+DELETE FROM reservation_attendees
+WHERE reservation_id = $1 AND user_id = $2
+`
+
+type DeleteReservationAttendeeParams struct {
+	ReservationID uint64 `json:"reservation_id"`
+	UserID        uint64 `json:"user_id"`
+}
+
+func (q *Queries) DeleteReservationAttendee(ctx context.Context, arg DeleteReservationAttendeeParams) error {
+	_, err := q.db.ExecContext(ctx, deleteReservationAttendee, arg.ReservationID, arg.UserID)
+	return err
+}
+
+const deleteReservationEquipment = `-- name: DeleteReservationEquipment :exec
+DELETE FROM reservation_equipment
+WHERE reservation_id = $1
+`
+
+func (q *Queries) DeleteReservationEquipment(ctx context.Context, reservationID uint64) error {
+	_, err := q.db.ExecContext(ctx, deleteReservationEquipment, reservationID)
+	return err
+}
+
+const endReservationEarly = `-- name: EndReservationEarly :exec
+UPDATE reservations
+SET
+  booked_end_time = COALESCE(booked_end_time, end_time),
+  end_time = $1,
+  actual_end_time = $2,
+  updated_at = CURRENT_TIMESTAMP
+WHERE id = $3
+`
+
+type EndReservationEarlyParams struct {
+	EndTime       time.Time    `json:"end_time"`
+	ActualEndTime sql.NullTime `json:"actual_end_time"`
+	ID            uint64       `json:"id"`
+}
+
+func (q *Queries) EndReservationEarly(ctx context.Context, arg EndReservationEarlyParams) error {
+	_, err := q.db.ExecContext(ctx, endReservationEarly, arg.EndTime, arg.ActualEndTime, arg.ID)
+	return err
+}
+
+const extendReservation = `-- name: ExtendReservation :exec
+UPDATE reservations
+SET
+  booked_end_time = COALESCE(booked_end_time, end_time),
+  end_time = $1,
+  updated_at = CURRENT_TIMESTAMP
+WHERE id = $2
+`
+
+type ExtendReservationParams struct {
+	EndTime time.Time `json:"end_time"`
+	ID      uint64    `json:"id"`
+}
+
+func (q *Queries) ExtendReservation(ctx context.Context, arg ExtendReservationParams) error {
+	_, err := q.db.ExecContext(ctx, extendReservation, arg.EndTime, arg.ID)
+	return err
+}
+
+const getCalendarSyncToken = `-- name: GetCalendarSyncToken :one
+SELECT sync_token FROM calendar_sync_states
+WHERE calendar_id = $1
+`
+
+func (q *Queries) GetCalendarSyncToken(ctx context.Context, calendarID string) (sql.NullString, error) {
+	row := q.db.QueryRowContext(ctx, getCalendarSyncToken, calendarID)
+	var sync_token sql.NullString
+	err := row.Scan(&sync_token)
+	return sync_token, err
+}
+
+const getCheckinTokenByReservationID = `-- name: GetCheckinTokenByReservationID :one
+SELECT token FROM reservation_checkin_tokens
+WHERE reservation_id = $1
+`
+
+func (q *Queries) GetCheckinTokenByReservationID(ctx context.Context, reservationID uint64) (string, error) {
+	row := q.db.QueryRowContext(ctx, getCheckinTokenByReservationID, reservationID)
+	var token string
+	err := row.Scan(&token)
+	return token, err
+}
+
+const getEquipmentByID = `-- name: GetEquipmentByID :one
+SELECT id, name, quantity, created_at, updated_at FROM equipment
+WHERE id = $1
+`
+
+func (q *Queries) GetEquipmentByID(ctx context.Context, id uint64) (Equipment, error) {
+	row := q.db.QueryRowContext(ctx, getEquipmentByID, id)
+	var i Equipment
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Quantity,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getEquipmentByIDForUpdate = `-- name: GetEquipmentByIDForUpdate :one
+SELECT id, name, quantity, created_at, updated_at FROM equipment
+WHERE id = $1
+FOR UPDATE
+`
+
+// 同じ備品を同時に予約して数量を超えないよう、空きの確認から登録までの間ロックする
+func (q *Queries) GetEquipmentByIDForUpdate(ctx context.Context, id uint64) (Equipment, error) {
+	row := q.db.QueryRowContext(ctx, getEquipmentByIDForUpdate, id)
+	var i Equipment
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Quantity,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getReservationByCaldavName = `-- name: GetReservationByCaldavName :one
+SELECT id, user_id, title, start_time, end_time, status, checked_in_at, actual_end_time, booked_end_time, origin, google_event_id, ical_uid, caldav_name, created_at, updated_at, description, headcount, visibility FROM reservations
+WHERE status = 'confirmed'
+  AND caldav_name = $1
+`
+
+func (q *Queries) GetReservationByCaldavName(ctx context.Context, caldavName sql.NullString) (Reservation, error) {
+	row := q.db.QueryRowContext(ctx, getReservationByCaldavName, caldavName)
+	var i Reservation
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Title,
+		&i.StartTime,
+		&i.EndTime,
+		&i.Status,
+		&i.CheckedInAt,
+		&i.ActualEndTime,
+		&i.BookedEndTime,
+		&i.Origin,
+		&i.GoogleEventID,
+		&i.IcalUid,
+		&i.CaldavName,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Description,
+		&i.Headcount,
+		&i.Visibility,
+	)
+	return i, err
+}
+
+const getReservationByCheckinToken = `-- name: GetReservationByCheckinToken :one
+SELECT r.id, r.user_id, r.title, r.start_time, r.end_time, r.status, r.checked_in_at, r.actual_end_time, r.booked_end_time, r.origin, r.google_event_id, r.ical_uid, r.caldav_name, r.created_at, r.updated_at, r.description, r.headcount, r.visibility FROM reservations AS r
+JOIN reservation_checkin_tokens AS t ON t.reservation_id = r.id
+WHERE t.token = $1
+`
+
+func (q *Queries) GetReservationByCheckinToken(ctx context.Context, token string) (Reservation, error) {
+	row := q.db.QueryRowContext(ctx, getReservationByCheckinToken, token)
+	var i Reservation
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Title,
+		&i.StartTime,
+		&i.EndTime,
+		&i.Status,
+		&i.CheckedInAt,
+		&i.ActualEndTime,
+		&i.BookedEndTime,
+		&i.Origin,
+		&i.GoogleEventID,
+		&i.IcalUid,
+		&i.CaldavName,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Description,
+		&i.Headcount,
+		&i.Visibility,
+	)
+	return i, err
+}
+
+const getReservationByGoogleEventID = `-- name: GetReservationByGoogleEventID :one
+SELECT id, user_id, title, start_time, end_time, status, checked_in_at, actual_end_time, booked_end_time, origin, google_event_id, ical_uid, caldav_name, created_at, updated_at, description, headcount, visibility FROM reservations
+WHERE google_event_id = $1
+`
+
+func (q *Queries) GetReservationByGoogleEventID(ctx context.Context, googleEventID sql.NullString) (Reservation, error) {
+	row := q.db.QueryRowContext(ctx, getReservationByGoogleEventID, googleEventID)
+	var i Reservation
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Title,
+		&i.StartTime,
+		&i.EndTime,
+		&i.Status,
+		&i.CheckedInAt,
+		&i.ActualEndTime,
+		&i.BookedEndTime,
+		&i.Origin,
+		&i.GoogleEventID,
+		&i.IcalUid,
+		&i.CaldavName,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Description,
+		&i.Headcount,
+		&i.Visibility,
+	)
+	return i, err
+}
+
+const getReservationByID = `-- name: GetReservationByID :one
+SELECT id, user_id, title, start_time, end_time, status, checked_in_at, actual_end_time, booked_end_time, origin, google_event_id, ical_uid, caldav_name, created_at, updated_at, description, headcount, visibility FROM reservations
+WHERE id = $1
+`
+
+func (q *Queries) GetReservationByID(ctx context.Context, id uint64) (Reservation, error) {
+	row := q.db.QueryRowContext(ctx, getReservationByID, id)
+	var i Reservation
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Title,
+		&i.StartTime,
+		&i.EndTime,
+		&i.Status,
+		&i.CheckedInAt,
+		&i.ActualEndTime,
+		&i.BookedEndTime,
+		&i.Origin,
+		&i.GoogleEventID,
+		&i.IcalUid,
+		&i.CaldavName,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Description,
+		&i.Headcount,
+		&i.Visibility,
+	)
+	return i, err
+}
+
+const getReservationByIDForUpdate = `-- name: GetReservationByIDForUpdate :one
+SELECT id, user_id, title, start_time, end_time, status, checked_in_at, actual_end_time, booked_end_time, origin, google_event_id, ical_uid, caldav_name, created_at, updated_at, description, headcount, visibility FROM reservations
+WHERE id = $1
+FOR UPDATE
+`
+
+func (q *Queries) GetReservationByIDForUpdate(ctx context.Context, id uint64) (Reservation, error) {
+	row := q.db.QueryRowContext(ctx, getReservationByIDForUpdate, id)
+	var i Reservation
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Title,
+		&i.StartTime,
+		&i.EndTime,
+		&i.Status,
+		&i.CheckedInAt,
+		&i.ActualEndTime,
+		&i.BookedEndTime,
+		&i.Origin,
+		&i.GoogleEventID,
+		&i.IcalUid,
+		&i.CaldavName,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Description,
+		&i.Headcount,
+		&i.Visibility,
+	)
+	return i, err
+}
+
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT id, name, email, google_id, avatar_url, role, caldav_token_hash, created_at, updated_at, deleted_at, language FROM users
+WHERE email = $1
+  AND deleted_at IS NULL
+`
+
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByEmail, email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Email,
+		&i.GoogleID,
+		&i.AvatarUrl,
+		&i.Role,
+		&i.CaldavTokenHash,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.Language,
+	)
+	return i, err
+}
+
+const getUserByGoogleID = `-- name: GetUserByGoogleID :one
+SELECT id, name, email, google_id, avatar_url, role, caldav_token_hash, created_at, updated_at, deleted_at, language FROM users
+WHERE google_id = $1
+  AND deleted_at IS NULL
+`
+
+func (q *Queries) GetUserByGoogleID(ctx context.Context, googleID string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByGoogleID, googleID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Email,
+		&i.GoogleID,
+		&i.AvatarUrl,
+		&i.Role,
+		&i.CaldavTokenHash,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.Language,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, name, email, google_id, avatar_url, role, caldav_token_hash, created_at, updated_at, deleted_at, language FROM users
+WHERE id = $1
+  AND deleted_at IS NULL
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uint64) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Email,
+		&i.GoogleID,
+		&i.AvatarUrl,
+		&i.Role,
+		&i.CaldavTokenHash,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.Language,
+	)
+	return i, err
+}
+
+const listBusyIntervals = `-- name: ListBusyIntervals :many
+SELECT start_time, end_time FROM reservations
+WHERE status = 'confirmed'
+  AND start_time < $1
+  AND end_time > $2
+ORDER BY start_time
+`
+
+type ListBusyIntervalsParams struct {
+	RangeEnd   time.Time `json:"range_end"`
+	RangeStart time.Time `json:"range_start"`
+}
+
+type ListBusyIntervalsRow struct {
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+}
+
+func (q *Queries) ListBusyIntervals(ctx context.Context, arg ListBusyIntervalsParams) ([]ListBusyIntervalsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listBusyIntervals, arg.RangeEnd, arg.RangeStart)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListBusyIntervalsRow
+	for rows.Next() {
+		var i ListBusyIntervalsRow
+		if err := rows.Scan(&i.StartTime, &i.EndTime); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listConfirmedReservations = `-- name: ListConfirmedReservations :many
+SELECT id, user_id, title, start_time, end_time, status, checked_in_at, actual_end_time, booked_end_time, origin, google_event_id, ical_uid, caldav_name, created_at, updated_at, description, headcount, visibility FROM reservations
+WHERE status = 'confirmed'
+ORDER BY start_time
+`
+
+func (q *Queries) ListConfirmedReservations(ctx context.Context) ([]Reservation, error) {
+	rows, err := q.db.QueryContext(ctx, listConfirmedReservations)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Reservation
+	for rows.Next() {
+		var i Reservation
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Title,
+			&i.StartTime,
+			&i.EndTime,
+			&i.Status,
+			&i.CheckedInAt,
+			&i.ActualEndTime,
+			&i.BookedEndTime,
+			&i.Origin,
+			&i.GoogleEventID,
+			&i.IcalUid,
+			&i.CaldavName,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Description,
+			&i.Headcount,
+			&i.Visibility,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listEquipment = `-- name: ListEquipment :many
+SELECT id, name, quantity, created_at, updated_at FROM equipment
+ORDER BY id
+`
+
+func (q *Queries) ListEquipment(ctx context.Context) ([]Equipment, error) {
+	rows, err := q.db.QueryContext(ctx, listEquipment)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Equipment
+	for rows.Next() {
+		var i Equipment
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Quantity,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listEquipmentUsage = `-- name: ListEquipmentUsage :many
+SELECT re.reservation_id, re.equipment_id, re.quantity, r.start_time, r.end_time
+FROM reservation_equipment AS re
+JOIN reservations AS r ON re.reservation_id = r.id
+WHERE r.status = 'confirmed'
+  AND r.start_time < $1
+  AND r.end_time > $2
+  AND r.id <> $3
+ORDER BY re.equipment_id, r.start_time, re.reservation_id
+`
+
+type ListEquipmentUsageParams struct {
+	RangeEnd   time.Time `json:"range_end"`
+	RangeStart time.Time `json:"range_start"`
+	ExcludeID  uint64    `json:"exclude_id"`
+}
+
+type ListEquipmentUsageRow struct {
+	ReservationID uint64    `json:"reservation_id"`
+	EquipmentID   uint64    `json:"equipment_id"`
+	Quantity      int32     `json:"quantity"`
+	StartTime     time.Time `json:"start_time"`
+	EndTime       time.Time `json:"end_time"`
+}
+
+// 期間に重なる確定済みの予約が借りている備品 (exclude_id の予約を除く)
+func (q *Queries) ListEquipmentUsage(ctx context.Context, arg ListEquipmentUsageParams) ([]ListEquipmentUsageRow, error) {
+	rows, err := q.db.QueryContext(ctx, listEquipmentUsage, arg.RangeEnd, arg.RangeStart, arg.ExcludeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListEquipmentUsageRow
+	for rows.Next() {
+		var i ListEquipmentUsageRow
+		if err := rows.Scan(
+			&i.ReservationID,
+			&i.EquipmentID,
+			&i.Quantity,
+			&i.StartTime,
+			&i.EndTime,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listInvitationsByUserID = `-- name: ListInvitationsByUserID :many
+SELECT r.id, r.user_id, r.title, r.start_time, r.end_time, r.status, r.checked_in_at, r.actual_end_time, r.booked_end_time, r.origin, r.google_event_id, r.ical_uid, r.caldav_name, r.created_at, r.updated_at, r.description, r.headcount, r.visibility, u.name AS user_name, a.response, a.responded_at
+FROM reservation_attendees AS a
+JOIN reservations AS r ON a.reservation_id = r.id
+JOIN users AS u ON r.user_id = u.id AND u.deleted_at IS NULL
+WHERE a.user_id = $1 AND r.status = 'confirmed' AND r.end_time >= $2
+ORDER BY r.start_time, r.id
+`
+
+type ListInvitationsByUserIDParams struct {
+	UserID uint64    `json:"user_id"`
+	After  time.Time `json:"after"`
+}
+
+type ListInvitationsByUserIDRow struct {
+	ID            uint64         `json:"id"`
+	UserID        uint64         `json:"user_id"`
+	Title         string         `json:"title"`
+	StartTime     time.Time      `json:"start_time"`
+	EndTime       time.Time      `json:"end_time"`
+	Status        string         `json:"status"`
+	CheckedInAt   sql.NullTime   `json:"checked_in_at"`
+	ActualEndTime sql.NullTime   `json:"actual_end_time"`
+	BookedEndTime sql.NullTime   `json:"booked_end_time"`
+	Origin        string         `json:"origin"`
+	GoogleEventID sql.NullString `json:"google_event_id"`
+	IcalUid       sql.NullString `json:"ical_uid"`
+	CaldavName    sql.NullString `json:"caldav_name"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	Description   string         `json:"description"`
+	Headcount     int32          `json:"headcount"`
+	Visibility    string         `json:"visibility"`
+	UserName      string         `json:"user_name"`
+	Response      string         `json:"response"`
+	RespondedAt   sql.NullTime   `json:"responded_at"`
+}
+
+// 終了していない確定済みの予約への招待 (予約者の名前と返答付き)
+func (q *Queries) ListInvitationsByUserID(ctx context.Context, arg ListInvitationsByUserIDParams) ([]ListInvitationsByUserIDRow, error) {
+	rows, err := q.db.QueryContext(ctx, listInvitationsByUserID, arg.UserID, arg.After)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListInvitationsByUserIDRow
+	for rows.Next() {
+		var i ListInvitationsByUserIDRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Title,
+			&i.StartTime,
+			&i.EndTime,
+			&i.Status,
+			&i.CheckedInAt,
+			&i.ActualEndTime,
+			&i.BookedEndTime,
+			&i.Origin,
+			&i.GoogleEventID,
+			&i.IcalUid,
+			&i.CaldavName,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Description,
+			&i.Headcount,
+			&i.Visibility,
+			&i.UserName,
+			&i.Response,
+			&i.RespondedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listNoShowCandidates = `-- name: ListNoShowCandidates :many
+SELECT id, user_id, title, start_time, end_time, status, checked_in_at, actual_end_time, booked_end_time, origin, google_event_id, ical_uid, caldav_name, created_at, updated_at, description, headcount, visibility FROM reservations
+WHERE status = 'confirmed'
+  AND checked_in_at IS NULL
+  AND actual_end_time IS NULL
+  AND start_time <= $1
+  AND start_time > $2
+ORDER BY start_time
+`
+
+type ListNoShowCandidatesParams struct {
+	StartedBefore time.Time `json:"started_before"`
+	StartedAfter  time.Time `json:"started_after"`
+}
+
+func (q *Queries) ListNoShowCandidates(ctx context.Context, arg ListNoShowCandidatesParams) ([]Reservation, error) {
+	rows, err := q.db.QueryContext(ctx, listNoShowCandidates, arg.StartedBefore, arg.StartedAfter)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Reservation
+	for rows.Next() {
+		var i Reservation
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Title,
+			&i.StartTime,
+			&i.EndTime,
+			&i.Status,
+			&i.CheckedInAt,
+			&i.ActualEndTime,
+			&i.BookedEndTime,
+			&i.Origin,
+			&i.GoogleEventID,
+			&i.IcalUid,
+			&i.CaldavName,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Description,
+			&i.Headcount,
+			&i.Visibility,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listOverlappingReservationIDsForUpdate = `-- name: ListOverlappingReservationIDsForUpdate :many
+SELECT id FROM reservations
+WHERE status = 'confirmed'
+  AND start_time < $1
+  AND end_time > $2
+  AND id <> $3
+ORDER BY id
+FOR UPDATE
+`
+
+type ListOverlappingReservationIDsForUpdateParams struct {
+	RangeEnd   time.Time `json:"range_end"`
+	RangeStart time.Time `json:"range_start"`
+	ExcludeID  uint64    `json:"exclude_id"`
+}
+
+// 時間帯が重なる確定済みの予約の行をロックして ID を返す (exclude_id の予約を除く)
+// PostgreSQL は集約関数と FOR UPDATE を組み合わせられないため、件数は呼び出し側で数える
+func (q *Queries) ListOverlappingReservationIDsForUpdate(ctx context.Context, arg ListOverlappingReservationIDsForUpdateParams) ([]uint64, error) {
+	rows, err := q.db.QueryContext(ctx, listOverlappingReservationIDsForUpdate, arg.RangeEnd, arg.RangeStart, arg.ExcludeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uint64
+	for rows.Next() {
+		var id uint64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listReservationAttendees = `-- name: ListReservationAttendees :many
+SELECT a.reservation_id, a.user_id, u.name, a.response, a.responded_at
+FROM reservation_attendees AS a
+JOIN users AS u ON a.user_id = u.id AND u.deleted_at IS NULL
+WHERE a.reservation_id = ANY(string_to_array($1::text, ',')::bigint[])
+ORDER BY a.reservation_id, a.user_id
+`
+
+type ListReservationAttendeesRow struct {
+	ReservationID uint64       `json:"reservation_id"`
+	UserID        uint64       `json:"user_id"`
+	Name          string       `json:"name"`
+	Response      string       `json:"response"`
+	RespondedAt   sql.NullTime `json:"responded_at"`
+}
+
+// lib/pq を使わずに配列を渡すため、IDはカンマ区切りの文字列で受け取る
+func (q *Queries) ListReservationAttendees(ctx context.Context, reservationIds string) ([]ListReservationAttendeesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listReservationAttendees, reservationIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListReservationAttendeesRow
+	for rows.Next() {
+		var i ListReservationAttendeesRow
+		if err := rows.Scan(
+			&i.ReservationID,
+			&i.UserID,
+			&i.Name,
+			&i.Response,
+			&i.RespondedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listReservationEquipment = `-- name: ListReservationEquipment :many
+SELECT re.reservation_id, re.equipment_id, e.name, re.quantity
+FROM reservation_equipment AS re
+JOIN equipment AS e ON re.equipment_id = e.id
+WHERE re.reservation_id = ANY(string_to_array($1::text, ',')::bigint[])
+ORDER BY re.reservation_id, re.equipment_id
+`
+
+type ListReservationEquipmentRow struct {
+	ReservationID uint64 `json:"reservation_id"`
+	EquipmentID   uint64 `json:"equipment_id"`
+	Name          string `json:"name"`
+	Quantity      int32  `json:"quantity"`
+}
+
+// lib/pq を使わずに配列を渡すため、IDはカンマ区切りの文字列で受け取る
+func (q *Queries) ListReservationEquipment(ctx context.Context, reservationIds string) ([]ListReservationEquipmentRow, error) {
+	rows, err := q.db.QueryContext(ctx, listReservationEquipment, reservationIds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListReservationEquipmentRow
+	for rows.Next() {
+		var i ListReservationEquipmentRow
+		if err := rows.Scan(
+			&i.ReservationID,
+			&i.EquipmentID,
+			&i.Name,
+			&i.Quantity,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listReservationEventsByReservationID = `-- name: ListReservationEventsByReservationID :many
+SELECT e.id, e.reservation_id, e.actor_user_id, e.action, e.before_data, e.after_data, e.client_ip, e.auth_method, e.created_at, u.name as actor_name
+FROM reservation_events AS e
+LEFT JOIN users AS u ON e.actor_user_id = u.id
+WHERE e.reservation_id = $1
+ORDER BY e.id
+`
+
+type ListReservationEventsByReservationIDRow struct {
+	ID            uint64          `json:"id"`
+	ReservationID uint64          `json:"reservation_id"`
+	ActorUserID   sql.NullInt64   `json:"actor_user_id"`
+	Action        string          `json:"action"`
+	BeforeData    json.RawMessage `json:"before_data"`
+	AfterData     json.RawMessage `json:"after_data"`
+	ClientIp      sql.NullString  `json:"client_ip"`
+	AuthMethod    string          `json:"auth_method"`
+	CreatedAt     time.Time       `json:"created_at"`
+	ActorName     sql.NullString  `json:"actor_name"`
+}
+
+func (q *Queries) ListReservationEventsByReservationID(ctx context.Context, reservationID uint64) ([]ListReservationEventsByReservationIDRow, error) {
+	rows, err := q.db.QueryContext(ctx, listReservationEventsByReservationID, reservationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListReservationEventsByReservationIDRow
+	for rows.Next() {
+		var i ListReservationEventsByReservationIDRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.ReservationID,
+			&i.ActorUserID,
+			&i.Action,
+			&i.BeforeData,
+			&i.AfterData,
+			&i.ClientIp,
+			&i.AuthMethod,
+			&i.CreatedAt,
+			&i.ActorName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listReservationsByDate = `-- name: ListReservationsByDate :many
+SELECT r.id, r.user_id, r.title, r.start_time, r.end_time, r.status, r.checked_in_at, r.actual_end_time, r.booked_end_time, r.origin, r.google_event_id, r.ical_uid, r.caldav_name, r.created_at, r.updated_at, r.description, r.headcount, r.visibility, u.name as user_name
+FROM reservations AS r
+JOIN users AS u ON r.user_id = u.id AND u.deleted_at IS NULL
+WHERE
+  r.status = 'confirmed'
+  AND r.start_time < $1 
+  AND r.end_time >= $2 
+ORDER BY
+  r.start_time
+`
+
+type ListReservationsByDateParams struct {
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+}
+
+type ListReservationsByDateRow struct {
+	ID            uint64         `json:"id"`
+	UserID        uint64         `json:"user_id"`
+	Title         string         `json:"title"`
+	StartTime     time.Time      `json:"start_time"`
+	EndTime       time.Time      `json:"end_time"`
+	Status        string         `json:"status"`
+	CheckedInAt   sql.NullTime   `json:"checked_in_at"`
+	ActualEndTime sql.NullTime   `json:"actual_end_time"`
+	BookedEndTime sql.NullTime   `json:"booked_end_time"`
+	Origin        string         `json:"origin"`
+	GoogleEventID sql.NullString `json:"google_event_id"`
+	IcalUid       sql.NullString `json:"ical_uid"`
+	CaldavName    sql.NullString `json:"caldav_name"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	Description   string         `json:"description"`
+	Headcount     int32          `json:"headcount"`
+	Visibility    string         `json:"visibility"`
+	UserName      string         `json:"user_name"`
+}
+
+func (q *Queries) ListReservationsByDate(ctx context.Context, arg ListReservationsByDateParams) ([]ListReservationsByDateRow, error) {
+	rows, err := q.db.QueryContext(ctx, listReservationsByDate, arg.StartTime, arg.EndTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListReservationsByDateRow
+	for rows.Next() {
+		var i ListReservationsByDateRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Title,
+			&i.StartTime,
+			&i.EndTime,
+			&i.Status,
+			&i.CheckedInAt,
+			&i.ActualEndTime,
+			&i.BookedEndTime,
+			&i.Origin,
+			&i.GoogleEventID,
+			&i.IcalUid,
+			&i.CaldavName,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Description,
+			&i.Headcount,
+			&i.Visibility,
+			&i.UserName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listReservationsByUserID = `-- name: ListReservationsByUserID :many
+SELECT id, user_id, title, start_time, end_time, status, checked_in_at, actual_end_time, booked_end_time, origin, google_event_id, ical_uid, caldav_name, created_at, updated_at, description, headcount, visibility FROM reservations
+WHERE status = 'confirmed'
+  AND user_id = $1
+ORDER BY start_time
+`
+
+func (q *Queries) ListReservationsByUserID(ctx context.Context, userID uint64) ([]Reservation, error) {
+	rows, err := q.db.QueryContext(ctx, listReservationsByUserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Reservation
+	for rows.Next() {
+		var i Reservation
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Title,
+			&i.StartTime,
+			&i.EndTime,
+			&i.Status,
+			&i.CheckedInAt,
+			&i.ActualEndTime,
+			&i.BookedEndTime,
+			&i.Origin,
+			&i.GoogleEventID,
+			&i.IcalUid,
+			&i.CaldavName,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Description,
+			&i.Headcount,
+			&i.Visibility,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsers = `-- name: ListUsers :many
+SELECT id, name, email, google_id, avatar_url, role, caldav_token_hash, created_at, updated_at, deleted_at, language FROM users
+WHERE deleted_at IS NULL
+ORDER BY id
+`
+
+func (q *Queries) ListUsers(ctx context.Context) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, listUsers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Email,
+			&i.GoogleID,
+			&i.AvatarUrl,
+			&i.Role,
+			&i.CaldavTokenHash,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+			&i.Language,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockRoom = `-- name: LockRoom :one
+SELECT id FROM room_locks
+WHERE id = $1
+FOR UPDATE
+`
+
+// 予約の重複チェックから登録までの間、部屋の行をロックする (同じ部屋の予約を同時に登録すると、どちらも重複なしと判定して二重に予約されるため)
+func (q *Queries) LockRoom(ctx context.Context, id string) (string, error) {
+	row := q.db.QueryRowContext(ctx, lockRoom, id)
+	err := row.Scan(&id)
+	return id, err
+}
+
+const markReservationNoShow = `-- name: MarkReservationNoShow :execresult
+UPDATE reservations
+SET status = 'no_show', updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+  AND status = 'confirmed'
+  AND checked_in_at IS NULL
+  AND actual_end_time IS NULL
+`
+
+func (q *Queries) MarkReservationNoShow(ctx context.Context, id uint64) (sql.Result, error) {
+	return q.db.ExecContext(ctx, markReservationNoShow, id)
+}
+
+const searchReservationEvents = `-- name: SearchReservationEvents :many
+SELECT e.id, e.reservation_id, e.actor_user_id, e.action, e.before_data, e.after_data, e.client_ip, e.auth_method, e.created_at, u.name as actor_name
+FROM reservation_events AS e
+LEFT JOIN users AS u ON e.actor_user_id = u.id
+WHERE
+  ($1 IS NULL OR e.actor_user_id = $1)
+  AND ($2 IS NULL OR e.reservation_id = $2)
+  AND ($3 IS NULL OR e.action = $3)
+  AND ($4 IS NULL OR e.auth_method = $4)
+  AND ($5 IS NULL OR e.created_at >= $5)
+  AND ($6 IS NULL OR e.created_at < $6)
+ORDER BY e.id DESC
+LIMIT $8::int OFFSET $7::int
+`
+
+type SearchReservationEventsParams struct {
+	ActorUserID   interface{} `json:"actor_user_id"`
+	ReservationID interface{} `json:"reservation_id"`
+	Action        interface{} `json:"action"`
+	AuthMethod    interface{} `json:"auth_method"`
+	CreatedFrom   interface{} `json:"created_from"`
+	CreatedTo     interface{} `json:"created_to"`
+	Offset        int32       `json:"offset"`
+	Limit         int32       `json:"limit"`
+}
+
+type SearchReservationEventsRow struct {
+	ID            uint64          `json:"id"`
+	ReservationID uint64          `json:"reservation_id"`
+	ActorUserID   sql.NullInt64   `json:"actor_user_id"`
+	Action        string          `json:"action"`
+	BeforeData    json.RawMessage `json:"before_data"`
+	AfterData     json.RawMessage `json:"after_data"`
+	ClientIp      sql.NullString  `json:"client_ip"`
+	AuthMethod    string          `json:"auth_method"`
+	CreatedAt     time.Time       `json:"created_at"`
+	ActorName     sql.NullString  `json:"actor_name"`
+}
+
+func (q *Queries) SearchReservationEvents(ctx context.Context, arg SearchReservationEventsParams) ([]SearchReservationEventsRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchReservationEvents,
+		arg.ActorUserID,
+		arg.ReservationID,
+		arg.Action,
+		arg.AuthMethod,
+		arg.CreatedFrom,
+		arg.CreatedTo,
+		arg.Offset,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchReservationEventsRow
+	for rows.Next() {
+		var i SearchReservationEventsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.ReservationID,
+			&i.ActorUserID,
+			&i.Action,
+			&i.BeforeData,
+			&i.AfterData,
+			&i.ClientIp,
+			&i.AuthMethod,
+			&i.CreatedAt,
+			&i.ActorName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchReservations = `-- name: SearchReservations :many
+SELECT r.id, r.user_id, r.title, r.start_time, r.end_time, r.status, r.checked_in_at, r.actual_end_time, r.booked_end_time, r.origin, r.google_event_id, r.ical_uid, r.caldav_name, r.created_at, r.updated_at, r.description, r.headcount, r.visibility, u.name as user_name
+FROM reservations AS r
+JOIN users AS u ON r.user_id = u.id AND u.deleted_at IS NULL
+WHERE
+  ($1 IS NULL OR r.user_id = $1)
+  -- 予約者か、参加を承諾 (未定を含む) した参加者の予約
+  AND ($2 IS NULL OR r.user_id = $2 OR EXISTS (
+    SELECT 1 FROM reservation_attendees AS m
+    WHERE m.reservation_id = r.id AND m.user_id = $2 AND m.response IN ('accepted', 'tentative')
+  ))
+  AND ($3 IS NULL OR r.status = $3)
+  AND ($4 IS NULL OR (strpos(lower(r.title), lower($4)) > 0 AND (
+    -- タイトルで絞り込む場合は、閲覧者がタイトルを見られる予約だけを対象にする
+    r.visibility = 'public'
+    OR ($5 IS NOT NULL AND r.visibility = 'members')
+    OR r.user_id = $5
+    OR EXISTS (
+      SELECT 1 FROM reservation_attendees AS a
+      WHERE a.reservation_id = r.id AND a.user_id = $5
+    )
+  )))
+  AND ($6 IS NULL OR EXISTS (
+    SELECT 1 FROM reservation_events AS e
+    WHERE e.reservation_id = r.id AND e.action = 'created' AND e.actor_user_id = $6
+  ))
+  AND ($7 IS NULL OR r.end_time >= $7)
+  AND ($8 IS NULL OR r.start_time < $8)
+  AND ($9 IS NULL
+    OR r.start_time > $9
+    OR (r.start_time = $9 AND r.id > $10))
+ORDER BY r.start_time, r.id
+LIMIT $11::int
+`
+
+type SearchReservationsParams struct {
+	UserID          interface{} `json:"user_id"`
+	MemberID        interface{} `json:"member_id"`
+	Status          interface{} `json:"status"`
+	Title           interface{} `json:"title"`
+	ViewerID        interface{} `json:"viewer_id"`
+	CreatedBy       interface{} `json:"created_by"`
+	RangeStart      interface{} `json:"range_start"`
+	RangeEnd        interface{} `json:"range_end"`
+	CursorStartTime interface{} `json:"cursor_start_time"`
+	CursorID        uint64      `json:"cursor_id"`
+	Limit           int32       `json:"limit"`
+}
+
+type SearchReservationsRow struct {
+	ID            uint64         `json:"id"`
+	UserID        uint64         `json:"user_id"`
+	Title         string         `json:"title"`
+	StartTime     time.Time      `json:"start_time"`
+	EndTime       time.Time      `json:"end_time"`
+	Status        string         `json:"status"`
+	CheckedInAt   sql.NullTime   `json:"checked_in_at"`
+	ActualEndTime sql.NullTime   `json:"actual_end_time"`
+	BookedEndTime sql.NullTime   `json:"booked_end_time"`
+	Origin        string         `json:"origin"`
+	GoogleEventID sql.NullString `json:"google_event_id"`
+	IcalUid       sql.NullString `json:"ical_uid"`
+	CaldavName    sql.NullString `json:"caldav_name"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	Description   string         `json:"description"`
+	Headcount     int32          `json:"headcount"`
+	Visibility    string         `json:"visibility"`
+	UserName      string         `json:"user_name"`
+}
+
+func (q *Queries) SearchReservations(ctx context.Context, arg SearchReservationsParams) ([]SearchReservationsRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchReservations,
+		arg.UserID,
+		arg.MemberID,
+		arg.Status,
+		arg.Title,
+		arg.ViewerID,
+		arg.CreatedBy,
+		arg.RangeStart,
+		arg.RangeEnd,
+		arg.CursorStartTime,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchReservationsRow
+	for rows.Next() {
+		var i SearchReservationsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Title,
+			&i.StartTime,
+			&i.EndTime,
+			&i.Status,
+			&i.CheckedInAt,
+			&i.ActualEndTime,
+			&i.BookedEndTime,
+			&i.Origin,
+			&i.GoogleEventID,
+			&i.IcalUid,
+			&i.CaldavName,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Description,
+			&i.Headcount,
+			&i.Visibility,
+			&i.UserName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchReservationsDesc = `-- name: SearchReservationsDesc :many
+SELECT r.id, r.user_id, r.title, r.start_time, r.end_time, r.status, r.checked_in_at, r.actual_end_time, r.booked_end_time, r.origin, r.google_event_id, r.ical_uid, r.caldav_name, r.created_at, r.updated_at, r.description, r.headcount, r.visibility, u.name as user_name
+FROM reservations AS r
+JOIN users AS u ON r.user_id = u.id AND u.deleted_at IS NULL
+WHERE
+  ($1 IS NULL OR r.user_id = $1)
+  -- 予約者か、参加を承諾 (未定を含む) した参加者の予約
+  AND ($2 IS NULL OR r.user_id = $2 OR EXISTS (
+    SELECT 1 FROM reservation_attendees AS m
+    WHERE m.reservation_id = r.id AND m.user_id = $2 AND m.response IN ('accepted', 'tentative')
+  ))
+  AND ($3 IS NULL OR r.status = $3)
+  AND ($4 IS NULL OR (strpos(lower(r.title), lower($4)) > 0 AND (
+    -- タイトルで絞り込む場合は、閲覧者がタイトルを見られる予約だけを対象にする
+    r.visibility = 'public'
+    OR ($5 IS NOT NULL AND r.visibility = 'members')
+    OR r.user_id = $5
+    OR EXISTS (
+      SELECT 1 FROM reservation_attendees AS a
+      WHERE a.reservation_id = r.id AND a.user_id = $5
+    )
+  )))
+  AND ($6 IS NULL OR EXISTS (
+    SELECT 1 FROM reservation_events AS e
+    WHERE e.reservation_id = r.id AND e.action = 'created' AND e.actor_user_id = $6
+  ))
+  AND ($7 IS NULL OR r.end_time >= $7)
+  AND ($8 IS NULL OR r.start_time < $8)
+  AND ($9 IS NULL
+    OR r.start_time < $9
+    OR (r.start_time = $9 AND r.id < $10))
+ORDER BY r.start_time DESC, r.id DESC
+LIMIT $11::int
+`
+
+type SearchReservationsDescParams struct {
+	UserID          interface{} `json:"user_id"`
+	MemberID        interface{} `json:"member_id"`
+	Status          interface{} `json:"status"`
+	Title           interface{} `json:"title"`
+	ViewerID        interface{} `json:"viewer_id"`
+	CreatedBy       interface{} `json:"created_by"`
+	RangeStart      interface{} `json:"range_start"`
+	RangeEnd        interface{} `json:"range_end"`
+	CursorStartTime interface{} `json:"cursor_start_time"`
+	CursorID        uint64      `json:"cursor_id"`
+	Limit           int32       `json:"limit"`
+}
+
+type SearchReservationsDescRow struct {
+	ID            uint64         `json:"id"`
+	UserID        uint64         `json:"user_id"`
+	Title         string         `json:"title"`
+	StartTime     time.Time      `json:"start_time"`
+	EndTime       time.Time      `json:"end_time"`
+	Status        string         `json:"status"`
+	CheckedInAt   sql.NullTime   `json:"checked_in_at"`
+	ActualEndTime sql.NullTime   `json:"actual_end_time"`
+	BookedEndTime sql.NullTime   `json:"booked_end_time"`
+	Origin        string         `json:"origin"`
+	GoogleEventID sql.NullString `json:"google_event_id"`
+	IcalUid       sql.NullString `json:"ical_uid"`
+	CaldavName    sql.NullString `json:"caldav_name"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	Description   string         `json:"description"`
+	Headcount     int32          `json:"headcount"`
+	Visibility    string         `json:"visibility"`
+	UserName      string         `json:"user_name"`
+}
+
+func (q *Queries) SearchReservationsDesc(ctx context.Context, arg SearchReservationsDescParams) ([]SearchReservationsDescRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchReservationsDesc,
+		arg.UserID,
+		arg.MemberID,
+		arg.Status,
+		arg.Title,
+		arg.ViewerID,
+		arg.CreatedBy,
+		arg.RangeStart,
+		arg.RangeEnd,
+		arg.CursorStartTime,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchReservationsDescRow
+	for rows.Next() {
+		var i SearchReservationsDescRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Title,
+			&i.StartTime,
+			&i.EndTime,
+			&i.Status,
+			&i.CheckedInAt,
+			&i.ActualEndTime,
+			&i.BookedEndTime,
+			&i.Origin,
+			&i.GoogleEventID,
+			&i.IcalUid,
+			&i.CaldavName,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Description,
+			&i.Headcount,
+			&i.Visibility,
+			&i.UserName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchReservationsFullText = `-- name: SearchReservationsFullText :many
+SELECT r.id, r.user_id, r.title, r.start_time, r.end_time, r.status, r.checked_in_at, r.actual_end_time, r.booked_end_time, r.origin, r.google_event_id, r.ical_uid, r.caldav_name, r.created_at, r.updated_at, r.description, r.headcount, r.visibility, u.name as user_name, (
+    (CASE WHEN strpos(lower(r.title), lower($1)) > 0 THEN 2 ELSE 0 END)
+    + (CASE WHEN strpos(lower(r.description), lower($1)) > 0 THEN 1 ELSE 0 END)
+  )::float8 AS score
+FROM reservations AS r
+JOIN users AS u ON r.user_id = u.id AND u.deleted_at IS NULL
+WHERE
+  (strpos(lower(r.title), lower($1)) > 0 OR strpos(lower(r.description), lower($1)) > 0)
+  -- キャンセルした予約は予約者本人だけが検索できる
+  AND (r.status <> 'canceled' OR r.user_id = $2)
+  -- 非公開の予約は予約者と参加者だけが検索できる
+  AND (r.visibility <> 'private' OR r.user_id = $2 OR EXISTS (
+    SELECT 1 FROM reservation_attendees AS a
+    WHERE a.reservation_id = r.id AND a.user_id = $2
+  ))
+ORDER BY score DESC, r.start_time DESC, r.id DESC
+LIMIT $4::int OFFSET $3::int
+`
+
+type SearchReservationsFullTextParams struct {
+	Query    string `json:"query"`
+	ViewerID uint64 `json:"viewer_id"`
+	Offset   int32  `json:"offset"`
+	Limit    int32  `json:"limit"`
+}
+
+type SearchReservationsFullTextRow struct {
+	ID            uint64         `json:"id"`
+	UserID        uint64         `json:"user_id"`
+	Title         string         `json:"title"`
+	StartTime     time.Time      `json:"start_time"`
+	EndTime       time.Time      `json:"end_time"`
+	Status        string         `json:"status"`
+	CheckedInAt   sql.NullTime   `json:"checked_in_at"`
+	ActualEndTime sql.NullTime   `json:"actual_end_time"`
+	BookedEndTime sql.NullTime   `json:"booked_end_time"`
+	Origin        string         `json:"origin"`
+	GoogleEventID sql.NullString `json:"google_event_id"`
+	IcalUid       sql.NullString `json:"ical_uid"`
+	CaldavName    sql.NullString `json:"caldav_name"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	Description   string         `json:"description"`
+	Headcount     int32          `json:"headcount"`
+	Visibility    string         `json:"visibility"`
+	UserName      string         `json:"user_name"`
+	Score         float64        `json:"score"`
+}
+
+func (q *Queries) SearchReservationsFullText(ctx context.Context, arg SearchReservationsFullTextParams) ([]SearchReservationsFullTextRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchReservationsFullText,
+		arg.Query,
+		arg.ViewerID,
+		arg.Offset,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchReservationsFullTextRow
+	for rows.Next() {
+		var i SearchReservationsFullTextRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Title,
+			&i.StartTime,
+			&i.EndTime,
+			&i.Status,
+			&i.CheckedInAt,
+			&i.ActualEndTime,
+			&i.BookedEndTime,
+			&i.Origin,
+			&i.GoogleEventID,
+			&i.IcalUid,
+			&i.CaldavName,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Description,
+			&i.Headcount,
+			&i.Visibility,
+			&i.UserName,
+			&i.Score,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setReservationGoogleEventID = `-- name: SetReservationGoogleEventID :exec
+UPDATE reservations
+SET google_event_id = $1
+WHERE id = $2
+`
+
+type SetReservationGoogleEventIDParams struct {
+	GoogleEventID sql.NullString `json:"google_event_id"`
+	ID            uint64         `json:"id"`
+}
+
+func (q *Queries) SetReservationGoogleEventID(ctx context.Context, arg SetReservationGoogleEventIDParams) error {
+	_, err := q.db.ExecContext(ctx, setReservationGoogleEventID, arg.GoogleEventID, arg.ID)
+	return err
+}
+
+const setUserCaldavTokenHash = `-- name: SetUserCaldavTokenHash :exec
+UPDATE users
+SET caldav_token_hash = $1, updated_at = CURRENT_TIMESTAMP
+WHERE id = $2
+`
+
+type SetUserCaldavTokenHashParams struct {
+	CaldavTokenHash sql.NullString `json:"caldav_token_hash"`
+	ID              uint64         `json:"id"`
+}
+
+func (q *Queries) SetUserCaldavTokenHash(ctx context.Context, arg SetUserCaldavTokenHashParams) error {
+	_, err := q.db.ExecContext(ctx, setUserCaldavTokenHash, arg.CaldavTokenHash, arg.ID)
+	return err
+}
+
+const setUserLanguage = `-- name: SetUserLanguage :exec
+UPDATE users
+SET language = $1, updated_at = CURRENT_TIMESTAMP
+WHERE id = $2
+`
+
+type SetUserLanguageParams struct {
+	Language sql.NullString `json:"language"`
+	ID       uint64         `json:"id"`
+}
+
+func (q *Queries) SetUserLanguage(ctx context.Context, arg SetUserLanguageParams) error {
+	_, err := q.db.ExecContext(ctx, setUserLanguage, arg.Language, arg.ID)
+	return err
+}
+
+const softDeleteUser = `-- name: SoftDeleteUser :exec
+UPDATE users
+SET deleted_at = CURRENT_TIMESTAMP
+WHERE id = $1
+`
+
+func (q *Queries) SoftDeleteUser(ctx context.Context, id uint64) error {
+	_, err := q.db.ExecContext(ctx, softDeleteUser, id)
+	return err
+}
+
+const updateEquipment = `-- name: UpdateEquipment :exec
+UPDATE equipment
+SET name = $1, quantity = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $3
+`
+
+type UpdateEquipmentParams struct {
+	Name     string `json:"name"`
+	Quantity int32  `json:"quantity"`
+	ID       uint64 `json:"id"`
+}
+
+func (q *Queries) UpdateEquipment(ctx context.Context, arg UpdateEquipmentParams) error {
+	_, err := q.db.ExecContext(ctx, updateEquipment, arg.Name, arg.Quantity, arg.ID)
+	return err
+}
+
+const updateReservationAttendeeResponse = `-- name: UpdateReservationAttendeeResponse :exec
+UPDATE reservation_attendees
+SET response = $1, responded_at = $2
+WHERE reservation_id = $3 AND user_id = $4
+`
+
+type UpdateReservationAttendeeResponseParams struct {
+	Response      string       `json:"response"`
+	RespondedAt   sql.NullTime `json:"responded_at"`
+	ReservationID uint64       `json:"reservation_id"`
+	UserID        uint64       `json:"user_id"`
+}
+
+func (q *Queries) UpdateReservationAttendeeResponse(ctx context.Context, arg UpdateReservationAttendeeResponseParams) error {
+	_, err := q.db.ExecContext(ctx, updateReservationAttendeeResponse,
+		arg.Response,
+		arg.RespondedAt,
+		arg.ReservationID,
+		arg.UserID,
+	)
+	return err
+}
+
+const updateReservationByID = `-- name: UpdateReservationByID :exec
+UPDATE reservations
+SET title = $1, description = $2, headcount = $3, visibility = $4, start_time = $5, end_time = $6, updated_at = CURRENT_TIMESTAMP
+WHERE id = $7
+`
+
+type UpdateReservationByIDParams struct {
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Headcount   int32     `json:"headcount"`
+	Visibility  string    `json:"visibility"`
+	StartTime   time.Time `json:"start_time"`
+	EndTime     time.Time `json:"end_time"`
+	ID          uint64    `json:"id"`
+}
+
+func (q *Queries) UpdateReservationByID(ctx context.Context, arg UpdateReservationByIDParams) error {
+	_, err := q.db.ExecContext(ctx, updateReservationByID,
+		arg.Title,
+		arg.Description,
+		arg.Headcount,
+		arg.Visibility,
+		arg.StartTime,
+		arg.EndTime,
+		arg.ID,
+	)
+	return err
+}
+
+const upsertCalendarSyncToken = `-- name: UpsertCalendarSyncToken :exec
+INSERT INTO calendar_sync_states (
+    calendar_id, sync_token
+) VALUES (
+    $1, $2
+)
+ON CONFLICT (calendar_id) DO UPDATE SET
+  sync_token = excluded.sync_token,
+  updated_at = CURRENT_TIMESTAMP
+`
+
+type UpsertCalendarSyncTokenParams struct {
+	CalendarID string         `json:"calendar_id"`
+	SyncToken  sql.NullString `json:"sync_token"`
+}
+
+func (q *Queries) UpsertCalendarSyncToken(ctx context.Context, arg UpsertCalendarSyncTokenParams) error {
+	_, err := q.db.ExecContext(ctx, upsertCalendarSyncToken, arg.CalendarID, arg.SyncToken)
+	return err
+}
@@ -8,9 +8,48 @@ package db
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"strings"
 	"time"
 )
 
+const addReservationAttendee = `-- name: AddReservationAttendee :exec
+INSERT INTO reservation_attendees (
+    reservation_id, user_id
+) VALUES (
+    ?, ?
+)
+`
+
+type AddReservationAttendeeParams struct {
+	ReservationID uint64 `json:"reservation_id"`
+	UserID        uint64 `json:"user_id"`
+}
+
+func (q *Queries) AddReservationAttendee(ctx context.Context, arg AddReservationAttendeeParams) error {
+	_, err := q.db.ExecContext(ctx, addReservationAttendee, arg.ReservationID, arg.UserID)
+	return err
+}
+
+const addReservationEquipment = `-- name: AddReservationEquipment :exec
+INSERT INTO reservation_equipment (
+    reservation_id, equipment_id, quantity
+) VALUES (
+    ?, ?, ?
+)
+`
+
+type AddReservationEquipmentParams struct {
+	ReservationID uint64 `json:"reservation_id"`
+	EquipmentID   uint64 `json:"equipment_id"`
+	Quantity      int32  `json:"quantity"`
+}
+
+func (q *Queries) AddReservationEquipment(ctx context.Context, arg AddReservationEquipmentParams) error {
+	_, err := q.db.ExecContext(ctx, addReservationEquipment, arg.ReservationID, arg.EquipmentID, arg.Quantity)
+	return err
+}
+
 const canceledReservationByID = `-- name: CanceledReservationByID :exec
 UPDATE reservations
 SET
@@ -18,6 +57,7 @@ SET
   updated_at = CURRENT_TIMESTAMP
 WHERE
   user_id = ? AND id = ?
+  AND status = 'confirmed'
 `
 
 type CanceledReservationByIDParams struct {
@@ -30,46 +70,185 @@ func (q *Queries) CanceledReservationByID(ctx context.Context, arg CanceledReser
 	return err
 }
 
-const checkOverlappingReservation = `-- name: CheckOverlappingReservation :one
+const checkInReservation = `-- name: CheckInReservation :exec
+UPDATE reservations
+SET checked_in_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+WHERE id = ?
+  AND status = 'confirmed'
+  AND checked_in_at IS NULL
+`
+
+func (q *Queries) CheckInReservation(ctx context.Context, id uint64) error {
+	_, err := q.db.ExecContext(ctx, checkInReservation, id)
+	return err
+}
+
+const countNoShowsByUserID = `-- name: CountNoShowsByUserID :one
 SELECT COUNT(*) FROM reservations
-WHERE status = 'confirmed'
-  AND start_time < ?
-  AND end_time > ?
+WHERE user_id = ?
+  AND status = 'no_show'
+  AND start_time >= ?
 `
 
-type CheckOverlappingReservationParams struct {
+type CountNoShowsByUserIDParams struct {
+	UserID    uint64    `json:"user_id"`
 	StartTime time.Time `json:"start_time"`
-	EndTime   time.Time `json:"end_time"`
 }
 
-func (q *Queries) CheckOverlappingReservation(ctx context.Context, arg CheckOverlappingReservationParams) (int64, error) {
-	row := q.db.QueryRowContext(ctx, checkOverlappingReservation, arg.StartTime, arg.EndTime)
+func (q *Queries) CountNoShowsByUserID(ctx context.Context, arg CountNoShowsByUserIDParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countNoShowsByUserID, arg.UserID, arg.StartTime)
 	var count int64
 	err := row.Scan(&count)
 	return count, err
 }
 
+const createCheckinToken = `-- name: CreateCheckinToken :exec
+INSERT INTO reservation_checkin_tokens (
+    reservation_id, token
+) VALUES (
+    ?, ?
+)
+`
+
+type CreateCheckinTokenParams struct {
+	ReservationID uint64 `json:"reservation_id"`
+	Token         string `json:"token"`
+}
+
+func (q *Queries) CreateCheckinToken(ctx context.Context, arg CreateCheckinTokenParams) error {
+	_, err := q.db.ExecContext(ctx, createCheckinToken, arg.ReservationID, arg.Token)
+	return err
+}
+
+const createEquipment = `-- name: CreateEquipment :execresult
+INSERT INTO equipment (
+    name, quantity
+) VALUES (
+    ?, ?
+)
+`
+
+type CreateEquipmentParams struct {
+	Name     string `json:"name"`
+	Quantity int32  `json:"quantity"`
+}
+
+func (q *Queries) CreateEquipment(ctx context.Context, arg CreateEquipmentParams) (sql.Result, error) {
+	return q.db.ExecContext(ctx, createEquipment, arg.Name, arg.Quantity)
+}
+
 const createReservation = `-- name: CreateReservation :execresult
 INSERT INTO reservations (
-    user_id, title, start_time, end_time, status
+    user_id, title, description, headcount, visibility, start_time, end_time, status
 ) VALUES (
-    ?, ?, ?, ?, 'confirmed'
+    ?, ?, ?, ?, ?, ?, ?, 'confirmed'
 )
 `
 
 type CreateReservationParams struct {
-	UserID    uint64    `json:"user_id"`
-	Title     string    `json:"title"`
-	StartTime time.Time `json:"start_time"`
-	EndTime   time.Time `json:"end_time"`
+	UserID      uint64    `json:"user_id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Headcount   int32     `json:"headcount"`
+	Visibility  string    `json:"visibility"`
+	StartTime   time.Time `json:"start_time"`
+	EndTime     time.Time `json:"end_time"`
 }
 
 func (q *Queries) CreateReservation(ctx context.Context, arg CreateReservationParams) (sql.Result, error) {
 	return q.db.ExecContext(ctx, createReservation,
+		arg.UserID,
+		arg.Title,
+		arg.Description,
+		arg.Headcount,
+		arg.Visibility,
+		arg.StartTime,
+		arg.EndTime,
+	)
+}
+
+const createReservationEvent = `-- name: CreateReservationEvent :exec
+INSERT INTO reservation_events (
+    reservation_id, actor_user_id, action, before_data, after_data, client_ip, auth_method
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?
+)
+`
+
+type CreateReservationEventParams struct {
+	ReservationID uint64          `json:"reservation_id"`
+	ActorUserID   sql.NullInt64   `json:"actor_user_id"`
+	Action        string          `json:"action"`
+	BeforeData    json.RawMessage `json:"before_data"`
+	AfterData     json.RawMessage `json:"after_data"`
+	ClientIp      sql.NullString  `json:"client_ip"`
+	AuthMethod    string          `json:"auth_method"`
+}
+
+func (q *Queries) CreateReservationEvent(ctx context.Context, arg CreateReservationEventParams) error {
+	_, err := q.db.ExecContext(ctx, createReservationEvent,
+		arg.ReservationID,
+		arg.ActorUserID,
+		arg.Action,
+		arg.BeforeData,
+		arg.AfterData,
+		arg.ClientIp,
+		arg.AuthMethod,
+	)
+	return err
+}
+
+const createReservationFromCaldav = `-- name: CreateReservationFromCaldav :execresult
+INSERT INTO reservations (
+    user_id, title, start_time, end_time, status, origin, ical_uid, caldav_name
+) VALUES (
+    ?, ?, ?, ?, 'confirmed', 'caldav', ?, ?
+)
+`
+
+type CreateReservationFromCaldavParams struct {
+	UserID     uint64         `json:"user_id"`
+	Title      string         `json:"title"`
+	StartTime  time.Time      `json:"start_time"`
+	EndTime    time.Time      `json:"end_time"`
+	IcalUid    sql.NullString `json:"ical_uid"`
+	CaldavName sql.NullString `json:"caldav_name"`
+}
+
+func (q *Queries) CreateReservationFromCaldav(ctx context.Context, arg CreateReservationFromCaldavParams) (sql.Result, error) {
+	return q.db.ExecContext(ctx, createReservationFromCaldav,
+		arg.UserID,
+		arg.Title,
+		arg.StartTime,
+		arg.EndTime,
+		arg.IcalUid,
+		arg.CaldavName,
+	)
+}
+
+const createReservationFromCalendar = `-- name: CreateReservationFromCalendar :execresult
+INSERT INTO reservations (
+    user_id, title, start_time, end_time, status, origin, google_event_id
+) VALUES (
+    ?, ?, ?, ?, 'confirmed', 'google_calendar', ?
+)
+`
+
+type CreateReservationFromCalendarParams struct {
+	UserID        uint64         `json:"user_id"`
+	Title         string         `json:"title"`
+	StartTime     time.Time      `json:"start_time"`
+	EndTime       time.Time      `json:"end_time"`
+	GoogleEventID sql.NullString `json:"google_event_id"`
+}
+
+func (q *Queries) CreateReservationFromCalendar(ctx context.Context, arg CreateReservationFromCalendarParams) (sql.Result, error) {
+	return q.db.ExecContext(ctx, createReservationFromCalendar,
 		arg.UserID,
 		arg.Title,
 		arg.StartTime,
 		arg.EndTime,
+		arg.GoogleEventID,
 	)
 }
 
@@ -99,24 +278,230 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (sql.Res
 	)
 }
 
-const deleteReservationByID = `-- name: DeleteReservationByID :exec
-DELETE FROM reservations
-WHERE user_id = ? 
-  AND id = ?
+const deleteReservationAttendee = `-- name: DeleteReservationAttendee :exec
+DELETE FROM reservation_attendees
+WHERE reservation_id = ? AND user_id = ?
 `
 
-type DeleteReservationByIDParams struct {
-	UserID uint64 `json:"user_id"`
-	ID     uint64 `json:"id"`
+type DeleteReservationAttendeeParams struct {
+	ReservationID uint64 `json:"reservation_id"`
+	UserID        uint64 `json:"user_id"`
+}
+
+func (q *Queries) DeleteReservationAttendee(ctx context.Context, arg DeleteReservationAttendeeParams) error {
+	_, err := q.db.ExecContext(ctx, deleteReservationAttendee, arg.ReservationID, arg.UserID)
+	return err
+}
+
+const deleteReservationEquipment = `-- name: DeleteReservationEquipment :exec
+DELETE FROM reservation_equipment
+WHERE reservation_id = ?
+`
+
+func (q *Queries) DeleteReservationEquipment(ctx context.Context, reservationID uint64) error {
+	_, err := q.db.ExecContext(ctx, deleteReservationEquipment, reservationID)
+	return err
+}
+
+const endReservationEarly = `-- name: EndReservationEarly :exec
+UPDATE reservations
+SET
+  booked_end_time = COALESCE(booked_end_time, end_time),
+  end_time = ?,
+  actual_end_time = ?,
+  updated_at = CURRENT_TIMESTAMP
+WHERE id = ?
+`
+
+type EndReservationEarlyParams struct {
+	EndTime       time.Time    `json:"end_time"`
+	ActualEndTime sql.NullTime `json:"actual_end_time"`
+	ID            uint64       `json:"id"`
+}
+
+func (q *Queries) EndReservationEarly(ctx context.Context, arg EndReservationEarlyParams) error {
+	_, err := q.db.ExecContext(ctx, endReservationEarly, arg.EndTime, arg.ActualEndTime, arg.ID)
+	return err
+}
+
+const extendReservation = `-- name: ExtendReservation :exec
+UPDATE reservations
+SET
+  booked_end_time = COALESCE(booked_end_time, end_time),
+  end_time = ?,
+  updated_at = CURRENT_TIMESTAMP
+WHERE id = ?
+`
+
+type ExtendReservationParams struct {
+	EndTime time.Time `json:"end_time"`
+	ID      uint64    `json:"id"`
 }
 
-func (q *Queries) DeleteReservationByID(ctx context.Context, arg DeleteReservationByIDParams) error {
-	_, err := q.db.ExecContext(ctx, deleteReservationByID, arg.UserID, arg.ID)
+func (q *Queries) ExtendReservation(ctx context.Context, arg ExtendReservationParams) error {
+	_, err := q.db.ExecContext(ctx, extendReservation, arg.EndTime, arg.ID)
 	return err
 }
 
+const getCalendarSyncToken = `-- name: GetCalendarSyncToken :one
+SELECT sync_token FROM calendar_sync_states
+WHERE calendar_id = ?
+`
+
+func (q *Queries) GetCalendarSyncToken(ctx context.Context, calendarID string) (sql.NullString, error) {
+	row := q.db.QueryRowContext(ctx, getCalendarSyncToken, calendarID)
+	var sync_token sql.NullString
+	err := row.Scan(&sync_token)
+	return sync_token, err
+}
+
+const getCheckinTokenByReservationID = `-- name: GetCheckinTokenByReservationID :one
+SELECT token FROM reservation_checkin_tokens
+WHERE reservation_id = ?
+`
+
+func (q *Queries) GetCheckinTokenByReservationID(ctx context.Context, reservationID uint64) (string, error) {
+	row := q.db.QueryRowContext(ctx, getCheckinTokenByReservationID, reservationID)
+	var token string
+	err := row.Scan(&token)
+	return token, err
+}
+
+const getEquipmentByID = `-- name: GetEquipmentByID :one
+SELECT id, name, quantity, created_at, updated_at FROM equipment
+WHERE id = ?
+`
+
+func (q *Queries) GetEquipmentByID(ctx context.Context, id uint64) (Equipment, error) {
+	row := q.db.QueryRowContext(ctx, getEquipmentByID, id)
+	var i Equipment
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Quantity,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getEquipmentByIDForUpdate = `-- name: GetEquipmentByIDForUpdate :one
+SELECT id, name, quantity, created_at, updated_at FROM equipment
+WHERE id = ?
+FOR UPDATE
+`
+
+// 同じ備品を同時に予約して数量を超えないよう、空きの確認から登録までの間ロックする
+func (q *Queries) GetEquipmentByIDForUpdate(ctx context.Context, id uint64) (Equipment, error) {
+	row := q.db.QueryRowContext(ctx, getEquipmentByIDForUpdate, id)
+	var i Equipment
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Quantity,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getReservationByCaldavName = `-- name: GetReservationByCaldavName :one
+SELECT id, user_id, title, start_time, end_time, status, checked_in_at, actual_end_time, booked_end_time, origin, google_event_id, ical_uid, caldav_name, created_at, updated_at, description, headcount, visibility FROM reservations
+WHERE status = 'confirmed'
+  AND caldav_name = ?
+`
+
+func (q *Queries) GetReservationByCaldavName(ctx context.Context, caldavName sql.NullString) (Reservation, error) {
+	row := q.db.QueryRowContext(ctx, getReservationByCaldavName, caldavName)
+	var i Reservation
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Title,
+		&i.StartTime,
+		&i.EndTime,
+		&i.Status,
+		&i.CheckedInAt,
+		&i.ActualEndTime,
+		&i.BookedEndTime,
+		&i.Origin,
+		&i.GoogleEventID,
+		&i.IcalUid,
+		&i.CaldavName,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Description,
+		&i.Headcount,
+		&i.Visibility,
+	)
+	return i, err
+}
+
+const getReservationByCheckinToken = `-- name: GetReservationByCheckinToken :one
+SELECT r.id, r.user_id, r.title, r.start_time, r.end_time, r.status, r.checked_in_at, r.actual_end_time, r.booked_end_time, r.origin, r.google_event_id, r.ical_uid, r.caldav_name, r.created_at, r.updated_at, r.description, r.headcount, r.visibility FROM reservations AS r
+JOIN reservation_checkin_tokens AS t ON t.reservation_id = r.id
+WHERE t.token = ?
+`
+
+func (q *Queries) GetReservationByCheckinToken(ctx context.Context, token string) (Reservation, error) {
+	row := q.db.QueryRowContext(ctx, getReservationByCheckinToken, token)
+	var i Reservation
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Title,
+		&i.StartTime,
+		&i.EndTime,
+		&i.Status,
+		&i.CheckedInAt,
+		&i.ActualEndTime,
+		&i.BookedEndTime,
+		&i.Origin,
+		&i.GoogleEventID,
+		&i.IcalUid,
+		&i.CaldavName,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Description,
+		&i.Headcount,
+		&i.Visibility,
+	)
+	return i, err
+}
+
+const getReservationByGoogleEventID = `-- name: GetReservationByGoogleEventID :one
+SELECT id, user_id, title, start_time, end_time, status, checked_in_at, actual_end_time, booked_end_time, origin, google_event_id, ical_uid, caldav_name, created_at, updated_at, description, headcount, visibility FROM reservations
+WHERE google_event_id = ?
+`
+
+func (q *Queries) GetReservationByGoogleEventID(ctx context.Context, googleEventID sql.NullString) (Reservation, error) {
+	row := q.db.QueryRowContext(ctx, getReservationByGoogleEventID, googleEventID)
+	var i Reservation
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Title,
+		&i.StartTime,
+		&i.EndTime,
+		&i.Status,
+		&i.CheckedInAt,
+		&i.ActualEndTime,
+		&i.BookedEndTime,
+		&i.Origin,
+		&i.GoogleEventID,
+		&i.IcalUid,
+		&i.CaldavName,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Description,
+		&i.Headcount,
+		&i.Visibility,
+	)
+	return i, err
+}
+
 const getReservationByID = `-- name: GetReservationByID :one
-SELECT id, user_id, title, start_time, end_time, status, created_at, updated_at FROM reservations
+SELECT id, user_id, title, start_time, end_time, status, checked_in_at, actual_end_time, booked_end_time, origin, google_event_id, ical_uid, caldav_name, created_at, updated_at, description, headcount, visibility FROM reservations
 WHERE id = ?
 `
 
@@ -130,19 +515,30 @@ func (q *Queries) GetReservationByID(ctx context.Context, id uint64) (Reservatio
 		&i.StartTime,
 		&i.EndTime,
 		&i.Status,
+		&i.CheckedInAt,
+		&i.ActualEndTime,
+		&i.BookedEndTime,
+		&i.Origin,
+		&i.GoogleEventID,
+		&i.IcalUid,
+		&i.CaldavName,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Description,
+		&i.Headcount,
+		&i.Visibility,
 	)
 	return i, err
 }
 
-const getReservationLastInserted = `-- name: GetReservationLastInserted :one
-SELECT id, user_id, title, start_time, end_time, status, created_at, updated_at FROM reservations
-WHERE id = LAST_INSERT_ID()
+const getReservationByIDForUpdate = `-- name: GetReservationByIDForUpdate :one
+SELECT id, user_id, title, start_time, end_time, status, checked_in_at, actual_end_time, booked_end_time, origin, google_event_id, ical_uid, caldav_name, created_at, updated_at, description, headcount, visibility FROM reservations
+WHERE id = ?
+FOR UPDATE
 `
 
-func (q *Queries) GetReservationLastInserted(ctx context.Context) (Reservation, error) {
-	row := q.db.QueryRowContext(ctx, getReservationLastInserted)
+func (q *Queries) GetReservationByIDForUpdate(ctx context.Context, id uint64) (Reservation, error) {
+	row := q.db.QueryRowContext(ctx, getReservationByIDForUpdate, id)
 	var i Reservation
 	err := row.Scan(
 		&i.ID,
@@ -151,14 +547,24 @@ func (q *Queries) GetReservationLastInserted(ctx context.Context) (Reservation,
 		&i.StartTime,
 		&i.EndTime,
 		&i.Status,
+		&i.CheckedInAt,
+		&i.ActualEndTime,
+		&i.BookedEndTime,
+		&i.Origin,
+		&i.GoogleEventID,
+		&i.IcalUid,
+		&i.CaldavName,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Description,
+		&i.Headcount,
+		&i.Visibility,
 	)
 	return i, err
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, name, email, google_id, avatar_url, role, created_at, updated_at, deleted_at FROM users
+SELECT id, name, email, google_id, avatar_url, role, caldav_token_hash, created_at, updated_at, deleted_at, language FROM users
 WHERE email = ?
   AND deleted_at IS NULL
 `
@@ -173,15 +579,17 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.GoogleID,
 		&i.AvatarUrl,
 		&i.Role,
+		&i.CaldavTokenHash,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
+		&i.Language,
 	)
 	return i, err
 }
 
 const getUserByGoogleID = `-- name: GetUserByGoogleID :one
-SELECT id, name, email, google_id, avatar_url, role, created_at, updated_at, deleted_at FROM users
+SELECT id, name, email, google_id, avatar_url, role, caldav_token_hash, created_at, updated_at, deleted_at, language FROM users
 WHERE google_id = ?
   AND deleted_at IS NULL
 `
@@ -196,15 +604,17 @@ func (q *Queries) GetUserByGoogleID(ctx context.Context, googleID string) (User,
 		&i.GoogleID,
 		&i.AvatarUrl,
 		&i.Role,
+		&i.CaldavTokenHash,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
+		&i.Language,
 	)
 	return i, err
 }
 
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, name, email, google_id, avatar_url, role, created_at, updated_at, deleted_at FROM users
+SELECT id, name, email, google_id, avatar_url, role, caldav_token_hash, created_at, updated_at, deleted_at, language FROM users
 WHERE id = ?
   AND deleted_at IS NULL
 `
@@ -219,62 +629,43 @@ func (q *Queries) GetUserByID(ctx context.Context, id uint64) (User, error) {
 		&i.GoogleID,
 		&i.AvatarUrl,
 		&i.Role,
+		&i.CaldavTokenHash,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
+		&i.Language,
 	)
 	return i, err
 }
 
-const listReservationsByDate = `-- name: ListReservationsByDate :many
-SELECT r.id, r.user_id, r.title, r.start_time, r.end_time, r.status, r.created_at, r.updated_at, u.name as user_name
-FROM reservations AS r
-JOIN users AS u ON r.user_id = u.id AND u.deleted_at IS NULL
-WHERE
-  r.status = 'confirmed'
-  AND r.start_time < ? 
-  AND r.end_time >= ? 
-ORDER BY
-  r.start_time
+const listBusyIntervals = `-- name: ListBusyIntervals :many
+SELECT start_time, end_time FROM reservations
+WHERE status = 'confirmed'
+  AND start_time < ?
+  AND end_time > ?
+ORDER BY start_time
 `
 
-type ListReservationsByDateParams struct {
-	StartTime time.Time `json:"start_time"`
-	EndTime   time.Time `json:"end_time"`
+type ListBusyIntervalsParams struct {
+	RangeEnd   time.Time `json:"range_end"`
+	RangeStart time.Time `json:"range_start"`
 }
 
-type ListReservationsByDateRow struct {
-	ID        uint64    `json:"id"`
-	UserID    uint64    `json:"user_id"`
-	Title     string    `json:"title"`
+type ListBusyIntervalsRow struct {
 	StartTime time.Time `json:"start_time"`
 	EndTime   time.Time `json:"end_time"`
-	Status    string    `json:"status"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	UserName  string    `json:"user_name"`
 }
 
-func (q *Queries) ListReservationsByDate(ctx context.Context, arg ListReservationsByDateParams) ([]ListReservationsByDateRow, error) {
-	rows, err := q.db.QueryContext(ctx, listReservationsByDate, arg.StartTime, arg.EndTime)
+func (q *Queries) ListBusyIntervals(ctx context.Context, arg ListBusyIntervalsParams) ([]ListBusyIntervalsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listBusyIntervals, arg.RangeEnd, arg.RangeStart)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []ListReservationsByDateRow
+	var items []ListBusyIntervalsRow
 	for rows.Next() {
-		var i ListReservationsByDateRow
-		if err := rows.Scan(
-			&i.ID,
-			&i.UserID,
-			&i.Title,
-			&i.StartTime,
-			&i.EndTime,
-			&i.Status,
-			&i.CreatedAt,
-			&i.UpdatedAt,
-			&i.UserName,
-		); err != nil {
+		var i ListBusyIntervalsRow
+		if err := rows.Scan(&i.StartTime, &i.EndTime); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -288,44 +679,21 @@ func (q *Queries) ListReservationsByDate(ctx context.Context, arg ListReservatio
 	return items, nil
 }
 
-const listReservationsByMonth = `-- name: ListReservationsByMonth :many
-SELECT r.id, r.user_id, r.title, r.start_time, r.end_time, r.status, r.created_at, r.updated_at, u.name as user_name
-FROM reservations AS r
-JOIN users AS u ON r.user_id = u.id AND u.deleted_at IS NULL
-WHERE
-  r.status = 'confirmed'
-  AND r.start_time < ?  -- 翌月の初日
-  AND r.end_time >= ? -- 月の初日
-ORDER BY
-  r.start_time
+const listConfirmedReservations = `-- name: ListConfirmedReservations :many
+SELECT id, user_id, title, start_time, end_time, status, checked_in_at, actual_end_time, booked_end_time, origin, google_event_id, ical_uid, caldav_name, created_at, updated_at, description, headcount, visibility FROM reservations
+WHERE status = 'confirmed'
+ORDER BY start_time
 `
 
-type ListReservationsByMonthParams struct {
-	StartTime time.Time `json:"start_time"`
-	EndTime   time.Time `json:"end_time"`
-}
-
-type ListReservationsByMonthRow struct {
-	ID        uint64    `json:"id"`
-	UserID    uint64    `json:"user_id"`
-	Title     string    `json:"title"`
-	StartTime time.Time `json:"start_time"`
-	EndTime   time.Time `json:"end_time"`
-	Status    string    `json:"status"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	UserName  string    `json:"user_name"`
-}
-
-func (q *Queries) ListReservationsByMonth(ctx context.Context, arg ListReservationsByMonthParams) ([]ListReservationsByMonthRow, error) {
-	rows, err := q.db.QueryContext(ctx, listReservationsByMonth, arg.StartTime, arg.EndTime)
+func (q *Queries) ListConfirmedReservations(ctx context.Context) ([]Reservation, error) {
+	rows, err := q.db.QueryContext(ctx, listConfirmedReservations)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []ListReservationsByMonthRow
+	var items []Reservation
 	for rows.Next() {
-		var i ListReservationsByMonthRow
+		var i Reservation
 		if err := rows.Scan(
 			&i.ID,
 			&i.UserID,
@@ -333,9 +701,18 @@ func (q *Queries) ListReservationsByMonth(ctx context.Context, arg ListReservati
 			&i.StartTime,
 			&i.EndTime,
 			&i.Status,
+			&i.CheckedInAt,
+			&i.ActualEndTime,
+			&i.BookedEndTime,
+			&i.Origin,
+			&i.GoogleEventID,
+			&i.IcalUid,
+			&i.CaldavName,
 			&i.CreatedAt,
 			&i.UpdatedAt,
-			&i.UserName,
+			&i.Description,
+			&i.Headcount,
+			&i.Visibility,
 		); err != nil {
 			return nil, err
 		}
@@ -350,15 +727,196 @@ func (q *Queries) ListReservationsByMonth(ctx context.Context, arg ListReservati
 	return items, nil
 }
 
-const listReservationsByUserID = `-- name: ListReservationsByUserID :many
-SELECT id, user_id, title, start_time, end_time, status, created_at, updated_at FROM reservations
-WHERE status = 'confirmed'
-  AND user_id = ?
-ORDER BY start_time
+const listEquipment = `-- name: ListEquipment :many
+SELECT id, name, quantity, created_at, updated_at FROM equipment
+ORDER BY id
 `
 
-func (q *Queries) ListReservationsByUserID(ctx context.Context, userID uint64) ([]Reservation, error) {
-	rows, err := q.db.QueryContext(ctx, listReservationsByUserID, userID)
+func (q *Queries) ListEquipment(ctx context.Context) ([]Equipment, error) {
+	rows, err := q.db.QueryContext(ctx, listEquipment)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Equipment
+	for rows.Next() {
+		var i Equipment
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Quantity,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listEquipmentUsage = `-- name: ListEquipmentUsage :many
+SELECT re.reservation_id, re.equipment_id, re.quantity, r.start_time, r.end_time
+FROM reservation_equipment AS re
+JOIN reservations AS r ON re.reservation_id = r.id
+WHERE r.status = 'confirmed'
+  AND r.start_time < ?
+  AND r.end_time > ?
+  AND r.id <> ?
+ORDER BY re.equipment_id, r.start_time, re.reservation_id
+`
+
+type ListEquipmentUsageParams struct {
+	RangeEnd   time.Time `json:"range_end"`
+	RangeStart time.Time `json:"range_start"`
+	ExcludeID  uint64    `json:"exclude_id"`
+}
+
+type ListEquipmentUsageRow struct {
+	ReservationID uint64    `json:"reservation_id"`
+	EquipmentID   uint64    `json:"equipment_id"`
+	Quantity      int32     `json:"quantity"`
+	StartTime     time.Time `json:"start_time"`
+	EndTime       time.Time `json:"end_time"`
+}
+
+// 期間に重なる確定済みの予約が借りている備品 (exclude_id の予約を除く)
+func (q *Queries) ListEquipmentUsage(ctx context.Context, arg ListEquipmentUsageParams) ([]ListEquipmentUsageRow, error) {
+	rows, err := q.db.QueryContext(ctx, listEquipmentUsage, arg.RangeEnd, arg.RangeStart, arg.ExcludeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListEquipmentUsageRow
+	for rows.Next() {
+		var i ListEquipmentUsageRow
+		if err := rows.Scan(
+			&i.ReservationID,
+			&i.EquipmentID,
+			&i.Quantity,
+			&i.StartTime,
+			&i.EndTime,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listInvitationsByUserID = `-- name: ListInvitationsByUserID :many
+SELECT r.id, r.user_id, r.title, r.start_time, r.end_time, r.status, r.checked_in_at, r.actual_end_time, r.booked_end_time, r.origin, r.google_event_id, r.ical_uid, r.caldav_name, r.created_at, r.updated_at, r.description, r.headcount, r.visibility, u.name AS user_name, a.response, a.responded_at
+FROM reservation_attendees AS a
+JOIN reservations AS r ON a.reservation_id = r.id
+JOIN users AS u ON r.user_id = u.id AND u.deleted_at IS NULL
+WHERE a.user_id = ? AND r.status = 'confirmed' AND r.end_time >= ?
+ORDER BY r.start_time, r.id
+`
+
+type ListInvitationsByUserIDParams struct {
+	UserID uint64    `json:"user_id"`
+	After  time.Time `json:"after"`
+}
+
+type ListInvitationsByUserIDRow struct {
+	ID            uint64         `json:"id"`
+	UserID        uint64         `json:"user_id"`
+	Title         string         `json:"title"`
+	StartTime     time.Time      `json:"start_time"`
+	EndTime       time.Time      `json:"end_time"`
+	Status        string         `json:"status"`
+	CheckedInAt   sql.NullTime   `json:"checked_in_at"`
+	ActualEndTime sql.NullTime   `json:"actual_end_time"`
+	BookedEndTime sql.NullTime   `json:"booked_end_time"`
+	Origin        string         `json:"origin"`
+	GoogleEventID sql.NullString `json:"google_event_id"`
+	IcalUid       sql.NullString `json:"ical_uid"`
+	CaldavName    sql.NullString `json:"caldav_name"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	Description   string         `json:"description"`
+	Headcount     int32          `json:"headcount"`
+	Visibility    string         `json:"visibility"`
+	UserName      string         `json:"user_name"`
+	Response      string         `json:"response"`
+	RespondedAt   sql.NullTime   `json:"responded_at"`
+}
+
+// 終了していない確定済みの予約への招待 (予約者の名前と返答付き)
+func (q *Queries) ListInvitationsByUserID(ctx context.Context, arg ListInvitationsByUserIDParams) ([]ListInvitationsByUserIDRow, error) {
+	rows, err := q.db.QueryContext(ctx, listInvitationsByUserID, arg.UserID, arg.After)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListInvitationsByUserIDRow
+	for rows.Next() {
+		var i ListInvitationsByUserIDRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Title,
+			&i.StartTime,
+			&i.EndTime,
+			&i.Status,
+			&i.CheckedInAt,
+			&i.ActualEndTime,
+			&i.BookedEndTime,
+			&i.Origin,
+			&i.GoogleEventID,
+			&i.IcalUid,
+			&i.CaldavName,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Description,
+			&i.Headcount,
+			&i.Visibility,
+			&i.UserName,
+			&i.Response,
+			&i.RespondedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listNoShowCandidates = `-- name: ListNoShowCandidates :many
+SELECT id, user_id, title, start_time, end_time, status, checked_in_at, actual_end_time, booked_end_time, origin, google_event_id, ical_uid, caldav_name, created_at, updated_at, description, headcount, visibility FROM reservations
+WHERE status = 'confirmed'
+  AND checked_in_at IS NULL
+  AND actual_end_time IS NULL
+  AND start_time <= ?
+  AND start_time > ?
+ORDER BY start_time
+`
+
+type ListNoShowCandidatesParams struct {
+	StartedBefore time.Time `json:"started_before"`
+	StartedAfter  time.Time `json:"started_after"`
+}
+
+func (q *Queries) ListNoShowCandidates(ctx context.Context, arg ListNoShowCandidatesParams) ([]Reservation, error) {
+	rows, err := q.db.QueryContext(ctx, listNoShowCandidates, arg.StartedBefore, arg.StartedAfter)
 	if err != nil {
 		return nil, err
 	}
@@ -373,8 +931,18 @@ func (q *Queries) ListReservationsByUserID(ctx context.Context, userID uint64) (
 			&i.StartTime,
 			&i.EndTime,
 			&i.Status,
+			&i.CheckedInAt,
+			&i.ActualEndTime,
+			&i.BookedEndTime,
+			&i.Origin,
+			&i.GoogleEventID,
+			&i.IcalUid,
+			&i.CaldavName,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Description,
+			&i.Headcount,
+			&i.Visibility,
 		); err != nil {
 			return nil, err
 		}
@@ -389,44 +957,257 @@ func (q *Queries) ListReservationsByUserID(ctx context.Context, userID uint64) (
 	return items, nil
 }
 
-const listReservationsByWeek = `-- name: ListReservationsByWeek :many
-SELECT r.id, r.user_id, r.title, r.start_time, r.end_time, r.status, r.created_at, r.updated_at, u.name as user_name
+const listOverlappingReservationIDsForUpdate = `-- name: ListOverlappingReservationIDsForUpdate :many
+SELECT id FROM reservations
+WHERE status = 'confirmed'
+  AND start_time < ?
+  AND end_time > ?
+  AND id <> ?
+ORDER BY id
+FOR UPDATE
+`
+
+type ListOverlappingReservationIDsForUpdateParams struct {
+	RangeEnd   time.Time `json:"range_end"`
+	RangeStart time.Time `json:"range_start"`
+	ExcludeID  uint64    `json:"exclude_id"`
+}
+
+// 時間帯が重なる確定済みの予約の行をロックして ID を返す (exclude_id の予約を除く)
+func (q *Queries) ListOverlappingReservationIDsForUpdate(ctx context.Context, arg ListOverlappingReservationIDsForUpdateParams) ([]uint64, error) {
+	rows, err := q.db.QueryContext(ctx, listOverlappingReservationIDsForUpdate, arg.RangeEnd, arg.RangeStart, arg.ExcludeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uint64
+	for rows.Next() {
+		var id uint64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listReservationAttendees = `-- name: ListReservationAttendees :many
+SELECT a.reservation_id, a.user_id, u.name, a.response, a.responded_at
+FROM reservation_attendees AS a
+JOIN users AS u ON a.user_id = u.id AND u.deleted_at IS NULL
+WHERE a.reservation_id IN (/*SLICE:reservation_ids*/?)
+ORDER BY a.reservation_id, a.user_id
+`
+
+type ListReservationAttendeesRow struct {
+	ReservationID uint64       `json:"reservation_id"`
+	UserID        uint64       `json:"user_id"`
+	Name          string       `json:"name"`
+	Response      string       `json:"response"`
+	RespondedAt   sql.NullTime `json:"responded_at"`
+}
+
+func (q *Queries) ListReservationAttendees(ctx context.Context, reservationIds []uint64) ([]ListReservationAttendeesRow, error) {
+	query := listReservationAttendees
+	var queryParams []interface{}
+	if len(reservationIds) > 0 {
+		for _, v := range reservationIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:reservation_ids*/?", strings.Repeat(",?", len(reservationIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:reservation_ids*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListReservationAttendeesRow
+	for rows.Next() {
+		var i ListReservationAttendeesRow
+		if err := rows.Scan(
+			&i.ReservationID,
+			&i.UserID,
+			&i.Name,
+			&i.Response,
+			&i.RespondedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listReservationEquipment = `-- name: ListReservationEquipment :many
+SELECT re.reservation_id, re.equipment_id, e.name, re.quantity
+FROM reservation_equipment AS re
+JOIN equipment AS e ON re.equipment_id = e.id
+WHERE re.reservation_id IN (/*SLICE:reservation_ids*/?)
+ORDER BY re.reservation_id, re.equipment_id
+`
+
+type ListReservationEquipmentRow struct {
+	ReservationID uint64 `json:"reservation_id"`
+	EquipmentID   uint64 `json:"equipment_id"`
+	Name          string `json:"name"`
+	Quantity      int32  `json:"quantity"`
+}
+
+func (q *Queries) ListReservationEquipment(ctx context.Context, reservationIds []uint64) ([]ListReservationEquipmentRow, error) {
+	query := listReservationEquipment
+	var queryParams []interface{}
+	if len(reservationIds) > 0 {
+		for _, v := range reservationIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:reservation_ids*/?", strings.Repeat(",?", len(reservationIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:reservation_ids*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListReservationEquipmentRow
+	for rows.Next() {
+		var i ListReservationEquipmentRow
+		if err := rows.Scan(
+			&i.ReservationID,
+			&i.EquipmentID,
+			&i.Name,
+			&i.Quantity,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listReservationEventsByReservationID = `-- name: ListReservationEventsByReservationID :many
+SELECT e.id, e.reservation_id, e.actor_user_id, e.action, e.before_data, e.after_data, e.client_ip, e.auth_method, e.created_at, u.name as actor_name
+FROM reservation_events AS e
+LEFT JOIN users AS u ON e.actor_user_id = u.id
+WHERE e.reservation_id = ?
+ORDER BY e.id
+`
+
+type ListReservationEventsByReservationIDRow struct {
+	ID            uint64          `json:"id"`
+	ReservationID uint64          `json:"reservation_id"`
+	ActorUserID   sql.NullInt64   `json:"actor_user_id"`
+	Action        string          `json:"action"`
+	BeforeData    json.RawMessage `json:"before_data"`
+	AfterData     json.RawMessage `json:"after_data"`
+	ClientIp      sql.NullString  `json:"client_ip"`
+	AuthMethod    string          `json:"auth_method"`
+	CreatedAt     time.Time       `json:"created_at"`
+	ActorName     sql.NullString  `json:"actor_name"`
+}
+
+func (q *Queries) ListReservationEventsByReservationID(ctx context.Context, reservationID uint64) ([]ListReservationEventsByReservationIDRow, error) {
+	rows, err := q.db.QueryContext(ctx, listReservationEventsByReservationID, reservationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListReservationEventsByReservationIDRow
+	for rows.Next() {
+		var i ListReservationEventsByReservationIDRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.ReservationID,
+			&i.ActorUserID,
+			&i.Action,
+			&i.BeforeData,
+			&i.AfterData,
+			&i.ClientIp,
+			&i.AuthMethod,
+			&i.CreatedAt,
+			&i.ActorName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listReservationsByDate = `-- name: ListReservationsByDate :many
+SELECT r.id, r.user_id, r.title, r.start_time, r.end_time, r.status, r.checked_in_at, r.actual_end_time, r.booked_end_time, r.origin, r.google_event_id, r.ical_uid, r.caldav_name, r.created_at, r.updated_at, r.description, r.headcount, r.visibility, u.name as user_name
 FROM reservations AS r
 JOIN users AS u ON r.user_id = u.id AND u.deleted_at IS NULL
 WHERE
   r.status = 'confirmed'
-  AND r.start_time < ?  
+  AND r.start_time < ? 
   AND r.end_time >= ? 
 ORDER BY
   r.start_time
 `
 
-type ListReservationsByWeekParams struct {
-	Endtime   time.Time `json:"endtime"`
-	Starttime time.Time `json:"starttime"`
-}
-
-type ListReservationsByWeekRow struct {
-	ID        uint64    `json:"id"`
-	UserID    uint64    `json:"user_id"`
-	Title     string    `json:"title"`
+type ListReservationsByDateParams struct {
 	StartTime time.Time `json:"start_time"`
 	EndTime   time.Time `json:"end_time"`
-	Status    string    `json:"status"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	UserName  string    `json:"user_name"`
 }
 
-func (q *Queries) ListReservationsByWeek(ctx context.Context, arg ListReservationsByWeekParams) ([]ListReservationsByWeekRow, error) {
-	rows, err := q.db.QueryContext(ctx, listReservationsByWeek, arg.Endtime, arg.Starttime)
+type ListReservationsByDateRow struct {
+	ID            uint64         `json:"id"`
+	UserID        uint64         `json:"user_id"`
+	Title         string         `json:"title"`
+	StartTime     time.Time      `json:"start_time"`
+	EndTime       time.Time      `json:"end_time"`
+	Status        string         `json:"status"`
+	CheckedInAt   sql.NullTime   `json:"checked_in_at"`
+	ActualEndTime sql.NullTime   `json:"actual_end_time"`
+	BookedEndTime sql.NullTime   `json:"booked_end_time"`
+	Origin        string         `json:"origin"`
+	GoogleEventID sql.NullString `json:"google_event_id"`
+	IcalUid       sql.NullString `json:"ical_uid"`
+	CaldavName    sql.NullString `json:"caldav_name"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	Description   string         `json:"description"`
+	Headcount     int32          `json:"headcount"`
+	Visibility    string         `json:"visibility"`
+	UserName      string         `json:"user_name"`
+}
+
+func (q *Queries) ListReservationsByDate(ctx context.Context, arg ListReservationsByDateParams) ([]ListReservationsByDateRow, error) {
+	rows, err := q.db.QueryContext(ctx, listReservationsByDate, arg.StartTime, arg.EndTime)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []ListReservationsByWeekRow
+	var items []ListReservationsByDateRow
 	for rows.Next() {
-		var i ListReservationsByWeekRow
+		var i ListReservationsByDateRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.UserID,
@@ -434,8 +1215,18 @@ func (q *Queries) ListReservationsByWeek(ctx context.Context, arg ListReservatio
 			&i.StartTime,
 			&i.EndTime,
 			&i.Status,
+			&i.CheckedInAt,
+			&i.ActualEndTime,
+			&i.BookedEndTime,
+			&i.Origin,
+			&i.GoogleEventID,
+			&i.IcalUid,
+			&i.CaldavName,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Description,
+			&i.Headcount,
+			&i.Visibility,
 			&i.UserName,
 		); err != nil {
 			return nil, err
@@ -451,8 +1242,57 @@ func (q *Queries) ListReservationsByWeek(ctx context.Context, arg ListReservatio
 	return items, nil
 }
 
+const listReservationsByUserID = `-- name: ListReservationsByUserID :many
+SELECT id, user_id, title, start_time, end_time, status, checked_in_at, actual_end_time, booked_end_time, origin, google_event_id, ical_uid, caldav_name, created_at, updated_at, description, headcount, visibility FROM reservations
+WHERE status = 'confirmed'
+  AND user_id = ?
+ORDER BY start_time
+`
+
+func (q *Queries) ListReservationsByUserID(ctx context.Context, userID uint64) ([]Reservation, error) {
+	rows, err := q.db.QueryContext(ctx, listReservationsByUserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Reservation
+	for rows.Next() {
+		var i Reservation
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Title,
+			&i.StartTime,
+			&i.EndTime,
+			&i.Status,
+			&i.CheckedInAt,
+			&i.ActualEndTime,
+			&i.BookedEndTime,
+			&i.Origin,
+			&i.GoogleEventID,
+			&i.IcalUid,
+			&i.CaldavName,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Description,
+			&i.Headcount,
+			&i.Visibility,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listUsers = `-- name: ListUsers :many
-SELECT id, name, email, google_id, avatar_url, role, created_at, updated_at, deleted_at FROM users
+SELECT id, name, email, google_id, avatar_url, role, caldav_token_hash, created_at, updated_at, deleted_at, language FROM users
 WHERE deleted_at IS NULL
 ORDER BY id
 `
@@ -473,9 +1313,263 @@ func (q *Queries) ListUsers(ctx context.Context) ([]User, error) {
 			&i.GoogleID,
 			&i.AvatarUrl,
 			&i.Role,
+			&i.CaldavTokenHash,
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.DeletedAt,
+			&i.Language,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockRoom = `-- name: LockRoom :one
+SELECT id FROM room_locks
+WHERE id = ?
+FOR UPDATE
+`
+
+// 予約の重複チェックから登録までの間、部屋の行をロックする (同じ部屋の予約を同時に登録すると、どちらも重複なしと判定して二重に予約されるため)
+func (q *Queries) LockRoom(ctx context.Context, id string) (string, error) {
+	row := q.db.QueryRowContext(ctx, lockRoom, id)
+	err := row.Scan(&id)
+	return id, err
+}
+
+const markReservationNoShow = `-- name: MarkReservationNoShow :execresult
+UPDATE reservations
+SET status = 'no_show', updated_at = CURRENT_TIMESTAMP
+WHERE id = ?
+  AND status = 'confirmed'
+  AND checked_in_at IS NULL
+  AND actual_end_time IS NULL
+`
+
+func (q *Queries) MarkReservationNoShow(ctx context.Context, id uint64) (sql.Result, error) {
+	return q.db.ExecContext(ctx, markReservationNoShow, id)
+}
+
+const searchReservationEvents = `-- name: SearchReservationEvents :many
+SELECT e.id, e.reservation_id, e.actor_user_id, e.action, e.before_data, e.after_data, e.client_ip, e.auth_method, e.created_at, u.name as actor_name
+FROM reservation_events AS e
+LEFT JOIN users AS u ON e.actor_user_id = u.id
+WHERE
+  (? IS NULL OR e.actor_user_id = ?)
+  AND (? IS NULL OR e.reservation_id = ?)
+  AND (? IS NULL OR e.action = ?)
+  AND (? IS NULL OR e.auth_method = ?)
+  AND (? IS NULL OR e.created_at >= ?)
+  AND (? IS NULL OR e.created_at < ?)
+ORDER BY e.id DESC
+LIMIT ? OFFSET ?
+`
+
+type SearchReservationEventsParams struct {
+	ActorUserID   sql.NullInt64  `json:"actor_user_id"`
+	ReservationID sql.NullInt64  `json:"reservation_id"`
+	Action        sql.NullString `json:"action"`
+	AuthMethod    sql.NullString `json:"auth_method"`
+	CreatedFrom   sql.NullTime   `json:"created_from"`
+	CreatedTo     sql.NullTime   `json:"created_to"`
+	Limit         int32          `json:"limit"`
+	Offset        int32          `json:"offset"`
+}
+
+type SearchReservationEventsRow struct {
+	ID            uint64          `json:"id"`
+	ReservationID uint64          `json:"reservation_id"`
+	ActorUserID   sql.NullInt64   `json:"actor_user_id"`
+	Action        string          `json:"action"`
+	BeforeData    json.RawMessage `json:"before_data"`
+	AfterData     json.RawMessage `json:"after_data"`
+	ClientIp      sql.NullString  `json:"client_ip"`
+	AuthMethod    string          `json:"auth_method"`
+	CreatedAt     time.Time       `json:"created_at"`
+	ActorName     sql.NullString  `json:"actor_name"`
+}
+
+func (q *Queries) SearchReservationEvents(ctx context.Context, arg SearchReservationEventsParams) ([]SearchReservationEventsRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchReservationEvents,
+		arg.ActorUserID,
+		arg.ActorUserID,
+		arg.ReservationID,
+		arg.ReservationID,
+		arg.Action,
+		arg.Action,
+		arg.AuthMethod,
+		arg.AuthMethod,
+		arg.CreatedFrom,
+		arg.CreatedFrom,
+		arg.CreatedTo,
+		arg.CreatedTo,
+		arg.Limit,
+		arg.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchReservationEventsRow
+	for rows.Next() {
+		var i SearchReservationEventsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.ReservationID,
+			&i.ActorUserID,
+			&i.Action,
+			&i.BeforeData,
+			&i.AfterData,
+			&i.ClientIp,
+			&i.AuthMethod,
+			&i.CreatedAt,
+			&i.ActorName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchReservations = `-- name: SearchReservations :many
+SELECT r.id, r.user_id, r.title, r.start_time, r.end_time, r.status, r.checked_in_at, r.actual_end_time, r.booked_end_time, r.origin, r.google_event_id, r.ical_uid, r.caldav_name, r.created_at, r.updated_at, r.description, r.headcount, r.visibility, u.name as user_name
+FROM reservations AS r
+JOIN users AS u ON r.user_id = u.id AND u.deleted_at IS NULL
+WHERE
+  (? IS NULL OR r.user_id = ?)
+  -- 予約者か、参加を承諾 (未定を含む) した参加者の予約
+  AND (? IS NULL OR r.user_id = ? OR EXISTS (
+    SELECT 1 FROM reservation_attendees AS m
+    WHERE m.reservation_id = r.id AND m.user_id = ? AND m.response IN ('accepted', 'tentative')
+  ))
+  AND (? IS NULL OR r.status = ?)
+  AND (? IS NULL OR (LOCATE(?, r.title) > 0 AND (
+    -- タイトルで絞り込む場合は、閲覧者がタイトルを見られる予約だけを対象にする
+    r.visibility = 'public'
+    OR (? IS NOT NULL AND r.visibility = 'members')
+    OR r.user_id = ?
+    OR EXISTS (
+      SELECT 1 FROM reservation_attendees AS a
+      WHERE a.reservation_id = r.id AND a.user_id = ?
+    )
+  )))
+  AND (? IS NULL OR EXISTS (
+    SELECT 1 FROM reservation_events AS e
+    WHERE e.reservation_id = r.id AND e.action = 'created' AND e.actor_user_id = ?
+  ))
+  AND (? IS NULL OR r.end_time >= ?)
+  AND (? IS NULL OR r.start_time < ?)
+  AND (? IS NULL
+    OR r.start_time > ?
+    OR (r.start_time = ? AND r.id > ?))
+ORDER BY r.start_time, r.id
+LIMIT ?
+`
+
+type SearchReservationsParams struct {
+	UserID          sql.NullInt64  `json:"user_id"`
+	MemberID        sql.NullInt64  `json:"member_id"`
+	Status          sql.NullString `json:"status"`
+	Title           sql.NullString `json:"title"`
+	ViewerID        sql.NullInt64  `json:"viewer_id"`
+	CreatedBy       sql.NullInt64  `json:"created_by"`
+	RangeStart      sql.NullTime   `json:"range_start"`
+	RangeEnd        sql.NullTime   `json:"range_end"`
+	CursorStartTime sql.NullTime   `json:"cursor_start_time"`
+	CursorID        sql.NullInt64  `json:"cursor_id"`
+	Limit           int32          `json:"limit"`
+}
+
+type SearchReservationsRow struct {
+	ID            uint64         `json:"id"`
+	UserID        uint64         `json:"user_id"`
+	Title         string         `json:"title"`
+	StartTime     time.Time      `json:"start_time"`
+	EndTime       time.Time      `json:"end_time"`
+	Status        string         `json:"status"`
+	CheckedInAt   sql.NullTime   `json:"checked_in_at"`
+	ActualEndTime sql.NullTime   `json:"actual_end_time"`
+	BookedEndTime sql.NullTime   `json:"booked_end_time"`
+	Origin        string         `json:"origin"`
+	GoogleEventID sql.NullString `json:"google_event_id"`
+	IcalUid       sql.NullString `json:"ical_uid"`
+	CaldavName    sql.NullString `json:"caldav_name"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	Description   string         `json:"description"`
+	Headcount     int32          `json:"headcount"`
+	Visibility    string         `json:"visibility"`
+	UserName      string         `json:"user_name"`
+}
+
+func (q *Queries) SearchReservations(ctx context.Context, arg SearchReservationsParams) ([]SearchReservationsRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchReservations,
+		arg.UserID,
+		arg.UserID,
+		arg.MemberID,
+		arg.MemberID,
+		arg.MemberID,
+		arg.Status,
+		arg.Status,
+		arg.Title,
+		arg.Title,
+		arg.ViewerID,
+		arg.ViewerID,
+		arg.ViewerID,
+		arg.CreatedBy,
+		arg.CreatedBy,
+		arg.RangeStart,
+		arg.RangeStart,
+		arg.RangeEnd,
+		arg.RangeEnd,
+		arg.CursorStartTime,
+		arg.CursorStartTime,
+		arg.CursorStartTime,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchReservationsRow
+	for rows.Next() {
+		var i SearchReservationsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Title,
+			&i.StartTime,
+			&i.EndTime,
+			&i.Status,
+			&i.CheckedInAt,
+			&i.ActualEndTime,
+			&i.BookedEndTime,
+			&i.Origin,
+			&i.GoogleEventID,
+			&i.IcalUid,
+			&i.CaldavName,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Description,
+			&i.Headcount,
+			&i.Visibility,
+			&i.UserName,
 		); err != nil {
 			return nil, err
 		}
@@ -490,6 +1584,192 @@ func (q *Queries) ListUsers(ctx context.Context) ([]User, error) {
 	return items, nil
 }
 
+const searchReservationsDesc = `-- name: SearchReservationsDesc :many
+SELECT r.id, r.user_id, r.title, r.start_time, r.end_time, r.status, r.checked_in_at, r.actual_end_time, r.booked_end_time, r.origin, r.google_event_id, r.ical_uid, r.caldav_name, r.created_at, r.updated_at, r.description, r.headcount, r.visibility, u.name as user_name
+FROM reservations AS r
+JOIN users AS u ON r.user_id = u.id AND u.deleted_at IS NULL
+WHERE
+  (? IS NULL OR r.user_id = ?)
+  -- 予約者か、参加を承諾 (未定を含む) した参加者の予約
+  AND (? IS NULL OR r.user_id = ? OR EXISTS (
+    SELECT 1 FROM reservation_attendees AS m
+    WHERE m.reservation_id = r.id AND m.user_id = ? AND m.response IN ('accepted', 'tentative')
+  ))
+  AND (? IS NULL OR r.status = ?)
+  AND (? IS NULL OR (LOCATE(?, r.title) > 0 AND (
+    -- タイトルで絞り込む場合は、閲覧者がタイトルを見られる予約だけを対象にする
+    r.visibility = 'public'
+    OR (? IS NOT NULL AND r.visibility = 'members')
+    OR r.user_id = ?
+    OR EXISTS (
+      SELECT 1 FROM reservation_attendees AS a
+      WHERE a.reservation_id = r.id AND a.user_id = ?
+    )
+  )))
+  AND (? IS NULL OR EXISTS (
+    SELECT 1 FROM reservation_events AS e
+    WHERE e.reservation_id = r.id AND e.action = 'created' AND e.actor_user_id = ?
+  ))
+  AND (? IS NULL OR r.end_time >= ?)
+  AND (? IS NULL OR r.start_time < ?)
+  AND (? IS NULL
+    OR r.start_time < ?
+    OR (r.start_time = ? AND r.id < ?))
+ORDER BY r.start_time DESC, r.id DESC
+LIMIT ?
+`
+
+type SearchReservationsDescParams struct {
+	UserID          sql.NullInt64  `json:"user_id"`
+	MemberID        sql.NullInt64  `json:"member_id"`
+	Status          sql.NullString `json:"status"`
+	Title           sql.NullString `json:"title"`
+	ViewerID        sql.NullInt64  `json:"viewer_id"`
+	CreatedBy       sql.NullInt64  `json:"created_by"`
+	RangeStart      sql.NullTime   `json:"range_start"`
+	RangeEnd        sql.NullTime   `json:"range_end"`
+	CursorStartTime sql.NullTime   `json:"cursor_start_time"`
+	CursorID        sql.NullInt64  `json:"cursor_id"`
+	Limit           int32          `json:"limit"`
+}
+
+type SearchReservationsDescRow struct {
+	ID            uint64         `json:"id"`
+	UserID        uint64         `json:"user_id"`
+	Title         string         `json:"title"`
+	StartTime     time.Time      `json:"start_time"`
+	EndTime       time.Time      `json:"end_time"`
+	Status        string         `json:"status"`
+	CheckedInAt   sql.NullTime   `json:"checked_in_at"`
+	ActualEndTime sql.NullTime   `json:"actual_end_time"`
+	BookedEndTime sql.NullTime   `json:"booked_end_time"`
+	Origin        string         `json:"origin"`
+	GoogleEventID sql.NullString `json:"google_event_id"`
+	IcalUid       sql.NullString `json:"ical_uid"`
+	CaldavName    sql.NullString `json:"caldav_name"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	Description   string         `json:"description"`
+	Headcount     int32          `json:"headcount"`
+	Visibility    string         `json:"visibility"`
+	UserName      string         `json:"user_name"`
+}
+
+func (q *Queries) SearchReservationsDesc(ctx context.Context, arg SearchReservationsDescParams) ([]SearchReservationsDescRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchReservationsDesc,
+		arg.UserID,
+		arg.UserID,
+		arg.MemberID,
+		arg.MemberID,
+		arg.MemberID,
+		arg.Status,
+		arg.Status,
+		arg.Title,
+		arg.Title,
+		arg.ViewerID,
+		arg.ViewerID,
+		arg.ViewerID,
+		arg.CreatedBy,
+		arg.CreatedBy,
+		arg.RangeStart,
+		arg.RangeStart,
+		arg.RangeEnd,
+		arg.RangeEnd,
+		arg.CursorStartTime,
+		arg.CursorStartTime,
+		arg.CursorStartTime,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchReservationsDescRow
+	for rows.Next() {
+		var i SearchReservationsDescRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Title,
+			&i.StartTime,
+			&i.EndTime,
+			&i.Status,
+			&i.CheckedInAt,
+			&i.ActualEndTime,
+			&i.BookedEndTime,
+			&i.Origin,
+			&i.GoogleEventID,
+			&i.IcalUid,
+			&i.CaldavName,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Description,
+			&i.Headcount,
+			&i.Visibility,
+			&i.UserName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setReservationGoogleEventID = `-- name: SetReservationGoogleEventID :exec
+UPDATE reservations
+SET google_event_id = ?
+WHERE id = ?
+`
+
+type SetReservationGoogleEventIDParams struct {
+	GoogleEventID sql.NullString `json:"google_event_id"`
+	ID            uint64         `json:"id"`
+}
+
+func (q *Queries) SetReservationGoogleEventID(ctx context.Context, arg SetReservationGoogleEventIDParams) error {
+	_, err := q.db.ExecContext(ctx, setReservationGoogleEventID, arg.GoogleEventID, arg.ID)
+	return err
+}
+
+const setUserCaldavTokenHash = `-- name: SetUserCaldavTokenHash :exec
+UPDATE users
+SET caldav_token_hash = ?, updated_at = CURRENT_TIMESTAMP
+WHERE id = ?
+`
+
+type SetUserCaldavTokenHashParams struct {
+	CaldavTokenHash sql.NullString `json:"caldav_token_hash"`
+	ID              uint64         `json:"id"`
+}
+
+func (q *Queries) SetUserCaldavTokenHash(ctx context.Context, arg SetUserCaldavTokenHashParams) error {
+	_, err := q.db.ExecContext(ctx, setUserCaldavTokenHash, arg.CaldavTokenHash, arg.ID)
+	return err
+}
+
+const setUserLanguage = `-- name: SetUserLanguage :exec
+UPDATE users
+SET language = ?, updated_at = CURRENT_TIMESTAMP
+WHERE id = ?
+`
+
+type SetUserLanguageParams struct {
+	Language sql.NullString `json:"language"`
+	ID       uint64         `json:"id"`
+}
+
+func (q *Queries) SetUserLanguage(ctx context.Context, arg SetUserLanguageParams) error {
+	_, err := q.db.ExecContext(ctx, setUserLanguage, arg.Language, arg.ID)
+	return err
+}
+
 const softDeleteUser = `-- name: SoftDeleteUser :exec
 UPDATE users
 SET deleted_at = CURRENT_TIMESTAMP
@@ -501,25 +1781,92 @@ func (q *Queries) SoftDeleteUser(ctx context.Context, id uint64) error {
 	return err
 }
 
+const updateEquipment = `-- name: UpdateEquipment :exec
+UPDATE equipment
+SET name = ?, quantity = ?, updated_at = CURRENT_TIMESTAMP
+WHERE id = ?
+`
+
+type UpdateEquipmentParams struct {
+	Name     string `json:"name"`
+	Quantity int32  `json:"quantity"`
+	ID       uint64 `json:"id"`
+}
+
+func (q *Queries) UpdateEquipment(ctx context.Context, arg UpdateEquipmentParams) error {
+	_, err := q.db.ExecContext(ctx, updateEquipment, arg.Name, arg.Quantity, arg.ID)
+	return err
+}
+
+const updateReservationAttendeeResponse = `-- name: UpdateReservationAttendeeResponse :exec
+UPDATE reservation_attendees
+SET response = ?, responded_at = ?
+WHERE reservation_id = ? AND user_id = ?
+`
+
+type UpdateReservationAttendeeResponseParams struct {
+	Response      string       `json:"response"`
+	RespondedAt   sql.NullTime `json:"responded_at"`
+	ReservationID uint64       `json:"reservation_id"`
+	UserID        uint64       `json:"user_id"`
+}
+
+func (q *Queries) UpdateReservationAttendeeResponse(ctx context.Context, arg UpdateReservationAttendeeResponseParams) error {
+	_, err := q.db.ExecContext(ctx, updateReservationAttendeeResponse,
+		arg.Response,
+		arg.RespondedAt,
+		arg.ReservationID,
+		arg.UserID,
+	)
+	return err
+}
+
 const updateReservationByID = `-- name: UpdateReservationByID :exec
 UPDATE reservations
-SET title = ?, start_time = ?, end_time = ?, updated_at = CURRENT_TIMESTAMP
+SET title = ?, description = ?, headcount = ?, visibility = ?, start_time = ?, end_time = ?, updated_at = CURRENT_TIMESTAMP
 WHERE id = ?
 `
 
 type UpdateReservationByIDParams struct {
-	Title     string    `json:"title"`
-	StartTime time.Time `json:"start_time"`
-	EndTime   time.Time `json:"end_time"`
-	ID        uint64    `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Headcount   int32     `json:"headcount"`
+	Visibility  string    `json:"visibility"`
+	StartTime   time.Time `json:"start_time"`
+	EndTime     time.Time `json:"end_time"`
+	ID          uint64    `json:"id"`
 }
 
 func (q *Queries) UpdateReservationByID(ctx context.Context, arg UpdateReservationByIDParams) error {
 	_, err := q.db.ExecContext(ctx, updateReservationByID,
 		arg.Title,
+		arg.Description,
+		arg.Headcount,
+		arg.Visibility,
 		arg.StartTime,
 		arg.EndTime,
 		arg.ID,
 	)
 	return err
 }
+
+const upsertCalendarSyncToken = `-- name: UpsertCalendarSyncToken :exec
+INSERT INTO calendar_sync_states (
+    calendar_id, sync_token
+) VALUES (
+    ?, ?
+)
+ON DUPLICATE KEY UPDATE
+  sync_token = VALUES(sync_token),
+  updated_at = CURRENT_TIMESTAMP
+`
+
+type UpsertCalendarSyncTokenParams struct {
+	CalendarID string         `json:"calendar_id"`
+	SyncToken  sql.NullString `json:"sync_token"`
+}
+
+func (q *Queries) UpsertCalendarSyncToken(ctx context.Context, arg UpsertCalendarSyncTokenParams) error {
+	_, err := q.db.ExecContext(ctx, upsertCalendarSyncToken, arg.CalendarID, arg.SyncToken)
+	return err
+}
@@ -0,0 +1,13 @@
+// Package sqlitedb は SQLite 用に sqlc で生成したクエリです。
+//
+// query.sql は db/query.sql と同じ名前・同じ引数の順番のクエリを定義し、生成される構造体を db パッケージと同じ形にしています
+// (store パッケージで db の型に変換するため)。
+// sqlc の SQLite のパーサーは ASCII 以外の文字を含むコメントを正しく扱えないため、query.sql には日本語のコメントを書かないでください。
+package sqlitedb
+
+import "embed"
+
+// Migrations はバイナリに埋め込んだ SQLite 用のマイグレーションファイルです。
+//
+//go:embed migrations/*.sql
+var Migrations embed.FS
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS reservation_events;
+DROP TABLE IF EXISTS reservation_checkin_tokens;
+DROP TABLE IF EXISTS calendar_sync_states;
+DROP TABLE IF EXISTS reservations;
+DROP TABLE IF EXISTS users;
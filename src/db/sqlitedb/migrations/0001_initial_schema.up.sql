@@ -0,0 +1,68 @@
+-- SQLite 用のスキーマ (MySQL の db/migrations/0001_initial_schema.up.sql と同じ構成)
+-- SQLite は後から外部キーや CHECK制約を追加できないため、0002_schema_hardening の制約はここで定義しています。
+-- 時刻は UTC の 'YYYY-MM-DD HH:MM:SS.SSS+00:00' 形式の文字列で保存します (文字列の比較で時刻の前後を比較できるようにするため)。
+
+-- users テーブル
+CREATE TABLE users (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name TEXT NOT NULL,
+  email TEXT NOT NULL UNIQUE,
+  google_id TEXT NOT NULL UNIQUE,
+  avatar_url TEXT,
+  role TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'admin', 'system')),
+  caldav_token_hash TEXT, -- CalDAVクライアント用トークンのSHA-256
+  created_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+  updated_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+  deleted_at DATETIME
+);
+
+
+-- reservations テーブル
+CREATE TABLE reservations (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL REFERENCES users (id),
+  title TEXT NOT NULL,
+  start_time DATETIME NOT NULL,
+  end_time DATETIME NOT NULL,
+  status TEXT NOT NULL DEFAULT 'confirmed' CHECK (status IN ('confirmed', 'canceled', 'no_show')),
+  checked_in_at DATETIME, -- 実際の利用開始 (チェックイン) 時刻
+  actual_end_time DATETIME, -- 「今すぐ終了」で記録した実際の利用終了時刻
+  booked_end_time DATETIME, -- 早期終了・延長する前に予約していた終了時刻
+  origin TEXT NOT NULL DEFAULT 'api' CHECK (origin IN ('api', 'google_calendar', 'caldav')),
+  google_event_id TEXT,
+  ical_uid TEXT, -- CalDAVクライアントが作成した予定のUID
+  caldav_name TEXT, -- CalDAVクライアントが作成した予定のリソース名 (xxx.ics)
+  created_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+  updated_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+  CHECK (end_time > start_time)
+);
+
+
+-- calendar_sync_states テーブル (Googleカレンダーの差分同期トークン)
+CREATE TABLE calendar_sync_states (
+  calendar_id TEXT NOT NULL PRIMARY KEY,
+  sync_token TEXT,
+  updated_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+
+-- reservation_checkin_tokens テーブル (ドアに掲示するQRコード用のトークン)
+CREATE TABLE reservation_checkin_tokens (
+  reservation_id INTEGER NOT NULL PRIMARY KEY REFERENCES reservations (id) ON DELETE CASCADE,
+  token TEXT NOT NULL UNIQUE,
+  created_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+
+-- reservation_events テーブル (予約の変更履歴、追記のみ)
+CREATE TABLE reservation_events (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  reservation_id INTEGER NOT NULL REFERENCES reservations (id),
+  actor_user_id INTEGER REFERENCES users (id), -- システムによる変更の場合は NULL
+  action TEXT NOT NULL CHECK (action IN ('created', 'updated', 'canceled', 'checked_in', 'no_show', 'ended_early', 'extended')),
+  before_data TEXT,
+  after_data TEXT,
+  client_ip TEXT,
+  auth_method TEXT NOT NULL CHECK (auth_method IN ('session', 'caldav', 'google_calendar', 'system')),
+  created_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
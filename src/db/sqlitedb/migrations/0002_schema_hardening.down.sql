@@ -0,0 +1,6 @@
+DROP INDEX idx_reservation_events_actor;
+DROP INDEX idx_reservation_events_reservation;
+DROP INDEX idx_reservations_caldav_name;
+DROP INDEX idx_reservations_google_event_id;
+DROP INDEX idx_reservations_user_status_time;
+DROP INDEX idx_reservations_status_time;
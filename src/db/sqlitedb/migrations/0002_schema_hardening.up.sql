@@ -0,0 +1,7 @@
+-- インデックスの追加 (外部キーと CHECK制約は 0001_initial_schema で定義済み)
+CREATE INDEX idx_reservations_status_time ON reservations (status, start_time, end_time);
+CREATE INDEX idx_reservations_user_status_time ON reservations (user_id, status, start_time);
+CREATE INDEX idx_reservations_google_event_id ON reservations (google_event_id);
+CREATE INDEX idx_reservations_caldav_name ON reservations (caldav_name);
+CREATE INDEX idx_reservation_events_reservation ON reservation_events (reservation_id, id);
+CREATE INDEX idx_reservation_events_actor ON reservation_events (actor_user_id, id);
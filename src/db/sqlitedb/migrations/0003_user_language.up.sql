@@ -0,0 +1,2 @@
+-- ユーザーが選んだ表示言語 (MySQL の 0003_user_language と同じ内容)
+ALTER TABLE users ADD COLUMN language TEXT CHECK (language IN ('ja', 'en'));
@@ -0,0 +1,3 @@
+-- 予約の説明 (MySQL の 0004_reservation_description と同じ内容)
+-- SQLite には ngram の全文検索が無いため、検索は部分一致で行う (索引は作らない)
+ALTER TABLE reservations ADD COLUMN description TEXT NOT NULL DEFAULT '';
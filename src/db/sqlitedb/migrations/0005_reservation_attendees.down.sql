@@ -0,0 +1,3 @@
+DROP TABLE reservation_attendees;
+ALTER TABLE reservations DROP COLUMN visibility;
+ALTER TABLE reservations DROP COLUMN headcount;
@@ -0,0 +1,12 @@
+-- 予約の参加予定人数・公開範囲と参加者 (MySQL の 0005_reservation_attendees と同じ内容)
+ALTER TABLE reservations ADD COLUMN headcount INTEGER NOT NULL DEFAULT 0 CHECK (headcount >= 0);
+ALTER TABLE reservations ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public' CHECK (visibility IN ('public', 'members', 'private'));
+
+-- reservation_attendees テーブル (予約の参加者。予約者本人は含めない)
+CREATE TABLE reservation_attendees (
+  reservation_id INTEGER NOT NULL REFERENCES reservations (id) ON DELETE CASCADE,
+  user_id INTEGER NOT NULL REFERENCES users (id),
+  created_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+  PRIMARY KEY (reservation_id, user_id)
+);
+CREATE INDEX idx_reservation_attendees_user ON reservation_attendees (user_id);
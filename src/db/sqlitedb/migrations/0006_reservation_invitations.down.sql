@@ -0,0 +1,2 @@
+ALTER TABLE reservation_attendees DROP COLUMN responded_at;
+ALTER TABLE reservation_attendees DROP COLUMN response;
@@ -0,0 +1,3 @@
+-- 参加者への招待の返答 (MySQL の 0006_reservation_invitations と同じ内容)
+ALTER TABLE reservation_attendees ADD COLUMN response TEXT NOT NULL DEFAULT 'needs_action' CHECK (response IN ('needs_action', 'accepted', 'declined', 'tentative'));
+ALTER TABLE reservation_attendees ADD COLUMN responded_at DATETIME;
@@ -0,0 +1,2 @@
+DROP TABLE reservation_equipment;
+DROP TABLE equipment;
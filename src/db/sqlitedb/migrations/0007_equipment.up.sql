@@ -0,0 +1,16 @@
+-- 部屋と一緒に予約できる備品 (MySQL の 0007_equipment と同じ内容)
+CREATE TABLE equipment (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name TEXT NOT NULL UNIQUE,
+  quantity INTEGER NOT NULL CHECK (quantity >= 0),
+  created_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+  updated_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+CREATE TABLE reservation_equipment (
+  reservation_id INTEGER NOT NULL REFERENCES reservations (id) ON DELETE CASCADE,
+  equipment_id INTEGER NOT NULL REFERENCES equipment (id),
+  quantity INTEGER NOT NULL CHECK (quantity > 0),
+  PRIMARY KEY (reservation_id, equipment_id)
+);
+CREATE INDEX idx_reservation_equipment_equipment ON reservation_equipment (equipment_id);
@@ -0,0 +1,7 @@
+-- 予約の重複チェックから登録までの間ロックする部屋ごとの行 (MySQL の 0008_room_locks と同じ内容)
+-- SQLite は BEGIN IMMEDIATE で書き込むトランザクションを1つずつ実行するため、行は参照するだけです
+CREATE TABLE room_locks (
+  id TEXT NOT NULL PRIMARY KEY
+);
+
+INSERT INTO room_locks (id) VALUES ('room-401');
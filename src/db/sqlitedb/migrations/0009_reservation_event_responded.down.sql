@@ -0,0 +1,20 @@
+-- 元の CHECK制約に戻すため、返答の変更履歴は削除する
+CREATE TABLE reservation_events_new (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  reservation_id INTEGER NOT NULL REFERENCES reservations (id),
+  actor_user_id INTEGER REFERENCES users (id), -- システムによる変更の場合は NULL
+  action TEXT NOT NULL CHECK (action IN ('created', 'updated', 'canceled', 'checked_in', 'no_show', 'ended_early', 'extended')),
+  before_data TEXT,
+  after_data TEXT,
+  client_ip TEXT,
+  auth_method TEXT NOT NULL CHECK (auth_method IN ('session', 'caldav', 'google_calendar', 'system')),
+  created_at DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+INSERT INTO reservation_events_new (id, reservation_id, actor_user_id, action, before_data, after_data, client_ip, auth_method, created_at)
+SELECT id, reservation_id, actor_user_id, action, before_data, after_data, client_ip, auth_method, created_at FROM reservation_events WHERE action <> 'responded';
+
+DROP TABLE reservation_events;
+ALTER TABLE reservation_events_new RENAME TO reservation_events;
+CREATE INDEX idx_reservation_events_reservation ON reservation_events (reservation_id, id);
+CREATE INDEX idx_reservation_events_actor ON reservation_events (actor_user_id, id);
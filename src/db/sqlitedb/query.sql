@@ -0,0 +1,416 @@
+-- name: CreateUser :one
+INSERT INTO users (
+    name, email, google_id, avatar_url, role
+) VALUES (
+    ?, ?, ?, ?, ?
+)
+RETURNING id;
+
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = ?
+  AND deleted_at IS NULL;
+
+-- name: GetUserByEmail :one
+SELECT * FROM users
+WHERE email = ?
+  AND deleted_at IS NULL;
+
+-- name: GetUserByGoogleID :one
+SELECT * FROM users
+WHERE google_id = ?
+  AND deleted_at IS NULL;
+
+-- name: ListUsers :many
+SELECT * FROM users
+WHERE deleted_at IS NULL
+ORDER BY id;
+
+-- name: SetUserCaldavTokenHash :exec
+UPDATE users
+SET caldav_token_hash = ?, updated_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE id = ?;
+
+-- name: SetUserLanguage :exec
+UPDATE users
+SET language = ?, updated_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE id = ?;
+
+-- name: SoftDeleteUser :exec
+UPDATE users
+SET deleted_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE id = ?;
+
+
+-- name: CreateReservation :one
+INSERT INTO reservations (
+    user_id, title, description, headcount, visibility, start_time, end_time, status
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, 'confirmed'
+)
+RETURNING id;
+
+-- name: GetReservationByID :one
+SELECT * FROM reservations
+WHERE id = ?;
+
+-- name: ListReservationsByUserID :many
+SELECT * FROM reservations
+WHERE status = 'confirmed'
+  AND user_id = ?
+ORDER BY start_time;
+
+-- name: ListReservationsByDate :many
+SELECT r.*, u.name as user_name
+FROM reservations AS r
+JOIN users AS u ON r.user_id = u.id AND u.deleted_at IS NULL
+WHERE
+  r.status = 'confirmed'
+  AND r.start_time < ?
+  AND r.end_time >= ?
+ORDER BY
+  r.start_time;
+
+-- name: SearchReservations :many
+SELECT r.*, u.name as user_name
+FROM reservations AS r
+JOIN users AS u ON r.user_id = u.id AND u.deleted_at IS NULL
+WHERE
+  (sqlc.narg(user_id) IS NULL OR r.user_id = sqlc.narg(user_id))
+  AND (sqlc.narg(member_id) IS NULL OR r.user_id = sqlc.narg(member_id) OR EXISTS (
+    SELECT 1 FROM reservation_attendees AS m
+    WHERE m.reservation_id = r.id AND m.user_id = sqlc.narg(member_id) AND m.response IN ('accepted', 'tentative')
+  ))
+  AND (sqlc.narg(status) IS NULL OR r.status = sqlc.narg(status))
+  AND (sqlc.narg(title) IS NULL OR (instr(lower(r.title), lower(sqlc.narg(title))) > 0 AND (
+    r.visibility = 'public'
+    OR (sqlc.narg(viewer_id) IS NOT NULL AND r.visibility = 'members')
+    OR r.user_id = sqlc.narg(viewer_id)
+    OR EXISTS (
+      SELECT 1 FROM reservation_attendees AS a
+      WHERE a.reservation_id = r.id AND a.user_id = sqlc.narg(viewer_id)
+    )
+  )))
+  AND (sqlc.narg(created_by) IS NULL OR EXISTS (
+    SELECT 1 FROM reservation_events AS e
+    WHERE e.reservation_id = r.id AND e.action = 'created' AND e.actor_user_id = sqlc.narg(created_by)
+  ))
+  AND (sqlc.narg(range_start) IS NULL OR r.end_time >= sqlc.narg(range_start))
+  AND (sqlc.narg(range_end) IS NULL OR r.start_time < sqlc.narg(range_end))
+  AND (sqlc.narg(cursor_start_time) IS NULL
+    OR r.start_time > sqlc.narg(cursor_start_time)
+    OR (r.start_time = sqlc.narg(cursor_start_time) AND r.id > sqlc.narg(cursor_id)))
+ORDER BY r.start_time, r.id
+LIMIT sqlc.arg('limit');
+
+-- name: SearchReservationsDesc :many
+SELECT r.*, u.name as user_name
+FROM reservations AS r
+JOIN users AS u ON r.user_id = u.id AND u.deleted_at IS NULL
+WHERE
+  (sqlc.narg(user_id) IS NULL OR r.user_id = sqlc.narg(user_id))
+  AND (sqlc.narg(member_id) IS NULL OR r.user_id = sqlc.narg(member_id) OR EXISTS (
+    SELECT 1 FROM reservation_attendees AS m
+    WHERE m.reservation_id = r.id AND m.user_id = sqlc.narg(member_id) AND m.response IN ('accepted', 'tentative')
+  ))
+  AND (sqlc.narg(status) IS NULL OR r.status = sqlc.narg(status))
+  AND (sqlc.narg(title) IS NULL OR (instr(lower(r.title), lower(sqlc.narg(title))) > 0 AND (
+    r.visibility = 'public'
+    OR (sqlc.narg(viewer_id) IS NOT NULL AND r.visibility = 'members')
+    OR r.user_id = sqlc.narg(viewer_id)
+    OR EXISTS (
+      SELECT 1 FROM reservation_attendees AS a
+      WHERE a.reservation_id = r.id AND a.user_id = sqlc.narg(viewer_id)
+    )
+  )))
+  AND (sqlc.narg(created_by) IS NULL OR EXISTS (
+    SELECT 1 FROM reservation_events AS e
+    WHERE e.reservation_id = r.id AND e.action = 'created' AND e.actor_user_id = sqlc.narg(created_by)
+  ))
+  AND (sqlc.narg(range_start) IS NULL OR r.end_time >= sqlc.narg(range_start))
+  AND (sqlc.narg(range_end) IS NULL OR r.start_time < sqlc.narg(range_end))
+  AND (sqlc.narg(cursor_start_time) IS NULL
+    OR r.start_time < sqlc.narg(cursor_start_time)
+    OR (r.start_time = sqlc.narg(cursor_start_time) AND r.id < sqlc.narg(cursor_id)))
+ORDER BY r.start_time DESC, r.id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: SearchReservationsFullText :many
+SELECT r.*, u.name as user_name, CAST(
+    (CASE WHEN instr(lower(r.title), lower(sqlc.arg(query))) > 0 THEN 2 ELSE 0 END)
+    + (CASE WHEN instr(lower(r.description), lower(sqlc.arg(query))) > 0 THEN 1 ELSE 0 END)
+  AS REAL) AS score
+FROM reservations AS r
+JOIN users AS u ON r.user_id = u.id AND u.deleted_at IS NULL
+WHERE
+  (instr(lower(r.title), lower(sqlc.arg(query))) > 0 OR instr(lower(r.description), lower(sqlc.arg(query))) > 0)
+  AND (r.status <> 'canceled' OR r.user_id = sqlc.arg(viewer_id))
+  AND (r.visibility <> 'private' OR r.user_id = sqlc.arg(viewer_id) OR EXISTS (
+    SELECT 1 FROM reservation_attendees AS a
+    WHERE a.reservation_id = r.id AND a.user_id = sqlc.arg(viewer_id)
+  ))
+ORDER BY score DESC, r.start_time DESC, r.id DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: UpdateReservationByID :exec
+UPDATE reservations
+SET title = ?, description = ?, headcount = ?, visibility = ?, start_time = ?, end_time = ?, updated_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE id = ?;
+
+-- name: CanceledReservationByID :exec
+UPDATE reservations
+SET
+  status = 'canceled',
+  updated_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE
+  user_id = ? AND id = ?
+  AND status = 'confirmed';
+
+-- name: CreateReservationFromCalendar :one
+INSERT INTO reservations (
+    user_id, title, start_time, end_time, status, origin, google_event_id
+) VALUES (
+    ?, ?, ?, ?, 'confirmed', 'google_calendar', ?
+)
+RETURNING id;
+
+-- name: GetReservationByGoogleEventID :one
+SELECT * FROM reservations
+WHERE google_event_id = ?;
+
+-- name: SetReservationGoogleEventID :exec
+UPDATE reservations
+SET google_event_id = ?
+WHERE id = ?;
+
+-- name: GetCalendarSyncToken :one
+SELECT sync_token FROM calendar_sync_states
+WHERE calendar_id = ?;
+
+-- name: UpsertCalendarSyncToken :exec
+INSERT INTO calendar_sync_states (
+    calendar_id, sync_token
+) VALUES (
+    ?, ?
+)
+ON CONFLICT (calendar_id) DO UPDATE SET
+  sync_token = excluded.sync_token,
+  updated_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now');
+
+-- name: ListConfirmedReservations :many
+SELECT * FROM reservations
+WHERE status = 'confirmed'
+ORDER BY start_time;
+
+-- name: GetReservationByCaldavName :one
+SELECT * FROM reservations
+WHERE status = 'confirmed'
+  AND caldav_name = ?;
+
+-- name: CreateReservationFromCaldav :one
+INSERT INTO reservations (
+    user_id, title, start_time, end_time, status, origin, ical_uid, caldav_name
+) VALUES (
+    ?, ?, ?, ?, 'confirmed', 'caldav', ?, ?
+)
+RETURNING id;
+
+-- name: ListBusyIntervals :many
+SELECT start_time, end_time FROM reservations
+WHERE status = 'confirmed'
+  AND start_time < sqlc.arg(range_end)
+  AND end_time > sqlc.arg(range_start)
+ORDER BY start_time;
+
+-- name: GetReservationByCheckinToken :one
+SELECT r.* FROM reservations AS r
+JOIN reservation_checkin_tokens AS t ON t.reservation_id = r.id
+WHERE t.token = ?;
+
+-- name: GetCheckinTokenByReservationID :one
+SELECT token FROM reservation_checkin_tokens
+WHERE reservation_id = ?;
+
+-- name: CreateCheckinToken :exec
+INSERT INTO reservation_checkin_tokens (
+    reservation_id, token
+) VALUES (
+    ?, ?
+);
+
+-- name: CheckInReservation :exec
+UPDATE reservations
+SET checked_in_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'), updated_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE id = ?
+  AND status = 'confirmed'
+  AND checked_in_at IS NULL;
+
+-- name: ListNoShowCandidates :many
+SELECT * FROM reservations
+WHERE status = 'confirmed'
+  AND checked_in_at IS NULL
+  AND actual_end_time IS NULL
+  AND start_time <= sqlc.arg(started_before)
+  AND start_time > sqlc.arg(started_after)
+ORDER BY start_time;
+
+-- name: MarkReservationNoShow :execresult
+UPDATE reservations
+SET status = 'no_show', updated_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE id = ?
+  AND status = 'confirmed'
+  AND checked_in_at IS NULL
+  AND actual_end_time IS NULL;
+
+-- name: CountNoShowsByUserID :one
+SELECT COUNT(*) FROM reservations
+WHERE user_id = ?
+  AND status = 'no_show'
+  AND start_time >= ?;
+
+-- name: GetReservationByIDForUpdate :one
+SELECT * FROM reservations
+WHERE id = ?;
+
+-- name: LockRoom :one
+-- Write transactions are already serialized by BEGIN IMMEDIATE; this only checks that the room exists
+SELECT id FROM room_locks
+WHERE id = ?;
+
+-- name: ListOverlappingReservationIDsForUpdate :many
+-- SQLite has no row locks; transactions are serialized by BEGIN IMMEDIATE (_txlock=immediate)
+SELECT id FROM reservations
+WHERE status = 'confirmed'
+  AND start_time < sqlc.arg(range_end)
+  AND end_time > sqlc.arg(range_start)
+  AND id <> sqlc.arg(exclude_id)
+ORDER BY id;
+
+-- name: EndReservationEarly :exec
+UPDATE reservations
+SET
+  booked_end_time = COALESCE(booked_end_time, end_time),
+  end_time = ?,
+  actual_end_time = ?,
+  updated_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE id = ?;
+
+-- name: ExtendReservation :exec
+UPDATE reservations
+SET
+  booked_end_time = COALESCE(booked_end_time, end_time),
+  end_time = ?,
+  updated_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE id = ?;
+
+-- name: CreateReservationEvent :exec
+INSERT INTO reservation_events (
+    reservation_id, actor_user_id, action, before_data, after_data, client_ip, auth_method
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?
+);
+
+-- name: ListReservationEventsByReservationID :many
+SELECT e.*, u.name as actor_name
+FROM reservation_events AS e
+LEFT JOIN users AS u ON e.actor_user_id = u.id
+WHERE e.reservation_id = ?
+ORDER BY e.id;
+
+-- name: SearchReservationEvents :many
+SELECT e.*, u.name as actor_name
+FROM reservation_events AS e
+LEFT JOIN users AS u ON e.actor_user_id = u.id
+WHERE
+  (sqlc.narg(actor_user_id) IS NULL OR e.actor_user_id = sqlc.narg(actor_user_id))
+  AND (sqlc.narg(reservation_id) IS NULL OR e.reservation_id = sqlc.narg(reservation_id))
+  AND (sqlc.narg(action) IS NULL OR e.action = sqlc.narg(action))
+  AND (sqlc.narg(auth_method) IS NULL OR e.auth_method = sqlc.narg(auth_method))
+  AND (sqlc.narg(created_from) IS NULL OR e.created_at >= sqlc.narg(created_from))
+  AND (sqlc.narg(created_to) IS NULL OR e.created_at < sqlc.narg(created_to))
+ORDER BY e.id DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: AddReservationAttendee :exec
+INSERT INTO reservation_attendees (
+    reservation_id, user_id
+) VALUES (
+    ?, ?
+);
+
+-- name: DeleteReservationAttendee :exec
+DELETE FROM reservation_attendees
+WHERE reservation_id = ? AND user_id = ?;
+
+-- name: UpdateReservationAttendeeResponse :exec
+UPDATE reservation_attendees
+SET response = ?, responded_at = ?
+WHERE reservation_id = ? AND user_id = ?;
+
+-- name: ListReservationAttendees :many
+SELECT a.reservation_id, a.user_id, u.name, a.response, a.responded_at
+FROM reservation_attendees AS a
+JOIN users AS u ON a.user_id = u.id AND u.deleted_at IS NULL
+WHERE a.reservation_id IN (sqlc.slice('reservation_ids'))
+ORDER BY a.reservation_id, a.user_id;
+
+-- name: ListInvitationsByUserID :many
+SELECT r.*, u.name AS user_name, a.response, a.responded_at
+FROM reservation_attendees AS a
+JOIN reservations AS r ON a.reservation_id = r.id
+JOIN users AS u ON r.user_id = u.id AND u.deleted_at IS NULL
+WHERE a.user_id = sqlc.arg(user_id) AND r.status = 'confirmed' AND r.end_time >= sqlc.arg(after)
+ORDER BY r.start_time, r.id;
+
+-- name: CreateEquipment :one
+INSERT INTO equipment (
+    name, quantity
+) VALUES (
+    ?, ?
+)
+RETURNING id;
+
+-- name: UpdateEquipment :exec
+UPDATE equipment
+SET name = ?, quantity = ?, updated_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE id = ?;
+
+-- name: GetEquipmentByID :one
+SELECT * FROM equipment
+WHERE id = ?;
+
+-- name: GetEquipmentByIDForUpdate :one
+SELECT * FROM equipment
+WHERE id = ?;
+
+-- name: ListEquipment :many
+SELECT * FROM equipment
+ORDER BY id;
+
+-- name: ListEquipmentUsage :many
+SELECT re.reservation_id, re.equipment_id, re.quantity, r.start_time, r.end_time
+FROM reservation_equipment AS re
+JOIN reservations AS r ON re.reservation_id = r.id
+WHERE r.status = 'confirmed'
+  AND r.start_time < sqlc.arg(range_end)
+  AND r.end_time > sqlc.arg(range_start)
+  AND r.id <> sqlc.arg(exclude_id)
+ORDER BY re.equipment_id, r.start_time, re.reservation_id;
+
+-- name: AddReservationEquipment :exec
+INSERT INTO reservation_equipment (
+    reservation_id, equipment_id, quantity
+) VALUES (
+    ?, ?, ?
+);
+
+-- name: DeleteReservationEquipment :exec
+DELETE FROM reservation_equipment
+WHERE reservation_id = ?;
+
+-- name: ListReservationEquipment :many
+SELECT re.reservation_id, re.equipment_id, e.name, re.quantity
+FROM reservation_equipment AS re
+JOIN equipment AS e ON re.equipment_id = e.id
+WHERE re.reservation_id IN (sqlc.slice('reservation_ids'))
+ORDER BY re.reservation_id, re.equipment_id;
@@ -0,0 +1,22 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// RunInTx は fn をトランザクション内で実行します。
+// fn がエラーを返した場合はロールバックし、そうでなければコミットします。
+// (このファイルは sqlc の生成対象ではありません)
+func RunInTx(ctx context.Context, sqlDB *sql.DB, fn func(qtx *Queries) error) error {
+	tx, err := sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(New(tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
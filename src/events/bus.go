@@ -0,0 +1,110 @@
+package events
+
+import (
+	"sync"
+	"time"
+
+	"yoyaku/db"
+)
+
+// イベントの種類
+const (
+	TypeReservationCreated  = "reservation.created"
+	TypeReservationUpdated  = "reservation.updated"
+	TypeReservationCanceled = "reservation.canceled"
+	// チェックインされた
+	TypeReservationCheckedIn = "reservation.checked_in"
+	// チェックインされずに解放された
+	TypeReservationNoShow = "reservation.no_show"
+)
+
+// イベントの発生元。空の場合はこのAPIでの操作を表します。
+const (
+	SourceGoogleCalendar = "google_calendar"
+)
+
+// Event は予約の変更を購読者に通知するためのメッセージです。
+// 複数のサーバー間で共有できるように、JSONにそのまま変換できる形にしています。
+type Event struct {
+	Type        string         `json:"type"`
+	Reservation db.Reservation `json:"reservation"`
+	Source      string         `json:"source,omitempty"`
+	// Previous は変更前の予約です (予約を更新した場合のみ)。時間帯を変更した予約を、
+	// 変更前の時間帯を表示している購読者にも配信するために使います。配信する JSON には含めません。
+	Previous *db.Reservation `json:"-"`
+	// PreviousEquipment は変更前に借りていた備品のIDです (予約を更新した場合のみ)。
+	// 備品を外した予約を、その備品で絞り込んでいる購読者にも配信するために使います。
+	PreviousEquipment []uint64  `json:"-"`
+	OccurredAt        time.Time `json:"occurred_at"`
+}
+
+// Bus はイベントの配信を行うバックエンドのインターフェースです。
+// 現在はプロセス内の MemoryBus のみですが、Redis などを使った実装に差し替えることで
+// 複数のレプリカ間でイベントを共有できるようにします。
+type Bus interface {
+	// Publish はイベントを全ての購読者に配信します。
+	Publish(event Event)
+	// Subscribe は新しい購読を開始します。不要になったら Close を呼んでください。
+	Subscribe() *Subscription
+}
+
+// Subscription はひとつの購読者を表します。
+type Subscription struct {
+	C      <-chan Event
+	ch     chan Event
+	cancel func()
+	once   sync.Once
+}
+
+// Close は購読を終了し、チャネルを閉じます。
+func (s *Subscription) Close() {
+	s.once.Do(s.cancel)
+}
+
+// MemoryBus はプロセス内でイベントを配信する Bus の実装です。
+type MemoryBus struct {
+	mu          sync.RWMutex
+	subscribers map[*Subscription]struct{}
+	bufferSize  int
+}
+
+// NewMemoryBus は MemoryBus を生成します。
+// bufferSize は購読者ごとのバッファ数で、溢れたイベントはその購読者に対して破棄されます。
+func NewMemoryBus(bufferSize int) *MemoryBus {
+	return &MemoryBus{
+		subscribers: make(map[*Subscription]struct{}),
+		bufferSize:  bufferSize,
+	}
+}
+
+func (b *MemoryBus) Publish(event Event) {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for sub := range b.subscribers {
+		select {
+		case sub.ch <- event:
+		default:
+			// 受信が追いついていない購読者のためにハンドラを止めないよう、イベントを破棄する
+		}
+	}
+}
+
+func (b *MemoryBus) Subscribe() *Subscription {
+	ch := make(chan Event, b.bufferSize)
+	sub := &Subscription{C: ch, ch: ch}
+	sub.cancel = func() {
+		b.mu.Lock()
+		delete(b.subscribers, sub)
+		b.mu.Unlock()
+		close(ch)
+	}
+
+	b.mu.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
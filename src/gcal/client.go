@@ -0,0 +1,214 @@
+package gcal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// DefaultBaseURL は Google Calendar API v3 のエンドポイントです。
+const DefaultBaseURL = "https://www.googleapis.com/calendar/v3"
+
+// ErrSyncTokenExpired は同期トークンが無効になり、全件同期からやり直す必要があることを表します。
+var ErrSyncTokenExpired = errors.New("gcal: sync token expired")
+
+// StatusError は Calendar API が成功以外のステータスを返したことを表します。
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("gcal: %s %s returned %d: %s", e.Method, e.URL, e.StatusCode, e.Body)
+}
+
+// hasStatus は err が code のステータスの StatusError かを返します。
+func hasStatus(err error, code int) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == code
+}
+
+// EventDateTime は予定の開始・終了時刻です。終日の予定は Date のみが設定されます。
+type EventDateTime struct {
+	DateTime string `json:"dateTime,omitempty"`
+	Date     string `json:"date,omitempty"`
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// EventCreator は予定の作成者です。
+type EventCreator struct {
+	Email string `json:"email,omitempty"`
+}
+
+// ExtendedProperties は予定に付与する独自のプロパティです。
+type ExtendedProperties struct {
+	Private map[string]string `json:"private,omitempty"`
+}
+
+// Event は Calendar API の予定リソースのうち、同期に必要な項目です。
+type Event struct {
+	ID                 string              `json:"id,omitempty"`
+	Status             string              `json:"status,omitempty"`
+	Summary            string              `json:"summary,omitempty"`
+	Start              *EventDateTime      `json:"start,omitempty"`
+	End                *EventDateTime      `json:"end,omitempty"`
+	Updated            time.Time           `json:"updated,omitzero"`
+	Creator            *EventCreator       `json:"creator,omitempty"`
+	ExtendedProperties *ExtendedProperties `json:"extendedProperties,omitempty"`
+}
+
+// EventList は events.list のレスポンスです。
+// 最後のページにのみ NextSyncToken が含まれます。
+type EventList struct {
+	Items         []Event `json:"items"`
+	NextPageToken string  `json:"nextPageToken"`
+	NextSyncToken string  `json:"nextSyncToken"`
+}
+
+// Client は同期処理が利用する Calendar API の操作です。
+// テストでは httptest のサーバーに向けた HTTPClient や、独自の実装に差し替えられます。
+type Client interface {
+	// ListEvents は予定を取得します。syncToken が空の場合は全件を取得します。
+	// 同期トークンが無効になっている場合は ErrSyncTokenExpired を返します。
+	ListEvents(ctx context.Context, calendarID, syncToken, pageToken string) (*EventList, error)
+	InsertEvent(ctx context.Context, calendarID string, event *Event) (*Event, error)
+	UpdateEvent(ctx context.Context, calendarID, eventID string, event *Event) (*Event, error)
+	// DeleteEvent は予定を削除します。予定がすでに削除されている場合も nil を返します。
+	DeleteEvent(ctx context.Context, calendarID, eventID string) error
+}
+
+// HTTPClient は Calendar API の REST エンドポイントを直接呼び出す Client の実装です。
+type HTTPClient struct {
+	httpClient *http.Client
+	baseURL    string
+}
+
+// NewHTTPClient は HTTPClient を生成します。
+// httpClient には認証済みのクライアント (oauth2.NewClient など) を渡してください。
+func NewHTTPClient(httpClient *http.Client, baseURL string) *HTTPClient {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &HTTPClient{httpClient: httpClient, baseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// NewServiceAccountClient はサービスアカウントの認証情報ファイルを使って HTTPClient を生成します。
+// 同期するカレンダーはサービスアカウントに「予定の変更権限」で共有しておく必要があります。
+func NewServiceAccountClient(ctx context.Context, credentialsFile string) (*HTTPClient, error) {
+	data, err := os.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("gcal: failed reading credentials: %w", err)
+	}
+	creds, err := google.CredentialsFromJSON(ctx, data, "https://www.googleapis.com/auth/calendar")
+	if err != nil {
+		return nil, fmt.Errorf("gcal: invalid credentials: %w", err)
+	}
+	return NewHTTPClient(oauth2.NewClient(ctx, creds.TokenSource), DefaultBaseURL), nil
+}
+
+func (c *HTTPClient) ListEvents(ctx context.Context, calendarID, syncToken, pageToken string) (*EventList, error) {
+	query := url.Values{}
+	query.Set("singleEvents", "true")
+	query.Set("showDeleted", "true")
+	if syncToken != "" {
+		query.Set("syncToken", syncToken)
+	}
+	if pageToken != "" {
+		query.Set("pageToken", pageToken)
+	}
+
+	var list EventList
+	err := c.do(ctx, http.MethodGet, c.eventsURL(calendarID, "")+"?"+query.Encode(), nil, &list)
+	// 一覧の 410 Gone は同期トークンの期限切れを表す
+	if syncToken != "" && hasStatus(err, http.StatusGone) {
+		return nil, ErrSyncTokenExpired
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+func (c *HTTPClient) InsertEvent(ctx context.Context, calendarID string, event *Event) (*Event, error) {
+	var created Event
+	if err := c.do(ctx, http.MethodPost, c.eventsURL(calendarID, ""), event, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (c *HTTPClient) UpdateEvent(ctx context.Context, calendarID, eventID string, event *Event) (*Event, error) {
+	var updated Event
+	if err := c.do(ctx, http.MethodPut, c.eventsURL(calendarID, eventID), event, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+func (c *HTTPClient) DeleteEvent(ctx context.Context, calendarID, eventID string) error {
+	err := c.do(ctx, http.MethodDelete, c.eventsURL(calendarID, eventID), nil, nil)
+	// 削除済みの予定は 410 Gone、存在しない予定は 404 Not Found になるが、どちらも目的は達している
+	if hasStatus(err, http.StatusGone) || hasStatus(err, http.StatusNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (c *HTTPClient) eventsURL(calendarID, eventID string) string {
+	u := c.baseURL + "/calendars/" + url.PathEscape(calendarID) + "/events"
+	if eventID != "" {
+		u += "/" + url.PathEscape(eventID)
+	}
+	return u
+}
+
+func (c *HTTPClient) do(ctx context.Context, method, u string, body any, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("gcal: failed encoding request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return fmt.Errorf("gcal: failed creating request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("gcal: request failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return &StatusError{Method: method, URL: u, StatusCode: res.StatusCode, Body: strings.TrimSpace(string(msg))}
+	}
+
+	if out == nil || res.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("gcal: failed decoding response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,348 @@
+package gcal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"yoyaku/audit"
+	"yoyaku/db"
+	"yoyaku/events"
+	"yoyaku/reservation"
+	"yoyaku/store"
+)
+
+// 予約の作成元 (reservations.origin)
+const (
+	OriginAPI            = "api"
+	OriginGoogleCalendar = "google_calendar"
+)
+
+// Googleカレンダーの予定に保存する、対応する予約IDのキー
+const reservationIDProperty = "yoyakuReservationId"
+
+// 停止するときに、受け取り済みの変更をカレンダーに反映するのを待つ時間の上限
+// (server.DefaultShutdownTimeout より短くし、他の処理の停止の時間を残す)
+const drainTimeout = 10 * time.Second
+
+// Syncer は予約テーブルと共有Googleカレンダーの双方向同期を行います。
+//
+//   - API経由の予約の作成・編集・キャンセルはイベントバスから受け取り、カレンダーに反映します。
+//   - カレンダーで直接作成・編集・削除された予定は、同期トークンを使って定期的に差分を取り込みます。
+//
+// カレンダーからの取り込みは reservation.Service を通すため、API と同じ入力の検証・重複の確認・
+// no-show による利用停止が適用されます。取り込めない変更は、カレンダーを予約テーブルに合わせて戻します。
+//
+// 双方で同じ予約が変更されていた場合は更新日時が新しい方を採用します。
+// 予約テーブルの更新日時は秒単位のため、秒単位で比べて同じ場合は予約テーブル側を優先します。
+type Syncer struct {
+	client     Client
+	store      store.Store
+	service    *reservation.Service
+	bus        events.Bus
+	calendarID string
+	interval   time.Duration
+}
+
+// NewSyncer は Syncer を生成します。
+func NewSyncer(client Client, st store.Store, service *reservation.Service, bus events.Bus, calendarID string, interval time.Duration) *Syncer {
+	return &Syncer{
+		client:     client,
+		store:      st,
+		service:    service,
+		bus:        bus,
+		calendarID: calendarID,
+		interval:   interval,
+	}
+}
+
+// Run は ctx がキャンセルされるまで同期を続けます。
+// キャンセルされたときは、既に受け取っている予約の変更をカレンダーに反映してから戻ります。
+func (s *Syncer) Run(ctx context.Context) {
+	sub := s.bus.Subscribe()
+	defer sub.Close()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	if err := s.Pull(ctx); err != nil {
+		log.Println("Googleカレンダーからの同期に失敗しました:", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.drain(ctx, sub)
+			return
+		case <-ticker.C:
+			if err := s.Pull(ctx); err != nil {
+				log.Println("Googleカレンダーからの同期に失敗しました:", err)
+			}
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if err := s.Push(ctx, event); err != nil {
+				log.Println("Googleカレンダーへの同期に失敗しました:", err)
+			}
+		}
+	}
+}
+
+// drain は sub に届いている予約の変更を全てカレンダーに反映します。
+// ctx は停止のためキャンセルされているため、キャンセルを引き継がず drainTimeout の期限を付けて送信します。
+func (s *Syncer) drain(ctx context.Context, sub *events.Subscription) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), drainTimeout)
+	defer cancel()
+
+	for {
+		select {
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if err := s.Push(ctx, event); err != nil {
+				log.Println("Googleカレンダーへの同期に失敗しました:", err)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// Push は予約の変更をカレンダーに反映します。
+func (s *Syncer) Push(ctx context.Context, event events.Event) error {
+	// カレンダーから取り込んだ変更を送り返さない
+	if event.Source == events.SourceGoogleCalendar {
+		return nil
+	}
+
+	// イベントにはgoogle_event_idが含まれていない場合があるため、最新の予約を取得する
+	reservation, err := s.store.GetReservationByID(ctx, event.Reservation.ID)
+	if err != nil {
+		return fmt.Errorf("予約 %d の取得に失敗しました: %w", event.Reservation.ID, err)
+	}
+
+	switch event.Type {
+	case events.TypeReservationCreated, events.TypeReservationUpdated:
+		return s.pushReservation(ctx, reservation)
+	case events.TypeReservationCanceled, events.TypeReservationNoShow:
+		if !reservation.GoogleEventID.Valid {
+			return nil
+		}
+		return s.client.DeleteEvent(ctx, s.calendarID, reservation.GoogleEventID.String)
+	}
+	return nil
+}
+
+// calendarSummary は予約をカレンダーに表示するときのタイトルです。
+// 共有カレンダーは誰でも見られるため、非公開の予約は「予約済み」と表示します。
+func calendarSummary(r db.Reservation) string {
+	if r.Visibility == reservation.VisibilityPrivate {
+		return reservation.MaskedTitle
+	}
+	return r.Title
+}
+
+func (s *Syncer) pushReservation(ctx context.Context, reservation db.Reservation) error {
+	calendarEvent := &Event{
+		Summary: calendarSummary(reservation),
+		Start:   &EventDateTime{DateTime: reservation.StartTime.Format(time.RFC3339)},
+		End:     &EventDateTime{DateTime: reservation.EndTime.Format(time.RFC3339)},
+		ExtendedProperties: &ExtendedProperties{Private: map[string]string{
+			reservationIDProperty: strconv.FormatUint(reservation.ID, 10),
+		}},
+	}
+
+	if reservation.GoogleEventID.Valid {
+		_, err := s.client.UpdateEvent(ctx, s.calendarID, reservation.GoogleEventID.String, calendarEvent)
+		return err
+	}
+
+	created, err := s.client.InsertEvent(ctx, s.calendarID, calendarEvent)
+	if err != nil {
+		return err
+	}
+	return s.store.SetReservationGoogleEventID(ctx, db.SetReservationGoogleEventIDParams{
+		GoogleEventID: sql.NullString{String: created.ID, Valid: true},
+		ID:            reservation.ID,
+	})
+}
+
+// Pull はカレンダーの差分を取り込みます。
+// 同期トークンが無効になっている場合は全件同期をやり直します。
+func (s *Syncer) Pull(ctx context.Context) error {
+	token, err := s.store.GetCalendarSyncToken(ctx, s.calendarID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("同期トークンの取得に失敗しました: %w", err)
+	}
+
+	nextSyncToken, err := s.pullPages(ctx, token.String)
+	if errors.Is(err, ErrSyncTokenExpired) {
+		log.Println("Googleカレンダーの同期トークンが無効になったため、全件同期を行います")
+		nextSyncToken, err = s.pullPages(ctx, "")
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.store.UpsertCalendarSyncToken(ctx, db.UpsertCalendarSyncTokenParams{
+		CalendarID: s.calendarID,
+		SyncToken:  sql.NullString{String: nextSyncToken, Valid: nextSyncToken != ""},
+	})
+}
+
+func (s *Syncer) pullPages(ctx context.Context, syncToken string) (string, error) {
+	pageToken := ""
+	for {
+		list, err := s.client.ListEvents(ctx, s.calendarID, syncToken, pageToken)
+		if err != nil {
+			return "", err
+		}
+		for _, calendarEvent := range list.Items {
+			if err := s.applyEvent(ctx, calendarEvent); err != nil {
+				log.Printf("予定 %s の取り込みに失敗しました: %v", calendarEvent.ID, err)
+			}
+		}
+		if list.NextPageToken == "" {
+			return list.NextSyncToken, nil
+		}
+		pageToken = list.NextPageToken
+	}
+}
+
+func (s *Syncer) applyEvent(ctx context.Context, calendarEvent Event) error {
+	existing, err := s.store.GetReservationByGoogleEventID(ctx, sql.NullString{String: calendarEvent.ID, Valid: true})
+	found := err == nil
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	actor := audit.System(audit.MethodGoogleCalendar)
+
+	if calendarEvent.Status == "cancelled" {
+		if !found || existing.Status != "confirmed" {
+			return nil
+		}
+		_, err := s.service.CancelFromCalendar(ctx, actor, existing.ID)
+		return err
+	}
+
+	startTime, endTime, ok := eventTimes(calendarEvent)
+	if !ok {
+		// 終日の予定は部屋の予約として扱わない
+		return nil
+	}
+
+	if found {
+		if existing.Status != "confirmed" {
+			return nil
+		}
+		// 非公開の予約のタイトルはカレンダーに出していないため、「予約済み」のままなら元のタイトルを残す
+		title := calendarEvent.Summary
+		if existing.Visibility == reservation.VisibilityPrivate && title == reservation.MaskedTitle {
+			title = existing.Title
+		}
+		if existing.Title == title && existing.StartTime.Equal(startTime) && existing.EndTime.Equal(endTime) {
+			return nil
+		}
+		// 予約テーブル側の方が新しい (同じ秒を含む) 場合は、カレンダーを予約に合わせる
+		if !calendarEvent.Updated.Truncate(time.Second).After(existing.UpdatedAt.Truncate(time.Second)) {
+			return s.pushReservation(ctx, existing)
+		}
+
+		_, err := s.service.UpdateFromCalendar(ctx, actor, existing.ID, reservation.CalendarEvent{
+			Title:     title,
+			StartTime: startTime,
+			EndTime:   endTime,
+		})
+		if rejected(err) {
+			log.Printf("予定 %s の変更は取り込めないため、カレンダーを予約に戻します: %v", calendarEvent.ID, err)
+			return s.pushReservation(ctx, existing)
+		}
+		return err
+	}
+
+	// このAPIから作成した予定は、google_event_idの保存前に取得された場合でも二重に取り込まない
+	if calendarEvent.ExtendedProperties != nil && calendarEvent.ExtendedProperties.Private[reservationIDProperty] != "" {
+		return nil
+	}
+
+	userID, err := s.ownerID(ctx, calendarEvent)
+	if err != nil {
+		return err
+	}
+	_, err = s.service.CreateFromCalendar(ctx, actor, reservation.CalendarEvent{
+		Title:         calendarEvent.Summary,
+		StartTime:     startTime,
+		EndTime:       endTime,
+		GoogleEventID: calendarEvent.ID,
+		UserID:        userID,
+	})
+	if rejected(err) || errors.Is(err, reservation.ErrSuspended) {
+		log.Printf("予定 %s は取り込めません: %v", calendarEvent.ID, err)
+		return nil
+	}
+	return err
+}
+
+// rejected は予約のルール (入力・重複・備品) により取り込めなかったエラーかを返します。
+func rejected(err error) bool {
+	return errors.Is(err, reservation.ErrValidation) ||
+		errors.Is(err, reservation.ErrConflict) ||
+		errors.Is(err, reservation.ErrEquipmentUnavailable)
+}
+
+// ownerID は予定の作成者に対応するユーザーを返します。
+// 作成者が登録済みのユーザーでない場合は、カレンダー用のシステムユーザーの予約として取り込みます。
+func (s *Syncer) ownerID(ctx context.Context, calendarEvent Event) (uint64, error) {
+	if calendarEvent.Creator != nil && calendarEvent.Creator.Email != "" {
+		user, err := s.store.GetUserByEmail(ctx, calendarEvent.Creator.Email)
+		if err == nil {
+			return user.ID, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return 0, err
+		}
+	}
+
+	googleID := "google-calendar:" + s.calendarID
+	user, err := s.store.GetUserByGoogleID(ctx, googleID)
+	if err == nil {
+		return user.ID, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+	result, err := s.store.CreateUser(ctx, db.CreateUserParams{
+		Name:     "Googleカレンダー",
+		Email:    s.calendarID,
+		GoogleID: googleID,
+		Role:     "system",
+	})
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(id), nil
+}
+
+// eventTimes は予定の開始・終了時刻を返します。時刻が指定されていない場合は false を返します。
+func eventTimes(calendarEvent Event) (time.Time, time.Time, bool) {
+	if calendarEvent.Start == nil || calendarEvent.End == nil ||
+		calendarEvent.Start.DateTime == "" || calendarEvent.End.DateTime == "" {
+		return time.Time{}, time.Time{}, false
+	}
+	startTime, err1 := time.Parse(time.RFC3339, calendarEvent.Start.DateTime)
+	endTime, err2 := time.Parse(time.RFC3339, calendarEvent.End.DateTime)
+	if err1 != nil || err2 != nil || !endTime.After(startTime) {
+		return time.Time{}, time.Time{}, false
+	}
+	return startTime, endTime, true
+}
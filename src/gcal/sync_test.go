@@ -0,0 +1,498 @@
+package gcal
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"yoyaku/audit"
+	"yoyaku/checkin"
+	"yoyaku/db"
+	"yoyaku/events"
+	"yoyaku/reservation"
+	"yoyaku/store"
+	"yoyaku/types"
+)
+
+const testCalendarID = "room401@group.calendar.google.com"
+
+// fakeCalendar は Calendar API の events エンドポイントを模したサーバーです。
+type fakeCalendar struct {
+	mu sync.Mutex
+	// items は次の events.list で返す予定です。
+	items []Event
+	// expiredTokens に含まれる同期トークンでの events.list は 410 Gone を返します。
+	expiredTokens map[string]bool
+	// events は挿入・更新された予定です (削除した予定は含みません)。
+	events   map[string]Event
+	nextID   int
+	requests []string
+}
+
+func newFakeCalendar(t *testing.T) (*fakeCalendar, *HTTPClient) {
+	t.Helper()
+	f := &fakeCalendar{expiredTokens: map[string]bool{}, events: map[string]Event{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, NewHTTPClient(srv.Client(), srv.URL)
+}
+
+func (f *fakeCalendar) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	prefix := "/calendars/" + testCalendarID + "/events"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		http.NotFound(w, r)
+		return
+	}
+	eventID := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, prefix), "/")
+	syncToken := r.URL.Query().Get("syncToken")
+	f.requests = append(f.requests, strings.TrimSpace(r.Method+" "+eventID+" "+syncToken))
+
+	switch {
+	case r.Method == http.MethodGet && eventID == "":
+		if f.expiredTokens[syncToken] {
+			http.Error(w, `{"error":{"code":410,"message":"Sync token is no longer valid"}}`, http.StatusGone)
+			return
+		}
+		items := f.items
+		f.items = nil
+		writeJSON(w, EventList{Items: items, NextSyncToken: fmt.Sprintf("token-%d", len(f.requests))})
+	case r.Method == http.MethodPost && eventID == "":
+		var event Event
+		json.NewDecoder(r.Body).Decode(&event)
+		f.nextID++
+		event.ID = fmt.Sprintf("event-%d", f.nextID)
+		event.Updated = time.Now()
+		f.events[event.ID] = event
+		writeJSON(w, event)
+	case r.Method == http.MethodPut:
+		if _, ok := f.events[eventID]; !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		var event Event
+		json.NewDecoder(r.Body).Decode(&event)
+		event.ID = eventID
+		event.Updated = time.Now()
+		f.events[eventID] = event
+		writeJSON(w, event)
+	case r.Method == http.MethodDelete:
+		if _, ok := f.events[eventID]; !ok {
+			http.Error(w, "deleted", http.StatusGone)
+			return
+		}
+		delete(f.events, eventID)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func (f *fakeCalendar) setItems(items ...Event) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.items = items
+}
+
+func (f *fakeCalendar) event(id string) (Event, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	event, ok := f.events[id]
+	return event, ok
+}
+
+func (f *fakeCalendar) lastRequest() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.requests) == 0 {
+		return ""
+	}
+	return f.requests[len(f.requests)-1]
+}
+
+type testSyncer struct {
+	*Syncer
+	calendar *fakeCalendar
+	store    *store.Memory
+	svc      *reservation.Service
+	bus      *events.MemoryBus
+}
+
+func newTestSyncer(t *testing.T) *testSyncer {
+	t.Helper()
+	calendar, client := newFakeCalendar(t)
+	st := store.NewMemory()
+	bus := events.NewMemoryBus(16)
+	svc := reservation.NewService(st, bus, checkin.DefaultPolicy(), reservation.DefaultRoom())
+	return &testSyncer{
+		Syncer:   NewSyncer(client, st, svc, bus, testCalendarID, time.Minute),
+		calendar: calendar,
+		store:    st,
+		svc:      svc,
+		bus:      bus,
+	}
+}
+
+func (s *testSyncer) createUser(t *testing.T, name string) uint64 {
+	t.Helper()
+	result, err := s.store.CreateUser(context.Background(), db.CreateUserParams{
+		Name: name, Email: name + "@example.com", GoogleID: "google-" + name, Role: "user",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := result.LastInsertId()
+	return uint64(id)
+}
+
+// createLinked は API から予約を作成し、カレンダーの予定 eventID と紐付けます。
+func (s *testSyncer) createLinked(t *testing.T, userID uint64, title string, start, end time.Time, eventID string) db.Reservation {
+	t.Helper()
+	ctx := context.Background()
+	created, err := s.svc.Create(ctx, audit.Actor{UserID: userID, Method: audit.MethodSession}, types.ReservationsRequest{
+		Title: title, StartTime: start, EndTime: end,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.store.SetReservationGoogleEventID(ctx, db.SetReservationGoogleEventIDParams{
+		GoogleEventID: sql.NullString{String: eventID, Valid: true}, ID: created.ID,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	s.calendar.mu.Lock()
+	s.calendar.events[eventID] = Event{ID: eventID}
+	s.calendar.mu.Unlock()
+	r, err := s.store.GetReservationByID(ctx, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func at(hour, minute int) time.Time {
+	return time.Date(2030, 1, 7, hour, minute, 0, 0, time.UTC)
+}
+
+func calendarEvent(id, summary, creator string, start, end time.Time) Event {
+	return Event{
+		ID:      id,
+		Status:  "confirmed",
+		Summary: summary,
+		Start:   &EventDateTime{DateTime: start.Format(time.RFC3339)},
+		End:     &EventDateTime{DateTime: end.Format(time.RFC3339)},
+		Updated: time.Now(),
+		Creator: &EventCreator{Email: creator},
+	}
+}
+
+func TestPullImportsEvents(t *testing.T) {
+	s := newTestSyncer(t)
+	ctx := context.Background()
+	alice := s.createUser(t, "alice")
+	sub := s.bus.Subscribe()
+	defer sub.Close()
+
+	allDay := calendarEvent("all-day", "終日", "alice@example.com", at(0, 0), at(0, 0))
+	allDay.Start, allDay.End = &EventDateTime{Date: "2030-01-07"}, &EventDateTime{Date: "2030-01-08"}
+	s.calendar.setItems(
+		calendarEvent("new", "定例", "alice@example.com", at(10, 0), at(11, 0)),
+		// 取り込んだ予定と重なるため、API と同じく重複として取り込まない
+		calendarEvent("overlap", "重複", "bob@example.com", at(10, 30), at(11, 30)),
+		// タイトルが空の予定は API と同じく検証エラーとして取り込まない
+		calendarEvent("untitled", " ", "alice@example.com", at(13, 0), at(14, 0)),
+		allDay,
+	)
+	if err := s.Pull(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	imported, err := s.store.GetReservationByGoogleEventID(ctx, sql.NullString{String: "new", Valid: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported.UserID != alice || imported.Title != "定例" || imported.Origin != OriginGoogleCalendar || !imported.StartTime.Equal(at(10, 0)) {
+		t.Errorf("取り込んだ予約 = %+v", imported)
+	}
+	for _, id := range []string{"overlap", "untitled", "all-day"} {
+		if _, err := s.store.GetReservationByGoogleEventID(ctx, sql.NullString{String: id, Valid: true}); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("予定 %s を取り込まないはずが err = %v", id, err)
+		}
+	}
+
+	recorded := s.store.Events()
+	if len(recorded) != 1 || recorded[0].Action != audit.ActionCreated || recorded[0].AuthMethod != audit.MethodGoogleCalendar {
+		t.Errorf("変更履歴 = %+v", recorded)
+	}
+	select {
+	case event := <-sub.C:
+		if event.Type != events.TypeReservationCreated || event.Source != events.SourceGoogleCalendar {
+			t.Errorf("配信されたイベント = %+v", event)
+		}
+	default:
+		t.Error("取り込んだ予約が配信されていません")
+	}
+
+	token, err := s.store.GetCalendarSyncToken(ctx, testCalendarID)
+	if err != nil || token.String != "token-1" {
+		t.Fatalf("同期トークン = %+v, %v", token, err)
+	}
+	if err := s.Pull(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.calendar.lastRequest(); got != "GET  token-1" {
+		t.Errorf("2回目の取得 = %q, want 同期トークンを使った差分の取得", got)
+	}
+}
+
+func TestPullImportsAsSystemUser(t *testing.T) {
+	s := newTestSyncer(t)
+	ctx := context.Background()
+
+	s.calendar.setItems(
+		calendarEvent("a", "外部の予定", "guest@example.org", at(9, 0), at(10, 0)),
+		calendarEvent("b", "作成者なし", "", at(10, 0), at(11, 0)),
+	)
+	if err := s.Pull(ctx); err != nil {
+		t.Fatal(err)
+	}
+	a, errA := s.store.GetReservationByGoogleEventID(ctx, sql.NullString{String: "a", Valid: true})
+	b, errB := s.store.GetReservationByGoogleEventID(ctx, sql.NullString{String: "b", Valid: true})
+	if errA != nil || errB != nil {
+		t.Fatal(errA, errB)
+	}
+	if a.UserID != b.UserID {
+		t.Errorf("システムユーザーが2人作成されました: %d, %d", a.UserID, b.UserID)
+	}
+	user, err := s.store.GetUserByID(ctx, a.UserID)
+	if err != nil || user.Role != "system" {
+		t.Errorf("予約者 = %+v, %v, want システムユーザー", user, err)
+	}
+}
+
+func TestPullExpiredSyncToken(t *testing.T) {
+	s := newTestSyncer(t)
+	ctx := context.Background()
+	s.createUser(t, "alice")
+
+	if err := s.store.UpsertCalendarSyncToken(ctx, db.UpsertCalendarSyncTokenParams{
+		CalendarID: testCalendarID, SyncToken: sql.NullString{String: "stale", Valid: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	s.calendar.expiredTokens["stale"] = true
+	s.calendar.setItems(calendarEvent("new", "定例", "alice@example.com", at(10, 0), at(11, 0)))
+
+	if err := s.Pull(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.calendar.lastRequest(); got != "GET" {
+		t.Errorf("最後の取得 = %q, want 全件同期", got)
+	}
+	if _, err := s.store.GetReservationByGoogleEventID(ctx, sql.NullString{String: "new", Valid: true}); err != nil {
+		t.Errorf("全件同期で予定を取り込んでいません: %v", err)
+	}
+	if token, _ := s.store.GetCalendarSyncToken(ctx, testCalendarID); token.String == "stale" {
+		t.Error("同期トークンが更新されていません")
+	}
+}
+
+func TestPullUpdates(t *testing.T) {
+	s := newTestSyncer(t)
+	ctx := context.Background()
+	alice := s.createUser(t, "alice")
+	existing := s.createLinked(t, alice, "定例", at(10, 0), at(11, 0), "linked")
+	s.createLinked(t, alice, "別の予約", at(12, 0), at(13, 0), "other")
+
+	// 予約テーブルの更新日時は秒単位のため、同じ秒の変更は予約テーブル側を優先してカレンダーを戻す
+	sameSecond := calendarEvent("linked", "定例 (変更)", "alice@example.com", at(10, 30), at(11, 30))
+	sameSecond.Updated = existing.UpdatedAt.Truncate(time.Second).Add(999 * time.Millisecond)
+	s.calendar.setItems(sameSecond)
+	if err := s.Pull(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.store.GetReservationByID(ctx, existing.ID); got.Title != "定例" || !got.StartTime.Equal(at(10, 0)) {
+		t.Errorf("同じ秒の変更を取り込みました: %+v", got)
+	}
+	if event, _ := s.calendar.event("linked"); event.Summary != "定例" || event.Start.DateTime != at(10, 0).Format(time.RFC3339) {
+		t.Errorf("カレンダーを予約に戻していません: %+v", event)
+	}
+
+	// 予約テーブルより新しい変更は取り込む
+	newer := calendarEvent("linked", "定例 (変更)", "alice@example.com", at(10, 30), at(11, 30))
+	newer.Updated = existing.UpdatedAt.Truncate(time.Second).Add(time.Second)
+	s.calendar.setItems(newer)
+	if err := s.Pull(ctx); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := s.store.GetReservationByID(ctx, existing.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Title != "定例 (変更)" || !updated.StartTime.Equal(at(10, 30)) || !updated.EndTime.Equal(at(11, 30)) {
+		t.Errorf("更新した予約 = %+v", updated)
+	}
+
+	// 他の予約と重なる変更は取り込まず、カレンダーを予約に戻す
+	overlapping := calendarEvent("linked", "定例 (変更)", "alice@example.com", at(11, 30), at(12, 30))
+	overlapping.Updated = time.Now().Add(time.Hour)
+	s.calendar.setItems(overlapping)
+	if err := s.Pull(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.store.GetReservationByID(ctx, existing.ID); !got.StartTime.Equal(at(10, 30)) {
+		t.Errorf("重複する変更を取り込みました: %+v", got)
+	}
+	if event, _ := s.calendar.event("linked"); event.Start.DateTime != at(10, 30).Format(time.RFC3339) {
+		t.Errorf("カレンダーを予約に戻していません: %+v", event)
+	}
+}
+
+func TestPullCancel(t *testing.T) {
+	s := newTestSyncer(t)
+	ctx := context.Background()
+	alice := s.createUser(t, "alice")
+	existing := s.createLinked(t, alice, "定例", at(10, 0), at(11, 0), "linked")
+
+	s.calendar.setItems(Event{ID: "linked", Status: "cancelled"})
+	if err := s.Pull(ctx); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.store.GetReservationByID(ctx, existing.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != "canceled" {
+		t.Errorf("status = %q, want canceled", got.Status)
+	}
+	recorded := s.store.Events()
+	last := recorded[len(recorded)-1]
+	if last.Action != audit.ActionCanceled || last.AuthMethod != audit.MethodGoogleCalendar {
+		t.Errorf("変更履歴 = %+v", last)
+	}
+}
+
+func TestPush(t *testing.T) {
+	s := newTestSyncer(t)
+	ctx := context.Background()
+	alice := s.createUser(t, "alice")
+	actor := audit.Actor{UserID: alice, Method: audit.MethodSession}
+
+	created, err := s.svc.Create(ctx, actor, types.ReservationsRequest{
+		Title: "非公開の打ち合わせ", Visibility: reservation.VisibilityPrivate, StartTime: at(10, 0), EndTime: at(11, 0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Push(ctx, events.Event{Type: events.TypeReservationCreated, Reservation: created.Reservation}); err != nil {
+		t.Fatal(err)
+	}
+	linked, err := s.store.GetReservationByID(ctx, created.ID)
+	if err != nil || !linked.GoogleEventID.Valid {
+		t.Fatalf("google_event_id が保存されていません: %+v, %v", linked, err)
+	}
+	event, ok := s.calendar.event(linked.GoogleEventID.String)
+	if !ok || event.Summary != reservation.MaskedTitle || event.ExtendedProperties.Private[reservationIDProperty] != fmt.Sprint(created.ID) {
+		t.Errorf("登録した予定 = %+v", event)
+	}
+
+	if err := s.Push(ctx, events.Event{Type: events.TypeReservationUpdated, Reservation: created.Reservation}); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.calendar.lastRequest(); got != "PUT "+linked.GoogleEventID.String {
+		t.Errorf("更新のリクエスト = %q", got)
+	}
+
+	// カレンダーから取り込んだ変更は送り返さない
+	before := s.calendar.lastRequest()
+	if err := s.Push(ctx, events.Event{Type: events.TypeReservationUpdated, Reservation: created.Reservation, Source: events.SourceGoogleCalendar}); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.calendar.lastRequest(); got != before {
+		t.Errorf("取り込んだ変更を送り返しました: %q", got)
+	}
+
+	canceled := events.Event{Type: events.TypeReservationCanceled, Reservation: created.Reservation}
+	if err := s.Push(ctx, canceled); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.calendar.event(linked.GoogleEventID.String); ok {
+		t.Error("予定が削除されていません")
+	}
+	// 削除済みの予定 (410 Gone) の削除は成功として扱う
+	if err := s.Push(ctx, canceled); err != nil {
+		t.Errorf("削除済みの予定の削除 = %v, want nil", err)
+	}
+}
+
+func TestHTTPClientStatus(t *testing.T) {
+	calendar, client := newFakeCalendar(t)
+	ctx := context.Background()
+	calendar.expiredTokens["stale"] = true
+
+	if _, err := client.ListEvents(ctx, testCalendarID, "stale", ""); !errors.Is(err, ErrSyncTokenExpired) {
+		t.Errorf("期限切れの同期トークンでの一覧 = %v, want ErrSyncTokenExpired", err)
+	}
+	if err := client.DeleteEvent(ctx, testCalendarID, "missing"); err != nil {
+		t.Errorf("削除済みの予定の削除 = %v, want nil", err)
+	}
+	_, err := client.UpdateEvent(ctx, testCalendarID, "missing", &Event{Summary: "x"})
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound || errors.Is(err, ErrSyncTokenExpired) {
+		t.Errorf("存在しない予定の更新 = %v, want 404 の StatusError", err)
+	}
+}
+
+func TestRunDrainsOnShutdown(t *testing.T) {
+	s := newTestSyncer(t)
+	alice := s.createUser(t, "alice")
+	actor := audit.Actor{UserID: alice, Method: audit.MethodSession}
+
+	sub := s.bus.Subscribe()
+	defer sub.Close()
+	var ids []uint64
+	for hour := 10; hour < 12; hour++ {
+		created, err := s.svc.Create(context.Background(), actor, types.ReservationsRequest{
+			Title: "定例", StartTime: at(hour, 0), EndTime: at(hour+1, 0),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, created.ID)
+	}
+
+	// 停止した (キャンセルされた) 後も、受け取り済みの変更は送信する
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.drain(ctx, sub)
+
+	for _, id := range ids {
+		r, err := s.store.GetReservationByID(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := s.calendar.event(r.GoogleEventID.String); !r.GoogleEventID.Valid || !ok {
+			t.Errorf("予約 %d がカレンダーに送信されていません", id)
+		}
+	}
+	select {
+	case event := <-sub.C:
+		t.Errorf("送信していない変更が残っています: %+v", event)
+	default:
+	}
+}
@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	"yoyaku/apierror"
+	"yoyaku/audit"
+	"yoyaku/db"
+	"yoyaku/reservation"
+	"yoyaku/store"
+	"yoyaku/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 監査ログ検索で一度に返す最大件数
+const maxAuditEvents = 200
+
+// sessionActor は Cookie セッションでログインしているユーザーの操作を表す Actor を返す
+func sessionActor(c *gin.Context, userID uint64) audit.Actor {
+	return audit.Actor{UserID: userID, ClientIP: c.ClientIP(), Method: audit.MethodSession}
+}
+
+// 予約の変更履歴を返す (予約者本人と管理者のみ)
+// GET /api/reservations/:id/history
+func HandleReservationHistory(c *gin.Context, s store.Store) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest("IDの形式が正しくありません"))
+		return
+	}
+	userID, ok := utils.GetUserIDFromSession(c)
+	if !ok {
+		return
+	}
+
+	target, err := s.GetReservationByID(c.Request.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Abort(c, reservation.ErrNotFound)
+		return
+	}
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("予約の取得に失敗しました", err))
+		return
+	}
+	if target.UserID != userID {
+		if _, ok := utils.GetAdminFromSession(c, s); !ok {
+			return
+		}
+	}
+
+	history, err := s.ListReservationEventsByReservationID(c.Request.Context(), id)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("変更履歴の取得に失敗しました", err))
+		return
+	}
+	if history == nil {
+		history = []db.ListReservationEventsByReservationIDRow{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   history,
+	})
+}
+
+// 全ての予約の変更履歴を検索する (管理者のみ)
+// GET /api/admin/audit?actor_id=...&reservation_id=...&action=...&auth_method=...&from=YYYY-MM-DD&to=YYYY-MM-DD&limit=...&offset=...
+func HandleAuditSearch(c *gin.Context, s store.Store) {
+	if _, ok := utils.GetAdminFromSession(c, s); !ok {
+		return
+	}
+
+	params := db.SearchReservationEventsParams{Limit: 50}
+	var err error
+	if v := c.Query("actor_id"); v != "" {
+		params.ActorUserID.Int64, err = strconv.ParseInt(v, 10, 64)
+		params.ActorUserID.Valid = err == nil
+	}
+	if v := c.Query("reservation_id"); v != "" && err == nil {
+		params.ReservationID.Int64, err = strconv.ParseInt(v, 10, 64)
+		params.ReservationID.Valid = err == nil
+	}
+	if v := c.Query("limit"); v != "" && err == nil {
+		var limit int
+		limit, err = strconv.Atoi(v)
+		if err == nil && (limit < 1 || limit > maxAuditEvents) {
+			err = errors.New("limit out of range")
+		}
+		params.Limit = int32(limit)
+	}
+	if v := c.Query("offset"); v != "" && err == nil {
+		var offset int
+		offset, err = strconv.Atoi(v)
+		if err == nil && offset < 0 {
+			err = errors.New("offset out of range")
+		}
+		params.Offset = int32(offset)
+	}
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest("数値パラメータの形式が正しくありません"))
+		return
+	}
+
+	if v := c.Query("action"); v != "" {
+		params.Action = sql.NullString{String: v, Valid: true}
+	}
+	if v := c.Query("auth_method"); v != "" {
+		params.AuthMethod = sql.NullString{String: v, Valid: true}
+	}
+
+	loc, err := utils.RequestLocation(c)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	layout := "2006-01-02"
+	if v := c.Query("from"); v != "" {
+		from, err := time.ParseInLocation(layout, v, loc)
+		if err != nil {
+			apierror.Abort(c, apierror.BadRequest("日付の形式が正しくありません (YYYY-MM-DD)"))
+			return
+		}
+		params.CreatedFrom = sql.NullTime{Time: from, Valid: true}
+	}
+	if v := c.Query("to"); v != "" {
+		to, err := time.ParseInLocation(layout, v, loc)
+		if err != nil {
+			apierror.Abort(c, apierror.BadRequest("日付の形式が正しくありません (YYYY-MM-DD)"))
+			return
+		}
+		params.CreatedTo = sql.NullTime{Time: to.AddDate(0, 0, 1), Valid: true}
+	}
+
+	events, err := s.SearchReservationEvents(c.Request.Context(), params)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("変更履歴の取得に失敗しました", err))
+		return
+	}
+	if events == nil {
+		events = []db.SearchReservationEventsRow{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   events,
+		"limit":  params.Limit,
+		"offset": params.Offset,
+	})
+}
@@ -1,16 +1,20 @@
 package handler
 
 import (
-	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
-	"os"
 	"strings"
+	"yoyaku/apierror"
 	"yoyaku/auth"
 	"yoyaku/db"
+	"yoyaku/i18n"
+	"yoyaku/metrics"
+	"yoyaku/store"
+	"yoyaku/types"
+	"yoyaku/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/sessions"
@@ -25,19 +29,16 @@ type GoogleUserInfo struct {
 }
 
 // GoogleのOAuthコールバックハンドラ
-func HandleGoogleCallback(c *gin.Context, queries *db.Queries) {
+// frontendUrl はログイン後のリダイレクト先 (config.Config.FrontendURL) です
+func HandleGoogleCallback(c *gin.Context, s store.Store, frontendUrl string) {
 	state := c.Query("state")
 	code := c.Query("code")
 
-	frontendUrl := os.Getenv("FRONTEND_URL")
-	if frontendUrl == "" {
-		log.Fatalf("環境変数 FRONTEND_URL が設定されていません")
-	}
-
 	// Googleからユーザー情報を取得
 	content, err := auth.GetUserInfo(state, code)
 	if err != nil {
 		log.Println(err.Error())
+		metrics.LoginFailed(metrics.ReasonExchange)
 		c.Redirect(http.StatusTemporaryRedirect, frontendUrl+"/login?error=true")
 		return
 	}
@@ -46,6 +47,7 @@ func HandleGoogleCallback(c *gin.Context, queries *db.Queries) {
 	var userInfo GoogleUserInfo
 	if err := json.Unmarshal(content, &userInfo); err != nil {
 		log.Println("JSON Unmarshal error:", err)
+		metrics.LoginFailed(metrics.ReasonExchange)
 		c.Redirect(http.StatusTemporaryRedirect, frontendUrl+"/login?error=true")
 		return
 	}
@@ -53,12 +55,13 @@ func HandleGoogleCallback(c *gin.Context, queries *db.Queries) {
 	// pluslab.org ドメインのみ許可
 	if !strings.HasSuffix(userInfo.Email, "@pluslab.org") {
 		log.Println("Unauthorized domain access attempt:", userInfo.Email)
+		metrics.LoginFailed(metrics.ReasonDomain)
 		c.Redirect(http.StatusTemporaryRedirect, frontendUrl+"/login?error=domain")
 		return
 	}
 
 	// ユーザー取得または作成
-	dbUser, err := queries.GetUserByGoogleID(context.Background(), userInfo.ID)
+	dbUser, err := s.GetUserByGoogleID(c.Request.Context(), userInfo.ID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			// 新規作成
@@ -69,20 +72,23 @@ func HandleGoogleCallback(c *gin.Context, queries *db.Queries) {
 				AvatarUrl: sql.NullString{String: userInfo.Picture, Valid: userInfo.Picture != ""},
 				Role:      "user",
 			}
-			if _, err := queries.CreateUser(context.Background(), params); err != nil {
+			if _, err := s.CreateUser(c.Request.Context(), params); err != nil {
 				log.Println("ユーザー作成エラー:", err)
+				metrics.LoginFailed(metrics.ReasonCreate)
 				c.Redirect(http.StatusTemporaryRedirect, frontendUrl+"/login?error=create")
 				return
 			}
 			// 再取得
-			dbUser, err = queries.GetUserByGoogleID(context.Background(), userInfo.ID)
+			dbUser, err = s.GetUserByGoogleID(c.Request.Context(), userInfo.ID)
 			if err != nil {
 				log.Println("作成後のユーザー取得失敗:", err)
+				metrics.LoginFailed(metrics.ReasonCreate)
 				c.Redirect(http.StatusTemporaryRedirect, frontendUrl+"/login?error=true")
 				return
 			}
 		} else {
 			log.Println("DBユーザー検索エラー:", err)
+			metrics.LoginFailed(metrics.ReasonLookup)
 			c.Redirect(http.StatusTemporaryRedirect, frontendUrl+"/login?error=true")
 			return
 		}
@@ -96,13 +102,16 @@ func HandleGoogleCallback(c *gin.Context, queries *db.Queries) {
 	session.Values["user_email"] = dbUser.Email
 	session.Values["user_name"] = dbUser.Name
 	session.Values["user_picture"] = dbUser.AvatarUrl.String
+	session.Values["language"] = dbUser.Language.String // 未設定なら空文字列 (Accept-Language を使う)
 
 	if err := session.Save(c.Request, c.Writer); err != nil {
 		log.Println("セッション保存失敗:", err)
+		metrics.LoginFailed(metrics.ReasonSession)
 		c.Redirect(http.StatusTemporaryRedirect, frontendUrl+"/login?error=true")
 		return
 	}
 
+	metrics.LoginSucceeded()
 	// フロントエンドへリダイレクト
 	c.Redirect(http.StatusPermanentRedirect, frontendUrl)
 }
@@ -114,7 +123,7 @@ func HandleGetMe(c *gin.Context) {
 
 	userID, ok := session.Values["user_id"].(string)
 	if !ok || userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		apierror.Abort(c, apierror.Unauthorized("ログイン情報が見つかりません"))
 		return
 	}
 
@@ -123,9 +132,59 @@ func HandleGetMe(c *gin.Context) {
 		"email":   session.Values["user_email"],
 		"name":    session.Values["user_name"],
 		"picture": session.Values["user_picture"],
+		// 選んだ表示言語 (未設定の場合は空文字列で、Accept-Language から決める)
+		"language": sessionLanguage(session),
 	})
 }
 
+// 表示言語を変更する
+// エラーメッセージなどはこの言語で返し、選んでいない場合は Accept-Language に従う
+func HandleSetLanguage(c *gin.Context, s store.Store) {
+	userID, ok := utils.GetUserIDFromSession(c)
+	if !ok {
+		return
+	}
+
+	var req types.SetLanguageRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	language := sql.NullString{}
+	if req.Language != "" {
+		lang, ok := i18n.Parse(req.Language)
+		if !ok || string(lang) != req.Language {
+			apierror.Abort(c, apierror.Validation("表示言語は ja または en で指定してください",
+				apierror.FieldError{Field: "language", Message: "表示言語は ja または en で指定してください"}))
+			return
+		}
+		language = sql.NullString{String: string(lang), Valid: true}
+	}
+
+	if err := s.SetUserLanguage(c.Request.Context(), db.SetUserLanguageParams{Language: language, ID: userID}); err != nil {
+		apierror.Abort(c, apierror.Internal("表示言語の保存に失敗しました", err))
+		return
+	}
+
+	// 以降のリクエストで使えるようセッションにも保存する
+	store := c.MustGet("session_store").(*sessions.CookieStore)
+	session, _ := store.Get(c.Request, "session-name")
+	session.Values["language"] = language.String
+	if err := session.Save(c.Request, c.Writer); err != nil {
+		apierror.Abort(c, apierror.Internal("表示言語の保存に失敗しました", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   "success",
+		"language": language.String,
+	})
+}
+
+func sessionLanguage(session *sessions.Session) string {
+	language, _ := session.Values["language"].(string)
+	return language
+}
+
 // ログアウト処理
 func HandleLogout(c *gin.Context) {
 	store := c.MustGet("session_store").(*sessions.CookieStore)
@@ -135,13 +194,48 @@ func HandleLogout(c *gin.Context) {
 	session.Options.MaxAge = -1
 
 	if err := session.Save(c.Request, c.Writer); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to logout"})
+		apierror.Abort(c, apierror.Internal("ログアウトに失敗しました", err))
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "logged out"})
 }
 
+// CalDAVクライアント用のトークンを発行する
+// 発行済みのトークンは無効になり、新しいトークンはこのレスポンスでのみ確認できる
+func HandleIssueCalDAVToken(c *gin.Context, s store.Store) {
+	userID, ok := utils.GetUserIDFromSession(c)
+	if !ok {
+		return
+	}
+
+	user, err := s.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("ユーザー情報の取得に失敗しました", err))
+		return
+	}
+
+	token, hash, err := auth.GenerateCalDAVToken()
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("トークンの発行に失敗しました", err))
+		return
+	}
+	if err := s.SetUserCaldavTokenHash(c.Request.Context(), db.SetUserCaldavTokenHashParams{
+		CaldavTokenHash: sql.NullString{String: hash, Valid: true},
+		ID:              user.ID,
+	}); err != nil {
+		apierror.Abort(c, apierror.Internal("トークンの発行に失敗しました", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   "success",
+		"url":      "/caldav/",
+		"username": user.Email,
+		"token":    token,
+	})
+}
+
 // Googleログイン開始
 func HandleGoogleLogin(c *gin.Context) {
 	url := auth.GoogleOauthConfig.AuthCodeURL(auth.OauthStateString)
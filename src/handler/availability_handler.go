@@ -0,0 +1,268 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"yoyaku/apierror"
+	"yoyaku/availability"
+	"yoyaku/db"
+	"yoyaku/reservation"
+	"yoyaku/store"
+	"yoyaku/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// 空き状況を一度に取得できる最大日数
+	maxAvailabilityDays = 62
+	// 空き枠検索のデフォルトの検索期間
+	defaultSearchWindow = 14 * 24 * time.Hour
+	// 空き枠検索で返す最大件数
+	maxSlots = 20
+	// 一度に指定できる resource の最大数
+	maxResources = 10
+)
+
+// 備品の resource の接頭辞 (equipment-{備品ID})
+const equipmentResourcePrefix = "equipment-"
+
+// 期間内の予約済み・空きの時間帯を返す
+// GET /api/availability?start=YYYY-MM-DD&end=YYYY-MM-DD&resource=room-401&resource=equipment-1
+// resource を複数指定した場合は、いずれかが使えない時間帯を busy、全てを使える時間帯を free として返す
+func HandleAvailability(c *gin.Context, s store.Store, rules availability.Rules) {
+	resources, err := parseResources(c)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	startStr := c.Query("start")
+	endStr := c.Query("end")
+	if startStr == "" || endStr == "" {
+		apierror.Abort(c, apierror.BadRequest("startとendクエリパラメータは必須です"))
+		return
+	}
+
+	// 日付は tz (省略時は APP_TIMEZONE) の0時から数える。営業時間は rules.Location で判定する
+	loc, err := utils.RequestLocation(c)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	layout := "2006-01-02"
+	startTime, err1 := time.ParseInLocation(layout, startStr, loc)
+	endTime, err2 := time.ParseInLocation(layout, endStr, loc)
+	if err1 != nil || err2 != nil {
+		apierror.Abort(c, apierror.BadRequest("日付の形式が正しくありません (YYYY-MM-DD)"))
+		return
+	}
+	endTime = endTime.AddDate(0, 0, 1)
+	// 夏時間の切り替えを含む期間は24時間の倍数にならないため、日数で比べる
+	if !endTime.After(startTime) || endTime.After(startTime.AddDate(0, 0, maxAvailabilityDays)) {
+		apierror.Abort(c, apierror.BadRequest("期間は1日以上62日以内で指定してください"))
+		return
+	}
+
+	busy, err := listBusy(c.Request.Context(), s, resources, startTime, endTime)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	busy = availability.Clip(busy, startTime, endTime)
+	free := availability.Free(busy, startTime, endTime, rules)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"resources": resources,
+			"start":     startTime,
+			"end":       endTime,
+			"busy":      nonNilIntervals(busy),
+			"free":      nonNilIntervals(free),
+		},
+	})
+}
+
+// 指定した長さの予約が入る、最も早い空き枠を返す
+// GET /api/availability/next?duration=90m&after=2025-07-01T10:00:00%2B09:00&limit=3&within=336h&resource=equipment-1
+// resource を複数指定した場合は、全てを同時に使える枠を返す
+func HandleNextAvailability(c *gin.Context, s store.Store, rules availability.Rules) {
+	resources, err := parseResources(c)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	duration, err := time.ParseDuration(c.Query("duration"))
+	if err != nil || duration <= 0 {
+		apierror.Abort(c, apierror.BadRequest("durationの形式が正しくありません (例: 90m)"))
+		return
+	}
+	if duration > rules.CloseAt-rules.OpenAt {
+		apierror.Abort(c, apierror.BadRequest("durationが営業時間より長いです"))
+		return
+	}
+
+	after := time.Now()
+	if afterStr := c.Query("after"); afterStr != "" {
+		after, err = time.Parse(time.RFC3339, afterStr)
+		if err != nil {
+			var loc *time.Location
+			if loc, err = utils.RequestLocation(c); err != nil {
+				apierror.Abort(c, err)
+				return
+			}
+			after, err = time.ParseInLocation("2006-01-02", afterStr, loc)
+		}
+		if err != nil {
+			apierror.Abort(c, apierror.BadRequest("afterの形式が正しくありません (RFC3339 または YYYY-MM-DD)"))
+			return
+		}
+	}
+
+	limit := 1
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxSlots {
+			apierror.Abort(c, apierror.BadRequest("limitは1から20の間で指定してください"))
+			return
+		}
+	}
+
+	window := defaultSearchWindow
+	if withinStr := c.Query("within"); withinStr != "" {
+		window, err = time.ParseDuration(withinStr)
+		if err != nil || window <= 0 || window > maxAvailabilityDays*24*time.Hour {
+			apierror.Abort(c, apierror.BadRequest("withinの形式が正しくありません (例: 336h)"))
+			return
+		}
+	}
+	until := after.Add(window)
+
+	busy, err := listBusy(c.Request.Context(), s, resources, after, until)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	slots := availability.NextSlots(busy, after, until, duration, limit, rules)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"resources": resources,
+			"duration":  duration.String(),
+			"slots":     nonNilIntervals(slots),
+		},
+	})
+}
+
+// parseResources は resource クエリ (複数指定またはカンマ区切り) を返す。省略時は部屋のみ
+// 部屋は reservation.RoomID、備品は equipment-{備品ID} で指定する
+func parseResources(c *gin.Context) ([]string, error) {
+	var resources []string
+	for _, value := range c.QueryArray("resource") {
+		for _, resource := range strings.Split(value, ",") {
+			resource = strings.TrimSpace(resource)
+			if resource == "" || slices.Contains(resources, resource) {
+				continue
+			}
+			if resource != reservation.RoomID {
+				if _, ok := equipmentID(resource); !ok {
+					return nil, apierror.BadRequest("resourceは room-401 または equipment-{備品ID} で指定してください")
+				}
+			}
+			resources = append(resources, resource)
+		}
+	}
+	if len(resources) > maxResources {
+		return nil, apierror.BadRequest("resourceは10個まで指定できます")
+	}
+	if len(resources) == 0 {
+		resources = []string{reservation.RoomID}
+	}
+	return resources, nil
+}
+
+// equipmentID は equipment-{備品ID} の resource の備品IDを返す
+func equipmentID(resource string) (uint64, bool) {
+	idStr, ok := strings.CutPrefix(resource, equipmentResourcePrefix)
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	return id, err == nil && id > 0
+}
+
+// listBusy は resources のいずれかが使えない時間帯を Merge 済みで返す
+// 部屋は確定済みの予約がある時間帯、備品は全数が借りられている時間帯を使えないものとする
+func listBusy(ctx context.Context, s store.Store, resources []string, start, end time.Time) ([]availability.Interval, error) {
+	var intervals []availability.Interval
+	var usage []db.ListEquipmentUsageRow
+	for _, resource := range resources {
+		if resource == reservation.RoomID {
+			rows, err := s.ListBusyIntervals(ctx, db.ListBusyIntervalsParams{
+				RangeEnd:   end,
+				RangeStart: start,
+			})
+			if err != nil {
+				return nil, apierror.Internal("予約の取得に失敗しました", err)
+			}
+			for _, row := range rows {
+				intervals = append(intervals, availability.Interval{Start: row.StartTime, End: row.EndTime})
+			}
+			continue
+		}
+
+		id, _ := equipmentID(resource)
+		equipment, err := s.GetEquipmentByID(ctx, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apierror.NotFound("備品が見つかりません")
+		}
+		if err != nil {
+			return nil, apierror.Internal("備品の取得に失敗しました", err)
+		}
+		if equipment.Quantity <= 0 {
+			intervals = append(intervals, availability.Interval{Start: start, End: end})
+			continue
+		}
+		// 借りられている備品は全ての備品の分をまとめて一度だけ取得する
+		if usage == nil {
+			usage, err = s.ListEquipmentUsage(ctx, db.ListEquipmentUsageParams{
+				RangeEnd:   end,
+				RangeStart: start,
+			})
+			if err != nil {
+				return nil, apierror.Internal("備品の利用状況の取得に失敗しました", err)
+			}
+			if usage == nil {
+				usage = []db.ListEquipmentUsageRow{}
+			}
+		}
+		var used []availability.Usage
+		for _, row := range usage {
+			if row.EquipmentID == id {
+				used = append(used, availability.Usage{
+					Interval: availability.Interval{Start: row.StartTime, End: row.EndTime},
+					Quantity: int(row.Quantity),
+				})
+			}
+		}
+		intervals = append(intervals, availability.Exhausted(used, int(equipment.Quantity))...)
+	}
+	return availability.Merge(intervals), nil
+}
+
+// 空の場合にnullではなく空配列を返す
+func nonNilIntervals(intervals []availability.Interval) []availability.Interval {
+	if intervals == nil {
+		return []availability.Interval{}
+	}
+	return intervals
+}
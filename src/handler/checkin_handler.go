@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"yoyaku/apierror"
+	"yoyaku/db"
+	"yoyaku/reservation"
+	"yoyaku/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 予約にチェックインする
+// POST /api/reservations/checkin?id=... (予約者本人)
+// POST /api/reservations/checkin?token=... (ドアに掲示したQRコードから。ログインしている研究室メンバーなら誰でも可)
+func HandleCheckIn(c *gin.Context, svc *reservation.Service) {
+	userID, ok := utils.GetUserIDFromSession(c)
+	if !ok {
+		return
+	}
+
+	var checkedIn db.Reservation
+	var err error
+	if token := c.Query("token"); token != "" {
+		checkedIn, err = svc.CheckInWithToken(c.Request.Context(), sessionActor(c, userID), token)
+	} else if idStr := c.Query("id"); idStr != "" {
+		id, parseErr := strconv.ParseUint(idStr, 10, 64)
+		if parseErr != nil {
+			apierror.Abort(c, apierror.BadRequest("IDの形式が正しくありません"))
+			return
+		}
+		checkedIn, err = svc.CheckIn(c.Request.Context(), sessionActor(c, userID), id)
+	} else {
+		apierror.Abort(c, apierror.BadRequest("IDまたはトークンが指定されていません"))
+		return
+	}
+	if err != nil {
+		// 予約が無い・他のユーザーの予約・受付時間外の場合は MapError が 404 / 403 / 409 に変換する
+		apierror.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":        "success",
+		"id":            checkedIn.ID,
+		"checked_in_at": checkedIn.CheckedInAt.Time,
+	})
+}
+
+// ドアに掲示するQRコード用のチェックイントークンとURLを返す (予約者本人のみ)
+// GET /api/reservations/checkin-token?id=...
+func HandleCheckinToken(c *gin.Context, svc *reservation.Service, frontendURL string) {
+	id, err := strconv.ParseUint(c.Query("id"), 10, 64)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest("IDの形式が正しくありません"))
+		return
+	}
+	userID, ok := utils.GetUserIDFromSession(c)
+	if !ok {
+		return
+	}
+
+	token, err := svc.CheckinToken(c.Request.Context(), sessionActor(c, userID), id)
+	if errors.Is(err, reservation.ErrNotFound) {
+		apierror.Abort(c, err)
+		return
+	}
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("チェックイントークンの発行に失敗しました", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"token":  token,
+		"url":    frontendURL + "/checkin?token=" + url.QueryEscape(token),
+	})
+}
+
+// ログインユーザーの直近の no-show の回数と予約停止の状態を返す
+// GET /api/me/no-shows
+func HandleMyNoShows(c *gin.Context, svc *reservation.Service) {
+	userID, ok := utils.GetUserIDFromSession(c)
+	if !ok {
+		return
+	}
+
+	status, err := svc.NoShows(c.Request.Context(), userID)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("no-show の取得に失敗しました", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":    "success",
+		"count":     status.Count,
+		"threshold": status.Threshold,
+		"window":    status.Window.String(),
+		"suspended": status.Suspended,
+	})
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	"yoyaku/apierror"
+	"yoyaku/reservation"
+	"yoyaku/store"
+	"yoyaku/types"
+	"yoyaku/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 全ての備品を返す。start と end (RFC 3339) を指定した場合は、その時間帯に借りられる数 (available) も計算する
+// GET /api/equipment?start=...&end=...
+func HandleListEquipment(c *gin.Context, svc *reservation.Service) {
+	var r reservation.Range
+	if c.Query("start") != "" || c.Query("end") != "" {
+		start, err1 := time.Parse(time.RFC3339, c.Query("start"))
+		end, err2 := time.Parse(time.RFC3339, c.Query("end"))
+		if err1 != nil || err2 != nil {
+			apierror.Abort(c, apierror.BadRequest("startとendは両方とも日時 (RFC3339) で指定してください"))
+			return
+		}
+		r = reservation.Range{Start: start, End: end}
+	}
+
+	equipment, err := svc.ListEquipment(c.Request.Context(), r)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   equipment,
+	})
+}
+
+// 備品を登録する (管理者のみ)
+// POST /api/admin/equipment
+func HandleCreateEquipment(c *gin.Context, s store.Store, svc *reservation.Service) {
+	if _, ok := utils.GetAdminFromSession(c, s); !ok {
+		return
+	}
+	var req types.EquipmentRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	created, err := svc.CreateEquipment(c.Request.Context(), req)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   created,
+	})
+}
+
+// 備品の名前と数を変更する (管理者のみ)
+// PUT /api/admin/equipment/:id
+func HandleUpdateEquipment(c *gin.Context, s store.Store, svc *reservation.Service) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest("IDの形式が正しくありません"))
+		return
+	}
+	if _, ok := utils.GetAdminFromSession(c, s); !ok {
+		return
+	}
+	var req types.EquipmentRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	updated, err := svc.UpdateEquipment(c.Request.Context(), id, req)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   updated,
+	})
+}
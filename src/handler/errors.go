@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"yoyaku/apierror"
+	"yoyaku/checkin"
+	"yoyaku/reservation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MapError は予約サービスなどのエラーをクライアントに返すエラーに変換します。
+// apierror.Middleware に渡して使います。
+func MapError(err error) *apierror.Error {
+	var serviceErr *reservation.Error
+	if errors.As(err, &serviceErr) && serviceErr.Field != "" {
+		return apierror.Validation(serviceErr.Message, apierror.FieldError{Field: serviceErr.Field, Message: serviceErr.Message})
+	}
+
+	switch {
+	case errors.Is(err, reservation.ErrValidation):
+		return apierror.Validation(err.Error())
+	case errors.Is(err, reservation.ErrNotFound):
+		return apierror.New(http.StatusNotFound, apierror.CodeReservationNotFound, err.Error())
+	case errors.Is(err, reservation.ErrSuspended):
+		return apierror.New(http.StatusForbidden, apierror.CodeAccountSuspended, err.Error())
+	case errors.Is(err, reservation.ErrForbidden):
+		return apierror.New(http.StatusForbidden, apierror.CodeForbidden, err.Error())
+	case errors.Is(err, reservation.ErrEquipmentNotFound):
+		return apierror.New(http.StatusNotFound, apierror.CodeEquipmentNotFound, err.Error())
+	case errors.Is(err, reservation.ErrEquipmentUnavailable):
+		return apierror.New(http.StatusConflict, apierror.CodeEquipmentUnavailable, err.Error())
+	case errors.Is(err, reservation.ErrConflict):
+		return apierror.New(http.StatusConflict, apierror.CodeReservationConflict, err.Error())
+	case errors.Is(err, reservation.ErrNotOngoing):
+		return apierror.New(http.StatusConflict, apierror.CodeReservationNotOngoing, err.Error())
+	case errors.Is(err, checkin.ErrTooEarly), errors.Is(err, checkin.ErrTooLate), errors.Is(err, checkin.ErrNotConfirmed):
+		return apierror.New(http.StatusConflict, apierror.CodeCheckinUnavailable, err.Error())
+	}
+	return nil
+}
+
+// HandleNoRoute は存在しないパスへのリクエストに共通の形式で404を返します。
+func HandleNoRoute(c *gin.Context) {
+	apierror.Abort(c, apierror.NotFound("ページが見つかりません"))
+}
+
+// bindJSON はリクエストボディを読み込みます。失敗した場合はエラーを登録して false を返します。
+func bindJSON(c *gin.Context, v any) bool {
+	if err := c.ShouldBindJSON(v); err != nil {
+		e := apierror.BadRequest("リクエストの形式が正しくありません")
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			e = e.WithDetails(apierror.FieldError{Field: typeErr.Field, Message: "値の型が正しくありません"})
+		}
+		apierror.Abort(c, e)
+		return false
+	}
+	return true
+}
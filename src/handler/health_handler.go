@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+	"yoyaku/buildinfo"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readinessTimeout は GET /readyz で1つの確認を待つ時間の上限です。
+const readinessTimeout = 2 * time.Second
+
+// ReadinessCheck は GET /readyz で確認する項目です。
+type ReadinessCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// プロセスが動いていることを返す (liveness probe。データベースなどは確認しない)
+// GET /healthz
+func HandleHealthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// リクエストを受け付けられるかを返す (readiness probe)
+// 確認に失敗した項目がある場合は 503 を返す。失敗の詳細はログにだけ出力する
+// GET /readyz
+func HandleReadyz(c *gin.Context, checks []ReadinessCheck) {
+	status := http.StatusOK
+	results := gin.H{}
+	for _, check := range checks {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+		err := check.Check(ctx)
+		cancel()
+		if err != nil {
+			log.Printf("readyz: %s: %v", check.Name, err)
+			results[check.Name] = "error"
+			status = http.StatusServiceUnavailable
+			continue
+		}
+		results[check.Name] = "ok"
+	}
+
+	if status != http.StatusOK {
+		c.JSON(status, gin.H{"status": "unavailable", "checks": results})
+		return
+	}
+	c.JSON(status, gin.H{"status": "ok", "checks": results})
+}
+
+// ビルドしたコミット・日時と Go のバージョンを返す
+// GET /version
+func HandleVersion(c *gin.Context) {
+	c.JSON(http.StatusOK, buildinfo.Get())
+}
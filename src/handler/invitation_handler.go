@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"yoyaku/apierror"
+	"yoyaku/reservation"
+	"yoyaku/types"
+	"yoyaku/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 自分が参加者として招待された、終了していない予約を開始時刻順に返す (自分の返答付き)
+// GET /api/me/invitations
+func HandleListInvitations(c *gin.Context, svc *reservation.Service) {
+	userID, ok := utils.GetUserIDFromSession(c)
+	if !ok {
+		return
+	}
+
+	invitations, err := svc.Invitations(c.Request.Context(), userID)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   invitations,
+	})
+}
+
+// 招待に返答する (accepted / declined / tentative)。返答後の参加者付きの予約を返す
+// PUT /api/me/invitations/:id
+func HandleRespondInvitation(c *gin.Context, svc *reservation.Service) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest("IDの形式が正しくありません"))
+		return
+	}
+	var req types.InvitationResponseRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	userID, ok := utils.GetUserIDFromSession(c)
+	if !ok {
+		return
+	}
+
+	responded, err := svc.Respond(c.Request.Context(), sessionActor(c, userID), id, req.Response)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, reservationResponse(responded))
+}
@@ -1,29 +1,22 @@
 package handler
 
 import (
-	"context"
-	"fmt"
-	"log"
 	"net/http"
 	"strconv"
 	"time"
-	"yoyaku/db"
+	"yoyaku/apierror"
+	"yoyaku/reservation"
 	"yoyaku/types"
 	"yoyaku/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
-func Handlereservations(c *gin.Context, queries *db.Queries) {
+func Handlereservations(c *gin.Context, svc *reservation.Service) {
 	var req types.ReservationsRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "error",
-			"message": "リクエストの形式が正しくありません",
-		})
+	if !bindJSON(c, &req) {
 		return
 	}
-	println("タイトル", req.Title, "開始時間", req.StartTime.String(), "終了時間", req.EndTime.String()) //デバック用
 
 	// ユーティリティ関数を呼び出す
 	userID, ok := utils.GetUserIDFromSession(c)
@@ -31,77 +24,55 @@ func Handlereservations(c *gin.Context, queries *db.Queries) {
 		return
 	}
 
-	// 重複チェック
-	count, err := queries.CheckOverlappingReservation(context.Background(), db.CheckOverlappingReservationParams{
-		StartTime: req.EndTime,
-		EndTime:   req.StartTime,
-	})
-
+	created, err := svc.Create(c.Request.Context(), sessionActor(c, userID), req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "重複チェック中にエラーが発生しました"})
-		return
-	}
-	fmt.Printf("count : %v", count)
-	if count > 0 {
-		c.JSON(http.StatusConflict, gin.H{"status": "error", "message": "この時間帯には既に予約があります"})
+		apierror.Abort(c, err)
 		return
 	}
 
-	// 登録処理
-	_, err = queries.CreateReservation(context.Background(), db.CreateReservationParams{
-		UserID:    userID,
-		Title:     req.Title,
-		StartTime: req.StartTime,
-		EndTime:   req.EndTime,
-	})
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "予約の登録に失敗しました"})
-		return
-	}
-	reservation, err := queries.GetReservationLastInserted(context.Background())
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "予約情報の取得に失敗しました"})
-		return
-	}
+	c.JSON(http.StatusOK, reservationResponse(created))
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"status":     "success",
-		"id":         reservation.ID,
-		"user_id":    reservation.UserID,
-		"title":      reservation.Title,
-		"start_time": reservation.StartTime,
-		"end_time":   reservation.EndTime,
-		"created_at": reservation.CreatedAt,
-		"updated_at": reservation.UpdatedAt,
-	})
+// reservationResponse は作成・編集・招待への返答の結果として返す予約です。
+func reservationResponse(r reservation.Detail) gin.H {
+	return gin.H{
+		"status":      "success",
+		"id":          r.ID,
+		"user_id":     r.UserID,
+		"title":       r.Title,
+		"description": r.Description,
+		"headcount":   r.Headcount,
+		"visibility":  r.Visibility,
+		"attendees":   r.Attendees,
+		"equipment":   r.Equipment,
+		"start_time":  r.StartTime,
+		"end_time":    r.EndTime,
+		"created_at":  r.CreatedAt,
+		"updated_at":  r.UpdatedAt,
+	}
 }
 
-func HandlereservationsMe(c *gin.Context, queries *db.Queries) {
+// 自分の予約と、参加を承諾 (未定を含む) した予約を返す。クエリパラメータは GET /api/reservations と同じ (user_id は指定できない)
+// GET /api/reservations/me
+func HandlereservationsMe(c *gin.Context, svc *reservation.Service) {
 	userID, ok := utils.GetUserIDFromSession(c)
 	if !ok {
 		return
 	}
 
-	reservations, err := queries.ListReservationsByUserID(context.Background(), userID)
-	if err != nil {
-		log.Println("予約取得エラー:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "予約の取得に失敗しました"})
+	q, ok := listQuery(c)
+	if !ok {
 		return
 	}
-
-	println("user_id", userID)
-	println("reservations", reservations)
-
-	c.JSON(http.StatusOK, gin.H{
-		"status": "success",
-		"data":   reservations,
-	})
+	q.MemberID = userID
+	q.ViewerID = userID
+	listReservations(c, svc, q)
 }
 
-func HandlereservationsCancele(c *gin.Context, queries *db.Queries) {
+func HandlereservationsCancele(c *gin.Context, svc *reservation.Service) {
 	idStr := c.Query("id")
 	if idStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "IDが指定されていません"})
+		apierror.Abort(c, apierror.BadRequest("IDが指定されていません"))
 		return
 	}
 
@@ -109,7 +80,7 @@ func HandlereservationsCancele(c *gin.Context, queries *db.Queries) {
 	id, err := strconv.ParseUint(idStr, 10, 64)
 	if err != nil {
 		// 変換に失敗した場合（例：IDが数値ではない）
-		c.JSON(http.StatusBadRequest, gin.H{"error": "IDの形式が正しくありません"})
+		apierror.Abort(c, apierror.BadRequest("IDの形式が正しくありません"))
 		return
 	}
 	userID, ok := utils.GetUserIDFromSession(c)
@@ -117,43 +88,31 @@ func HandlereservationsCancele(c *gin.Context, queries *db.Queries) {
 		return
 	}
 
-	err = queries.CanceledReservationByID(context.Background(), db.CanceledReservationByIDParams{
-		UserID: userID,
-		ID:     id,
-	})
-	if err != nil {
-		log.Println("予約キャンセルエラー:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "予約のキャンセルに失敗しました"})
+	if _, err := svc.Cancel(c.Request.Context(), sessionActor(c, userID), id); err != nil {
+		apierror.Abort(c, err)
 		return
 	}
 
-	println("user_id", userID)
-
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "success",
 		"message": "Reservation Canceled",
 	})
 }
 
-func HandlereservationsEdit(c *gin.Context, queries *db.Queries) {
+func HandlereservationsEdit(c *gin.Context, svc *reservation.Service) {
 	idStr := c.Query("id")
 	var req types.ReservationsRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "error",
-			"message": "リクエストの形式が正しくありません",
-		})
+	if !bindJSON(c, &req) {
 		return
 	}
-	println("タイトル", req.Title, "開始時間", req.StartTime.String(), "終了時間", req.EndTime.String()) //デバック用
 	if idStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "IDが指定されていません"})
+		apierror.Abort(c, apierror.BadRequest("IDが指定されていません"))
 		return
 	}
 
 	id, err := strconv.ParseUint(idStr, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "IDの形式が正しくありません"})
+		apierror.Abort(c, apierror.BadRequest("IDの形式が正しくありません"))
 		return
 	}
 	userID, ok := utils.GetUserIDFromSession(c)
@@ -161,149 +120,193 @@ func HandlereservationsEdit(c *gin.Context, queries *db.Queries) {
 		return
 	}
 
-	err = queries.UpdateReservationByID(context.Background(), db.UpdateReservationByIDParams{
-		Title:     req.Title,
-		StartTime: req.StartTime,
-		EndTime:   req.EndTime,
-		ID:        id,
-	})
+	updated, err := svc.Update(c.Request.Context(), sessionActor(c, userID), id, req)
 	if err != nil {
-		log.Println("予約編集エラー:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "予約の編集に失敗しました"})
+		apierror.Abort(c, err)
 		return
 	}
 
-	println("user_id", userID)
-	updated, err := queries.GetReservationByID(context.Background(), id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新後の予約取得に失敗しました"})
+	c.JSON(http.StatusOK, reservationResponse(updated))
+}
+
+// 全ユーザーの予約を条件で絞り込み、カーソルでページを分けて返す
+// GET /api/reservations?from=...&to=...&user_id=...&status=...&title=...&created_by=...&sort=...&limit=...&cursor=...
+// 期間は month=YYYY-MM、date=YYYY-MM-DD、start=YYYY-MM-DD&end=YYYY-MM-DD でも指定できる
+// ログインしていなくても見られるが、公開範囲により詳細を見られない予約は「予約済み」と表示する
+func HandlerListReservations(c *gin.Context, svc *reservation.Service) {
+	q, ok := listQuery(c)
+	if !ok {
 		return
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"status":     "success",
-		"id":         updated.ID,
-		"user_id":    updated.UserID,
-		"title":      updated.Title,
-		"start_time": updated.StartTime,
-		"end_time":   updated.EndTime,
-		"created_at": updated.CreatedAt,
-		"updated_at": updated.UpdatedAt,
-	})
+	q.ViewerID, _ = utils.OptionalUserIDFromSession(c)
+	if v := c.Query("user_id"); v != "" {
+		userID, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			apierror.Abort(c, apierror.BadRequest("数値パラメータの形式が正しくありません"))
+			return
+		}
+		q.UserID = userID
+	}
+	listReservations(c, svc, q)
 }
 
-func HandlerListByMonth(c *gin.Context, queries *db.Queries) {
-	monthStr := c.Query("month") // "2025-07" のような文字列
-	if monthStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "monthクエリパラメータは必須です"})
+// 予約のタイトルと説明を検索し、関連度の高い順に返す。キャンセルした予約は自分の予約だけ、
+// 非公開の予約は自分が予約者か参加者の予約だけが見つかる
+// GET /api/reservations/search?q=...&limit=...&offset=...
+func HandleSearchReservations(c *gin.Context, svc *reservation.Service) {
+	userID, ok := utils.GetUserIDFromSession(c)
+	if !ok {
 		return
 	}
 
-	// "YYYY-MM" 形式の文字列をtime.Timeオブジェクトに変換
-	layout := "2006-01"
-	t, err := time.Parse(layout, monthStr)
+	q := reservation.SearchQuery{Q: c.Query("q"), ViewerID: userID}
+	var err error
+	if v := c.Query("limit"); v != "" {
+		q.Limit, err = strconv.Atoi(v)
+		if q.Limit == 0 {
+			// SearchQuery の0は既定の件数を表すため、明示された0は範囲外としてサービスに検証させる
+			q.Limit = -1
+		}
+	}
+	if v := c.Query("offset"); v != "" && err == nil {
+		q.Offset, err = strconv.Atoi(v)
+	}
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "monthの形式が正しくありません (YYYY-MM)"})
+		apierror.Abort(c, apierror.BadRequest("数値パラメータの形式が正しくありません"))
 		return
 	}
 
-	// 月の初日と最終日の翌日を計算
-	startOfMonth := t
-	endOfMonth := t.AddDate(0, 1, 0)
-
-	reservations, err := queries.ListReservationsByMonth(context.Background(), db.ListReservationsByMonthParams{
-		EndTime:   startOfMonth,
-		StartTime: endOfMonth,
-	})
+	page, err := svc.Search(c.Request.Context(), q)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "予約の取得に失敗しました"})
+		apierror.Abort(c, err)
 		return
 	}
 
-	// 予約が存在しない場合にnullではなく空配列を返す
-	if reservations == nil {
-		reservations = []db.ListReservationsByMonthRow{}
+	var nextOffset any
+	if page.NextOffset != 0 {
+		nextOffset = page.NextOffset
 	}
-
 	c.JSON(http.StatusOK, gin.H{
-		"status": "success",
-		"data":   reservations,
+		"status":      "success",
+		"data":        page.Hits,
+		"next_offset": nextOffset,
 	})
 }
 
-func HandlerListByWeek(c *gin.Context, queries *db.Queries) {
-	startStr := c.Query("start")
-	endStr := c.Query("end")
-
-	if startStr == "" || endStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "startとendクエリパラメータは必須です"})
-		return
-	}
-
-	layout := "2006-01-02"
-	startTime, err1 := time.Parse(layout, startStr)
-	endTime, err2 := time.Parse(layout, endStr)
-
-	if err1 != nil || err2 != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "日付の形式が正しくありません (YYYY-MM-DD)"})
-		return
-	}
-
-	endTime = endTime.AddDate(0, 0, 1)
-
-	reservations, err := queries.ListReservationsByWeek(context.Background(), db.ListReservationsByWeekParams{
-		Starttime: startTime,
-		Endtime:   endTime,
-	})
+func listReservations(c *gin.Context, svc *reservation.Service, q reservation.Query) {
+	page, err := svc.List(c.Request.Context(), q)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "予約の取得に失敗しました"})
+		apierror.Abort(c, err)
 		return
 	}
 
-	if reservations == nil {
-		reservations = []db.ListReservationsByWeekRow{}
+	var nextCursor any
+	if page.NextCursor != "" {
+		nextCursor = page.NextCursor
 	}
-
-	fmt.Printf("reservations : %v", reservations)
-
 	c.JSON(http.StatusOK, gin.H{
-		"status": "success",
-		"data":   reservations,
+		"status":      "success",
+		"data":        page.Items,
+		"next_cursor": nextCursor,
 	})
 }
 
-func HandlerListByDate(c *gin.Context, queries *db.Queries) {
-	dateStr := c.Query("date")
-	if dateStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "dateクエリパラメータは必須です"})
-		return
+// listQuery は一覧のクエリパラメータ (user_id 以外) を読み取る。形式が正しくない場合はエラーを返して false を返す
+func listQuery(c *gin.Context) (reservation.Query, bool) {
+	q := reservation.Query{
+		Status: c.Query("status"),
+		Title:  c.Query("title"),
+		Sort:   c.Query("sort"),
+		Cursor: c.Query("cursor"),
 	}
 
-	layout := "2006-01-02"
-	date, err := time.Parse(layout, dateStr)
+	var err error
+	if v := c.Query("created_by"); v != "" {
+		q.CreatedBy, err = strconv.ParseUint(v, 10, 64)
+	}
+	if v := c.Query("limit"); v != "" && err == nil {
+		q.Limit, err = strconv.Atoi(v)
+		if q.Limit == 0 {
+			// Query の0は既定の件数を表すため、明示された0は範囲外としてサービスに検証させる
+			q.Limit = -1
+		}
+	}
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "日付の形式が正しくありません (YYYY-MM-DD)"})
-		return
+		apierror.Abort(c, apierror.BadRequest("数値パラメータの形式が正しくありません"))
+		return q, false
+	}
+	if v := c.Query("include_canceled"); v != "" {
+		q.IncludeCanceled, err = strconv.ParseBool(v)
+	}
+	if v := c.Query("include_past"); v != "" && err == nil {
+		q.IncludePast, err = strconv.ParseBool(v)
+	}
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest("include_canceled と include_past は true または false で指定してください"))
+		return q, false
 	}
 
-	startOfDay := date
-	endOfDay := date.AddDate(0, 0, 1)
+	r, err := listRange(c)
+	if err != nil {
+		apierror.Abort(c, err)
+		return q, false
+	}
+	q.Range = r
+	return q, true
+}
 
-	reservations, err := queries.ListReservationsByDate(context.Background(), db.ListReservationsByDateParams{
-		StartTime: endOfDay,
-		EndTime:   startOfDay,
-	})
+// listRange は期間のクエリパラメータを読み取る。from / to は日時 (RFC 3339) または日付 (YYYY-MM-DD、to はその日を含む)
+// 日付と月は tz (省略時は APP_TIMEZONE) の0時から数える。夏時間で1日が23・25時間の日も、日付の区切りで期間を作る
+func listRange(c *gin.Context) (reservation.Range, error) {
+	const dateLayout = "2006-01-02"
+	badDate := apierror.BadRequest("日付の形式が正しくありません (YYYY-MM-DD)")
+	loc, err := utils.RequestLocation(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "予約の取得に失敗しました"})
-		return
+		return reservation.Range{}, err
 	}
 
-	if reservations == nil {
-		reservations = []db.ListReservationsByDateRow{}
+	switch {
+	case c.Query("month") != "":
+		// "YYYY-MM" 形式の文字列をtime.Timeオブジェクトに変換
+		t, err := time.ParseInLocation("2006-01", c.Query("month"), loc)
+		if err != nil {
+			return reservation.Range{}, apierror.BadRequest("monthの形式が正しくありません (YYYY-MM)")
+		}
+		return reservation.Range{Start: t, End: t.AddDate(0, 1, 0)}, nil
+	case c.Query("date") != "":
+		date, err := time.ParseInLocation(dateLayout, c.Query("date"), loc)
+		if err != nil {
+			return reservation.Range{}, badDate
+		}
+		return reservation.Range{Start: date, End: date.AddDate(0, 0, 1)}, nil
+	case c.Query("start") != "" || c.Query("end") != "":
+		startTime, err1 := time.ParseInLocation(dateLayout, c.Query("start"), loc)
+		endTime, err2 := time.ParseInLocation(dateLayout, c.Query("end"), loc)
+		if err1 != nil || err2 != nil {
+			return reservation.Range{}, badDate
+		}
+		return reservation.Range{Start: startTime, End: endTime.AddDate(0, 0, 1)}, nil
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"status": "success",
-		"data":   reservations,
-	})
+	var r reservation.Range
+	if v := c.Query("from"); v != "" {
+		from, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			if from, err = time.ParseInLocation(dateLayout, v, loc); err != nil {
+				return r, badDate
+			}
+		}
+		r.Start = from
+	}
+	if v := c.Query("to"); v != "" {
+		to, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			if to, err = time.ParseInLocation(dateLayout, v, loc); err != nil {
+				return r, badDate
+			}
+			to = to.AddDate(0, 0, 1)
+		}
+		r.End = to
+	}
+	return r, nil
 }
@@ -0,0 +1,1144 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"yoyaku/apierror"
+	"yoyaku/checkin"
+	"yoyaku/db"
+	"yoyaku/events"
+	"yoyaku/openapi"
+	"yoyaku/reservation"
+	"yoyaku/store"
+	"yoyaku/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/sessions"
+)
+
+var jst = time.FixedZone("Asia/Tokyo", 9*60*60)
+
+// testServer はメモリ上の Store を使って予約のAPIを立ち上げます。
+type testServer struct {
+	t        *testing.T
+	router   *gin.Engine
+	store    *store.Memory
+	sessions *sessions.CookieStore
+	// ready は GET /readyz で確認する項目 "database" の結果です。
+	ready error
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	s := &testServer{
+		t:        t,
+		router:   gin.New(),
+		store:    store.NewMemory(),
+		sessions: sessions.NewCookieStore([]byte("test-secret")),
+	}
+	svc := reservation.NewService(s.store, events.NewMemoryBus(16), checkin.DefaultPolicy(), reservation.DefaultRoom())
+
+	s.router.Use(checkContract(t), apierror.Middleware(MapError), func(c *gin.Context) {
+		c.Set("session_store", s.sessions)
+		c.Next()
+	}, utils.SetLanguageFromSession, utils.SetLocation(jst))
+	s.router.GET("/api/me", HandleGetMe)
+	s.router.PUT("/api/me/language", func(c *gin.Context) { HandleSetLanguage(c, s.store) })
+	s.router.GET("/api/me/invitations", func(c *gin.Context) { HandleListInvitations(c, svc) })
+	s.router.PUT("/api/me/invitations/:id", func(c *gin.Context) { HandleRespondInvitation(c, svc) })
+	s.router.GET("/api/equipment", func(c *gin.Context) { HandleListEquipment(c, svc) })
+	s.router.POST("/api/admin/equipment", func(c *gin.Context) { HandleCreateEquipment(c, s.store, svc) })
+	s.router.PUT("/api/admin/equipment/:id", func(c *gin.Context) { HandleUpdateEquipment(c, s.store, svc) })
+	s.router.GET("/healthz", HandleHealthz)
+	s.router.GET("/readyz", func(c *gin.Context) {
+		HandleReadyz(c, []ReadinessCheck{
+			{Name: "database", Check: func(context.Context) error { return s.ready }},
+			{Name: "migrations", Check: func(context.Context) error { return nil }},
+		})
+	})
+	s.router.GET("/version", HandleVersion)
+	reservations := s.router.Group("/api/reservations")
+	reservations.POST("", func(c *gin.Context) { Handlereservations(c, svc) })
+	reservations.PUT("", func(c *gin.Context) { HandlereservationsEdit(c, svc) })
+	reservations.GET("/me", func(c *gin.Context) { HandlereservationsMe(c, svc) })
+	reservations.PUT("/cancel", func(c *gin.Context) { HandlereservationsCancele(c, svc) })
+	reservations.GET("/search", func(c *gin.Context) { HandleSearchReservations(c, svc) })
+	reservations.GET("", func(c *gin.Context) { HandlerListReservations(c, svc) })
+	return s
+}
+
+// checkContract は全てのレスポンスが OpenAPI 仕様 (openapi.Spec) と一致するか確認します (契約テスト)。
+// ハンドラーのレスポンスにフィールドを追加・変更した場合は、openapi パッケージの仕様も更新してください。
+func checkContract(t *testing.T) gin.HandlerFunc {
+	spec := openapi.Spec()
+	return func(c *gin.Context) {
+		w := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		if c.FullPath() == "" {
+			// 登録されていないパス
+			return
+		}
+		path := openapi.PathFromGin(c.FullPath())
+		if err := spec.ValidateResponse(c.Request.Method, path, w.Status(), w.Header().Get("Content-Type"), w.body.Bytes()); err != nil {
+			t.Errorf("レスポンスが仕様と異なります: %v\n%s", err, w.body.String())
+		}
+	}
+}
+
+// recordingWriter はレスポンスボディを記録します。
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// createUser はユーザーを作成してそのIDを返します。
+func (s *testServer) createUser(name string) uint64 {
+	s.t.Helper()
+	return s.createUserWithRole(name, "user")
+}
+
+// createAdmin は管理者 (role = 'admin') のユーザーを作成してそのIDを返します。
+func (s *testServer) createAdmin(name string) uint64 {
+	s.t.Helper()
+	return s.createUserWithRole(name, "admin")
+}
+
+func (s *testServer) createUserWithRole(name, role string) uint64 {
+	s.t.Helper()
+	result, err := s.store.CreateUser(context.Background(), db.CreateUserParams{
+		Name:     name,
+		Email:    name + "@example.com",
+		GoogleID: "google-" + name,
+		Role:     role,
+	})
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	id, _ := result.LastInsertId()
+	return uint64(id)
+}
+
+// sessionCookie はログイン済みのセッションの Cookie を返します。
+func (s *testServer) sessionCookie(userID uint64) *http.Cookie {
+	s.t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	session, _ := s.sessions.Get(req, "session-name")
+	session.Values["user_id"] = strconv.FormatUint(userID, 10)
+	if err := session.Save(req, rec); err != nil {
+		s.t.Fatal(err)
+	}
+	return rec.Result().Cookies()[0]
+}
+
+// do はリクエストを送信します。userID が0の場合はログインしていない状態で送信します。
+func (s *testServer) do(method, target string, userID uint64, body any) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var reader *bytes.Reader
+	switch b := body.(type) {
+	case nil:
+		reader = bytes.NewReader(nil)
+	case string:
+		reader = bytes.NewReader([]byte(b))
+	default:
+		buf, err := json.Marshal(b)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		reader = bytes.NewReader(buf)
+	}
+	req := httptest.NewRequest(method, target, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if userID != 0 {
+		req.AddCookie(s.sessionCookie(userID))
+	}
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// reserve は予約を作成してそのIDを返します。
+func (s *testServer) reserve(userID uint64, title string, start, end time.Time) uint64 {
+	s.t.Helper()
+	rec := s.do(http.MethodPost, "/api/reservations", userID, reservationBody(title, start, end))
+	if rec.Code != http.StatusOK {
+		s.t.Fatalf("予約の作成に失敗しました: %d %s", rec.Code, rec.Body)
+	}
+	var res struct {
+		ID uint64 `json:"id"`
+	}
+	decode(s.t, rec, &res)
+	return res.ID
+}
+
+func reservationBody(title string, start, end time.Time) map[string]any {
+	return map[string]any{"title": title, "start_time": start, "end_time": end}
+}
+
+func decode(t *testing.T, rec *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("レスポンスを読み込めません: %v (%s)", err, rec.Body)
+	}
+}
+
+// listTitles は一覧APIのレスポンスから予約のタイトルを取り出します。
+func listTitles(t *testing.T, rec *httptest.ResponseRecorder) []string {
+	t.Helper()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	var res struct {
+		Data []struct {
+			Title    string `json:"title"`
+			UserName string `json:"user_name"`
+		} `json:"data"`
+	}
+	decode(t, rec, &res)
+	titles := []string{}
+	for _, r := range res.Data {
+		titles = append(titles, r.Title)
+	}
+	return titles
+}
+
+func at(day, hour int) time.Time {
+	return time.Date(2025, 7, day, hour, 0, 0, 0, jst)
+}
+
+func TestCreateReservation(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.createUser("alice")
+
+	rec := s.do(http.MethodPost, "/api/reservations", alice, reservationBody("輪講", at(1, 10), at(1, 12)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	var res struct {
+		Status string `json:"status"`
+		ID     uint64 `json:"id"`
+		UserID uint64 `json:"user_id"`
+		Title  string `json:"title"`
+	}
+	decode(t, rec, &res)
+	if res.Status != "success" || res.UserID != alice || res.Title != "輪講" {
+		t.Errorf("unexpected response: %+v", res)
+	}
+
+	reservation, err := s.store.GetReservationByID(context.Background(), res.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reservation.Status != "confirmed" || !reservation.StartTime.Equal(at(1, 10)) {
+		t.Errorf("unexpected reservation: %+v", reservation)
+	}
+	if events := s.store.Events(); len(events) != 1 || events[0].Action != "created" {
+		t.Errorf("変更履歴が記録されていません: %+v", events)
+	}
+}
+
+func TestCreateReservationValidation(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.createUser("alice")
+
+	tests := []struct {
+		name string
+		body any
+	}{
+		{"invalid json", "{"},
+		{"empty title", reservationBody(" ", at(1, 10), at(1, 12))},
+		{"end before start", reservationBody("輪講", at(1, 12), at(1, 10))},
+		{"same start and end", reservationBody("輪講", at(1, 10), at(1, 10))},
+		{"missing times", map[string]any{"title": "輪講"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(http.MethodPost, "/api/reservations", alice, tt.body)
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d (%s)", rec.Code, http.StatusBadRequest, rec.Body)
+			}
+		})
+	}
+	if events := s.store.Events(); len(events) != 0 {
+		t.Errorf("不正な予約の変更履歴が記録されました: %+v", events)
+	}
+}
+
+func TestCreateReservationOverlap(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.createUser("alice")
+	bob := s.createUser("bob")
+	s.reserve(alice, "輪講", at(1, 10), at(1, 12))
+
+	tests := []struct {
+		name       string
+		start, end time.Time
+		want       int
+	}{
+		{"same time", at(1, 10), at(1, 12), http.StatusConflict},
+		{"starts during", at(1, 11), at(1, 13), http.StatusConflict},
+		{"ends during", at(1, 9), at(1, 11), http.StatusConflict},
+		{"contains", at(1, 9), at(1, 13), http.StatusConflict},
+		{"ends at start", at(1, 9), at(1, 10), http.StatusOK},
+		{"starts at end", at(1, 12), at(1, 13), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(http.MethodPost, "/api/reservations", bob, reservationBody(tt.name, tt.start, tt.end))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d (%s)", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestEditReservation(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.createUser("alice")
+	bob := s.createUser("bob")
+	id := s.reserve(alice, "輪講", at(1, 10), at(1, 12))
+	target := "/api/reservations?id=" + strconv.FormatUint(id, 10)
+
+	rec := s.do(http.MethodPut, target, alice, reservationBody("ゼミ", at(1, 13), at(1, 15)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	reservation, _ := s.store.GetReservationByID(context.Background(), id)
+	if reservation.Title != "ゼミ" || !reservation.StartTime.Equal(at(1, 13)) || !reservation.EndTime.Equal(at(1, 15)) {
+		t.Errorf("予約が更新されていません: %+v", reservation)
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		userID uint64
+		body   any
+		want   int
+	}{
+		{"other user's reservation", target, bob, reservationBody("乗っ取り", at(1, 13), at(1, 15)), http.StatusNotFound},
+		{"not found", "/api/reservations?id=999", alice, reservationBody("ゼミ", at(1, 13), at(1, 15)), http.StatusNotFound},
+		{"missing id", "/api/reservations", alice, reservationBody("ゼミ", at(1, 13), at(1, 15)), http.StatusBadRequest},
+		{"invalid id", "/api/reservations?id=abc", alice, reservationBody("ゼミ", at(1, 13), at(1, 15)), http.StatusBadRequest},
+		{"invalid json", target, alice, "{", http.StatusBadRequest},
+		{"not logged in", target, 0, reservationBody("ゼミ", at(1, 13), at(1, 15)), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(http.MethodPut, tt.target, tt.userID, tt.body)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d (%s)", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+	if reservation, _ := s.store.GetReservationByID(context.Background(), id); reservation.Title != "ゼミ" {
+		t.Errorf("失敗したリクエストで予約が変更されました: %+v", reservation)
+	}
+}
+
+func TestCancelReservation(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.createUser("alice")
+	bob := s.createUser("bob")
+	id := s.reserve(alice, "輪講", at(1, 10), at(1, 12))
+	target := "/api/reservations/cancel?id=" + strconv.FormatUint(id, 10)
+
+	if rec := s.do(http.MethodPut, target, bob, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("他のユーザーの予約: status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	if rec := s.do(http.MethodPut, target, 0, nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("未ログイン: status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if rec := s.do(http.MethodPut, "/api/reservations/cancel?id=abc", alice, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("不正なID: status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if reservation, _ := s.store.GetReservationByID(context.Background(), id); reservation.Status != "confirmed" {
+		t.Fatalf("失敗したリクエストで予約がキャンセルされました: %+v", reservation)
+	}
+
+	if rec := s.do(http.MethodPut, target, alice, nil); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	if reservation, _ := s.store.GetReservationByID(context.Background(), id); reservation.Status != "canceled" {
+		t.Errorf("予約がキャンセルされていません: %+v", reservation)
+	}
+
+	// キャンセルした時間帯には再び予約できる
+	s.reserve(bob, "ミーティング", at(1, 10), at(1, 12))
+}
+
+func TestCreateReservationRequiresLogin(t *testing.T) {
+	s := newTestServer(t)
+
+	rec := s.do(http.MethodPost, "/api/reservations", 0, reservationBody("輪講", at(1, 10), at(1, 12)))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	// 改ざんされた Cookie も受け付けない
+	req := httptest.NewRequest(http.MethodGet, "/api/reservations/me", nil)
+	req.AddCookie(&http.Cookie{Name: "session-name", Value: "forged"})
+	forged := httptest.NewRecorder()
+	s.router.ServeHTTP(forged, req)
+	if forged.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", forged.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestListReservations(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.createUser("alice")
+	bob := s.createUser("bob")
+
+	s.reserve(alice, "6月末", time.Date(2025, 6, 30, 22, 0, 0, 0, time.UTC), time.Date(2025, 7, 1, 1, 0, 0, 0, time.UTC))
+	s.reserve(bob, "7月1日", time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC), time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC))
+	s.reserve(alice, "7月3日", time.Date(2025, 7, 3, 10, 0, 0, 0, time.UTC), time.Date(2025, 7, 3, 12, 0, 0, 0, time.UTC))
+	canceled := s.reserve(alice, "キャンセル済み", time.Date(2025, 7, 1, 13, 0, 0, 0, time.UTC), time.Date(2025, 7, 1, 14, 0, 0, 0, time.UTC))
+	s.reserve(bob, "8月", time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC), time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC))
+	if rec := s.do(http.MethodPut, "/api/reservations/cancel?id="+strconv.FormatUint(canceled, 10), alice, nil); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		want   []string
+	}{
+		{"month", "/api/reservations?month=2025-07", []string{"6月末", "7月1日", "7月3日"}},
+		{"month without reservations", "/api/reservations?month=2025-09", []string{}},
+		{"week", "/api/reservations?start=2025-07-02&end=2025-07-08", []string{"7月3日"}},
+		{"week includes end date", "/api/reservations?start=2025-07-28&end=2025-08-01", []string{"8月"}},
+		{"date", "/api/reservations?date=2025-07-01", []string{"6月末", "7月1日"}},
+		{"date without reservations", "/api/reservations?date=2025-07-02", []string{}},
+		{"from and to", "/api/reservations?from=2025-07-01T09:00:00Z&to=2025-07-03", []string{"7月1日", "7月3日"}},
+		{"past", "/api/reservations?include_past=true", []string{"6月末", "7月1日", "7月3日", "8月"}},
+		{"upcoming only by default", "/api/reservations", []string{}},
+		{"user", "/api/reservations?month=2025-07&user_id=" + strconv.FormatUint(bob, 10), []string{"7月1日"}},
+		{"title", "/api/reservations?month=2025-07&title=3日", []string{"7月3日"}},
+		{"canceled", "/api/reservations?date=2025-07-01&include_canceled=true", []string{"6月末", "7月1日", "キャンセル済み"}},
+		{"status", "/api/reservations?month=2025-07&status=canceled", []string{"キャンセル済み"}},
+		{"descending", "/api/reservations?month=2025-07&sort=-start_time", []string{"7月3日", "7月1日", "6月末"}},
+		{"me", "/api/reservations/me?include_past=true", []string{"6月末", "7月3日"}},
+		{"me upcoming", "/api/reservations/me", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := listTitles(t, s.do(http.MethodGet, tt.target, alice, nil))
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+
+	t.Run("pages", func(t *testing.T) {
+		var got []string
+		target := "/api/reservations?include_past=true&limit=3"
+		for {
+			rec := s.do(http.MethodGet, target, alice, nil)
+			got = append(got, listTitles(t, rec)...)
+			var res struct {
+				NextCursor *string `json:"next_cursor"`
+			}
+			decode(t, rec, &res)
+			if res.NextCursor == nil {
+				break
+			}
+			target = "/api/reservations?include_past=true&limit=3&cursor=" + url.QueryEscape(*res.NextCursor)
+		}
+		want := []string{"6月末", "7月1日", "7月3日", "8月"}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	badRequests := []string{
+		"/api/reservations?month=2025/07",
+		"/api/reservations?start=2025-07-01",
+		"/api/reservations?start=2025-07-01&end=07-08",
+		"/api/reservations?date=20250701",
+		"/api/reservations?date=2025-07-01&tz=Mars/Olympus",
+		"/api/reservations?date=2025-07-01&tz=Local",
+		"/api/reservations?from=2025-07-03&to=2025-07-01",
+		"/api/reservations?user_id=alice",
+		"/api/reservations?status=deleted",
+		"/api/reservations?sort=title",
+		"/api/reservations?limit=0",
+		"/api/reservations?limit=201",
+		"/api/reservations?include_past=maybe",
+		"/api/reservations?cursor=broken",
+		"/api/reservations/me?created_by=x",
+	}
+	for _, target := range badRequests {
+		if rec := s.do(http.MethodGet, target, alice, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", target, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+// 日付と月は UTC ではなく、設定したタイムゾーン (テストでは日本時間) または tz の0時で区切ります。
+func TestListReservationsTimezone(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.createUser("alice")
+
+	s.reserve(alice, "6月30日23時", time.Date(2025, 6, 30, 23, 0, 0, 0, jst), time.Date(2025, 6, 30, 23, 30, 0, 0, jst))
+	s.reserve(alice, "7月1日0時", time.Date(2025, 7, 1, 0, 0, 0, 0, jst), time.Date(2025, 7, 1, 1, 0, 0, 0, jst))
+	s.reserve(alice, "7月1日23時", time.Date(2025, 7, 1, 23, 0, 0, 0, jst), time.Date(2025, 7, 1, 23, 30, 0, 0, jst))
+	s.reserve(alice, "7月31日23時", time.Date(2025, 7, 31, 23, 0, 0, 0, jst), time.Date(2025, 7, 31, 23, 30, 0, 0, jst))
+
+	tests := []struct {
+		name   string
+		target string
+		want   []string
+	}{
+		{"date", "/api/reservations?date=2025-07-01", []string{"7月1日0時", "7月1日23時"}},
+		{"date in UTC", "/api/reservations?date=2025-07-01&tz=UTC", []string{"7月1日23時"}},
+		{"date in UTC includes previous JST day", "/api/reservations?date=2025-06-30&tz=UTC", []string{"6月30日23時", "7月1日0時"}},
+		{"month starts at JST midnight", "/api/reservations?month=2025-07", []string{"7月1日0時", "7月1日23時", "7月31日23時"}},
+		{"month ends at JST midnight", "/api/reservations?month=2025-08", []string{}},
+		{"previous month", "/api/reservations?month=2025-06", []string{"6月30日23時"}},
+		{"to date", "/api/reservations?from=2025-07-01&to=2025-07-01", []string{"7月1日0時", "7月1日23時"}},
+		{"RFC 3339 ignores tz", "/api/reservations?from=2025-07-01T14:00:00Z&to=2025-07-01T15:00:00Z&tz=America/New_York", []string{"7月1日23時"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := listTitles(t, s.do(http.MethodGet, tt.target, alice, nil))
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// 夏時間の切り替わる日は23時間・25時間になり、月やその年の区切りも tz の0時になります。
+func TestListRange(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	utc := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2025, month, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		query     string
+		wantStart time.Time
+		wantEnd   time.Time
+	}{
+		// 既定のタイムゾーン (日本時間)
+		{"date=2025-07-01", utc(6, 30, 15), utc(7, 1, 15)},
+		{"month=2025-07", utc(6, 30, 15), utc(7, 31, 15)},
+		{"month=2025-12", utc(11, 30, 15), utc(12, 31, 15)},
+		{"start=2025-06-30&end=2025-07-01", utc(6, 29, 15), utc(7, 1, 15)},
+		{"from=2025-07-01&to=2025-07-31", utc(6, 30, 15), utc(7, 31, 15)},
+		// 夏時間の始まり (3月9日は23時間)
+		{"date=2025-03-09&tz=America/New_York", utc(3, 9, 5), utc(3, 10, 4)},
+		{"start=2025-03-08&end=2025-03-09&tz=America/New_York", utc(3, 8, 5), utc(3, 10, 4)},
+		{"month=2025-03&tz=America/New_York", utc(3, 1, 5), utc(4, 1, 4)},
+		// 夏時間の終わり (11月2日は25時間)
+		{"date=2025-11-02&tz=America/New_York", utc(11, 2, 4), utc(11, 3, 5)},
+		{"from=2025-11-01&to=2025-11-02&tz=America/New_York", utc(11, 1, 4), utc(11, 3, 5)},
+		{"month=2025-11&tz=America/New_York", utc(11, 1, 4), utc(12, 1, 5)},
+		// 南半球 (10月5日に夏時間が始まる)
+		{"date=2025-10-05&tz=Australia/Sydney", utc(10, 4, 14), utc(10, 5, 13)},
+		{"date=2025-07-01&tz=UTC", utc(7, 1, 0), utc(7, 2, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/reservations?"+tt.query, nil)
+			utils.SetLocation(jst)(c)
+
+			r, err := listRange(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !r.Start.Equal(tt.wantStart) || !r.End.Equal(tt.wantEnd) {
+				t.Errorf("got %s - %s, want %s - %s", r.Start.UTC(), r.End.UTC(), tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestListReservationsVisibility(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.createUser("alice")
+	bob := s.createUser("bob")
+	carol := s.createUser("carol")
+
+	s.reserve(alice, "公開", at(1, 9), at(1, 10))
+	for _, r := range []struct {
+		title, visibility string
+		start             int
+	}{
+		{"メンバー", "members", 10},
+		{"非公開", "private", 11},
+	} {
+		body := reservationBody(r.title, at(1, r.start), at(1, r.start+1))
+		body["visibility"] = r.visibility
+		body["headcount"] = 2
+		body["attendees"] = []uint64{carol}
+		rec := s.do(http.MethodPost, "/api/reservations", alice, body)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+		}
+		var res struct {
+			Visibility string `json:"visibility"`
+			Headcount  int    `json:"headcount"`
+			Attendees  []struct {
+				UserID uint64 `json:"user_id"`
+				Name   string `json:"name"`
+			} `json:"attendees"`
+		}
+		decode(t, rec, &res)
+		if res.Visibility != r.visibility || res.Headcount != 2 || len(res.Attendees) != 1 || res.Attendees[0].Name != "carol" {
+			t.Errorf("unexpected response: %+v", res)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		viewer uint64
+		want   []string
+	}{
+		// 一覧はログインしていなくても見られるが、公開以外の予約は「予約済み」と表示する
+		{"anonymous", 0, []string{"公開", "予約済み", "予約済み"}},
+		{"member", bob, []string{"公開", "メンバー", "予約済み"}},
+		{"attendee", carol, []string{"公開", "メンバー", "非公開"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := listTitles(t, s.do(http.MethodGet, "/api/reservations?date=2025-07-01&include_past=true", tt.viewer, nil))
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	body := reservationBody("満員", at(2, 10), at(2, 11))
+	body["headcount"] = reservation.DefaultCapacity + 1
+	if rec := s.do(http.MethodPost, "/api/reservations", alice, body); rec.Code != http.StatusBadRequest {
+		t.Errorf("定員を超える: status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestInvitations(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.createUser("alice")
+	bob := s.createUser("bob")
+
+	// 招待の一覧には終了していない予約だけが含まれるため、明日の予約にする
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+	body := reservationBody("輪講", start, start.Add(time.Hour))
+	body["attendees"] = []uint64{bob}
+	rec := s.do(http.MethodPost, "/api/reservations", alice, body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	var created struct {
+		ID uint64 `json:"id"`
+	}
+	decode(t, rec, &created)
+	target := "/api/me/invitations/" + strconv.FormatUint(created.ID, 10)
+
+	var invitations struct {
+		Data []struct {
+			ID       uint64 `json:"id"`
+			UserName string `json:"user_name"`
+			Response string `json:"response"`
+		} `json:"data"`
+	}
+	decode(t, s.do(http.MethodGet, "/api/me/invitations", bob, nil), &invitations)
+	if len(invitations.Data) != 1 || invitations.Data[0].ID != created.ID || invitations.Data[0].Response != "needs_action" {
+		t.Fatalf("invitations = %+v", invitations.Data)
+	}
+
+	rec = s.do(http.MethodPut, target, bob, map[string]any{"response": "accepted"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	var responded struct {
+		Attendees []struct {
+			UserID   uint64 `json:"user_id"`
+			Response string `json:"response"`
+		} `json:"attendees"`
+	}
+	decode(t, rec, &responded)
+	if len(responded.Attendees) != 1 || responded.Attendees[0].Response != "accepted" {
+		t.Errorf("attendees = %+v, want bob が accepted", responded.Attendees)
+	}
+	// 承諾した予約は自分の予約一覧にも含まれる
+	if got := listTitles(t, s.do(http.MethodGet, "/api/reservations/me", bob, nil)); strings.Join(got, ",") != "輪講" {
+		t.Errorf("/api/reservations/me = %v, want [輪講]", got)
+	}
+
+	requests := []struct {
+		target string
+		userID uint64
+		body   any
+		want   int
+	}{
+		{target, bob, map[string]any{"response": "maybe"}, http.StatusBadRequest},
+		{target, alice, map[string]any{"response": "accepted"}, http.StatusNotFound},
+		{"/api/me/invitations/x", bob, map[string]any{"response": "accepted"}, http.StatusBadRequest},
+		{target, 0, map[string]any{"response": "accepted"}, http.StatusUnauthorized},
+	}
+	for _, r := range requests {
+		if rec := s.do(http.MethodPut, r.target, r.userID, r.body); rec.Code != r.want {
+			t.Errorf("PUT %s (user %d): status = %d, want %d", r.target, r.userID, rec.Code, r.want)
+		}
+	}
+}
+
+func TestEquipment(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.createAdmin("admin")
+	alice := s.createUser("alice")
+	bob := s.createUser("bob")
+
+	if rec := s.do(http.MethodPost, "/api/admin/equipment", alice, map[string]any{"name": "プロジェクター", "quantity": 1}); rec.Code != http.StatusForbidden {
+		t.Errorf("管理者以外の登録: status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	rec := s.do(http.MethodPost, "/api/admin/equipment", admin, map[string]any{"name": "プロジェクター", "quantity": 1})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	var created struct {
+		Data struct {
+			ID uint64 `json:"id"`
+		} `json:"data"`
+	}
+	decode(t, rec, &created)
+	projector := created.Data.ID
+
+	body := reservationBody("輪講", at(1, 10), at(1, 12))
+	body["equipment"] = []map[string]any{{"equipment_id": projector, "quantity": 1}}
+	rec = s.do(http.MethodPost, "/api/reservations", alice, body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	var reserved struct {
+		Equipment []struct {
+			EquipmentID uint64 `json:"equipment_id"`
+			Name        string `json:"name"`
+			Quantity    int    `json:"quantity"`
+		} `json:"equipment"`
+	}
+	decode(t, rec, &reserved)
+	if len(reserved.Equipment) != 1 || reserved.Equipment[0].Name != "プロジェクター" || reserved.Equipment[0].Quantity != 1 {
+		t.Errorf("equipment = %+v, want プロジェクター1台", reserved.Equipment)
+	}
+
+	available := func(start, end time.Time) int {
+		t.Helper()
+		rec := s.do(http.MethodGet, "/api/equipment?start="+url.QueryEscape(start.Format(time.RFC3339))+"&end="+url.QueryEscape(end.Format(time.RFC3339)), 0, nil)
+		var res struct {
+			Data []struct {
+				Available int `json:"available"`
+			} `json:"data"`
+		}
+		decode(t, rec, &res)
+		if rec.Code != http.StatusOK || len(res.Data) != 1 {
+			t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+		}
+		return res.Data[0].Available
+	}
+	if got := available(at(1, 11), at(1, 13)); got != 0 {
+		t.Errorf("予約と重なる時間帯の空き = %d, want 0", got)
+	}
+	if got := available(at(1, 12), at(1, 13)); got != 1 {
+		t.Errorf("予約の後の時間帯の空き = %d, want 1", got)
+	}
+
+	requests := []struct {
+		method, target string
+		userID         uint64
+		body           any
+		wantCode       string
+	}{
+		{http.MethodPost, "/api/reservations", bob, map[string]any{
+			"title": "会議", "start_time": at(1, 13), "end_time": at(1, 14),
+			"equipment": []map[string]any{{"equipment_id": projector, "quantity": 2}},
+		}, apierror.CodeEquipmentUnavailable},
+		{http.MethodPost, "/api/reservations", bob, map[string]any{
+			"title": "会議", "start_time": at(1, 13), "end_time": at(1, 14),
+			"equipment": []map[string]any{{"equipment_id": 999, "quantity": 1}},
+		}, apierror.CodeValidation},
+		{http.MethodPut, "/api/admin/equipment/999", admin, map[string]any{"name": "スピーカー", "quantity": 1}, apierror.CodeEquipmentNotFound},
+		{http.MethodPost, "/api/admin/equipment", admin, map[string]any{"name": "プロジェクター", "quantity": 2}, apierror.CodeValidation},
+		{http.MethodGet, "/api/equipment?start=2025-07-01", 0, nil, apierror.CodeBadRequest},
+	}
+	for _, r := range requests {
+		var res struct {
+			Code string `json:"code"`
+		}
+		decode(t, s.do(r.method, r.target, r.userID, r.body), &res)
+		if res.Code != r.wantCode {
+			t.Errorf("%s %s: code = %q, want %q", r.method, r.target, res.Code, r.wantCode)
+		}
+	}
+}
+
+func TestSearchReservations(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.createUser("alice")
+	bob := s.createUser("bob")
+
+	s.reserve(alice, "輪講", at(1, 10), at(1, 12))
+	body := reservationBody("会議", at(2, 10), at(2, 12))
+	body["description"] = "輪講の日程を決める"
+	if rec := s.do(http.MethodPost, "/api/reservations", bob, body); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	s.reserve(bob, "面談", at(3, 10), at(3, 12))
+
+	search := func(query string) *httptest.ResponseRecorder {
+		return s.do(http.MethodGet, "/api/reservations/search?"+query, alice, nil)
+	}
+	got := listTitles(t, search("q="+url.QueryEscape("輪講")))
+	if want := []string{"輪講", "会議"}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	rec := search("q=" + url.QueryEscape("輪講") + "&limit=1")
+	var res struct {
+		NextOffset *int `json:"next_offset"`
+	}
+	decode(t, rec, &res)
+	if res.NextOffset == nil || *res.NextOffset != 1 {
+		t.Errorf("next_offset = %v, want 1", res.NextOffset)
+	}
+
+	badRequests := []string{
+		"q=",
+		"q=" + strings.Repeat("a", 101),
+		"q=a&limit=0",
+		"q=a&limit=x",
+		"q=a&offset=-1",
+	}
+	for _, query := range badRequests {
+		if rec := search(query); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", query, rec.Code, http.StatusBadRequest)
+		}
+	}
+	if rec := s.do(http.MethodGet, "/api/reservations/search?q=a", 0, nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("ログインしていない: status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestErrorResponse(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.createUser("alice")
+	s.reserve(alice, "輪講", at(1, 10), at(1, 12))
+
+	type errorResponse struct {
+		Status    string                `json:"status"`
+		Code      string                `json:"code"`
+		Message   string                `json:"message"`
+		Details   []apierror.FieldError `json:"details"`
+		RequestID string                `json:"request_id"`
+	}
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		userID     uint64
+		body       any
+		wantStatus int
+		wantCode   string
+		wantField  string
+	}{
+		{"validation", http.MethodPost, "/api/reservations", alice, reservationBody(" ", at(2, 10), at(2, 12)), http.StatusBadRequest, apierror.CodeValidation, "title"},
+		{"invalid json", http.MethodPost, "/api/reservations", alice, "{", http.StatusBadRequest, apierror.CodeBadRequest, ""},
+		{"wrong type", http.MethodPost, "/api/reservations", alice, map[string]any{"title": 1}, http.StatusBadRequest, apierror.CodeBadRequest, "title"},
+		{"conflict", http.MethodPost, "/api/reservations", alice, reservationBody("ゼミ", at(1, 11), at(1, 13)), http.StatusConflict, apierror.CodeReservationConflict, ""},
+		{"not found", http.MethodPut, "/api/reservations/cancel?id=999", alice, nil, http.StatusNotFound, apierror.CodeReservationNotFound, ""},
+		{"not logged in", http.MethodGet, "/api/reservations/me", 0, nil, http.StatusUnauthorized, apierror.CodeUnauthorized, ""},
+		{"bad query", http.MethodGet, "/api/reservations?date=20250701", alice, nil, http.StatusBadRequest, apierror.CodeBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(tt.method, tt.target, tt.userID, tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			var res errorResponse
+			decode(t, rec, &res)
+			if res.Status != "error" || res.Code != tt.wantCode || res.Message == "" {
+				t.Errorf("unexpected response: %s", rec.Body)
+			}
+			if res.RequestID == "" || res.RequestID != rec.Header().Get(apierror.RequestIDHeader) {
+				t.Errorf("request_id = %q, header = %q", res.RequestID, rec.Header().Get(apierror.RequestIDHeader))
+			}
+			if tt.wantField != "" && (len(res.Details) != 1 || res.Details[0].Field != tt.wantField) {
+				t.Errorf("details = %+v, want field %q", res.Details, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestErrorResponseProblemJSON(t *testing.T) {
+	s := newTestServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/reservations/me", nil)
+	req.Header.Set("Accept", "application/problem+json, application/json;q=0.9")
+	req.Header.Set(apierror.RequestIDHeader, "client-request-1")
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/problem+json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	var res struct {
+		Type      string `json:"type"`
+		Title     string `json:"title"`
+		Status    int    `json:"status"`
+		Detail    string `json:"detail"`
+		Instance  string `json:"instance"`
+		Code      string `json:"code"`
+		RequestID string `json:"request_id"`
+	}
+	decode(t, rec, &res)
+	if res.Type != "about:blank" || res.Title != "Unauthorized" || res.Status != http.StatusUnauthorized ||
+		res.Detail == "" || res.Instance != "/api/reservations/me" || res.Code != apierror.CodeUnauthorized {
+		t.Errorf("unexpected problem: %+v", res)
+	}
+	// クライアントが指定したリクエストIDをそのまま使う
+	if res.RequestID != "client-request-1" {
+		t.Errorf("request_id = %q, want client-request-1", res.RequestID)
+	}
+}
+
+func TestErrorResponseLanguage(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.createUser("alice")
+
+	send := func(method, target string, cookie *http.Cookie, acceptLanguage string, body any) *httptest.ResponseRecorder {
+		t.Helper()
+		buf, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(method, target, bytes.NewReader(buf))
+		req.Header.Set("Content-Type", "application/json")
+		if acceptLanguage != "" {
+			req.Header.Set("Accept-Language", acceptLanguage)
+		}
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		return rec
+	}
+	invalid := reservationBody(" ", at(2, 10), at(2, 12))
+	message := func(rec *httptest.ResponseRecorder) (string, string) {
+		t.Helper()
+		var res struct {
+			Message string                `json:"message"`
+			Details []apierror.FieldError `json:"details"`
+		}
+		decode(t, rec, &res)
+		if len(res.Details) != 1 {
+			t.Fatalf("details = %+v", res.Details)
+		}
+		return res.Message, res.Details[0].Message
+	}
+
+	// 表示言語を選んでいなければ Accept-Language に従う
+	cookie := s.sessionCookie(alice)
+	rec := send(http.MethodPost, "/api/reservations", cookie, "en-US,en;q=0.9,ja;q=0.8", invalid)
+	if msg, detail := message(rec); msg != "Please enter a title" || detail != "Please enter a title" {
+		t.Errorf("英語のメッセージではありません: %q %q", msg, detail)
+	}
+	if got := rec.Header().Get("Content-Language"); got != "en" {
+		t.Errorf("Content-Language = %q, want en", got)
+	}
+	rec = send(http.MethodPost, "/api/reservations", cookie, "", invalid)
+	if msg, _ := message(rec); msg != "タイトルを入力してください" {
+		t.Errorf("既定は日本語のはずです: %q", msg)
+	}
+
+	// 選んだ表示言語は Accept-Language より優先する
+	rec = send(http.MethodPut, "/api/me/language", cookie, "", map[string]any{"language": "ja"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("表示言語の変更に失敗しました: %d %s", rec.Code, rec.Body)
+	}
+	user, err := s.store.GetUserByID(context.Background(), alice)
+	if err != nil || user.Language.String != "ja" {
+		t.Errorf("表示言語が保存されていません: %+v, %v", user.Language, err)
+	}
+	cookie = rec.Result().Cookies()[0]
+	rec = send(http.MethodPost, "/api/reservations", cookie, "en", invalid)
+	if msg, _ := message(rec); msg != "タイトルを入力してください" {
+		t.Errorf("選んだ表示言語が使われていません: %q", msg)
+	}
+
+	// 対応していない言語は選べない
+	rec = send(http.MethodPut, "/api/me/language", cookie, "", map[string]any{"language": "fr"})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+// 予約の一連の操作のレスポンスが OpenAPI 仕様と一致することを確認します (確認は checkContract が行います)。
+func TestContract(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.createUser("alice")
+	bob := s.createUser("bob")
+	admin := s.createAdmin("admin")
+
+	id := s.reserve(alice, "輪講", at(1, 10), at(1, 12))
+	tomorrow := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+	invited := s.reserve(bob, "面談", tomorrow, tomorrow.Add(time.Hour))
+	if rec := s.do(http.MethodPut, "/api/reservations?id="+strconv.FormatUint(invited, 10), bob, map[string]any{
+		"title": "面談", "start_time": tomorrow, "end_time": tomorrow.Add(time.Hour), "attendees": []uint64{alice},
+	}); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	requests := []struct {
+		method, target string
+		body           any
+		wantStatus     int
+	}{
+		{http.MethodGet, "/api/me", nil, http.StatusOK},
+		{http.MethodPut, "/api/me/language", map[string]any{"language": "en"}, http.StatusOK},
+		{http.MethodGet, "/api/me/invitations", nil, http.StatusOK},
+		{http.MethodPut, "/api/me/invitations/" + strconv.FormatUint(invited, 10), map[string]any{"response": "tentative"}, http.StatusOK},
+		{http.MethodPut, "/api/me/invitations/" + strconv.FormatUint(id, 10), map[string]any{"response": "accepted"}, http.StatusNotFound},
+		{http.MethodPut, "/api/reservations?id=" + strconv.FormatUint(id, 10), reservationBody("ゼミ", at(1, 13), at(1, 14)), http.StatusOK},
+		{http.MethodGet, "/api/reservations/me?include_past=true", nil, http.StatusOK},
+		{http.MethodGet, "/api/reservations?include_past=true&limit=1", nil, http.StatusOK},
+		{http.MethodGet, "/api/reservations?status=deleted", nil, http.StatusBadRequest},
+		{http.MethodPost, "/api/reservations", map[string]any{
+			"title": "非公開", "start_time": at(2, 10), "end_time": at(2, 11),
+			"headcount": 2, "visibility": "private", "attendees": []uint64{bob},
+		}, http.StatusOK},
+		{http.MethodPost, "/api/reservations", map[string]any{
+			"title": "満員", "start_time": at(3, 10), "end_time": at(3, 11), "headcount": 100,
+		}, http.StatusBadRequest},
+		{http.MethodGet, "/api/reservations?month=2025-07", nil, http.StatusOK},
+		{http.MethodGet, "/api/reservations/search?q=" + url.QueryEscape("ゼミ") + "&limit=1", nil, http.StatusOK},
+		{http.MethodGet, "/api/reservations/search?q=", nil, http.StatusBadRequest},
+		{http.MethodGet, "/api/reservations?start=2025-07-01&end=2025-07-07", nil, http.StatusOK},
+		{http.MethodGet, "/api/reservations?date=2025-07-01", nil, http.StatusOK},
+		{http.MethodPut, "/api/reservations/cancel?id=" + strconv.FormatUint(id, 10), nil, http.StatusOK},
+		{http.MethodPut, "/api/reservations/cancel?id=999", nil, http.StatusNotFound},
+		{http.MethodGet, "/api/equipment", nil, http.StatusOK},
+		{http.MethodPost, "/api/admin/equipment", map[string]any{"name": "プロジェクター", "quantity": 1}, http.StatusForbidden},
+	}
+	for _, r := range requests {
+		if rec := s.do(r.method, r.target, alice, r.body); rec.Code != r.wantStatus {
+			t.Errorf("%s %s: status = %d, want %d (%s)", r.method, r.target, rec.Code, r.wantStatus, rec.Body)
+		}
+	}
+
+	// 備品の登録・変更と、備品を借りる予約
+	adminRequests := []struct {
+		method, target string
+		body           any
+		wantStatus     int
+	}{
+		{http.MethodPost, "/api/admin/equipment", map[string]any{"name": "プロジェクター", "quantity": 1}, http.StatusOK},
+		{http.MethodPut, "/api/admin/equipment/1", map[string]any{"name": "プロジェクター", "quantity": 2}, http.StatusOK},
+		{http.MethodPut, "/api/admin/equipment/1", map[string]any{"name": "", "quantity": 2}, http.StatusBadRequest},
+		{http.MethodPost, "/api/reservations", map[string]any{
+			"title": "備品", "start_time": at(4, 10), "end_time": at(4, 11),
+			"equipment": []map[string]any{{"equipment_id": 1, "quantity": 2}},
+		}, http.StatusOK},
+		{http.MethodGet, "/api/equipment?start=2025-07-04T10:00:00%2B09:00&end=2025-07-04T11:00:00%2B09:00", nil, http.StatusOK},
+		{http.MethodGet, "/api/reservations?month=2025-07", nil, http.StatusOK},
+	}
+	for _, r := range adminRequests {
+		if rec := s.do(r.method, r.target, admin, r.body); rec.Code != r.wantStatus {
+			t.Errorf("%s %s: status = %d, want %d (%s)", r.method, r.target, rec.Code, r.wantStatus, rec.Body)
+		}
+	}
+}
+
+// GET /readyz は確認に失敗した項目があれば 503 を返します (レスポンスは契約テストで確認)。
+func TestHealth(t *testing.T) {
+	s := newTestServer(t)
+	if rec := s.do(http.MethodGet, "/healthz", 0, nil); rec.Code != http.StatusOK {
+		t.Errorf("healthz: status = %d", rec.Code)
+	}
+
+	type readiness struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks"`
+	}
+	rec := s.do(http.MethodGet, "/readyz", 0, nil)
+	var got readiness
+	decode(t, rec, &got)
+	if rec.Code != http.StatusOK || got.Status != "ok" || got.Checks["database"] != "ok" || got.Checks["migrations"] != "ok" {
+		t.Errorf("readyz: status = %d, body = %+v", rec.Code, got)
+	}
+
+	s.ready = errors.New("接続できません")
+	rec = s.do(http.MethodGet, "/readyz", 0, nil)
+	got = readiness{}
+	decode(t, rec, &got)
+	if rec.Code != http.StatusServiceUnavailable || got.Status != "unavailable" || got.Checks["database"] != "error" || got.Checks["migrations"] != "ok" {
+		t.Errorf("readyz: status = %d, body = %+v", rec.Code, got)
+	}
+	// エラーの詳細は返さない
+	if strings.Contains(rec.Body.String(), "接続できません") {
+		t.Errorf("readyz: body = %s", rec.Body.String())
+	}
+
+	rec = s.do(http.MethodGet, "/version", 0, nil)
+	var version struct {
+		Commit    string `json:"commit"`
+		GoVersion string `json:"go_version"`
+	}
+	decode(t, rec, &version)
+	if rec.Code != http.StatusOK || version.Commit == "" || !strings.HasPrefix(version.GoVersion, "go") {
+		t.Errorf("version: status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestMapError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{"suspended", reservation.ErrSuspended, http.StatusForbidden, apierror.CodeAccountSuspended},
+		{"forbidden", reservation.ErrForbidden, http.StatusForbidden, apierror.CodeForbidden},
+		{"not found", reservation.ErrNotFound, http.StatusNotFound, apierror.CodeReservationNotFound},
+		{"conflict", reservation.ErrConflict, http.StatusConflict, apierror.CodeReservationConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MapError(tt.err)
+			if got == nil || got.Status != tt.wantStatus || got.Code != tt.wantCode {
+				t.Errorf("MapError(%v) = %+v, want %d %s", tt.err, got, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+	"yoyaku/apierror"
+	"yoyaku/db"
+	"yoyaku/events"
+	"yoyaku/reservation"
+	"yoyaku/store"
+	"yoyaku/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SSEの接続を維持するためにコメントを送る間隔
+const streamHeartbeatInterval = 30 * time.Second
+
+// 予約の作成・編集・キャンセルをServer-Sent Eventsで配信する
+// GET /api/reservations/stream?start=YYYY-MM-DD&end=YYYY-MM-DD または ?date=YYYY-MM-DD (日付は tz のタイムゾーン)
+// 期間を指定しない場合は全ての予約のイベントを配信する
+// 時間帯を変更した予約は、変更前か変更後の時間帯が期間と重なる場合に配信する (期間外へ移動した予約を消せるように)
+// resource (room-401 または equipment-{備品ID}、複数指定可) を指定した場合は、そのいずれかを使う予約のイベントだけを配信する
+// 全ての予約が部屋を使うため、省略した場合と room-401 を指定した場合は全ての予約が対象になる
+// 備品を外した予約は、変更前に借りていた備品で絞り込んでいる場合にも配信する
+// 他のユーザーの非公開の予約は、タイトルと説明を伏せて配信する
+// draining が閉じられたら (サーバーの停止中) 配信を終える。クライアントは再接続する
+func HandleReservationStream(c *gin.Context, s store.Store, bus events.Bus, draining <-chan struct{}) {
+	userID, ok := utils.GetUserIDFromSession(c)
+	if !ok {
+		return
+	}
+
+	loc, err := utils.RequestLocation(c)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+	layout := "2006-01-02"
+	var rangeStart, rangeEnd time.Time
+	if dateStr := c.Query("date"); dateStr != "" {
+		date, err := time.ParseInLocation(layout, dateStr, loc)
+		if err != nil {
+			apierror.Abort(c, apierror.BadRequest("日付の形式が正しくありません (YYYY-MM-DD)"))
+			return
+		}
+		rangeStart = date
+		rangeEnd = date.AddDate(0, 0, 1)
+	} else if c.Query("start") != "" || c.Query("end") != "" {
+		startTime, err1 := time.ParseInLocation(layout, c.Query("start"), loc)
+		endTime, err2 := time.ParseInLocation(layout, c.Query("end"), loc)
+		if err1 != nil || err2 != nil {
+			apierror.Abort(c, apierror.BadRequest("日付の形式が正しくありません (YYYY-MM-DD)"))
+			return
+		}
+		rangeStart = startTime
+		rangeEnd = endTime.AddDate(0, 0, 1)
+	}
+	resources, err := parseResources(c)
+	if err != nil {
+		apierror.Abort(c, err)
+		return
+	}
+
+	sub := bus.Subscribe()
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // nginx等のプロキシでバッファリングさせない
+
+	// 配信は接続している間ずっと続くため、サーバーの書き込みのタイムアウトを解除する
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		log.Println("SSE の書き込みのタイムアウトを解除できませんでした:", err)
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-draining:
+			return false
+		case <-heartbeat.C:
+			// コメント行はクライアントに無視されるが、接続が切れていないことを確認できる
+			_, err := io.WriteString(w, ": ping\n\n")
+			return err == nil
+		case event, ok := <-sub.C:
+			if !ok {
+				return false
+			}
+			if !rangeStart.IsZero() && !eventInRange(event, rangeStart, rangeEnd) {
+				return true
+			}
+			if ok, err := eventUsesResources(c.Request.Context(), s, event, resources); err != nil {
+				log.Println("SSE の予約の備品取得エラー:", err)
+				return true
+			} else if !ok {
+				return true
+			}
+			// イベントには参加者が含まれないため、参加者にも伏せたまま配信する
+			if event.Reservation.Visibility == reservation.VisibilityPrivate && event.Reservation.UserID != userID {
+				event.Reservation = reservation.Masked(event.Reservation)
+			}
+			c.SSEvent(event.Type, event)
+			return true
+		}
+	})
+}
+
+// eventInRange はイベントの予約、または変更前の予約が [start, end) の期間と重なるかを返します。
+// 期間の開始時刻ちょうどに終わる予約は含めません。
+func eventInRange(event events.Event, start, end time.Time) bool {
+	overlaps := func(r db.Reservation) bool {
+		return r.StartTime.Before(end) && r.EndTime.After(start)
+	}
+	return overlaps(event.Reservation) || (event.Previous != nil && overlaps(*event.Previous))
+}
+
+// eventUsesResources はイベントの予約が resources のいずれかを使うか (備品は変更前に借りていた場合も含む) を返します。
+// 全ての予約が部屋を使うため、resources に部屋が含まれる場合は常に true です。
+func eventUsesResources(ctx context.Context, s store.EquipmentStore, event events.Event, resources []string) (bool, error) {
+	if slices.Contains(resources, reservation.RoomID) {
+		return true, nil
+	}
+	rows, err := s.ListReservationEquipment(ctx, []uint64{event.Reservation.ID})
+	if err != nil {
+		return false, err
+	}
+	used := slices.Clone(event.PreviousEquipment)
+	for _, row := range rows {
+		used = append(used, row.EquipmentID)
+	}
+	for _, resource := range resources {
+		if id, ok := equipmentID(resource); ok && slices.Contains(used, id) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"yoyaku/db"
+	"yoyaku/events"
+	"yoyaku/reservation"
+	"yoyaku/store"
+)
+
+func TestEventInRange(t *testing.T) {
+	day := time.Date(2030, 1, 7, 0, 0, 0, 0, jst)
+	rangeStart, rangeEnd := day, day.AddDate(0, 0, 1)
+	r := func(start, end time.Time) db.Reservation {
+		return db.Reservation{StartTime: start, EndTime: end}
+	}
+	hour := func(h int) time.Time { return day.Add(time.Duration(h) * time.Hour) }
+	previous := r(hour(10), hour(11))
+
+	tests := []struct {
+		name  string
+		event events.Event
+		want  bool
+	}{
+		{"期間内", events.Event{Reservation: r(hour(10), hour(11))}, true},
+		{"前日から続く", events.Event{Reservation: r(hour(-2), hour(1))}, true},
+		{"期間の開始時刻に終わる", events.Event{Reservation: r(hour(-2), hour(0))}, false},
+		{"期間の終了時刻に始まる", events.Event{Reservation: r(hour(24), hour(25))}, false},
+		{"期間外", events.Event{Reservation: r(hour(30), hour(31))}, false},
+		{"期間外から期間内へ移動", events.Event{Reservation: r(hour(10), hour(11)), Previous: &db.Reservation{StartTime: hour(30), EndTime: hour(31)}}, true},
+		{"期間内から期間外へ移動", events.Event{Reservation: r(hour(30), hour(31)), Previous: &previous}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := eventInRange(tt.event, rangeStart, rangeEnd); got != tt.want {
+				t.Errorf("eventInRange = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventUsesResources(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory()
+	var equipmentIDs []uint64
+	for _, name := range []string{"プロジェクター", "マイク"} {
+		result, err := s.CreateEquipment(ctx, db.CreateEquipmentParams{Name: name, Quantity: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, _ := result.LastInsertId()
+		equipmentIDs = append(equipmentIDs, uint64(id))
+	}
+	projector, mic := equipmentIDs[0], equipmentIDs[1]
+	result, err := s.CreateUser(ctx, db.CreateUserParams{Name: "alice", Email: "alice@example.com", GoogleID: "google-alice", Role: "user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	userID, _ := result.LastInsertId()
+	day := time.Date(2030, 1, 7, 10, 0, 0, 0, jst)
+	result, err = s.CreateReservation(ctx, db.CreateReservationParams{UserID: uint64(userID), Title: "輪講", StartTime: day, EndTime: day.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := result.LastInsertId()
+	withProjector, err := s.GetReservationByID(ctx, uint64(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddReservationEquipment(ctx, db.AddReservationEquipmentParams{ReservationID: withProjector.ID, EquipmentID: projector, Quantity: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	equipment := func(id uint64) string { return fmt.Sprintf("equipment-%d", id) }
+	tests := []struct {
+		name      string
+		event     events.Event
+		resources []string
+		want      bool
+	}{
+		{"部屋", events.Event{Reservation: withProjector}, []string{reservation.RoomID}, true},
+		{"借りている備品", events.Event{Reservation: withProjector}, []string{equipment(projector)}, true},
+		{"借りていない備品", events.Event{Reservation: withProjector}, []string{equipment(mic)}, false},
+		{"いずれかを借りている", events.Event{Reservation: withProjector}, []string{equipment(mic), equipment(projector)}, true},
+		{"変更前に借りていた備品", events.Event{Reservation: withProjector, PreviousEquipment: []uint64{mic}}, []string{equipment(mic)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := eventUsesResources(ctx, s, tt.event, tt.resources)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("eventUsesResources = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
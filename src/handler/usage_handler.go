@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"yoyaku/apierror"
+	"yoyaku/db"
+	"yoyaku/reservation"
+	"yoyaku/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 利用中の予約を今すぐ終了し、部屋を解放する
+// POST /api/reservations/end?id=...
+func HandleEndReservationNow(c *gin.Context, svc *reservation.Service) {
+	id, err := strconv.ParseUint(c.Query("id"), 10, 64)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest("IDの形式が正しくありません"))
+		return
+	}
+	userID, ok := utils.GetUserIDFromSession(c)
+	if !ok {
+		return
+	}
+
+	ended, err := svc.EndNow(c.Request.Context(), sessionActor(c, userID), id)
+	if err != nil {
+		// 予約が無い・利用中ではない場合は MapError が 404 / 409 に変換する
+		apierror.Abort(c, err)
+		return
+	}
+
+	respondUsageUpdated(c, ended)
+}
+
+// 利用中の予約を延長する
+// POST /api/reservations/extend?id=...&minutes=30
+func HandleExtendReservation(c *gin.Context, svc *reservation.Service) {
+	id, err := strconv.ParseUint(c.Query("id"), 10, 64)
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest("IDの形式が正しくありません"))
+		return
+	}
+	minutes, err := strconv.Atoi(c.Query("minutes"))
+	if err != nil {
+		apierror.Abort(c, apierror.BadRequest("minutesは1から240の間で指定してください"))
+		return
+	}
+	userID, ok := utils.GetUserIDFromSession(c)
+	if !ok {
+		return
+	}
+
+	extended, err := svc.Extend(c.Request.Context(), sessionActor(c, userID), id, minutes)
+	if err != nil {
+		// 予約が無い・利用中ではない・次の予約と重なる場合は MapError が 404 / 409 に変換する
+		apierror.Abort(c, err)
+		return
+	}
+
+	respondUsageUpdated(c, extended)
+}
+
+func respondUsageUpdated(c *gin.Context, updated db.Reservation) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":          "success",
+		"id":              updated.ID,
+		"start_time":      updated.StartTime,
+		"end_time":        updated.EndTime,
+		"booked_end_time": updated.BookedEndTime,
+		"checked_in_at":   updated.CheckedInAt,
+		"actual_end_time": updated.ActualEndTime,
+	})
+}
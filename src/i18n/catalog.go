@@ -0,0 +1,111 @@
+package i18n
+
+// catalog は日本語のメッセージと英語の訳です。
+// 利用者に返すメッセージを追加・変更したときは、ここにも追加してください (catalog_test.go で確認しています)。
+var catalog = map[string]string{
+	// 共通
+	"サーバー内部エラーが発生しました":          "An internal server error occurred",
+	"ページが見つかりません":               "Page not found",
+	"リクエストの形式が正しくありません":         "The request is malformed",
+	"値の型が正しくありません":              "The value has the wrong type",
+	"数値パラメータの形式が正しくありません":       "A numeric parameter is malformed",
+	"IDが指定されていません":              "No ID was given",
+	"IDの形式が正しくありません":            "The ID is malformed",
+	"IDまたはトークンが指定されていません":       "No ID or token was given",
+	"表示言語は ja または en で指定してください": "The language must be ja or en",
+	"表示言語の保存に失敗しました":            "Failed to save the language",
+
+	// ログイン・ユーザー
+	"ログイン情報が見つかりません":     "You are not logged in",
+	"ユーザーIDが不正です":        "The user ID is invalid",
+	"不正なユーザーIDです":        "The user ID is invalid",
+	"ユーザー情報の取得に失敗しました":   "Failed to load the user",
+	"ログアウトに失敗しました":       "Failed to log out",
+	"管理者のみ利用できます":        "Only administrators can use this",
+	"トークンの発行に失敗しました":     "Failed to issue a token",
+	"セッションストアの取得に失敗しました": "Failed to load the session store",
+
+	// 予約の入力
+	"入力が正しくありません":                                                "The input is invalid",
+	"タイトルを入力してください":                                              "Please enter a title",
+	"説明は2000文字以内で入力してください":                                       "The description must be at most 2000 characters",
+	"参加予定人数は0以上で入力してください":                                        "The headcount must be 0 or more",
+	"公開範囲は public、members、private のいずれかで指定してください":                "The visibility must be one of public, members or private",
+	"参加予定人数は部屋の定員以内で入力してください":                                    "The headcount must not exceed the room capacity",
+	"参加者が部屋の定員を超えています":                                           "There are more attendees than the room capacity",
+	"参加予定人数は予約者と参加者の合計以上にしてください":                                 "The headcount must be at least the organizer plus the attendees",
+	"参加者に存在しないユーザーが含まれています":                                      "The attendees include a user that does not exist",
+	"返答は accepted、declined、tentative のいずれかで指定してください":             "The response must be one of accepted, declined or tentative",
+	"終了またはキャンセルされた予約には返答できません":                                   "You cannot respond to a reservation that has ended or been canceled",
+	"開始時刻と終了時刻を指定してください":                                         "Please specify the start and end times",
+	"終了時刻は開始時刻より後にしてください":                                        "The end time must be after the start time",
+	"並び順は start_time または -start_time で指定してください":                  "Sort must be start_time or -start_time",
+	"件数は1から200の範囲で指定してください":                                      "Limit must be between 1 and 200",
+	"予約の状態は confirmed、canceled、no_show のいずれかで指定してください":           "Status must be one of confirmed, canceled or no_show",
+	"カーソルが正しくありません":                                              "The cursor is invalid",
+	"include_canceled と include_past は true または false で指定してください": "include_canceled and include_past must be true or false",
+	"期間の終了は開始より後にしてください":                                         "The end of the period must be after its start",
+	"期間は1日以上62日以内で指定してください":                                      "The period must be between 1 and 62 days",
+	"startとendクエリパラメータは必須です":                                     "The start and end query parameters are required",
+	"tzのタイムゾーンが正しくありません (例: Asia/Tokyo)":                         "The tz time zone is invalid (e.g. Asia/Tokyo)",
+	"日付の形式が正しくありません (YYYY-MM-DD)":                                "The date is malformed (YYYY-MM-DD)",
+	"monthの形式が正しくありません (YYYY-MM)":                                "The month is malformed (YYYY-MM)",
+	"afterの形式が正しくありません (RFC3339 または YYYY-MM-DD)":                 "The after parameter is malformed (RFC3339 or YYYY-MM-DD)",
+	"durationの形式が正しくありません (例: 90m)":                              "The duration is malformed (e.g. 90m)",
+	"durationが営業時間より長いです":                                        "The duration is longer than the opening hours",
+	"withinの形式が正しくありません (例: 336h)":                               "The within parameter is malformed (e.g. 336h)",
+	"resourceは room-401 または equipment-{備品ID} で指定してください":          "The resource must be room-401 or equipment-{equipment ID}",
+	"resourceは10個まで指定できます":                                       "At most 10 resources can be given",
+	"limitは1から20の間で指定してください":                                     "The limit must be between 1 and 20",
+	"minutesは1から240の間で指定してください":                                  "The minutes must be between 1 and 240",
+	"検索語を入力してください":                                               "Please enter a search term",
+	"検索語は100文字以内で入力してください":                                       "The search term must be at most 100 characters",
+	"offsetの値が正しくありません":                                          "The offset is invalid",
+
+	// 予約の操作
+	"予約が見つかりません":                                            "The reservation was not found",
+	"この操作は許可されていません":                                        "This operation is not allowed",
+	"この時間帯には既に予約があります":                                      "There is already a reservation at this time",
+	"確定していない予約はキャンセルできません":                                  "Only confirmed reservations can be canceled",
+	"確定していない予約は編集できません":                                     "Only confirmed reservations can be edited",
+	"no-show が続いたため、現在は新しい予約ができません":                         "You cannot make new reservations for now because of repeated no-shows",
+	"他のユーザーの予約は編集できません":                                     "You cannot edit another user's reservation",
+	"他のユーザーの予約はキャンセルできません":                                  "You cannot cancel another user's reservation",
+	"reservation-{番号}.ics はAPIから作成した予約の名前のため、別の名前で作成してください": "reservation-{number}.ics is reserved for reservations created through the API; use a different name",
+	"予約の取得に失敗しました":                                          "Failed to load the reservation",
+	"更新後の予約取得に失敗しました":                                       "Failed to load the updated reservation",
+	"変更履歴の取得に失敗しました":                                        "Failed to load the change history",
+	"no-show の取得に失敗しました":                                    "Failed to load the no-shows",
+
+	// 備品
+	"備品が見つかりません":                           "The equipment was not found",
+	"備品の取得に失敗しました":                         "Failed to load the equipment",
+	"備品の利用状況の取得に失敗しました":                    "Failed to load the equipment usage",
+	"この時間帯に借りられる備品の数が足りません":                "Not enough equipment is available at this time",
+	"備品の名前を入力してください":                       "Please enter a name for the equipment",
+	"備品の名前は100文字以内で入力してください":               "The equipment name must be at most 100 characters",
+	"備品の数は0から10000の範囲で入力してください":            "The equipment quantity must be between 0 and 10000",
+	"同じ名前の備品が既にあります":                       "Equipment with the same name already exists",
+	"同じ備品が複数含まれています":                       "The same equipment is listed more than once",
+	"存在しない備品が含まれています":                      "The equipment includes an item that does not exist",
+	"借りる備品の数は1から10000の範囲で入力してください":         "The equipment quantity must be between 1 and 10000",
+	"startとendは両方とも日時 (RFC3339) で指定してください": "Both start and end must be given as date-times (RFC3339)",
+
+	// 利用の終了・延長
+	"利用中の予約ではありません":                      "The reservation is not in use",
+	"次の予約と重なるため延長できません":                  "The reservation cannot be extended because it would overlap the next one",
+	"開始直後の予約はまだ終了できません。少し待ってからやり直してください": "The reservation has just started and cannot be ended yet. Please try again in a moment",
+
+	// チェックイン
+	"チェックインは開始時刻の少し前から受け付けます":        "Check-in opens shortly before the start time",
+	"チェックインの受付時間を過ぎています":             "The check-in window has passed",
+	"この予約はチェックインできません":               "This reservation cannot be checked in",
+	"他のユーザーの予約にはQRコードからチェックインしてください": "Please check in to another user's reservation with its QR code",
+	"チェックイントークンの発行に失敗しました":           "Failed to issue a check-in token",
+
+	// CalDAV
+	"401号室":              "Room 401",
+	"VEVENTが含まれていません":    "The data contains no VEVENT",
+	"DTSTARTとDTENDは必須です": "DTSTART and DTEND are required",
+	"終日の予定は予約できません":      "All-day events cannot be reserved",
+}
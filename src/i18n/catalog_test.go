@@ -0,0 +1,142 @@
+package i18n
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// messagePackages は利用者にメッセージを返すパッケージです。
+var messagePackages = []string{"apierror", "caldav", "checkin", "handler", "reservation", "utils"}
+
+// 利用者に返す日本語のメッセージに英語の訳が登録されていることを確認します。
+// 対象は apierror のコンストラクタ・newError・errors.New・writeMessage の引数と、Message フィールドの文字列リテラルです。
+func TestCatalogCoversMessages(t *testing.T) {
+	fset := token.NewFileSet()
+	for _, dir := range messagePackages {
+		files, err := filepath.Glob(filepath.Join("..", dir, "*.go"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, path := range files {
+			if strings.HasSuffix(path, "_test.go") {
+				continue
+			}
+			file, err := parser.ParseFile(fset, path, nil, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, lit := range messageLiterals(file) {
+				message, err := strconv.Unquote(lit.Value)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, ok := catalog[message]; !ok && !isASCII(message) {
+					t.Errorf("%s: 英語の訳がありません: %q", fset.Position(lit.Pos()), message)
+				}
+			}
+		}
+	}
+}
+
+// messageLiterals は利用者に返すメッセージとして使われている文字列リテラルを返します。
+func messageLiterals(file *ast.File) []*ast.BasicLit {
+	var lits []*ast.BasicLit
+	add := func(args []ast.Expr, i int) {
+		if i < len(args) {
+			if lit, ok := args[i].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+				lits = append(lits, lit)
+			}
+		}
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			switch callName(n.Fun) {
+			case "apierror.BadRequest", "apierror.Validation", "apierror.Unauthorized",
+				"apierror.Forbidden", "apierror.NotFound", "apierror.Conflict", "errors.New":
+				add(n.Args, 0)
+			case "apierror.Internal", "Internal":
+				// 2番目の引数はログにだけ残すエラーなので対象外
+				add(n.Args, 0)
+				return false
+			case "newError":
+				add(n.Args, 1)
+			case "apierror.New", "writeMessage":
+				add(n.Args, 2)
+			}
+		case *ast.KeyValueExpr:
+			if key, ok := n.Key.(*ast.Ident); ok && key.Name == "Message" {
+				add([]ast.Expr{n.Value}, 0)
+			}
+		}
+		return true
+	})
+	return lits
+}
+
+func callName(fun ast.Expr) string {
+	switch f := fun.(type) {
+	case *ast.Ident:
+		return f.Name
+	case *ast.SelectorExpr:
+		if pkg, ok := f.X.(*ast.Ident); ok {
+			return pkg.Name + "." + f.Sel.Name
+		}
+	}
+	return ""
+}
+
+func isASCII(s string) bool {
+	return utf8.RuneCountInString(s) == len(s)
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		header string
+		want   Language
+	}{
+		{"", Japanese},
+		{"en", English},
+		{"en-US,en;q=0.9", English},
+		{"fr-FR,fr;q=0.9,en;q=0.8,ja;q=0.7", English},
+		{"ja,en;q=0.9", Japanese},
+		{"en;q=0.5,ja;q=0.8", Japanese},
+		{"en;q=0,fr", Japanese},
+		{"de", Japanese},
+		{"*", Japanese},
+		{"en;q=abc,ja", Japanese},
+	}
+	for _, tt := range tests {
+		if got := Negotiate(tt.header); got != tt.want {
+			t.Errorf("Negotiate(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	if got := Resolve("en", "ja"); got != English {
+		t.Errorf("ユーザーが選んだ言語が優先されていません: %q", got)
+	}
+	if got := Resolve("", "en-GB"); got != English {
+		t.Errorf("未設定の場合は Accept-Language に従うはずです: %q", got)
+	}
+}
+
+func TestT(t *testing.T) {
+	if got := T(English, "予約が見つかりません"); got != "The reservation was not found" {
+		t.Errorf("T(English) = %q", got)
+	}
+	if got := T(Japanese, "予約が見つかりません"); got != "予約が見つかりません" {
+		t.Errorf("T(Japanese) = %q", got)
+	}
+	// 訳が無いメッセージはそのまま返す
+	if got := T(English, "未登録のメッセージ"); got != "未登録のメッセージ" {
+		t.Errorf("T(English) = %q", got)
+	}
+}
@@ -0,0 +1,111 @@
+// Package i18n は利用者に返すメッセージを日本語と英語に切り替えます。
+//
+// メッセージは日本語の文言をそのままキーにして、英語の訳を catalog に登録します。
+// コード中では今まで通り日本語で書き、返す直前に T で翻訳してください。訳が無いメッセージは日本語のまま返します。
+//
+// 言語はユーザーが選んだもの (users.language) を優先し、未設定の場合は Accept-Language から決めます。
+package i18n
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Language は対応している言語です。
+type Language string
+
+const (
+	Japanese Language = "ja"
+	English  Language = "en"
+)
+
+// Default は言語を決められない場合に使う言語です。
+const Default = Japanese
+
+// ContextKey はリクエストの言語を gin.Context に保存するキーです。
+const ContextKey = "language"
+
+// Parse は "ja" や "en-US" のような言語タグを対応する言語に変換します。
+func Parse(tag string) (Language, bool) {
+	primary, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+	switch Language(primary) {
+	case Japanese:
+		return Japanese, true
+	case English:
+		return English, true
+	}
+	return "", false
+}
+
+// Negotiate は Accept-Language ヘッダーから、対応している言語のうち最も優先度 (q) の高いものを返します。
+// 対応している言語が含まれない場合は Default を返します。
+func Negotiate(acceptLanguage string) Language {
+	type candidate struct {
+		lang Language
+		q    float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		if name, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(name) == "q" {
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q <= 0 {
+			continue
+		}
+		if strings.TrimSpace(tag) == "*" {
+			candidates = append(candidates, candidate{Default, q})
+			continue
+		}
+		if lang, ok := Parse(tag); ok {
+			candidates = append(candidates, candidate{lang, q})
+		}
+	}
+	if len(candidates) == 0 {
+		return Default
+	}
+	// q が同じ場合はヘッダーに書かれた順を優先する
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	return candidates[0].lang
+}
+
+// Resolve はユーザーが選んだ言語 (未設定の場合は空文字列) を優先し、無ければ Accept-Language から言語を決めます。
+func Resolve(preferred, acceptLanguage string) Language {
+	if lang, ok := Parse(preferred); ok {
+		return lang
+	}
+	return Negotiate(acceptLanguage)
+}
+
+// Set はリクエストの言語を設定します。ログイン中のユーザーが言語を選んでいる場合に使います。
+func Set(c *gin.Context, lang Language) {
+	c.Set(ContextKey, lang)
+}
+
+// FromContext はリクエストの言語を返します。Set されていなければ Accept-Language から決めます。
+func FromContext(c *gin.Context) Language {
+	if v, ok := c.Get(ContextKey); ok {
+		if lang, ok := v.(Language); ok {
+			return lang
+		}
+	}
+	return Negotiate(c.GetHeader("Accept-Language"))
+}
+
+// T は日本語の message を lang に翻訳します。
+func T(lang Language, message string) string {
+	if lang == English {
+		if translated, ok := catalog[message]; ok {
+			return translated
+		}
+	}
+	return message
+}
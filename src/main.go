@@ -1,13 +1,29 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"yoyaku/apierror"
 	"yoyaku/auth"
-	"yoyaku/db"
+	"yoyaku/caldav"
+	"yoyaku/checkin"
+	"yoyaku/config"
+	"yoyaku/events"
+	"yoyaku/gcal"
 	"yoyaku/handler"
+	"yoyaku/metrics"
+	"yoyaku/openapi"
+	"yoyaku/reservation"
+	"yoyaku/server"
+	"yoyaku/store"
 	"yoyaku/utils"
 
 	"github.com/gin-contrib/cors"
@@ -16,38 +32,128 @@ import (
 )
 
 func main() {
+	// app migrate <command> でマイグレーションだけを実行する
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+	// app config check で設定を確認する
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		runConfig(os.Args[2:])
+		return
+	}
+	// app openapi で OpenAPI 仕様を出力する
+	if len(os.Args) > 1 && os.Args[1] == "openapi" {
+		runOpenAPI()
+		return
+	}
+
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "設定ファイル (YAML) のパス")
+	autoMigrate := flag.Bool("auto-migrate", false, "起動時に未適用のマイグレーションを適用する (AUTO_MIGRATE=true と同じ)")
+	flag.Parse()
 
-	//データベースとの接続
-	sqlDB, err := utils.NewDBConnection()
+	// 設定はデフォルト値、設定ファイル、環境変数の順に上書きし、起動する前に全て確認する
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		log.Fatalf("設定を読み込めませんでした: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("設定が正しくありません (app config check で確認できます):\n%v", err)
+	}
+
+	//データベースとの接続 (DATABASE_URL のスキームで MySQL / SQLite / PostgreSQL を選ぶ)
+	backend, err := store.Open(cfg.DatabaseURL)
 	if err != nil {
 		log.Fatalf("データベースに接続できませんでした: %v", err)
 	}
-	defer sqlDB.Close()
-	// sql.DBからsqlcのクエリオブジェクトを生成
-	queries := db.New(sqlDB)
 
-	// 1. OAuth設定の初期化
-	if err := auth.Setup(); err != nil {
-		log.Fatalf("OAuth設定の初期化に失敗しました: %v", err)
+	// HTTP サーバーとバックグラウンドの処理 (終了のシグナルを受け取ったら、リクエスト・バックグラウンドの処理・データベースの順に停止する)
+	srv := server.New(fmt.Sprintf(":%d", cfg.Port), nil)
+	srv.ShutdownTimeout = time.Duration(cfg.ShutdownTimeout)
+	srv.OnClose("データベースの接続", backend.Close)
+
+	// マイグレーション (GET /readyz では未適用のマイグレーションが無いことも確認する)
+	migrator, err := backend.Migrator()
+	if err != nil {
+		log.Fatalf("マイグレーションを読み込めませんでした: %v", err)
+	}
+	if *autoMigrate || cfg.AutoMigrate {
+		if err := migrator.Up(context.Background()); err != nil {
+			log.Fatalf("マイグレーションに失敗しました: %v", err)
+		}
 	}
+	// ハンドラーなどはインターフェース越しに読み書きする (テストではメモリ上の実装に差し替える)
+	dataStore := backend.Store
 
-	secretKey := os.Getenv("SECRET_KEY")
-	if secretKey == "" {
-		log.Fatalf("環境変数 SECRET_KEY が設定されていません")
+	// 予約の変更を配信するイベントバス (現在はプロセス内のみ)
+	// 配信した予約の作成・キャンセルは GET /metrics で数える
+	bus := metrics.InstrumentBus(events.NewMemoryBus(64))
+	// データベースの接続プールと部屋の利用状況を GET /metrics で返す
+	metrics.RegisterDB(backend.DB)
+	metrics.RegisterRoom(dataStore)
+
+	// Prometheus 形式のメトリクス (ログインが不要なため、公開する PORT とは別の METRICS_ADDR で待ち受ける)
+	// 停止中の様子も取得できるよう、他のバックグラウンドの処理より先に登録して最後に停止する
+	if cfg.MetricsAddr != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("GET /metrics", metrics.Handler())
+		metricsServer := server.New(cfg.MetricsAddr, metricsMux)
+		srv.Go("メトリクスの配信", func(ctx context.Context) {
+			if err := metricsServer.Run(ctx); err != nil {
+				log.Println("メトリクスの配信を停止しました:", err)
+			}
+		})
+		log.Println("メトリクスを配信します:", "http://"+cfg.MetricsAddr+"/metrics")
+	}
+
+	// 1. OAuth設定の初期化
+	auth.Setup(cfg.Google)
+
+	// 日付 (YYYY-MM-DD) や月の区切り、営業時間に使うタイムゾーン (APP_TIMEZONE、省略時は Asia/Tokyo)
+	loc := cfg.Location()
+	// 空き状況の計算に使う営業時間などのルール
+	rules := cfg.Rules()
+
+	// チェックインと no-show のルール
+	policy := cfg.CheckinPolicy()
+	// 参加予定人数と参加者の上限に使う部屋の定員
+	room := reservation.Room{Capacity: cfg.Room.Capacity}
+	// 予約の作成・編集・キャンセル・一覧のルール (HTTP 以外の入口からも共有する)
+	reservationService := reservation.NewService(dataStore, bus, policy, room)
+
+	// Googleカレンダーとの同期 (GOOGLE_CALENDAR_ID が設定されている場合のみ)
+	// カレンダーから取り込む予定にも予約と同じルールを適用するため、reservationService を通す
+	if calendarID := cfg.GoogleCalendar.ID; calendarID != "" {
+		client, err := gcal.NewServiceAccountClient(context.Background(), cfg.GoogleCalendar.CredentialsFile)
+		if err != nil {
+			log.Fatalf("Googleカレンダーの認証情報を読み込めませんでした: %v", err)
+		}
+		syncer := gcal.NewSyncer(client, dataStore, reservationService, bus, calendarID, time.Duration(cfg.GoogleCalendar.SyncInterval))
+		srv.Go("Googleカレンダーとの同期", syncer.Run)
+		log.Println("Googleカレンダーとの同期を有効にしました:", calendarID)
 	}
 
+	// チェックインされなかった予約を定期的に解放する
+	// (解放した予約のイベントをGoogleカレンダーに送れるよう、同期より後に登録して先に停止する)
+	srv.Go("no-show の解放", checkin.NewReleaser(dataStore, bus, policy, time.Minute).Run)
+
 	// セッション情報を保存するためのストア (キーは秘密の値にしてください)
-	var store = sessions.NewCookieStore([]byte(secretKey))
+	var store = sessions.NewCookieStore([]byte(cfg.SecretKey))
 
 	// Ginのルーティング
-	r := gin.Default()
+	// エラーはハンドラーが apierror.Abort で登録し、apierror.Middleware が共通の形式で返す
+	// (ログとメトリクスにステータスが正しく残るよう、Logger と metrics.Middleware より内側に置く)
+	r := gin.New()
+	r.Use(gin.Logger(), metrics.Middleware, gin.CustomRecovery(apierror.Recovery), apierror.Middleware(handler.MapError))
+	r.NoRoute(handler.HandleNoRoute)
 
 	// CORS設定
-	// フロントエンドのURL(http://localhost:3000)からのリクエストを許可
-	config := cors.DefaultConfig()
-	config.AllowOrigins = []string{"http://localhost:3000"}
-	config.AllowCredentials = true // Cookieの送受信を許可
-	r.Use(cors.New(config))
+	// フロントエンドのURL (allow_origins、省略時は FRONTEND_URL) からのリクエストを許可
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowOrigins = cfg.AllowOrigins
+	corsConfig.AllowCredentials = true // Cookieの送受信を許可
+	corsConfig.ExposeHeaders = []string{apierror.RequestIDHeader}
+	r.Use(cors.New(corsConfig))
 
 	// セッションミドルウェア
 	// 全てのリクエストでセッションストアを利用可能にする
@@ -55,11 +161,29 @@ func main() {
 		c.Set("session_store", store)
 		c.Next()
 	})
+	// ログイン中のユーザーが選んだ表示言語をメッセージに使う (未設定なら Accept-Language)
+	r.Use(utils.SetLanguageFromSession)
+	// クエリパラメータの日付を解釈するタイムゾーン (リクエストごとに tz で変更できる)
+	r.Use(utils.SetLocation(loc))
+
+	// ヘルスチェック (コンテナのオーケストレーション向け。ログインは不要)
+	r.GET("/healthz", handler.HandleHealthz)
+	readinessChecks := []handler.ReadinessCheck{
+		{Name: "database", Check: backend.DB.PingContext},
+		{Name: "migrations", Check: migrator.CheckUpToDate},
+		// 停止中は新しいリクエストを振り分けないよう、準備ができていないことにする
+		{Name: "server", Check: func(context.Context) error { return srv.Serving() }},
+		{Name: "workers", Check: func(context.Context) error { return srv.CheckWorkers() }},
+	}
+	r.GET("/readyz", func(c *gin.Context) {
+		handler.HandleReadyz(c, readinessChecks)
+	})
+	r.GET("/version", handler.HandleVersion)
 
 	// 3. ルーティングの設定
 	r.GET("/login", handler.HandleGoogleLogin)
 	r.GET("/callback", func(c *gin.Context) {
-		handler.HandleGoogleCallback(c, queries)
+		handler.HandleGoogleCallback(c, dataStore, cfg.FrontendURL)
 	})
 
 	// フロントエンドがユーザー情報を確認するためのAPIエンドポイント
@@ -73,9 +197,42 @@ func main() {
 	// }
 	api := r.Group("/api")
 	{
+		// APIの仕様 (OpenAPI 3) と Swagger UI
+		api.GET("/openapi.json", openapi.HandleSpec)
+		api.GET("/docs", openapi.HandleSwaggerUI)
+
 		// ユーザー認証関連
 		api.GET("/me", handler.HandleGetMe)
 		api.POST("/logout", handler.HandleLogout)
+		api.PUT("/me/language", func(c *gin.Context) {
+			handler.HandleSetLanguage(c, dataStore)
+		})
+		api.POST("/me/caldav-token", func(c *gin.Context) {
+			handler.HandleIssueCalDAVToken(c, dataStore)
+		})
+		api.GET("/me/no-shows", func(c *gin.Context) {
+			handler.HandleMyNoShows(c, reservationService)
+		})
+		// 参加者として招待された予約と、その返答 (accepted / declined / tentative)
+		api.GET("/me/invitations", func(c *gin.Context) {
+			handler.HandleListInvitations(c, reservationService)
+		})
+		api.PUT("/me/invitations/:id", func(c *gin.Context) {
+			handler.HandleRespondInvitation(c, reservationService)
+		})
+
+		// 空き状況の検索
+		api.GET("/availability", func(c *gin.Context) {
+			handler.HandleAvailability(c, dataStore, rules)
+		})
+		api.GET("/availability/next", func(c *gin.Context) {
+			handler.HandleNextAvailability(c, dataStore, rules)
+		})
+
+		// 部屋と一緒に借りられる備品 (start と end を指定すると、その時間帯に借りられる数も返す)
+		api.GET("/equipment", func(c *gin.Context) {
+			handler.HandleListEquipment(c, reservationService)
+		})
 
 		// 予約関連のAPIをグループ化
 		reservations := api.Group("/reservations")
@@ -83,45 +240,111 @@ func main() {
 			// POST /api/reservations
 			// 新しい予約を作成
 			reservations.POST("", func(c *gin.Context) {
-				handler.Handlereservations(c, queries)
+				handler.Handlereservations(c, reservationService)
 			})
 
 			reservations.PUT("", func(c *gin.Context) {
-				handler.HandlereservationsEdit(c, queries)
+				handler.HandlereservationsEdit(c, reservationService)
 			})
 
 			// GET /api/reservations/me
-			// ログインユーザー自身の予約一覧を取得
+			// ログインユーザー自身の予約一覧を取得 (条件とページは GET /api/reservations と同じ)
 			reservations.GET("/me", func(c *gin.Context) {
-				handler.HandlereservationsMe(c, queries)
+				handler.HandlereservationsMe(c, reservationService)
 			})
 
 			// PUT /api/reservations/cancel
 			// 予約をキャンセル
 			reservations.PUT("/cancel", func(c *gin.Context) {
-				handler.HandlereservationsCancele(c, queries)
+				handler.HandlereservationsCancele(c, reservationService)
+			})
+
+			// POST /api/reservations/checkin?id=... または ?token=...
+			// 予約にチェックイン (開始から一定時間チェックインが無い予約は自動で解放される)
+			reservations.POST("/checkin", func(c *gin.Context) {
+				handler.HandleCheckIn(c, reservationService)
+			})
+
+			// GET /api/reservations/checkin-token?id=...
+			// ドアに掲示するQRコード用のチェックインURLを取得
+			reservations.GET("/checkin-token", func(c *gin.Context) {
+				handler.HandleCheckinToken(c, reservationService, cfg.FrontendURL)
+			})
+
+			// POST /api/reservations/end?id=...
+			// 利用中の予約を今すぐ終了して部屋を解放
+			reservations.POST("/end", func(c *gin.Context) {
+				handler.HandleEndReservationNow(c, reservationService)
+			})
+
+			// POST /api/reservations/extend?id=...&minutes=...
+			// 利用中の予約を延長 (次の予約と重なる場合は延長できない)
+			reservations.POST("/extend", func(c *gin.Context) {
+				handler.HandleExtendReservation(c, reservationService)
+			})
+
+			// GET /api/reservations/:id/history
+			// 予約の変更履歴を取得 (予約者本人と管理者のみ)
+			reservations.GET("/:id/history", func(c *gin.Context) {
+				handler.HandleReservationHistory(c, dataStore)
+			})
+
+			// GET /api/reservations/search?q=...&limit=...&offset=...
+			// 予約のタイトルと説明を全文検索し、関連度の高い順に取得
+			reservations.GET("/search", func(c *gin.Context) {
+				handler.HandleSearchReservations(c, reservationService)
 			})
 
-			// GET /api/reservations?month=... や ?date=...
-			// クエリパラメータに応じて全ユーザーの予約を期間で絞り込んで取得
+			// GET /api/reservations/stream?date=... や ?start=...&end=...&resource=...
+			// 予約の作成・編集・キャンセルをServer-Sent Eventsで配信
+			reservations.GET("/stream", func(c *gin.Context) {
+				handler.HandleReservationStream(c, dataStore, bus, srv.Draining())
+			})
+
+			// GET /api/reservations?from=...&to=...&status=...&title=...&sort=...&limit=...&cursor=...
+			// 全ユーザーの予約を条件で絞り込み、カーソルでページを分けて取得
 			reservations.GET("", func(c *gin.Context) {
-				if c.Query("month") != "" {
-					handler.HandlerListByMonth(c, queries)
-				} else if c.Query("start") != "" && c.Query("end") != "" {
-					handler.HandlerListByWeek(c, queries)
-				} else if c.Query("date") != "" {
-					handler.HandlerListByDate(c, queries)
-				} else {
-					// ここに全件取得や、パラメータがない場合のエラー処理などを記述
-					c.JSON(http.StatusBadRequest, gin.H{"error": "有効なクエリパラメータがありません"})
-				}
+				handler.HandlerListReservations(c, reservationService)
 			})
 		}
 	}
 
-	// 4. サーバーの起動
-	log.Println("Started server on http://localhost:8080")
-	if err := r.Run(":8080"); err != nil {
-		log.Fatalf("サーバーの起動に失敗しました: %v", err)
+	// 管理者向けのAPI
+	admin := r.Group("/api/admin")
+	{
+		// GET /api/admin/audit
+		// 全ての予約の変更履歴を検索
+		admin.GET("/audit", func(c *gin.Context) {
+			handler.HandleAuditSearch(c, dataStore)
+		})
+
+		// POST /api/admin/equipment, PUT /api/admin/equipment/:id
+		// 備品の登録と、名前・数の変更
+		admin.POST("/equipment", func(c *gin.Context) {
+			handler.HandleCreateEquipment(c, dataStore, reservationService)
+		})
+		admin.PUT("/equipment/:id", func(c *gin.Context) {
+			handler.HandleUpdateEquipment(c, dataStore, reservationService)
+		})
+	}
+
+	// CalDAV (Apple カレンダー、DAVx5 など) 向けのエンドポイント
+	r.GET("/.well-known/caldav", caldav.HandleWellKnown)
+	r.Handle("PROPFIND", "/.well-known/caldav", caldav.HandleWellKnown)
+	for _, method := range caldav.Methods {
+		r.Handle(method, "/caldav/*path", func(c *gin.Context) {
+			caldav.Handle(c, dataStore, reservationService)
+		})
+	}
+
+	// 4. サーバーの起動 (SIGINT / SIGTERM で処理中のリクエストを終えてから停止する)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv.HTTP.Handler = r
+	log.Printf("Started server on http://localhost:%d", cfg.Port)
+	if err := srv.Run(ctx); err != nil {
+		log.Fatalf("サーバーを正常に停止できませんでした: %v", err)
 	}
+	log.Println("サーバーを停止しました")
 }
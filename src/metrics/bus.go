@@ -0,0 +1,33 @@
+package metrics
+
+import "yoyaku/events"
+
+// sourceAPI は events.Event.Source が空の場合 (このAPIや CalDAV での操作) の source です。
+const sourceAPI = "api"
+
+// instrumentedBus は配信したイベントから予約の作成・キャンセルを数え、部屋の利用状況のキャッシュを無効にする events.Bus です。
+type instrumentedBus struct {
+	events.Bus
+}
+
+// InstrumentBus は bus に配信した予約の作成・キャンセルを記録する events.Bus を返します。
+// 予約を作成・キャンセルする全ての処理 (API、CalDAV、Googleカレンダーとの同期) はイベントを配信するため、ここでまとめて数えます。
+func InstrumentBus(bus events.Bus) events.Bus {
+	return instrumentedBus{Bus: bus}
+}
+
+func (b instrumentedBus) Publish(event events.Event) {
+	source := event.Source
+	if source == "" {
+		source = sourceAPI
+	}
+	switch event.Type {
+	case events.TypeReservationCreated:
+		reservationsCreated.WithLabelValues(source).Inc()
+	case events.TypeReservationCanceled:
+		reservationsCanceled.WithLabelValues(source).Inc()
+	}
+	// 部屋の利用状況を次の取得で読み込み直す
+	roomGeneration.Add(1)
+	b.Bus.Publish(event)
+}
@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"yoyaku/db"
+	"yoyaku/store"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const (
+	// roomTimeout は部屋の利用状況を集計するときにデータベースを待つ時間の上限です。
+	roomTimeout = 2 * time.Second
+	// roomCacheTTL は部屋の利用状況の計算に使う予約を読み込み直す間隔です。
+	// 読み込んだ時刻から roomCacheTTL の間に重なる予約を保持し、その間の取得ではデータベースに問い合わせません。
+	roomCacheTTL = time.Minute
+)
+
+// roomGeneration は予約の変更を配信するたびに増やします (InstrumentBus)。
+// 部屋の利用状況は、読み込んだ後に予約が変更されていればすぐに読み込み直します。
+var roomGeneration atomic.Uint64
+
+// RegisterDB はデータベースの接続プールの状態 (sql.DB.Stats) を Registry に登録します。
+func RegisterDB(database *sql.DB) {
+	Registry.MustRegister(collectors.NewDBStatsCollector(database, namespace))
+}
+
+// RegisterRoom は部屋の現在の利用状況を Registry に登録します。
+// 予約は roomCacheTTL ごと、または予約の変更を配信したときに s から読み込み直します。
+func RegisterRoom(s store.ReservationStore) {
+	Registry.MustRegister(newRoomCollector(s, time.Now))
+}
+
+var (
+	roomOccupiedDesc = prometheus.NewDesc(namespace+"_room_occupied",
+		"現在の時刻に確定済みの予約があれば 1、無ければ 0", nil, nil)
+	roomCheckedInDesc = prometheus.NewDesc(namespace+"_room_checked_in",
+		"現在の予約がチェックイン済みなら 1、そうでなければ 0", nil, nil)
+	roomHeadcountDesc = prometheus.NewDesc(namespace+"_room_headcount",
+		"現在の予約の参加予定人数 (予約が無ければ 0)", nil, nil)
+)
+
+// roomCollector は部屋の現在の利用状況を集計します。
+type roomCollector struct {
+	store store.ReservationStore
+	now   func() time.Time
+
+	mu sync.Mutex
+	// rows は loadedAt から roomCacheTTL の間に重なる確定済みの予約です。
+	rows       []db.ListReservationsByDateRow
+	loadedAt   time.Time
+	generation uint64
+	loaded     bool
+}
+
+func newRoomCollector(s store.ReservationStore, now func() time.Time) *roomCollector {
+	return &roomCollector{store: s, now: now}
+}
+
+func (c *roomCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- roomOccupiedDesc
+	ch <- roomCheckedInDesc
+	ch <- roomHeadcountDesc
+}
+
+func (c *roomCollector) Collect(ch chan<- prometheus.Metric) {
+	now := c.now()
+	rows, err := c.reservations(now)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(roomOccupiedDesc, err)
+		return
+	}
+
+	var occupied, checkedIn, headcount float64
+	for _, r := range rows {
+		if now.Before(r.StartTime) || !now.Before(r.EndTime) {
+			continue
+		}
+		occupied = 1
+		if r.CheckedInAt.Valid {
+			checkedIn = 1
+		}
+		headcount = float64(r.Headcount)
+		break
+	}
+	ch <- prometheus.MustNewConstMetric(roomOccupiedDesc, prometheus.GaugeValue, occupied)
+	ch <- prometheus.MustNewConstMetric(roomCheckedInDesc, prometheus.GaugeValue, checkedIn)
+	ch <- prometheus.MustNewConstMetric(roomHeadcountDesc, prometheus.GaugeValue, headcount)
+}
+
+// reservations は now を含む確定済みの予約の候補を返します。保持している予約が古い場合だけ読み込み直します。
+func (c *roomCollector) reservations(now time.Time) ([]db.ListReservationsByDateRow, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	generation := roomGeneration.Load()
+	if c.loaded && c.generation == generation && !now.Before(c.loadedAt) && now.Before(c.loadedAt.Add(roomCacheTTL)) {
+		return c.rows, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), roomTimeout)
+	defer cancel()
+	// r.start_time < StartTime AND r.end_time >= EndTime (now から roomCacheTTL の間に重なる予約)
+	rows, err := c.store.ListReservationsByDate(ctx, db.ListReservationsByDateParams{StartTime: now.Add(roomCacheTTL), EndTime: now})
+	if err != nil {
+		return nil, err
+	}
+	c.rows, c.loadedAt, c.generation, c.loaded = rows, now, generation, true
+	return rows, nil
+}
@@ -0,0 +1,112 @@
+// Package metrics は Prometheus 形式のメトリクス (GET /metrics) を集計します。
+//
+// HTTP リクエストは Middleware、予約の作成・キャンセルは InstrumentBus で包んだイベントバス、
+// データベースの接続プールと部屋の利用状況は RegisterDB / RegisterRoom で登録した Collector が集計します。
+// 予約の重複とログインは、それぞれの処理から ConflictRejected / LoginSucceeded / LoginFailed を呼んで記録します。
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace は全てのメトリクス名の接頭辞です。
+const namespace = "yoyaku"
+
+// ConflictRejected に渡す操作
+const (
+	OperationCreate = "create"
+	OperationUpdate = "update"
+	OperationExtend = "extend"
+)
+
+// LoginFailed に渡す失敗の理由
+const (
+	// ReasonExchange は Google からトークンやユーザー情報を取得できなかったことを表します (state の不一致を含む)。
+	ReasonExchange = "exchange"
+	// ReasonDomain は許可していないドメインのアカウントだったことを表します。
+	ReasonDomain = "domain"
+	// ReasonCreate はユーザーを登録できなかったことを表します。
+	ReasonCreate = "create"
+	// ReasonLookup はユーザーを検索できなかったことを表します。
+	ReasonLookup = "lookup"
+	// ReasonSession はセッションを保存できなかったことを表します。
+	ReasonSession = "session"
+)
+
+// Registry はこのアプリのメトリクスを登録するレジストリです (Go のランタイムとプロセスのメトリクスを含む)。
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Name: "http_requests_total",
+		Help: "HTTP リクエストの数 (route は登録したルートのパターン)",
+	}, []string{"method", "route", "status"})
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace, Name: "http_request_duration_seconds",
+		Help:    "HTTP リクエストの処理時間 (Server-Sent Events は接続していた時間)",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	reservationsCreated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Name: "reservations_created_total",
+		Help: "作成された予約の数 (source は api または google_calendar)",
+	}, []string{"source"})
+	reservationsCanceled = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Name: "reservations_canceled_total",
+		Help: "キャンセルされた予約の数 (source は api または google_calendar)",
+	}, []string{"source"})
+	reservationConflicts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Name: "reservation_conflicts_total",
+		Help: "他の予約と時間帯が重なるため断った作成・変更・延長の数",
+	}, []string{"operation"})
+
+	loginSuccesses = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace, Name: "oauth_login_success_total",
+		Help: "Google ログインに成功した数",
+	})
+	loginFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Name: "oauth_login_failures_total",
+		Help: "Google ログインに失敗した数 (reason は exchange, domain, create, lookup, session)",
+	}, []string{"reason"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration,
+		reservationsCreated, reservationsCanceled, reservationConflicts,
+		loginSuccesses, loginFailures,
+	)
+	// 一度も起きていない値も 0 として出力する (rate() などが値の無い期間を扱えるように)
+	for _, operation := range []string{OperationCreate, OperationUpdate, OperationExtend} {
+		reservationConflicts.WithLabelValues(operation)
+	}
+	for _, reason := range []string{ReasonExchange, ReasonDomain, ReasonCreate, ReasonLookup, ReasonSession} {
+		loginFailures.WithLabelValues(reason)
+	}
+}
+
+// Handler は Registry のメトリクスを Prometheus のテキスト形式で返す http.Handler です。
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// ConflictRejected は他の予約と時間帯が重なるため operation (OperationCreate など) を断ったことを記録します。
+func ConflictRejected(operation string) {
+	reservationConflicts.WithLabelValues(operation).Inc()
+}
+
+// LoginSucceeded は Google ログインに成功したことを記録します。
+func LoginSucceeded() {
+	loginSuccesses.Inc()
+}
+
+// LoginFailed は Google ログインに reason (ReasonExchange など) で失敗したことを記録します。
+func LoginFailed(reason string) {
+	loginFailures.WithLabelValues(reason).Inc()
+}
@@ -0,0 +1,185 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"yoyaku/db"
+	"yoyaku/events"
+	"yoyaku/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// リクエストはパスではなく登録したルートのパターンで数え、登録していないパスはまとめます。
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware)
+	r.GET("/api/reservations/:id/history", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	before := testutil.ToFloat64(httpRequests.WithLabelValues("GET", "/api/reservations/:id/history", "204"))
+	beforeUnmatched := testutil.ToFloat64(httpRequests.WithLabelValues("GET", unmatchedRoute, "404"))
+	for _, path := range []string{"/api/reservations/1/history", "/api/reservations/2/history", "/wp-login.php"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := testutil.ToFloat64(httpRequests.WithLabelValues("GET", "/api/reservations/:id/history", "204")) - before; got != 2 {
+		t.Errorf("history のリクエスト = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(httpRequests.WithLabelValues("GET", unmatchedRoute, "404")) - beforeUnmatched; got != 1 {
+		t.Errorf("登録していないパスのリクエスト = %v, want 1", got)
+	}
+}
+
+func TestInstrumentBus(t *testing.T) {
+	inner := events.NewMemoryBus(4)
+	sub := inner.Subscribe()
+	defer sub.Close()
+	bus := InstrumentBus(inner)
+
+	createdAPI := testutil.ToFloat64(reservationsCreated.WithLabelValues(sourceAPI))
+	canceledCalendar := testutil.ToFloat64(reservationsCanceled.WithLabelValues(events.SourceGoogleCalendar))
+	bus.Publish(events.Event{Type: events.TypeReservationCreated})
+	bus.Publish(events.Event{Type: events.TypeReservationUpdated})
+	bus.Publish(events.Event{Type: events.TypeReservationCanceled, Source: events.SourceGoogleCalendar})
+
+	if got := testutil.ToFloat64(reservationsCreated.WithLabelValues(sourceAPI)) - createdAPI; got != 1 {
+		t.Errorf("作成 (api) = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(reservationsCanceled.WithLabelValues(events.SourceGoogleCalendar)) - canceledCalendar; got != 1 {
+		t.Errorf("キャンセル (google_calendar) = %v, want 1", got)
+	}
+	// 元のバスにもそのまま配信する
+	for _, want := range []string{events.TypeReservationCreated, events.TypeReservationUpdated, events.TypeReservationCanceled} {
+		if got := (<-sub.C).Type; got != want {
+			t.Errorf("配信したイベント = %s, want %s", got, want)
+		}
+	}
+}
+
+func TestRoomCollector(t *testing.T) {
+	ctx := context.Background()
+	mem := store.NewMemory()
+	result, err := mem.CreateUser(ctx, db.CreateUserParams{Name: "alice", Email: "alice@pluslab.org", GoogleID: "alice", Role: "user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	userID, _ := result.LastInsertId()
+	start := time.Date(2030, 7, 1, 10, 0, 0, 0, time.UTC)
+	if _, err := mem.CreateReservation(ctx, db.CreateReservationParams{
+		UserID: uint64(userID), Title: "輪講", Headcount: 5, Visibility: "public", StartTime: start, EndTime: start.Add(2 * time.Hour),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		now       time.Time
+		checkedIn bool
+		want      string
+	}{
+		{"before", start.Add(-time.Minute), false, "0 0 0"},
+		{"at start", start, false, "1 0 5"},
+		{"checked in", start.Add(time.Hour), true, "1 1 5"},
+		{"at end", start.Add(2 * time.Hour), true, "0 0 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.checkedIn {
+				if err := mem.CheckInReservation(ctx, 1); err != nil {
+					t.Fatal(err)
+				}
+			}
+			values := strings.Fields(tt.want)
+			expected := `
+# HELP yoyaku_room_checked_in 現在の予約がチェックイン済みなら 1、そうでなければ 0
+# TYPE yoyaku_room_checked_in gauge
+yoyaku_room_checked_in ` + values[1] + `
+# HELP yoyaku_room_headcount 現在の予約の参加予定人数 (予約が無ければ 0)
+# TYPE yoyaku_room_headcount gauge
+yoyaku_room_headcount ` + values[2] + `
+# HELP yoyaku_room_occupied 現在の時刻に確定済みの予約があれば 1、無ければ 0
+# TYPE yoyaku_room_occupied gauge
+yoyaku_room_occupied ` + values[0] + `
+`
+			collector := newRoomCollector(mem, func() time.Time { return tt.now })
+			if err := testutil.CollectAndCompare(collector, strings.NewReader(expected)); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// countingStore は ListReservationsByDate を呼んだ回数を数えます。
+type countingStore struct {
+	*store.Memory
+	calls int
+}
+
+func (s *countingStore) ListReservationsByDate(ctx context.Context, arg db.ListReservationsByDateParams) ([]db.ListReservationsByDateRow, error) {
+	s.calls++
+	return s.Memory.ListReservationsByDate(ctx, arg)
+}
+
+// 部屋の利用状況は取得のたびではなく、roomCacheTTL ごとか予約の変更を配信したときに読み込み直します。
+func TestRoomCollectorCache(t *testing.T) {
+	ctx := context.Background()
+	counting := &countingStore{Memory: store.NewMemory()}
+	result, err := counting.CreateUser(ctx, db.CreateUserParams{Name: "alice", Email: "alice@pluslab.org", GoogleID: "alice", Role: "user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	userID, _ := result.LastInsertId()
+	start := time.Date(2030, 7, 1, 10, 0, 0, 0, time.UTC)
+	if _, err := counting.CreateReservation(ctx, db.CreateReservationParams{
+		UserID: uint64(userID), Title: "輪講", Headcount: 5, Visibility: "public", StartTime: start, EndTime: start.Add(30 * time.Second),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	now := start.Add(-10 * time.Second)
+	collector := newRoomCollector(counting, func() time.Time { return now })
+	// occupied は yoyaku_room_occupied が want であることを確認し、それまでに読み込んだ回数を返します。
+	occupied := func(want string) int {
+		t.Helper()
+		expected := `
+# HELP yoyaku_room_occupied 現在の時刻に確定済みの予約があれば 1、無ければ 0
+# TYPE yoyaku_room_occupied gauge
+yoyaku_room_occupied ` + want + `
+`
+		if err := testutil.CollectAndCompare(collector, strings.NewReader(expected), "yoyaku_room_occupied"); err != nil {
+			t.Error(err)
+		}
+		return counting.calls
+	}
+
+	if calls := occupied("0"); calls != 1 {
+		t.Errorf("開始前の読み込み = %d 回, want 1 回", calls)
+	}
+	// 読み込み済みの予約から、開始・終了の時刻をまたいでも正しく計算する
+	now = start.Add(10 * time.Second)
+	if calls := occupied("1"); calls != 1 {
+		t.Errorf("利用中の読み込み = %d 回, want 1 回", calls)
+	}
+	now = start.Add(40 * time.Second)
+	if calls := occupied("0"); calls != 1 {
+		t.Errorf("終了後の読み込み = %d 回, want 1 回", calls)
+	}
+
+	// 予約の変更を配信したら読み込み直す
+	InstrumentBus(events.NewMemoryBus(1)).Publish(events.Event{Type: events.TypeReservationUpdated})
+	if calls := occupied("0"); calls != 2 {
+		t.Errorf("変更の配信後の読み込み = %d 回, want 2 回", calls)
+	}
+	// roomCacheTTL を過ぎたら読み込み直す
+	now = now.Add(roomCacheTTL)
+	if calls := occupied("0"); calls != 3 {
+		t.Errorf("roomCacheTTL 後の読み込み = %d 回, want 3 回", calls)
+	}
+}
@@ -0,0 +1,25 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// unmatchedRoute は登録していないパスへのリクエストの route です (パスをそのまま使うと値の種類が増え続けるため)。
+const unmatchedRoute = "unmatched"
+
+// Middleware は HTTP リクエストの数と処理時間をルートごとに記録します。
+func Middleware(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+
+	route := c.FullPath()
+	if route == "" {
+		route = unmatchedRoute
+	}
+	method := c.Request.Method
+	httpRequests.WithLabelValues(method, route, strconv.Itoa(c.Writer.Status())).Inc()
+	httpDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,87 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Dialect はマイグレーションを適用するデータベースの種類です。
+type Dialect int
+
+const (
+	MySQL Dialect = iota
+	SQLite
+	Postgres
+)
+
+// advisoryLockKey は PostgreSQL で複数のプロセスが同時にマイグレーションしないように取得するロックのキーです。
+const advisoryLockKey = 4010401
+
+func (d Dialect) String() string {
+	switch d {
+	case SQLite:
+		return "sqlite"
+	case Postgres:
+		return "postgres"
+	default:
+		return "mysql"
+	}
+}
+
+// lock はマイグレーション中に他のプロセスがマイグレーションしないようにロックを取得し、解放する関数を返します。
+func (d Dialect) lock(ctx context.Context, conn *sql.Conn) (func(), error) {
+	switch d {
+	case MySQL:
+		var locked sql.NullInt64
+		if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 60)", lockName).Scan(&locked); err != nil {
+			return nil, err
+		}
+		if locked.Int64 != 1 {
+			return nil, errors.New("マイグレーションのロックを取得できませんでした")
+		}
+		return func() { conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", lockName) }, nil
+	case Postgres:
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", advisoryLockKey); err != nil {
+			return nil, err
+		}
+		return func() { conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", advisoryLockKey) }, nil
+	default:
+		// SQLite は書き込みがファイル単位でロックされるため、ここではロックを取らない
+		return func() {}, nil
+	}
+}
+
+// createTable は schema_migrations テーブルを作成するSQLです。
+func (d Dialect) createTable() string {
+	if d == MySQL {
+		return `CREATE TABLE IF NOT EXISTS schema_migrations (
+  version BIGINT UNSIGNED NOT NULL,
+  dirty BOOLEAN NOT NULL
+)`
+	}
+	return `CREATE TABLE IF NOT EXISTS schema_migrations (
+  version BIGINT NOT NULL,
+  dirty BOOLEAN NOT NULL
+)`
+}
+
+// bind は ? のプレースホルダーを PostgreSQL の $1, $2, ... に置き換えます。
+func (d Dialect) bind(query string) string {
+	if d != Postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
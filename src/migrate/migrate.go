@@ -0,0 +1,296 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// lockName は MySQL で複数のプロセスが同時にマイグレーションしないように取得するロックの名前です。
+const lockName = "yoyaku_schema_migrations"
+
+// ErrDirty は前回のマイグレーションが途中で失敗していることを表します。
+// スキーマを手動で修正した後に Force でバージョンを設定してください。
+var ErrDirty = errors.New("前回のマイグレーションが途中で失敗しています (migrate force で修復してください)")
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration は1つのバージョンの up/down のSQLです。
+type Migration struct {
+	Version uint64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status は現在のスキーマのバージョンです。
+type Status struct {
+	Version uint64
+	Dirty   bool
+	// Pending はまだ適用されていないマイグレーションです。
+	Pending []Migration
+}
+
+// Migrator は schema_migrations テーブルでバージョンを管理しながらマイグレーションを適用します。
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration
+}
+
+// New は fsys 内の dir にある NNNN_name.up.sql / NNNN_name.down.sql を読み込みます。
+func New(sqlDB *sql.DB, dialect Dialect, fsys fs.FS, dir string) (*Migrator, error) {
+	migrations, err := Load(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: sqlDB, dialect: dialect, migrations: migrations}, nil
+}
+
+// Load はマイグレーションファイルを読み込んでバージョン順に並べます。
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[uint64]*Migration{}
+	for _, entry := range entries {
+		m := fileNamePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || m == nil {
+			continue
+		}
+		version, err := strconv.ParseUint(m[1], 10, 64)
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("マイグレーションのバージョンが正しくありません: %s", entry.Name())
+		}
+		body, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = migration
+		} else if migration.Name != m[2] {
+			return nil, fmt.Errorf("バージョン %d のマイグレーションが重複しています", version)
+		}
+		if m[3] == "up" {
+			migration.Up = string(body)
+		} else {
+			migration.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("バージョン %d の up マイグレーションがありません", migration.Version)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrations は読み込んだマイグレーションを返します。
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Up は未適用のマイグレーションを全て適用します。
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		version, dirty, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return ErrDirty
+		}
+		for _, migration := range m.migrations {
+			if migration.Version <= version {
+				continue
+			}
+			if err := m.apply(ctx, conn, migration.Version, migration.Up, migration.Version); err != nil {
+				return fmt.Errorf("%04d_%s.up.sql: %w", migration.Version, migration.Name, err)
+			}
+			log.Printf("マイグレーションを適用しました: %04d_%s", migration.Version, migration.Name)
+		}
+		return nil
+	})
+}
+
+// Down は適用済みのマイグレーションを新しい順に steps 個だけ取り消します。
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		version, dirty, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return ErrDirty
+		}
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			migration := m.migrations[i]
+			if migration.Version > version {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("バージョン %d の down マイグレーションがありません", migration.Version)
+			}
+			var previous uint64
+			if i > 0 {
+				previous = m.migrations[i-1].Version
+			}
+			if err := m.apply(ctx, conn, migration.Version, migration.Down, previous); err != nil {
+				return fmt.Errorf("%04d_%s.down.sql: %w", migration.Version, migration.Name, err)
+			}
+			log.Printf("マイグレーションを取り消しました: %04d_%s", migration.Version, migration.Name)
+			version = previous
+			steps--
+		}
+		return nil
+	})
+}
+
+// Status は現在のバージョンと未適用のマイグレーションを返します。
+func (m *Migrator) Status(ctx context.Context) (Status, error) {
+	var status Status
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		var err error
+		status.Version, status.Dirty, err = currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if migration.Version > status.Version {
+				status.Pending = append(status.Pending, migration)
+			}
+		}
+		return nil
+	})
+	return status, err
+}
+
+// CheckUpToDate は全てのマイグレーションが適用済みで、dirty 状態でないことを確認します (GET /readyz)。
+func (m *Migrator) CheckUpToDate(ctx context.Context) error {
+	status, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	if status.Dirty {
+		return fmt.Errorf("バージョン %d のマイグレーションが失敗したままです (dirty)", status.Version)
+	}
+	if len(status.Pending) > 0 {
+		return fmt.Errorf("未適用のマイグレーションが %d 個あります", len(status.Pending))
+	}
+	return nil
+}
+
+// Force はマイグレーションを実行せずにバージョンだけを設定し、dirty 状態を解除します。
+// 手動で作成した既存のデータベースを取り込む場合や、失敗したマイグレーションを手動で修復した後に使います。
+func (m *Migrator) Force(ctx context.Context, version uint64) error {
+	if version != 0 && !m.has(version) {
+		return fmt.Errorf("バージョン %d のマイグレーションは存在しません", version)
+	}
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		return m.setVersion(ctx, conn, version, false)
+	})
+}
+
+func (m *Migrator) has(version uint64) bool {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// withLock は1つのコネクション上でロックを取得して fn を実行します。
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	unlock, err := m.dialect.lock(ctx, conn)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := conn.ExecContext(ctx, m.dialect.createTable()); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// apply は SQL を実行し、成功したら newVersion を記録します。
+// MySQL の DDL はトランザクションで巻き戻せないため、実行中は dirty にしておきます。
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, version uint64, body string, newVersion uint64) error {
+	if err := m.setVersion(ctx, conn, version, true); err != nil {
+		return err
+	}
+	for _, statement := range SplitStatements(body) {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return m.setVersion(ctx, conn, newVersion, false)
+}
+
+func currentVersion(ctx context.Context, conn *sql.Conn) (uint64, bool, error) {
+	var version uint64
+	var dirty bool
+	err := conn.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
+
+func (m *Migrator) setVersion(ctx context.Context, conn *sql.Conn, version uint64, dirty bool) error {
+	if _, err := conn.ExecContext(ctx, "DELETE FROM schema_migrations"); err != nil {
+		return err
+	}
+	if version == 0 && !dirty {
+		return nil
+	}
+	_, err := conn.ExecContext(ctx, m.dialect.bind("INSERT INTO schema_migrations (version, dirty) VALUES (?, ?)"), version, dirty)
+	return err
+}
+
+// SplitStatements はマイグレーションファイルを1文ずつに分割します。
+// 行末の ; を文の区切りとみなし、-- で始まる行コメントは取り除きます。
+func SplitStatements(body string) []string {
+	var statements []string
+	var current strings.Builder
+	for _, line := range strings.Split(body, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			if statement := strings.TrimSpace(current.String()); statement != ";" {
+				statements = append(statements, statement)
+			}
+			current.Reset()
+		}
+	}
+	if statement := strings.TrimSpace(current.String()); statement != "" {
+		statements = append(statements, statement)
+	}
+	return statements
+}
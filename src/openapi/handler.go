@@ -0,0 +1,39 @@
+package openapi
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleSpec は OpenAPI 仕様を JSON で返します。
+// GET /api/openapi.json
+func HandleSpec(c *gin.Context) {
+	c.JSON(http.StatusOK, Spec())
+}
+
+// HandleSwaggerUI は仕様を閲覧するための Swagger UI を返します。
+// Swagger UI 本体は CDN (unpkg) から読み込みます。
+// GET /api/docs
+func HandleSwaggerUI(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUI))
+}
+
+const swaggerUI = `<!DOCTYPE html>
+<html lang="ja">
+<head>
+  <meta charset="utf-8">
+  <title>401号室 予約API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.onload = () => {
+      window.ui = SwaggerUIBundle({ url: "/api/openapi.json", dom_id: "#swagger-ui", withCredentials: true });
+    };
+  </script>
+</body>
+</html>
+`
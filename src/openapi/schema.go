@@ -0,0 +1,201 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Schema は OpenAPI 3.0 の Schema Object のうち、このAPIで使う項目です。
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+
+	// optional はオブジェクトのプロパティとして必須にしないことを表します (json の omitempty)。
+	optional bool
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemas は Go の型から Schema を作り、名前の付いた構造体を components に登録します。
+type schemas struct {
+	components map[string]*Schema
+	// names は components に登録する型とその名前です。未登録の構造体は型名で登録します。
+	names map[reflect.Type]string
+}
+
+func newSchemas() *schemas {
+	return &schemas{components: map[string]*Schema{}, names: map[reflect.Type]string{}}
+}
+
+// named は v の型を name という名前で components に登録し、その参照を返します。
+func (s *schemas) named(name string, v any) *Schema {
+	s.names[reflect.TypeOf(v)] = name
+	return s.of(reflect.TypeOf(v))
+}
+
+// of は t の Schema を返します。構造体は components への参照を返します。
+func (s *schemas) of(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawMessageType:
+		// 任意の JSON (変更履歴の before_data / after_data など)
+		return &Schema{Nullable: true}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := s.of(t.Elem())
+		if schema.Ref != "" {
+			// 3.0 では $ref と nullable を並べられないため、参照先をそのまま使う
+			return schema
+		}
+		schema.Nullable = true
+		return schema
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: s.of(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object"}
+	case reflect.Struct:
+		name, ok := s.names[t]
+		if !ok {
+			name = t.Name()
+		}
+		if _, done := s.components[name]; !done {
+			// 自身を参照する型に備えて、先に登録してから中身を作る
+			s.components[name] = &Schema{}
+			*s.components[name] = *object(s.fields(t))
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	return &Schema{}
+}
+
+// fields は構造体の JSON のフィールドを encoding/json と同じ名前で返します。
+// 埋め込んだ構造体のフィールドは、encoding/json と同じく外側のフィールドとして展開します。
+func (s *schemas) fields(t reflect.Type) map[string]*Schema {
+	props := map[string]*Schema{}
+	embedded := map[string]*Schema{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" && f.Anonymous && f.Type.Kind() == reflect.Struct {
+			for name, schema := range s.fields(f.Type) {
+				embedded[name] = schema
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		schema := s.of(f.Type)
+		// 省略される可能性があるフィールドは必須にしない
+		schema.optional = strings.Contains(opts, "omitempty")
+		props[name] = schema
+	}
+	// 同じ名前のフィールドは外側の構造体のものを優先する
+	for name, schema := range embedded {
+		if _, ok := props[name]; !ok {
+			props[name] = schema
+		}
+	}
+	return props
+}
+
+// pick は構造体 v のフィールドのうち names だけを取り出します。
+// ハンドラーが行の一部だけを返す場合に、型から Schema を作るために使います。
+func (s *schemas) pick(v any, names ...string) map[string]*Schema {
+	all := s.fields(reflect.TypeOf(v))
+	props := make(map[string]*Schema, len(names))
+	for _, name := range names {
+		schema, ok := all[name]
+		if !ok {
+			panic("openapi: " + reflect.TypeOf(v).String() + " に " + name + " がありません")
+		}
+		props[name] = schema
+	}
+	return props
+}
+
+// object は props を持つオブジェクトの Schema を返します。
+// omitempty のフィールド以外は必須で、props に無いフィールドは許可しません (契約テストで余計なフィールドを検出するため)。
+func object(props map[string]*Schema) *Schema {
+	schema := &Schema{Type: "object", Properties: props, AdditionalProperties: new(bool)}
+	for name, prop := range props {
+		if !prop.optional {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	sort.Strings(schema.Required)
+	return schema
+}
+
+// merge は複数のプロパティをまとめます。
+func merge(props ...map[string]*Schema) map[string]*Schema {
+	merged := map[string]*Schema{}
+	for _, p := range props {
+		for name, schema := range p {
+			merged[name] = schema
+		}
+	}
+	return merged
+}
+
+func str(description string) *Schema {
+	return &Schema{Type: "string", Description: description}
+}
+
+func enum(values ...string) *Schema {
+	return &Schema{Type: "string", Enum: values}
+}
+
+func integer(description string) *Schema {
+	return &Schema{Type: "integer", Format: "int64", Description: description}
+}
+
+func boolean(description string) *Schema {
+	return &Schema{Type: "boolean", Description: description}
+}
+
+func arrayOf(items *Schema) *Schema {
+	return &Schema{Type: "array", Items: items}
+}
+
+func nullable(schema *Schema) *Schema {
+	schema.Nullable = true
+	return schema
+}
+
+func optional(schema *Schema) *Schema {
+	schema.optional = true
+	return schema
+}
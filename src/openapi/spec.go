@@ -0,0 +1,535 @@
+// Package openapi は main.go に登録している全てのAPIの OpenAPI 3 仕様を組み立てます。
+//
+// リクエスト・レスポンスの Schema は types.ReservationsRequest や db の行の型からリフレクションで作るため、
+// 型にフィールドを追加すると仕様にも反映されます。gin.H で返しているレスポンスは、ここに同じ形を書いてください。
+// 仕様と実際のレスポンスがずれていないかは handler のテスト (契約テスト) で確認しています。
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"yoyaku/apierror"
+	"yoyaku/audit"
+	"yoyaku/availability"
+	"yoyaku/buildinfo"
+	"yoyaku/db"
+	"yoyaku/events"
+	"yoyaku/reservation"
+	"yoyaku/types"
+)
+
+// Document は OpenAPI 3.0 のドキュメントです。
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+	Tags       []Tag               `json:"tags,omitempty"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem は HTTP メソッド (小文字) ごとの操作です。
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	In          string `json:"in"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+var (
+	specOnce sync.Once
+	spec     *Document
+)
+
+// Spec は API 全体の仕様を返します。呼び出し側で変更しないでください。
+func Spec() *Document {
+	specOnce.Do(func() { spec = build() })
+	return spec
+}
+
+// PathFromGin は "/api/reservations/:id/history" のような gin のパスを仕様のパス ("{id}") に変換します。
+func PathFromGin(path string) string {
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, ":") || strings.HasPrefix(part, "*") {
+			parts[i] = "{" + part[1:] + "}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// builder は操作を登録しながら仕様を組み立てます。
+type builder struct {
+	doc     *Document
+	schemas *schemas
+	// errorResponse は全ての操作で共通のエラーレスポンスです。
+	errorResponse *Response
+}
+
+func (b *builder) add(method, path string, op *Operation) {
+	if b.doc.Paths[path] == nil {
+		b.doc.Paths[path] = PathItem{}
+	}
+	if op.Responses["default"] == nil {
+		op.Responses["default"] = b.errorResponse
+	}
+	b.doc.Paths[path][strings.ToLower(method)] = op
+}
+
+func build() *Document {
+	s := newSchemas()
+	b := &builder{
+		doc: &Document{
+			OpenAPI: "3.0.3",
+			Info: Info{
+				Title:       "401号室 予約API",
+				Version:     "1.0.0",
+				Description: "401号室の予約・チェックイン・空き状況のAPIです。ログインが必要なAPIは Google ログイン後のセッション Cookie で認証します。",
+			},
+			Paths: map[string]PathItem{},
+			Tags: []Tag{
+				{Name: "auth", Description: "ログインとユーザー設定"},
+				{Name: "reservations", Description: "予約"},
+				{Name: "usage", Description: "チェックインと利用の終了・延長"},
+				{Name: "availability", Description: "空き状況"},
+				{Name: "equipment", Description: "備品"},
+				{Name: "admin", Description: "管理者向け"},
+				{Name: "docs", Description: "この仕様"},
+				{Name: "health", Description: "コンテナのヘルスチェック、ビルドの情報とメトリクス"},
+			},
+		},
+		schemas: s,
+	}
+
+	fieldError := s.named("FieldError", apierror.FieldError{})
+	errorSchema := s.component("Error", object(map[string]*Schema{
+		"status":     enum("error"),
+		"code":       str("エラーコード (クライアントはメッセージではなくこのコードで処理を分けてください)"),
+		"message":    str("利用者に見せるメッセージ (表示言語に翻訳済み)"),
+		"details":    optional(arrayOf(fieldError)),
+		"request_id": str("X-Request-ID ヘッダーと同じ値"),
+	}))
+	problemSchema := s.component("Problem", object(map[string]*Schema{
+		"type":       str("常に about:blank"),
+		"title":      str("ステータスの説明"),
+		"status":     integer("HTTP ステータス"),
+		"detail":     str("利用者に見せるメッセージ"),
+		"instance":   str("リクエストのパス"),
+		"code":       str("エラーコード"),
+		"details":    optional(arrayOf(fieldError)),
+		"request_id": str("X-Request-ID ヘッダーと同じ値"),
+	}))
+	b.errorResponse = &Response{
+		Description: "エラー (Accept に application/problem+json を含む場合は RFC 7807 の形式)",
+		Content: map[string]MediaType{
+			"application/json":         {Schema: errorSchema},
+			"application/problem+json": {Schema: problemSchema},
+		},
+	}
+	b.doc.Components.SecuritySchemes = map[string]SecurityScheme{
+		"session": {Type: "apiKey", In: "cookie", Name: "session-name", Description: "GET /login からログインすると発行されるセッション Cookie"},
+	}
+
+	s.named("Reservation", db.Reservation{})
+	listItem := s.named("ReservationListItem", reservation.Item{})
+	searchHit := s.named("ReservationSearchHit", reservation.Hit{})
+	history := s.named("ReservationEvent", db.ListReservationEventsByReservationIDRow{})
+	interval := s.named("Interval", availability.Interval{})
+	s.named("StreamEvent", events.Event{})
+	reservationRequest := s.named("ReservationsRequest", types.ReservationsRequest{})
+	languageRequest := s.named("SetLanguageRequest", types.SetLanguageRequest{})
+	invitation := s.named("Invitation", reservation.Invitation{})
+	invitationRequest := s.named("InvitationResponseRequest", types.InvitationResponseRequest{})
+	equipment := s.named("Equipment", db.Equipment{})
+	equipmentAvailability := s.named("EquipmentAvailability", reservation.Equipment{})
+	equipmentRequest := s.named("EquipmentRequest", types.EquipmentRequest{})
+
+	success := map[string]*Schema{"status": enum("success")}
+	list := func(items *Schema) *Response {
+		return jsonResponse("成功", object(merge(success, map[string]*Schema{"data": arrayOf(items)})))
+	}
+	reservationResult := object(merge(success, s.pick(reservation.Detail{},
+		"id", "user_id", "title", "description", "headcount", "visibility", "attendees", "equipment",
+		"start_time", "end_time", "created_at", "updated_at")))
+	equipmentResult := jsonResponse("成功", object(merge(success, map[string]*Schema{"data": equipment})))
+	usageResult := object(merge(success, s.pick(db.Reservation{},
+		"id", "start_time", "end_time", "booked_end_time", "checked_in_at", "actual_end_time")))
+
+	// ログイン
+	b.add(http.MethodGet, "/login", &Operation{
+		OperationID: "login", Summary: "Google のログイン画面へリダイレクトする", Tags: []string{"auth"},
+		Responses: map[string]*Response{"307": {Description: "Google のログイン画面へリダイレクト"}},
+	})
+	b.add(http.MethodGet, "/callback", &Operation{
+		OperationID: "oauthCallback", Summary: "Google ログインのコールバック (フロントエンドへリダイレクトする)", Tags: []string{"auth"},
+		Parameters: []Parameter{query("state", "OAuth の state", true), query("code", "認可コード", true)},
+		Responses: map[string]*Response{
+			"307": {Description: "ログインに失敗した場合はフロントエンドの /login?error=... へリダイレクト"},
+			"308": {Description: "ログインに成功した場合はフロントエンドへリダイレクト"},
+		},
+	})
+	b.add(http.MethodGet, "/api/me", &Operation{
+		OperationID: "getMe", Summary: "ログイン中のユーザー", Tags: []string{"auth"}, Security: sessionAuth,
+		Responses: map[string]*Response{"200": jsonResponse("成功", object(map[string]*Schema{
+			"id":       str("ユーザーID (文字列)"),
+			"email":    nullable(str("")),
+			"name":     nullable(str("")),
+			"picture":  nullable(str("アイコンのURL")),
+			"language": str("選んだ表示言語 (ja / en)。未設定の場合は空文字列"),
+		}))},
+	})
+	b.add(http.MethodPost, "/api/logout", &Operation{
+		OperationID: "logout", Summary: "ログアウト", Tags: []string{"auth"},
+		Responses: map[string]*Response{"200": jsonResponse("成功", object(map[string]*Schema{"message": str("")}))},
+	})
+	b.add(http.MethodPut, "/api/me/language", &Operation{
+		OperationID: "setLanguage", Summary: "表示言語を変更する (空文字列で Accept-Language に戻す)", Tags: []string{"auth"}, Security: sessionAuth,
+		RequestBody: jsonBody(languageRequest),
+		Responses: map[string]*Response{"200": jsonResponse("成功", object(merge(success, map[string]*Schema{
+			"language": str("保存した表示言語"),
+		})))},
+	})
+	b.add(http.MethodPost, "/api/me/caldav-token", &Operation{
+		OperationID: "issueCalDAVToken", Summary: "CalDAV クライアント用のトークンを発行する (発行済みのトークンは無効になる)", Tags: []string{"auth"}, Security: sessionAuth,
+		Responses: map[string]*Response{"200": jsonResponse("成功", object(merge(success, map[string]*Schema{
+			"url":      str("CalDAV のURL"),
+			"username": str("CalDAV のユーザー名 (メールアドレス)"),
+			"token":    str("CalDAV のパスワード。このレスポンスでのみ確認できる"),
+		})))},
+	})
+	b.add(http.MethodGet, "/api/me/no-shows", &Operation{
+		OperationID: "getMyNoShows", Summary: "直近の no-show の回数と予約停止の状態", Tags: []string{"usage"}, Security: sessionAuth,
+		Responses: map[string]*Response{"200": jsonResponse("成功", object(merge(success, map[string]*Schema{
+			"count":     integer("直近の no-show の回数"),
+			"threshold": integer("予約が停止される回数 (0 の場合は停止しない)"),
+			"window":    str("数える期間 (例: 720h0m0s)"),
+			"suspended": boolean("新しい予約が停止されているか"),
+		})))},
+	})
+	b.add(http.MethodGet, "/api/me/invitations", &Operation{
+		OperationID: "listInvitations", Summary: "参加者として招待された、終了していない予約 (自分の返答付き)", Tags: []string{"reservations"}, Security: sessionAuth,
+		Responses: map[string]*Response{"200": list(invitation)},
+	})
+	b.add(http.MethodPut, "/api/me/invitations/{id}", &Operation{
+		OperationID: "respondInvitation", Summary: "招待に返答する (accepted / declined / tentative)", Tags: []string{"reservations"}, Security: sessionAuth,
+		Parameters:  []Parameter{{Name: "id", In: "path", Description: "予約ID", Required: true, Schema: integer("")}},
+		RequestBody: jsonBody(invitationRequest),
+		Responses:   map[string]*Response{"200": jsonResponse("返答後の予約", reservationResult)},
+	})
+
+	// 日付 (YYYY-MM-DD) や月 (YYYY-MM) の区切りに使うタイムゾーン (RFC 3339 の日時には影響しない)
+	tz := query("tz", "日付と月を解釈するタイムゾーン (IANA の名前、例: America/New_York)。省略時はサーバーの APP_TIMEZONE (既定は Asia/Tokyo)", false)
+	// 空き状況を調べる部屋・備品
+	resource := Parameter{
+		Name: "resource", In: "query",
+		Description: "調べる部屋 (room-401) または備品 (equipment-{備品ID})。複数指定すると全てを同時に使える時間帯を調べる。省略時は部屋のみ",
+		Schema:      arrayOf(&Schema{Type: "string"}),
+	}
+	resources := arrayOf(str("部屋 (room-401) または備品 (equipment-{備品ID})"))
+
+	// 空き状況
+	b.add(http.MethodGet, "/api/availability", &Operation{
+		OperationID: "getAvailability", Summary: "期間内の予約済み・空きの時間帯", Tags: []string{"availability"},
+		Parameters: []Parameter{query("start", "開始日 (YYYY-MM-DD)", true), query("end", "終了日 (YYYY-MM-DD、この日を含む)", true), resource, tz},
+		Responses: map[string]*Response{"200": jsonResponse("成功", object(merge(success, map[string]*Schema{
+			"data": object(map[string]*Schema{
+				"resources": resources,
+				"start":     {Type: "string", Format: "date-time"},
+				"end":       {Type: "string", Format: "date-time"},
+				"busy":      arrayOf(interval),
+				"free":      arrayOf(interval),
+			}),
+		})))},
+	})
+	b.add(http.MethodGet, "/api/availability/next", &Operation{
+		OperationID: "getNextAvailability", Summary: "指定した長さの予約が入る、最も早い空き枠", Tags: []string{"availability"},
+		Parameters: []Parameter{
+			query("duration", "予約の長さ (例: 90m)", true),
+			query("after", "この時刻以降を探す (RFC3339 または YYYY-MM-DD、省略時は現在)", false),
+			queryInt("limit", "返す件数 (1〜20、省略時は1)", false),
+			query("within", "探す期間 (例: 336h、省略時は14日)", false),
+			resource,
+			tz,
+		},
+		Responses: map[string]*Response{"200": jsonResponse("成功", object(merge(success, map[string]*Schema{
+			"data": object(map[string]*Schema{
+				"resources": resources,
+				"duration":  str("予約の長さ"),
+				"slots":     arrayOf(interval),
+			}),
+		})))},
+	})
+
+	// 備品
+	b.add(http.MethodGet, "/api/equipment", &Operation{
+		OperationID: "listEquipment", Summary: "部屋と一緒に借りられる備品 (start と end を指定すると、その時間帯に借りられる数も返す)", Tags: []string{"equipment"},
+		Parameters: []Parameter{
+			query("start", "時間帯の開始 (RFC3339、end と一緒に指定する)", false),
+			query("end", "時間帯の終了 (RFC3339、start と一緒に指定する)", false),
+		},
+		Responses: map[string]*Response{"200": list(equipmentAvailability)},
+	})
+
+	// 予約
+	b.add(http.MethodPost, "/api/reservations", &Operation{
+		OperationID: "createReservation", Summary: "予約を作成する", Tags: []string{"reservations"}, Security: sessionAuth,
+		RequestBody: jsonBody(reservationRequest),
+		Responses:   map[string]*Response{"200": jsonResponse("作成した予約", reservationResult)},
+	})
+	b.add(http.MethodPut, "/api/reservations", &Operation{
+		OperationID: "updateReservation", Summary: "自分の予約のタイトル・説明・時間帯・参加者・公開範囲を変更する", Tags: []string{"reservations"}, Security: sessionAuth,
+		Parameters:  []Parameter{queryInt("id", "予約ID", true)},
+		RequestBody: jsonBody(reservationRequest),
+		Responses:   map[string]*Response{"200": jsonResponse("変更後の予約", reservationResult)},
+	})
+	listParameters := []Parameter{
+		query("from", "この日時 (RFC 3339) または日付 (YYYY-MM-DD) 以降に終わる予約。省略した場合は現在時刻 (include_past=true の場合は制限なし)", false),
+		query("to", "この日時 (RFC 3339) より前、または日付 (YYYY-MM-DD) の終わりまでに始まる予約", false),
+		query("month", "from と to の代わりに月 (YYYY-MM) で指定する", false),
+		query("date", "from と to の代わりに日付 (YYYY-MM-DD) で指定する", false),
+		query("start", "from と to の代わりに開始日 (YYYY-MM-DD) で指定する。end と一緒に指定する", false),
+		query("end", "終了日 (YYYY-MM-DD、この日を含む)", false),
+		tz,
+		{Name: "status", In: "query", Description: "予約の状態。省略した場合は confirmed (include_canceled=true の場合は全て)", Schema: enum("confirmed", "canceled", "no_show")},
+		{Name: "include_canceled", In: "query", Description: "キャンセル済み・no-show の予約も返す", Schema: boolean("")},
+		{Name: "include_past", In: "query", Description: "from を省略した場合に、終了した予約も返す", Schema: boolean("")},
+		query("title", "タイトルに含まれる文字列 (英字の大文字と小文字は区別しない)", false),
+		queryInt("created_by", "予約を作成したユーザーのID", false),
+		{Name: "sort", In: "query", Description: "並び順 (開始時刻の早い順 / 遅い順)", Schema: enum(reservation.SortStartTime, reservation.SortStartTimeDesc)},
+		queryInt("limit", fmt.Sprintf("件数 (1から%d、既定は%d)", reservation.MaxLimit, reservation.DefaultLimit), false),
+		query("cursor", "前のページの next_cursor", false),
+	}
+	page := jsonResponse("成功", object(merge(success, map[string]*Schema{
+		"data":        arrayOf(listItem),
+		"next_cursor": nullable(str("次のページのカーソル (次のページが無い場合は null)")),
+	})))
+	b.add(http.MethodGet, "/api/reservations", &Operation{
+		OperationID: "listReservations", Summary: "全ユーザーの予約を条件で絞り込み、開始時刻順にカーソルでページを分けて返す (詳細を見られない予約のタイトルは「予約済み」)", Tags: []string{"reservations"},
+		Parameters: append([]Parameter{queryInt("user_id", "予約者のユーザーID", false)}, listParameters...),
+		Security:   sessionAuth,
+		Responses:  map[string]*Response{"200": page},
+	})
+	b.add(http.MethodGet, "/api/reservations/me", &Operation{
+		OperationID: "listMyReservations", Summary: "自分の予約と、参加を承諾 (未定を含む) した予約 (条件は listReservations と同じ)", Tags: []string{"reservations"}, Security: sessionAuth,
+		Parameters: listParameters,
+		Responses:  map[string]*Response{"200": page},
+	})
+	b.add(http.MethodGet, "/api/reservations/search", &Operation{
+		OperationID: "searchReservations", Summary: "予約のタイトルと説明を全文検索し、関連度の高い順に返す (キャンセルした予約は自分の予約だけ、非公開の予約は予約者と参加者だけ)", Tags: []string{"reservations"}, Security: sessionAuth,
+		Parameters: []Parameter{
+			query("q", fmt.Sprintf("検索語 (%d文字以内)", reservation.MaxSearchQueryLength), true),
+			queryInt("limit", fmt.Sprintf("件数 (1から%d、既定は%d)", reservation.MaxLimit, reservation.DefaultLimit), false),
+			queryInt("offset", "読み飛ばす件数 (前のページの next_offset)", false),
+		},
+		Responses: map[string]*Response{"200": jsonResponse("成功", object(merge(success, map[string]*Schema{
+			"data":        arrayOf(searchHit),
+			"next_offset": nullable(integer("次のページの offset (次のページが無い場合は null)")),
+		})))},
+	})
+	b.add(http.MethodPut, "/api/reservations/cancel", &Operation{
+		OperationID: "cancelReservation", Summary: "自分の予約をキャンセルする", Tags: []string{"reservations"}, Security: sessionAuth,
+		Parameters: []Parameter{queryInt("id", "予約ID", true)},
+		Responses: map[string]*Response{"200": jsonResponse("成功", object(merge(success, map[string]*Schema{
+			"message": str(""),
+		})))},
+	})
+	b.add(http.MethodGet, "/api/reservations/{id}/history", &Operation{
+		OperationID: "getReservationHistory", Summary: "予約の変更履歴 (予約者本人と管理者のみ)", Tags: []string{"reservations"}, Security: sessionAuth,
+		Parameters: []Parameter{{Name: "id", In: "path", Description: "予約ID", Required: true, Schema: integer("")}},
+		Responses:  map[string]*Response{"200": list(history)},
+	})
+	b.add(http.MethodGet, "/api/reservations/stream", &Operation{
+		OperationID: "streamReservations", Summary: "予約の作成・編集・キャンセルを Server-Sent Events で配信する (data は StreamEvent)", Tags: []string{"reservations"}, Security: sessionAuth,
+		Parameters: []Parameter{
+			query("date", "日付 (YYYY-MM-DD)", false),
+			query("start", "開始日 (YYYY-MM-DD)", false),
+			query("end", "終了日 (YYYY-MM-DD、この日を含む)", false),
+			{
+				Name: "resource", In: "query",
+				Description: "部屋 (room-401) または備品 (equipment-{備品ID})。指定した場合はいずれかを使う予約のイベントだけを配信する (備品を外した予約も含む)。全ての予約が部屋を使うため、省略時と room-401 は全ての予約",
+				Schema:      arrayOf(&Schema{Type: "string"}),
+			},
+			tz,
+		},
+		Responses: map[string]*Response{"200": {
+			Description: "イベントの種類を event、StreamEvent の JSON を data とするイベントストリーム",
+			Content:     map[string]MediaType{"text/event-stream": {Schema: str("")}},
+		}},
+	})
+
+	// チェックインと利用の終了・延長
+	b.add(http.MethodPost, "/api/reservations/checkin", &Operation{
+		OperationID: "checkIn", Summary: "予約にチェックインする (QRコードの token、または自分の予約の id を指定)", Tags: []string{"usage"}, Security: sessionAuth,
+		Parameters: []Parameter{queryInt("id", "予約ID", false), query("token", "QRコードのチェックイントークン", false)},
+		Responses: map[string]*Response{"200": jsonResponse("成功", object(merge(success, map[string]*Schema{
+			"id":            integer("予約ID"),
+			"checked_in_at": {Type: "string", Format: "date-time"},
+		})))},
+	})
+	b.add(http.MethodGet, "/api/reservations/checkin-token", &Operation{
+		OperationID: "getCheckinToken", Summary: "ドアに掲示するQRコード用のチェックインURL (予約者本人のみ)", Tags: []string{"usage"}, Security: sessionAuth,
+		Parameters: []Parameter{queryInt("id", "予約ID", true)},
+		Responses: map[string]*Response{"200": jsonResponse("成功", object(merge(success, map[string]*Schema{
+			"token": str("チェックイントークン"),
+			"url":   str("チェックイン画面のURL"),
+		})))},
+	})
+	b.add(http.MethodPost, "/api/reservations/end", &Operation{
+		OperationID: "endReservation", Summary: "利用中の予約を今すぐ終了して部屋を解放する", Tags: []string{"usage"}, Security: sessionAuth,
+		Parameters: []Parameter{queryInt("id", "予約ID", true)},
+		Responses:  map[string]*Response{"200": jsonResponse("変更後の予約", usageResult)},
+	})
+	b.add(http.MethodPost, "/api/reservations/extend", &Operation{
+		OperationID: "extendReservation", Summary: "利用中の予約を延長する (次の予約と重なる場合は延長できない)", Tags: []string{"usage"}, Security: sessionAuth,
+		Parameters: []Parameter{queryInt("id", "予約ID", true), queryInt("minutes", "延長する分数 (1〜240)", true)},
+		Responses:  map[string]*Response{"200": jsonResponse("変更後の予約", usageResult)},
+	})
+
+	// 管理者向け
+	b.add(http.MethodGet, "/api/admin/audit", &Operation{
+		OperationID: "searchAuditEvents", Summary: "全ての予約の変更履歴を検索する (管理者のみ)", Tags: []string{"admin"}, Security: sessionAuth,
+		Parameters: []Parameter{
+			queryInt("actor_id", "操作したユーザーのID", false),
+			queryInt("reservation_id", "予約ID", false),
+			{Name: "action", In: "query", Description: "操作", Schema: enum(audit.ActionCreated, audit.ActionUpdated, audit.ActionCanceled, audit.ActionCheckedIn,
+				audit.ActionNoShow, audit.ActionEndedEarly, audit.ActionExtended, audit.ActionResponded)},
+			{Name: "auth_method", In: "query", Description: "認証方法", Schema: enum(audit.MethodSession, audit.MethodCalDAV, audit.MethodGoogleCalendar, audit.MethodSystem)},
+			query("from", "この日以降 (YYYY-MM-DD)", false),
+			query("to", "この日以前 (YYYY-MM-DD)", false),
+			tz,
+			queryInt("limit", "件数 (最大200)", false),
+			queryInt("offset", "読み飛ばす件数", false),
+		},
+		Responses: map[string]*Response{"200": jsonResponse("成功", object(merge(success, map[string]*Schema{
+			"data":   arrayOf(history),
+			"limit":  integer(""),
+			"offset": integer(""),
+		})))},
+	})
+
+	b.add(http.MethodPost, "/api/admin/equipment", &Operation{
+		OperationID: "createEquipment", Summary: "備品を登録する (管理者のみ)", Tags: []string{"admin", "equipment"}, Security: sessionAuth,
+		RequestBody: jsonBody(equipmentRequest),
+		Responses:   map[string]*Response{"200": equipmentResult},
+	})
+	b.add(http.MethodPut, "/api/admin/equipment/{id}", &Operation{
+		OperationID: "updateEquipment", Summary: "備品の名前と数を変更する (管理者のみ。既に借りている予約はそのまま残る)", Tags: []string{"admin", "equipment"}, Security: sessionAuth,
+		Parameters:  []Parameter{{Name: "id", In: "path", Description: "備品ID", Required: true, Schema: integer("")}},
+		RequestBody: jsonBody(equipmentRequest),
+		Responses:   map[string]*Response{"200": equipmentResult},
+	})
+
+	// この仕様
+	b.add(http.MethodGet, "/api/openapi.json", &Operation{
+		OperationID: "getOpenAPI", Summary: "この OpenAPI 仕様", Tags: []string{"docs"},
+		Responses: map[string]*Response{"200": jsonResponse("OpenAPI 3 のドキュメント", &Schema{Type: "object"})},
+	})
+	b.add(http.MethodGet, "/api/docs", &Operation{
+		OperationID: "getDocs", Summary: "この仕様の Swagger UI", Tags: []string{"docs"},
+		Responses: map[string]*Response{"200": {Description: "Swagger UI", Content: map[string]MediaType{"text/html": {}}}},
+	})
+
+	// ヘルスチェック
+	b.add(http.MethodGet, "/healthz", &Operation{
+		OperationID: "healthz", Summary: "プロセスが動いていること (liveness probe。データベースなどは確認しない)", Tags: []string{"health"},
+		Responses: map[string]*Response{"200": jsonResponse("動いている", object(map[string]*Schema{"status": enum("ok")}))},
+	})
+	readiness := func(status string) *Schema {
+		return object(map[string]*Schema{
+			"status": enum(status),
+			"checks": {Type: "object", Description: "確認した項目 (database, migrations, server, workers) ごとの結果 (ok / error)"},
+		})
+	}
+	b.add(http.MethodGet, "/readyz", &Operation{
+		OperationID: "readyz", Summary: "リクエストを受け付けられること (readiness probe。データベースへの接続、マイグレーション、バックグラウンドの処理を確認する)", Tags: []string{"health"},
+		Responses: map[string]*Response{
+			"200": jsonResponse("受け付けられる", readiness("ok")),
+			"503": jsonResponse("受け付けられない (失敗の詳細はサーバーのログに出力する)", readiness("unavailable")),
+		},
+	})
+	b.add(http.MethodGet, "/version", &Operation{
+		OperationID: "getVersion", Summary: "ビルドしたコミット・日時と Go のバージョン", Tags: []string{"health"},
+		Responses: map[string]*Response{"200": jsonResponse("ビルドの情報", s.named("BuildInfo", buildinfo.Info{}))},
+	})
+
+	b.doc.Components.Schemas = s.components
+	return b.doc
+}
+
+var sessionAuth = []map[string][]string{{"session": {}}}
+
+// component は schema を name という名前で components に登録し、その参照を返します。
+func (s *schemas) component(name string, schema *Schema) *Schema {
+	s.components[name] = schema
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func query(name, description string, required bool) Parameter {
+	return Parameter{Name: name, In: "query", Description: description, Required: required, Schema: &Schema{Type: "string"}}
+}
+
+func queryInt(name, description string, required bool) Parameter {
+	p := query(name, description, required)
+	p.Schema = &Schema{Type: "integer", Format: "int64"}
+	return p
+}
+
+func jsonBody(schema *Schema) *RequestBody {
+	return &RequestBody{Required: true, Content: map[string]MediaType{"application/json": {Schema: schema}}}
+}
+
+func jsonResponse(description string, schema *Schema) *Response {
+	return &Response{Description: description, Content: map[string]MediaType{"application/json": {Schema: schema}}}
+}
@@ -0,0 +1,207 @@
+package openapi
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// 仕様に含めないパス。CalDAV は JSON のAPIではなく WebDAV のため対象外です。
+var undocumentedPrefixes = []string{"/caldav/", "/.well-known/"}
+
+// main.go に登録している全てのルートが仕様にあり、仕様のパスが全て main.go にあることを確認します。
+func TestSpecCoversRoutes(t *testing.T) {
+	routes := mainRoutes(t)
+	spec := Spec()
+
+	documented := map[string]bool{}
+	for path, item := range spec.Paths {
+		for method := range item {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+	for _, route := range routes {
+		if !documented[route] {
+			t.Errorf("%s が仕様にありません", route)
+		}
+		delete(documented, route)
+	}
+	for route := range documented {
+		t.Errorf("%s は main.go に登録されていません", route)
+	}
+}
+
+// mainRoutes は main.go の r.GET(...) や api.Group(...) の呼び出しから "GET /api/me" の形でルートを返します。
+func mainRoutes(t *testing.T) []string {
+	t.Helper()
+	file, err := parser.ParseFile(token.NewFileSet(), "../main.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// グループの変数名とパスの接頭辞 (r は gin.Engine)
+	prefixes := map[string]string{"r": ""}
+	var routes []string
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			// api := r.Group("/api")
+			if len(n.Lhs) != 1 || len(n.Rhs) != 1 {
+				return true
+			}
+			name, ok := n.Lhs[0].(*ast.Ident)
+			call, isCall := n.Rhs[0].(*ast.CallExpr)
+			if !ok || !isCall {
+				return true
+			}
+			if group, path, ok := routeCall(call, "Group"); ok {
+				if prefix, known := prefixes[group]; known {
+					prefixes[name.Name] = prefix + path
+				}
+			}
+		case *ast.CallExpr:
+			for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+				if group, path, ok := routeCall(n, method); ok {
+					prefix, known := prefixes[group]
+					if !known {
+						t.Fatalf("%s.%s(%q) のグループが分かりません", group, method, path)
+					}
+					path = PathFromGin(prefix + path)
+					if !undocumented(path) {
+						routes = append(routes, method+" "+path)
+					}
+				}
+			}
+		}
+		return true
+	})
+	sort.Strings(routes)
+	if len(routes) == 0 {
+		t.Fatal("main.go からルートを読み取れませんでした")
+	}
+	return routes
+}
+
+// routeCall は x.method("path", ...) の形の呼び出しから x とパスを返します。
+func routeCall(call *ast.CallExpr, method string) (string, string, bool) {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != method || len(call.Args) == 0 {
+		return "", "", false
+	}
+	x, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return "", "", false
+	}
+	lit, ok := call.Args[0].(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", "", false
+	}
+	path, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return "", "", false
+	}
+	return x.Name, path, true
+}
+
+func undocumented(path string) bool {
+	for _, prefix := range undocumentedPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// 仕様の全ての $ref が components に定義されていることを確認します。
+func TestSpecRefs(t *testing.T) {
+	spec := Spec()
+	data, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			if ref, ok := v["$ref"].(string); ok {
+				name := strings.TrimPrefix(ref, "#/components/schemas/")
+				if _, ok := spec.Components.Schemas[name]; !ok {
+					t.Errorf("%s が定義されていません", ref)
+				}
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	walk(doc)
+
+	operationIDs := map[string]bool{}
+	for path, item := range spec.Paths {
+		for method, op := range item {
+			if operationIDs[op.OperationID] {
+				t.Errorf("%s %s: operationId %q が重複しています", method, path, op.OperationID)
+			}
+			operationIDs[op.OperationID] = true
+		}
+	}
+}
+
+func TestValidateResponse(t *testing.T) {
+	spec := Spec()
+	reservation := `{"status":"success","id":1,"user_id":2,"title":"輪講","description":"",` +
+		`"headcount":3,"visibility":"public","attendees":[{"user_id":3,"name":"佐藤","response":"accepted"}],` +
+		`"equipment":[{"equipment_id":1,"name":"プロジェクター","quantity":1}],` +
+		`"start_time":"2025-07-01T10:00:00+09:00","end_time":"2025-07-01T12:00:00+09:00",` +
+		`"created_at":"2025-06-01T00:00:00Z","updated_at":"2025-06-01T00:00:00Z"}`
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		status      int
+		contentType string
+		body        string
+		wantErr     string
+	}{
+		{"ok", "POST", "/api/reservations", 200, "application/json; charset=utf-8", reservation, ""},
+		{"extra field", "POST", "/api/reservations", 200, "application/json", strings.Replace(reservation, `"id":1`, `"id":1,"extra":true`, 1), "extra"},
+		{"missing field", "POST", "/api/reservations", 200, "application/json", strings.Replace(reservation, `"title":"輪講",`, "", 1), "title"},
+		{"wrong type", "POST", "/api/reservations", 200, "application/json", strings.Replace(reservation, `"id":1`, `"id":"1"`, 1), "id"},
+		{"bad date-time", "POST", "/api/reservations", 200, "application/json", strings.Replace(reservation, "2025-07-01T10:00:00+09:00", "2025-07-01", 1), "date-time"},
+		{"error", "POST", "/api/reservations", 409, "application/json", `{"status":"error","code":"reservation_conflict","message":"x","request_id":"abc"}`, ""},
+		{"problem", "POST", "/api/reservations", 400, "application/problem+json",
+			`{"type":"about:blank","title":"Bad Request","status":400,"detail":"x","instance":"/api/reservations","code":"bad_request","details":[{"field":"title","message":"x"}],"request_id":"abc"}`, ""},
+		{"undocumented status", "POST", "/api/reservations", 201, "application/json", reservation, "201"},
+		{"undocumented path", "GET", "/api/unknown", 200, "application/json", `{}`, "/api/unknown"},
+		{"nullable", "GET", "/api/me", 200, "application/json", `{"id":"1","email":null,"name":null,"picture":null,"language":""}`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := spec.ValidateResponse(tt.method, tt.path, tt.status, tt.contentType, []byte(tt.body))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("err = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q を含むエラー", err, tt.wantErr)
+			}
+		})
+	}
+}
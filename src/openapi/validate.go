@@ -0,0 +1,152 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValidateResponse はハンドラーのレスポンスが仕様と一致するか確認します (契約テスト用)。
+// path は "/api/reservations/{id}/history" のような仕様のパスです。
+// status が仕様に無い場合は default のレスポンス (エラー) と比べます。
+func (d *Document) ValidateResponse(method, path string, status int, contentType string, body []byte) error {
+	item, ok := d.Paths[path]
+	if !ok {
+		return fmt.Errorf("%s は仕様にありません", path)
+	}
+	op, ok := item[strings.ToLower(method)]
+	if !ok {
+		return fmt.Errorf("%s %s は仕様にありません", method, path)
+	}
+	res, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		if res, ok = op.Responses["default"]; !ok || status < 400 {
+			return fmt.Errorf("%s %s: ステータス %d は仕様にありません", method, path, status)
+		}
+	}
+	if len(res.Content) == 0 {
+		if len(body) != 0 {
+			return fmt.Errorf("%s %s %d: 仕様ではボディがありません", method, path, status)
+		}
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	media, ok := res.Content[mediaType]
+	if !ok {
+		return fmt.Errorf("%s %s %d: Content-Type %q は仕様にありません", method, path, status, contentType)
+	}
+	if media.Schema == nil || !strings.HasSuffix(mediaType, "json") {
+		return nil
+	}
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Errorf("%s %s %d: JSON として読み込めません: %w", method, path, status, err)
+	}
+	if err := d.validate(media.Schema, value, "$"); err != nil {
+		return fmt.Errorf("%s %s %d: %w", method, path, status, err)
+	}
+	return nil
+}
+
+// validate は value が schema に一致するか確認します。OpenAPI のうち Schema が使う項目だけに対応しています。
+func (d *Document) validate(schema *Schema, value any, at string) error {
+	if schema.Ref != "" {
+		name := strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+		resolved, ok := d.Components.Schemas[name]
+		if !ok {
+			return fmt.Errorf("%s: %s は定義されていません", at, schema.Ref)
+		}
+		return d.validate(resolved, value, at)
+	}
+	if value == nil {
+		if schema.Nullable || schema.Type == "" {
+			return nil
+		}
+		return fmt.Errorf("%s: null は許可されていません", at)
+	}
+
+	switch schema.Type {
+	case "":
+		return nil
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: 文字列ではありません (%T)", at, value)
+		}
+		if schema.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, s); err != nil {
+				return fmt.Errorf("%s: date-time ではありません: %q", at, s)
+			}
+		}
+		if len(schema.Enum) > 0 && !contains(schema.Enum, s) {
+			return fmt.Errorf("%s: %q は %v のいずれでもありません", at, s, schema.Enum)
+		}
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != float64(int64(n)) {
+			return fmt.Errorf("%s: 整数ではありません (%v)", at, value)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%s: 数値ではありません (%T)", at, value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: 真偽値ではありません (%T)", at, value)
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%s: 配列ではありません (%T)", at, value)
+		}
+		for i, item := range items {
+			if err := d.validate(schema.Items, item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: オブジェクトではありません (%T)", at, value)
+		}
+		for _, name := range schema.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s: 必須のフィールド %s がありません", at, name)
+			}
+		}
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, ok := schema.Properties[name]
+			if !ok {
+				if schema.AdditionalProperties != nil && !*schema.AdditionalProperties {
+					return fmt.Errorf("%s: 仕様に無いフィールド %s があります", at, name)
+				}
+				continue
+			}
+			if err := d.validate(prop, obj[name], at+"."+name); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%s: 型 %s には対応していません", at, schema.Type)
+	}
+	return nil
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,207 @@
+package reservation
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"yoyaku/audit"
+	"yoyaku/db"
+	"yoyaku/events"
+	"yoyaku/store"
+	"yoyaku/types"
+)
+
+// CalendarEvent は CalDAV クライアントや共有Googleカレンダーから登録・編集する予定です。
+// カレンダーからはタイトルと時間帯だけを編集でき、説明・参加予定人数・参加者・公開範囲・備品は予約の値をそのまま残します。
+type CalendarEvent struct {
+	Title     string
+	StartTime time.Time
+	EndTime   time.Time
+	// UID はカレンダーの予定の UID (iCalendar の UID) です。
+	UID string
+	// Name はカレンダーのリソース名 (例: "abc.ics") です。
+	Name string
+	// GoogleEventID は共有Googleカレンダーの予定のIDです。設定した場合は origin = 'google_calendar' の予約として作成します。
+	GoogleEventID string
+	// UserID は予約者です。0 の場合は actor のユーザーの予約にします。
+	UserID uint64
+}
+
+func (e CalendarEvent) owner(actor audit.Actor) uint64 {
+	if e.UserID != 0 {
+		return e.UserID
+	}
+	return actor.UserID
+}
+
+func (e CalendarEvent) request() types.ReservationsRequest {
+	return types.ReservationsRequest{Title: e.Title, StartTime: e.StartTime, EndTime: e.EndTime}
+}
+
+// CreateFromCalendar はカレンダーから予約を作成します。エラーは Create と同じです。
+func (s *Service) CreateFromCalendar(ctx context.Context, actor audit.Actor, ev CalendarEvent) (db.Reservation, error) {
+	req := ev.request()
+	if err := validate(req); err != nil {
+		return db.Reservation{}, err
+	}
+	userID := ev.owner(actor)
+	suspended, err := s.policy.IsSuspended(ctx, s.store, userID)
+	if err != nil {
+		return db.Reservation{}, err
+	}
+	if suspended {
+		return db.Reservation{}, ErrSuspended
+	}
+
+	var created db.Reservation
+	err = s.store.InTx(ctx, func(tx store.Store) error {
+		if err := checkOverlap(ctx, tx, req, 0); err != nil {
+			return err
+		}
+		var result sql.Result
+		var err error
+		if ev.GoogleEventID != "" {
+			result, err = tx.CreateReservationFromCalendar(ctx, db.CreateReservationFromCalendarParams{
+				UserID:        userID,
+				Title:         req.Title,
+				StartTime:     req.StartTime,
+				EndTime:       req.EndTime,
+				GoogleEventID: sql.NullString{String: ev.GoogleEventID, Valid: true},
+			})
+		} else {
+			result, err = tx.CreateReservationFromCaldav(ctx, db.CreateReservationFromCaldavParams{
+				UserID:     userID,
+				Title:      req.Title,
+				StartTime:  req.StartTime,
+				EndTime:    req.EndTime,
+				IcalUid:    sql.NullString{String: ev.UID, Valid: true},
+				CaldavName: sql.NullString{String: ev.Name, Valid: true},
+			})
+		}
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		created, err = tx.GetReservationByID(ctx, uint64(id))
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, actor, audit.ActionCreated, created.ID, nil, &created)
+	})
+	if err != nil {
+		return db.Reservation{}, err
+	}
+	s.publishFromCalendar(actor, events.Event{Type: events.TypeReservationCreated, Reservation: created})
+	return created, nil
+}
+
+// UpdateFromCalendar はカレンダーから actor の予約 id のタイトルと時間帯を変更します。
+// 他のユーザーの予約の場合は ErrForbidden、他の予約と重なる場合は ErrConflict、
+// 借りている備品が新しい時間帯に足りない場合は ErrEquipmentUnavailable を返します。
+func (s *Service) UpdateFromCalendar(ctx context.Context, actor audit.Actor, id uint64, ev CalendarEvent) (db.Reservation, error) {
+	req := ev.request()
+	if err := validate(req); err != nil {
+		return db.Reservation{}, err
+	}
+
+	var before, updated db.Reservation
+	err := s.store.InTx(ctx, func(tx store.Store) error {
+		var err error
+		before, err = lockForCalendar(ctx, tx, actor, id, "他のユーザーの予約は編集できません")
+		if err != nil {
+			return err
+		}
+		if before.Status != "confirmed" {
+			return newError(ErrConflict, "確定していない予約は編集できません")
+		}
+		if err := checkOverlap(ctx, tx, req, id); err != nil {
+			return err
+		}
+		if err := checkReservedEquipment(ctx, tx, id, req.StartTime, req.EndTime); err != nil {
+			return err
+		}
+		if err := tx.UpdateReservationByID(ctx, db.UpdateReservationByIDParams{
+			Title:       req.Title,
+			Description: before.Description,
+			Headcount:   before.Headcount,
+			Visibility:  before.Visibility,
+			StartTime:   req.StartTime,
+			EndTime:     req.EndTime,
+			ID:          id,
+		}); err != nil {
+			return err
+		}
+		updated, err = tx.GetReservationByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, actor, audit.ActionUpdated, id, &before, &updated)
+	})
+	if err != nil {
+		return db.Reservation{}, err
+	}
+	s.publishFromCalendar(actor, events.Event{Type: events.TypeReservationUpdated, Reservation: updated, Previous: &before})
+	return updated, nil
+}
+
+// CancelFromCalendar はカレンダーから actor の予約 id をキャンセルします。
+// 他のユーザーの予約の場合は ErrForbidden、確定していない予約の場合は ErrConflict を返します。
+func (s *Service) CancelFromCalendar(ctx context.Context, actor audit.Actor, id uint64) (db.Reservation, error) {
+	var canceled db.Reservation
+	err := s.store.InTx(ctx, func(tx store.Store) error {
+		before, err := lockForCalendar(ctx, tx, actor, id, "他のユーザーの予約はキャンセルできません")
+		if err != nil {
+			return err
+		}
+		if before.Status != "confirmed" {
+			return newError(ErrConflict, "確定していない予約はキャンセルできません")
+		}
+		if err := tx.CanceledReservationByID(ctx, db.CanceledReservationByIDParams{
+			UserID: before.UserID,
+			ID:     id,
+		}); err != nil {
+			return err
+		}
+		canceled, err = tx.GetReservationByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, actor, audit.ActionCanceled, id, &before, &canceled)
+	})
+	if err != nil {
+		return db.Reservation{}, err
+	}
+	s.publishFromCalendar(actor, events.Event{Type: events.TypeReservationCanceled, Reservation: canceled})
+	return canceled, nil
+}
+
+// publishFromCalendar は変更を配信します。Googleカレンダーから取り込んだ変更は
+// 同期処理がカレンダーに送り返さないよう、Source を付けて配信します。
+func (s *Service) publishFromCalendar(actor audit.Actor, event events.Event) {
+	if actor.Method == audit.MethodGoogleCalendar {
+		event.Source = events.SourceGoogleCalendar
+	}
+	s.bus.Publish(event)
+}
+
+// lockForCalendar は予約を行ロックして取得します。カレンダーには他のユーザーの予約も表示されるため、
+// lockOwned と異なり他のユーザーの予約は ErrNotFound ではなく ErrForbidden (メッセージは forbidden) にします。
+// システムの操作 (Googleカレンダーとの同期) では、編集権限をカレンダーの共有設定で管理するため予約者を確認しません。
+func lockForCalendar(ctx context.Context, tx store.Store, actor audit.Actor, id uint64, forbidden string) (db.Reservation, error) {
+	reservation, err := tx.GetReservationByIDForUpdate(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return db.Reservation{}, ErrNotFound
+	}
+	if err != nil {
+		return db.Reservation{}, err
+	}
+	if actor.UserID != 0 && reservation.UserID != actor.UserID {
+		return db.Reservation{}, newError(ErrForbidden, forbidden)
+	}
+	return reservation, nil
+}
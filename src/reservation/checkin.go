@@ -0,0 +1,122 @@
+package reservation
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"yoyaku/audit"
+	"yoyaku/checkin"
+	"yoyaku/db"
+	"yoyaku/events"
+	"yoyaku/store"
+)
+
+// CheckIn は actor の予約 id にチェックインし、チェックイン後の予約を返します (既にチェックイン済みの場合はそのまま返します)。
+// 予約が無い場合は ErrNotFound、他のユーザーの予約の場合は ErrForbidden を返します (QRコードのトークンを使ってください)。
+// 受付時間外の場合は checkin.ErrTooEarly / checkin.ErrTooLate を返します。
+func (s *Service) CheckIn(ctx context.Context, actor audit.Actor, id uint64) (db.Reservation, error) {
+	reservation, err := s.store.GetReservationByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return db.Reservation{}, ErrNotFound
+	}
+	if err != nil {
+		return db.Reservation{}, err
+	}
+	if reservation.UserID != actor.UserID {
+		return db.Reservation{}, newError(ErrForbidden, "他のユーザーの予約にはQRコードからチェックインしてください")
+	}
+	return s.checkIn(ctx, actor, reservation)
+}
+
+// CheckInWithToken はドアに掲示したQRコードのトークンの予約にチェックインします。
+// ログインしている研究室メンバーなら誰でもチェックインできます。エラーは CheckIn と同じです。
+func (s *Service) CheckInWithToken(ctx context.Context, actor audit.Actor, token string) (db.Reservation, error) {
+	reservation, err := s.store.GetReservationByCheckinToken(ctx, token)
+	if errors.Is(err, sql.ErrNoRows) {
+		return db.Reservation{}, ErrNotFound
+	}
+	if err != nil {
+		return db.Reservation{}, err
+	}
+	return s.checkIn(ctx, actor, reservation)
+}
+
+func (s *Service) checkIn(ctx context.Context, actor audit.Actor, before db.Reservation) (db.Reservation, error) {
+	// 既にチェックイン済みの場合はそのまま成功として返す
+	if before.CheckedInAt.Valid {
+		return before, nil
+	}
+	if err := s.policy.CanCheckIn(before, time.Now()); err != nil {
+		return db.Reservation{}, err
+	}
+
+	var checkedIn db.Reservation
+	err := s.store.InTx(ctx, func(tx store.Store) error {
+		if err := tx.CheckInReservation(ctx, before.ID); err != nil {
+			return err
+		}
+		var err error
+		checkedIn, err = tx.GetReservationByID(ctx, before.ID)
+		if err != nil {
+			return err
+		}
+		if !checkedIn.CheckedInAt.Valid {
+			// 解放処理と競合して no-show になった
+			return checkin.ErrTooLate
+		}
+		return audit.Record(ctx, tx, actor, audit.ActionCheckedIn, before.ID, &before, &checkedIn)
+	})
+	if err != nil {
+		return db.Reservation{}, err
+	}
+	s.bus.Publish(events.Event{Type: events.TypeReservationCheckedIn, Reservation: checkedIn})
+	return checkedIn, nil
+}
+
+// CheckinToken は actor の予約 id のドアに掲示するQRコード用のチェックイントークンを返します。
+// まだ発行していない場合は新しく発行します。予約が無いか他のユーザーの予約の場合は ErrNotFound を返します。
+func (s *Service) CheckinToken(ctx context.Context, actor audit.Actor, id uint64) (string, error) {
+	reservation, err := s.store.GetReservationByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && reservation.UserID != actor.UserID) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	token, err := s.store.GetCheckinTokenByReservationID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		token, err = checkin.NewToken()
+		if err == nil {
+			err = s.store.CreateCheckinToken(ctx, db.CreateCheckinTokenParams{
+				ReservationID: id,
+				Token:         token,
+			})
+		}
+	}
+	return token, err
+}
+
+// NoShowStatus はユーザーの直近の no-show の回数と予約停止の状態です。
+type NoShowStatus struct {
+	Count     int64
+	Threshold int64
+	Window    time.Duration
+	Suspended bool
+}
+
+// NoShows は userID の直近 (checkin.Policy の SuspendWindow の間) の no-show の回数と予約停止の状態を返します。
+func (s *Service) NoShows(ctx context.Context, userID uint64) (NoShowStatus, error) {
+	count, err := s.policy.NoShowCount(ctx, s.store, userID)
+	if err != nil {
+		return NoShowStatus{}, err
+	}
+	return NoShowStatus{
+		Count:     count,
+		Threshold: s.policy.SuspendThreshold,
+		Window:    s.policy.SuspendWindow,
+		Suspended: s.policy.SuspendThreshold > 0 && count >= s.policy.SuspendThreshold,
+	}, nil
+}
@@ -0,0 +1,286 @@
+package reservation
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"yoyaku/db"
+	"yoyaku/store"
+	"yoyaku/types"
+)
+
+// 備品のエラーの種類
+var (
+	// ErrEquipmentNotFound は備品が存在しないことを表します。
+	ErrEquipmentNotFound = errors.New("備品が見つかりません")
+	// ErrEquipmentUnavailable は同じ時間帯の他の予約が借りているため、備品の数が足りないことを表します。
+	ErrEquipmentUnavailable = errors.New("この時間帯に借りられる備品の数が足りません")
+)
+
+// MaxEquipmentNameLength は備品の名前の最大の文字数です。
+const MaxEquipmentNameLength = 100
+
+// ReservedEquipment は予約で借りる備品と数です。
+type ReservedEquipment struct {
+	EquipmentID uint64 `json:"equipment_id"`
+	Name        string `json:"name"`
+	Quantity    int32  `json:"quantity"`
+}
+
+// Equipment は備品と、指定した時間帯に借りられる数です。
+type Equipment struct {
+	db.Equipment
+	// Available は時間帯の全体を通して借りられる数です。時間帯を指定しない場合は Quantity と同じです。
+	Available int32 `json:"available"`
+}
+
+// ListEquipment は全ての備品を返します。r を指定した場合は、その時間帯に借りられる数も計算します。
+func (s *Service) ListEquipment(ctx context.Context, r Range) ([]Equipment, error) {
+	if !r.Start.IsZero() && !r.End.After(r.Start) {
+		return nil, &Error{Kind: ErrValidation, Message: "終了時刻は開始時刻より後にしてください", Field: "end"}
+	}
+	rows, err := s.store.ListEquipment(ctx)
+	if err != nil {
+		return nil, err
+	}
+	used := map[uint64]int32{}
+	if !r.Start.IsZero() {
+		if used, err = equipmentInUse(ctx, s.store, r.Start, r.End, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	equipment := make([]Equipment, 0, len(rows))
+	for _, row := range rows {
+		equipment = append(equipment, Equipment{Equipment: row, Available: max(row.Quantity-used[row.ID], 0)})
+	}
+	return equipment, nil
+}
+
+// CreateEquipment は備品を登録します。同じ名前の備品がある場合は ErrValidation を返します。
+func (s *Service) CreateEquipment(ctx context.Context, req types.EquipmentRequest) (db.Equipment, error) {
+	name, err := validateEquipment(req)
+	if err != nil {
+		return db.Equipment{}, err
+	}
+
+	var created db.Equipment
+	err = s.store.InTx(ctx, func(tx store.Store) error {
+		if err := checkEquipmentName(ctx, tx, name, 0); err != nil {
+			return err
+		}
+		result, err := tx.CreateEquipment(ctx, db.CreateEquipmentParams{Name: name, Quantity: int32(req.Quantity)})
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		created, err = tx.GetEquipmentByID(ctx, uint64(id))
+		return err
+	})
+	if err != nil {
+		return db.Equipment{}, err
+	}
+	return created, nil
+}
+
+// UpdateEquipment は備品の名前と数を変更します。
+// 数を減らしても、既に備品を借りている予約はそのまま残ります (以降の予約から新しい数で確認します)。
+// 備品が無い場合は ErrEquipmentNotFound、同じ名前の備品が他にある場合は ErrValidation を返します。
+func (s *Service) UpdateEquipment(ctx context.Context, id uint64, req types.EquipmentRequest) (db.Equipment, error) {
+	name, err := validateEquipment(req)
+	if err != nil {
+		return db.Equipment{}, err
+	}
+
+	var updated db.Equipment
+	err = s.store.InTx(ctx, func(tx store.Store) error {
+		if _, err := tx.GetEquipmentByIDForUpdate(ctx, id); errors.Is(err, sql.ErrNoRows) {
+			return ErrEquipmentNotFound
+		} else if err != nil {
+			return err
+		}
+		if err := checkEquipmentName(ctx, tx, name, id); err != nil {
+			return err
+		}
+		if err := tx.UpdateEquipment(ctx, db.UpdateEquipmentParams{Name: name, Quantity: int32(req.Quantity), ID: id}); err != nil {
+			return err
+		}
+		updated, err = tx.GetEquipmentByID(ctx, id)
+		return err
+	})
+	if err != nil {
+		return db.Equipment{}, err
+	}
+	return updated, nil
+}
+
+// validateEquipment は備品の入力を確認し、前後の空白を取り除いた名前を返します。
+func validateEquipment(req types.EquipmentRequest) (string, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return "", &Error{Kind: ErrValidation, Message: "備品の名前を入力してください", Field: "name"}
+	}
+	if utf8.RuneCountInString(name) > MaxEquipmentNameLength {
+		return "", &Error{Kind: ErrValidation, Message: "備品の名前は100文字以内で入力してください", Field: "name"}
+	}
+	if req.Quantity < 0 || req.Quantity > 10000 {
+		return "", &Error{Kind: ErrValidation, Message: "備品の数は0から10000の範囲で入力してください", Field: "quantity"}
+	}
+	return name, nil
+}
+
+// checkEquipmentName は excludeID 以外に同じ名前の備品が無いことを確認します。
+// UNIQUE 制約のエラーはデータベースごとに異なるため、登録する前に確認します。
+func checkEquipmentName(ctx context.Context, tx store.Store, name string, excludeID uint64) error {
+	equipment, err := tx.ListEquipment(ctx)
+	if err != nil {
+		return err
+	}
+	if slices.ContainsFunc(equipment, func(e db.Equipment) bool { return e.Name == name && e.ID != excludeID }) {
+		return &Error{Kind: ErrValidation, Message: "同じ名前の備品が既にあります", Field: "name"}
+	}
+	return nil
+}
+
+// checkEquipment は予約で借りる備品が、その時間帯に他の予約と合わせても数が足りるか確認します。
+// 同じ備品を同時に予約して数を超えないよう、備品の行をロックします (予約の登録まで同じトランザクションで行ってください)。
+// 既存の予約を編集する場合は excludeID にその予約のIDを渡してください。
+// 存在しない備品や重複した備品が含まれる場合は ErrValidation、数が足りない場合は ErrEquipmentUnavailable を返します。
+func checkEquipment(ctx context.Context, tx store.Store, req types.ReservationsRequest, excludeID uint64) ([]ReservedEquipment, error) {
+	if len(req.Equipment) == 0 {
+		return []ReservedEquipment{}, nil
+	}
+	// デッドロックしないよう、備品のID順にロックする
+	requested := slices.Clone(req.Equipment)
+	slices.SortFunc(requested, func(a, b types.ReservationEquipmentRequest) int { return cmp.Compare(a.EquipmentID, b.EquipmentID) })
+
+	equipment := make([]ReservedEquipment, 0, len(requested))
+	quantities := map[uint64]int32{}
+	for i, r := range requested {
+		if i > 0 && requested[i-1].EquipmentID == r.EquipmentID {
+			return nil, &Error{Kind: ErrValidation, Message: "同じ備品が複数含まれています", Field: "equipment"}
+		}
+		e, err := tx.GetEquipmentByIDForUpdate(ctx, r.EquipmentID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &Error{Kind: ErrValidation, Message: "存在しない備品が含まれています", Field: "equipment"}
+		}
+		if err != nil {
+			return nil, err
+		}
+		quantities[e.ID] = e.Quantity
+		equipment = append(equipment, ReservedEquipment{EquipmentID: e.ID, Name: e.Name, Quantity: int32(r.Quantity)})
+	}
+
+	used, err := equipmentInUse(ctx, tx, req.StartTime, req.EndTime, excludeID)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range equipment {
+		if used[e.EquipmentID]+e.Quantity > quantities[e.EquipmentID] {
+			return nil, ErrEquipmentUnavailable
+		}
+	}
+	return equipment, nil
+}
+
+// checkReservedEquipment は予約 id が借りている備品が、start から end の時間帯にも足りるか確認します。
+// 延長やカレンダーからの編集のように、借りる備品を変えずに時間帯だけを変更する場合に使います。
+func checkReservedEquipment(ctx context.Context, tx store.Store, id uint64, start, end time.Time) error {
+	reserved, err := listEquipment(ctx, tx, []uint64{id})
+	if err != nil {
+		return err
+	}
+	req := types.ReservationsRequest{StartTime: start, EndTime: end}
+	for _, e := range reserved[id] {
+		req.Equipment = append(req.Equipment, types.ReservationEquipmentRequest{EquipmentID: e.EquipmentID, Quantity: int(e.Quantity)})
+	}
+	_, err = checkEquipment(ctx, tx, req, id)
+	return err
+}
+
+// syncEquipment は予約で借りる備品を equipment に置き換えます。
+func syncEquipment(ctx context.Context, tx store.Store, reservationID uint64, equipment []ReservedEquipment) error {
+	if err := tx.DeleteReservationEquipment(ctx, reservationID); err != nil {
+		return err
+	}
+	for _, e := range equipment {
+		if err := tx.AddReservationEquipment(ctx, db.AddReservationEquipmentParams{
+			ReservationID: reservationID,
+			EquipmentID:   e.EquipmentID,
+			Quantity:      e.Quantity,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// equipmentInUse は start から end の間に、他の予約が同時に借りている備品の数の最大値を備品ごとに返します。
+// 時間帯が重ならない予約どうしの数は足し合わせないため、例えば 10時〜11時 と 11時〜12時 に1つずつ借りている場合は1です。
+func equipmentInUse(ctx context.Context, st store.EquipmentStore, start, end time.Time, excludeID uint64) (map[uint64]int32, error) {
+	rows, err := st.ListEquipmentUsage(ctx, db.ListEquipmentUsageParams{
+		RangeEnd:   end,
+		RangeStart: start,
+		ExcludeID:  excludeID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	type change struct {
+		at    time.Time
+		delta int32
+	}
+	changes := map[uint64][]change{}
+	for _, row := range rows {
+		// どちらの予約も期間に重なっているので、予約どうしが重なる時刻は必ず期間内にある (期間で切り詰める必要はない)
+		changes[row.EquipmentID] = append(changes[row.EquipmentID],
+			change{at: row.StartTime, delta: row.Quantity},
+			change{at: row.EndTime, delta: -row.Quantity})
+	}
+	used := make(map[uint64]int32, len(changes))
+	for id, cs := range changes {
+		// 同じ時刻に終わる予約と始まる予約は重ならないので、返却を先に数える
+		slices.SortFunc(cs, func(a, b change) int {
+			if c := a.at.Compare(b.at); c != 0 {
+				return c
+			}
+			return cmp.Compare(a.delta, b.delta)
+		})
+		var current int32
+		for _, c := range cs {
+			current += c.delta
+			used[id] = max(used[id], current)
+		}
+	}
+	return used, nil
+}
+
+// listEquipment は予約ごとに借りる備品を返します。
+func listEquipment(ctx context.Context, st store.EquipmentStore, ids []uint64) (map[uint64][]ReservedEquipment, error) {
+	equipment := make(map[uint64][]ReservedEquipment, len(ids))
+	if len(ids) == 0 {
+		return equipment, nil
+	}
+	rows, err := st.ListReservationEquipment(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		equipment[row.ReservationID] = append(equipment[row.ReservationID], ReservedEquipment{
+			EquipmentID: row.EquipmentID,
+			Name:        row.Name,
+			Quantity:    row.Quantity,
+		})
+	}
+	return equipment, nil
+}
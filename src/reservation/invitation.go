@@ -0,0 +1,106 @@
+package reservation
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"time"
+
+	"yoyaku/audit"
+	"yoyaku/db"
+	"yoyaku/events"
+	"yoyaku/store"
+)
+
+// 招待への返答 (reservation_attendees.response)
+const (
+	// ResponseNeedsAction はまだ返答していないことを表します (招待した直後の状態)。
+	ResponseNeedsAction = "needs_action"
+	ResponseAccepted    = "accepted"
+	ResponseDeclined    = "declined"
+	ResponseTentative   = "tentative"
+)
+
+// Invitation はユーザーが参加者として招待された予約です (予約者の名前と自分の返答付き)。
+type Invitation = db.ListInvitationsByUserIDRow
+
+// Invitations は userID が招待された、終了していない確定済みの予約を開始時刻順に返します。
+func (s *Service) Invitations(ctx context.Context, userID uint64) ([]Invitation, error) {
+	invitations, err := s.store.ListInvitationsByUserID(ctx, db.ListInvitationsByUserIDParams{
+		UserID: userID,
+		After:  time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if invitations == nil {
+		invitations = []Invitation{}
+	}
+	return invitations, nil
+}
+
+// Respond は actor が招待された予約 id に返答 (ResponseAccepted / ResponseDeclined / ResponseTentative) し、
+// 返答後の参加者付きの予約を返します。招待されていない場合は ErrNotFound、
+// 予約が終了またはキャンセルされている場合は ErrConflict を返します。
+func (s *Service) Respond(ctx context.Context, actor audit.Actor, id uint64, response string) (Detail, error) {
+	if !slices.Contains([]string{ResponseAccepted, ResponseDeclined, ResponseTentative}, response) {
+		return Detail{}, &Error{Kind: ErrValidation, Message: "返答は accepted、declined、tentative のいずれかで指定してください", Field: "response"}
+	}
+
+	var responded Detail
+	err := s.store.InTx(ctx, func(tx store.Store) error {
+		reservation, err := tx.GetReservationByIDForUpdate(ctx, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		rows, err := tx.ListReservationAttendees(ctx, []uint64{id})
+		if err != nil {
+			return err
+		}
+		i := slices.IndexFunc(rows, func(a db.ListReservationAttendeesRow) bool { return a.UserID == actor.UserID })
+		if i < 0 {
+			return ErrNotFound
+		}
+		before := rows[i]
+		now := time.Now()
+		if reservation.Status != "confirmed" || reservation.EndTime.Before(now) {
+			return newError(ErrConflict, "終了またはキャンセルされた予約には返答できません")
+		}
+		if err := tx.UpdateReservationAttendeeResponse(ctx, db.UpdateReservationAttendeeResponseParams{
+			Response:      response,
+			RespondedAt:   sql.NullTime{Time: now, Valid: true},
+			ReservationID: id,
+			UserID:        actor.UserID,
+		}); err != nil {
+			return err
+		}
+		responded.Reservation = reservation
+		attendees, err := listAttendees(ctx, tx, []uint64{id})
+		if err != nil {
+			return err
+		}
+		responded.Attendees = attendees[id]
+		equipment, err := listEquipment(ctx, tx, []uint64{id})
+		if err != nil {
+			return err
+		}
+		responded.Equipment = equipment[id]
+		if responded.Equipment == nil {
+			responded.Equipment = []ReservedEquipment{}
+		}
+		after := before
+		after.Response = response
+		after.RespondedAt = sql.NullTime{Time: now, Valid: true}
+		return audit.RecordResponse(ctx, tx, actor, id, &before, &after)
+	})
+	if err != nil {
+		return Detail{}, err
+	}
+	// 参加者の返答は予約の詳細の一部のため、予約の変更として通知する
+	s.bus.Publish(events.Event{Type: events.TypeReservationUpdated, Reservation: responded.Reservation})
+	return responded, nil
+}
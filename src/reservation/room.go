@@ -0,0 +1,18 @@
+package reservation
+
+// RoomID は部屋のIDです (room_locks の行、CalDAV のカレンダー、空き時間の resource に使います)。
+const RoomID = "room-401"
+
+// DefaultCapacity は部屋の定員を設定しない場合の定員です (config.DefaultRoomCapacity と同じです)。
+const DefaultCapacity = 10
+
+// Room は予約できる部屋の設定です (現在は401号室のみ)。
+type Room struct {
+	// Capacity は部屋の定員で、参加予定人数と「予約者 + 参加者」の人数の上限です。
+	Capacity int
+}
+
+// DefaultRoom は既定の設定の Room を返します。
+func DefaultRoom() Room {
+	return Room{Capacity: DefaultCapacity}
+}
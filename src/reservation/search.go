@@ -0,0 +1,103 @@
+package reservation
+
+import (
+	"context"
+	"math"
+	"strings"
+	"unicode/utf8"
+	"yoyaku/db"
+)
+
+// MaxSearchQueryLength は検索語の最大の文字数です。
+const MaxSearchQueryLength = 100
+
+// SearchQuery は予約の全文検索の条件です。
+type SearchQuery struct {
+	// Q はタイトルと説明から探す文字列です (前後の空白は取り除きます)。
+	Q string
+	// ViewerID は検索するユーザーです。キャンセルした予約は ViewerID の予約だけを、
+	// 非公開の予約は ViewerID が予約者か参加者の予約だけを返します。
+	ViewerID uint64
+	// Limit は1から MaxLimit の件数で、0の場合は DefaultLimit です。
+	Limit int
+	// Offset は読み飛ばす件数です。
+	Offset int
+}
+
+// SearchPage は全文検索の結果の1ページです。
+type SearchPage struct {
+	Hits []Hit
+	// NextOffset は次のページの Offset で、次のページが無い場合は0です。
+	NextOffset int
+}
+
+// Hit は全文検索で見つかった予約です (予約者の名前・参加者・備品・関連度付き)。
+type Hit struct {
+	db.SearchReservationsFullTextRow
+	// Attendees は参加者で、いない場合は空のスライスです。
+	Attendees []Attendee `json:"attendees"`
+	// Equipment は借りる備品で、無い場合は空のスライスです。
+	Equipment []ReservedEquipment `json:"equipment"`
+}
+
+// Search は予約のタイトルと説明を検索し、関連度の高い順に返します。
+// 関連度はデータベースによって異なります (MySQL は FULLTEXT インデックス、それ以外は部分一致)。
+func (s *Service) Search(ctx context.Context, q SearchQuery) (SearchPage, error) {
+	text := strings.TrimSpace(q.Q)
+	if text == "" {
+		return SearchPage{}, &Error{Kind: ErrValidation, Message: "検索語を入力してください", Field: "q"}
+	}
+	if utf8.RuneCountInString(text) > MaxSearchQueryLength {
+		return SearchPage{}, &Error{Kind: ErrValidation, Message: "検索語は100文字以内で入力してください", Field: "q"}
+	}
+	limit := DefaultLimit
+	if q.Limit != 0 {
+		if q.Limit < 1 || q.Limit > MaxLimit {
+			return SearchPage{}, &Error{Kind: ErrValidation, Message: "件数は1から200の範囲で指定してください", Field: "limit"}
+		}
+		limit = q.Limit
+	}
+	if q.Offset < 0 || q.Offset > math.MaxInt32-MaxLimit-1 {
+		return SearchPage{}, &Error{Kind: ErrValidation, Message: "offsetの値が正しくありません", Field: "offset"}
+	}
+
+	// 次のページがあるか判定するため1件多く取得する
+	rows, err := s.store.SearchReservationsFullText(ctx, db.SearchReservationsFullTextParams{
+		Query:    text,
+		ViewerID: q.ViewerID,
+		Offset:   int32(q.Offset),
+		Limit:    int32(limit + 1),
+	})
+	if err != nil {
+		return SearchPage{}, err
+	}
+
+	page := SearchPage{Hits: []Hit{}}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		page.NextOffset = q.Offset + limit
+	}
+	ids := make([]uint64, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+	attendees, err := listAttendees(ctx, s.store, ids)
+	if err != nil {
+		return SearchPage{}, err
+	}
+	equipment, err := listEquipment(ctx, s.store, ids)
+	if err != nil {
+		return SearchPage{}, err
+	}
+	for _, row := range rows {
+		hit := Hit{SearchReservationsFullTextRow: row, Attendees: attendees[row.ID], Equipment: equipment[row.ID]}
+		if hit.Attendees == nil {
+			hit.Attendees = []Attendee{}
+		}
+		if hit.Equipment == nil {
+			hit.Equipment = []ReservedEquipment{}
+		}
+		page.Hits = append(page.Hits, hit)
+	}
+	return page, nil
+}
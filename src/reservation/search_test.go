@@ -0,0 +1,78 @@
+package reservation
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestServiceSearch(t *testing.T) {
+	ctx := context.Background()
+	svc, s := newTestService(t)
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+
+	if _, err := svc.Create(ctx, alice, request("輪講", 9, 10)); err != nil {
+		t.Fatal(err)
+	}
+	meeting := request("会議", 10, 11)
+	meeting.Description = "輪講の日程を決める"
+	if _, err := svc.Create(ctx, bob, meeting); err != nil {
+		t.Fatal(err)
+	}
+	for hour := 11; hour < 14; hour++ {
+		if _, err := svc.Create(ctx, alice, request("輪講の準備", hour, hour+1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	canceled, err := svc.Create(ctx, bob, request("輪講 (中止)", 14, 15))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Cancel(ctx, bob, canceled.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// タイトルに含む予約 (開始時刻の遅い順)、説明に含む予約の順に、2件ずつ返す
+	var got []string
+	q := SearchQuery{Q: " 輪講 ", ViewerID: alice.UserID, Limit: 2}
+	for pages := 1; ; pages++ {
+		page, err := svc.Search(ctx, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, hit := range page.Hits {
+			got = append(got, hit.Title)
+		}
+		if page.NextOffset == 0 {
+			if pages != 3 {
+				t.Errorf("%d ページ, want 3", pages)
+			}
+			break
+		}
+		if pages > 3 {
+			t.Fatal("ページが終わりません")
+		}
+		q.Offset = page.NextOffset
+	}
+	want := []string{"輪講の準備", "輪講の準備", "輪講の準備", "輪講", "会議"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// キャンセルした予約は予約者本人にだけ見つかる
+	page, err := svc.Search(ctx, SearchQuery{Q: "中止", ViewerID: bob.UserID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Hits) != 1 || page.Hits[0].ID != canceled.ID {
+		t.Errorf("bob: hits = %+v, want the canceled reservation", page.Hits)
+	}
+	page, err = svc.Search(ctx, SearchQuery{Q: "中止", ViewerID: alice.UserID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Hits) != 0 {
+		t.Errorf("alice: hits = %+v, want none", page.Hits)
+	}
+}
@@ -0,0 +1,611 @@
+// Package reservation は予約の作成・編集・キャンセル・一覧のルールをまとめたものです。
+// HTTP のハンドラーだけでなく、Slack や CLI など他の入口からも同じルールで予約を扱えるようにします。
+package reservation
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"yoyaku/audit"
+	"yoyaku/checkin"
+	"yoyaku/db"
+	"yoyaku/events"
+	"yoyaku/metrics"
+	"yoyaku/store"
+	"yoyaku/types"
+	"yoyaku/utils"
+)
+
+// エラーの種類。呼び出し側は errors.Is で判定し、Error() のメッセージを利用者にそのまま見せられます。
+var (
+	// ErrValidation は入力が正しくないことを表します。
+	ErrValidation = errors.New("入力が正しくありません")
+	// ErrNotFound は予約が存在しないか、他のユーザーの予約であることを表します。
+	ErrNotFound = errors.New("予約が見つかりません")
+	// ErrForbidden は操作が許可されていないことを表します。
+	ErrForbidden = errors.New("この操作は許可されていません")
+	// ErrSuspended は no-show が続いたため、一時的に新しい予約ができないことを表します。
+	ErrSuspended = errors.New("no-show が続いたため、現在は新しい予約ができません")
+	// ErrConflict は他の予約と時間帯が重なることを表します。
+	ErrConflict = errors.New("この時間帯には既に予約があります")
+)
+
+// Error は種類 (ErrValidation など) と利用者に見せるメッセージを持つエラーです。
+type Error struct {
+	Kind    error
+	Message string
+	// Field は ErrValidation の原因となった入力項目 (JSON のフィールド名) です。
+	Field string
+}
+
+func (e *Error) Error() string { return e.Message }
+func (e *Error) Unwrap() error { return e.Kind }
+
+func newError(kind error, message string) error {
+	return &Error{Kind: kind, Message: message}
+}
+
+func validate(req types.ReservationsRequest) error {
+	err := utils.ValidateReservation(req)
+	var fieldErr *utils.FieldError
+	if errors.As(err, &fieldErr) {
+		return &Error{Kind: ErrValidation, Message: fieldErr.Message, Field: fieldErr.Field}
+	}
+	return err
+}
+
+// Range は一覧を取得する期間です。Start 以降に終わり End より前に始まる予約を対象にします。
+// ゼロ値の Start / End はその側を制限しないことを表します。
+type Range struct {
+	Start time.Time
+	End   time.Time
+}
+
+// 一覧の並び順
+const (
+	// SortStartTime は開始時刻の早い順です (既定)。
+	SortStartTime = "start_time"
+	// SortStartTimeDesc は開始時刻の遅い順です。
+	SortStartTimeDesc = "-start_time"
+)
+
+// 一覧で一度に返す件数
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+)
+
+// 予約の状態 (reservations.status)
+var statuses = []string{"confirmed", "canceled", "no_show"}
+
+// Query は一覧の検索条件です。ゼロ値は「これから終わる確定済みの予約を開始時刻順に DefaultLimit 件」です。
+type Query struct {
+	// Range を省略 (Start がゼロ値) した場合は、IncludePast でなければ現在時刻以降に終わる予約だけを返します。
+	Range Range
+	// UserID が0でない場合は、そのユーザーの予約だけを返します。
+	UserID uint64
+	// MemberID が0でない場合は、そのユーザーの予約と、参加を承諾 (未定を含む) した予約だけを返します。
+	MemberID uint64
+	// Status は予約の状態 (confirmed / canceled / no_show) です。
+	// 省略した場合は確定済みの予約だけを返し、IncludeCanceled の場合は全ての状態の予約を返します。
+	Status          string
+	IncludeCanceled bool
+	IncludePast     bool
+	// Title は予約のタイトルに含まれる文字列です (英字の大文字と小文字は区別しません)。
+	Title string
+	// CreatedBy が0でない場合は、そのユーザーが作成した予約 (変更履歴の created の操作者) だけを返します。
+	CreatedBy uint64
+	// Sort は SortStartTime (既定) または SortStartTimeDesc です。
+	Sort string
+	// Limit は1から MaxLimit の件数で、0の場合は DefaultLimit です。
+	Limit int
+	// Cursor は前のページの Page.NextCursor です。
+	Cursor string
+	// ViewerID は一覧を見るユーザー (ログインしていない場合は0) です。
+	// 公開範囲により詳細を見られない予約は、タイトルを MaskedTitle にして返します。
+	ViewerID uint64
+}
+
+// Page は一覧の1ページです。
+type Page struct {
+	Items []Item
+	// NextCursor は次のページを取得するためのカーソルで、次のページが無い場合は空文字列です。
+	NextCursor string
+}
+
+// Item は一覧に表示する予約です (予約者の名前・参加者・備品付き)。
+type Item struct {
+	db.SearchReservationsRow
+	// Attendees は参加者で、いない場合や詳細を見られない場合は空のスライスです。
+	Attendees []Attendee `json:"attendees"`
+	// Equipment は借りる備品で、無い場合や詳細を見られない場合は空のスライスです。
+	Equipment []ReservedEquipment `json:"equipment"`
+}
+
+// Service は予約のルールを適用して Store に読み書きし、変更をイベントとして配信します。
+type Service struct {
+	store  store.Store
+	bus    events.Bus
+	policy checkin.Policy
+	room   Room
+}
+
+// NewService は Service を返します。
+func NewService(s store.Store, bus events.Bus, policy checkin.Policy, room Room) *Service {
+	return &Service{store: s, bus: bus, policy: policy, room: room}
+}
+
+// Detail は参加者と備品付きの予約です。
+type Detail struct {
+	db.Reservation
+	// Attendees は参加者 (予約者本人を除く、ユーザーID順) で、いない場合は空のスライスです。
+	Attendees []Attendee `json:"attendees"`
+	// Equipment は借りる備品 (備品ID順) で、無い場合は空のスライスです。
+	Equipment []ReservedEquipment `json:"equipment"`
+}
+
+// Create は actor の予約を作成します。
+// no-show が続いているユーザーは ErrSuspended、他の予約と重なる場合は ErrConflict、
+// 備品の数が足りない場合は ErrEquipmentUnavailable を返します。
+func (s *Service) Create(ctx context.Context, actor audit.Actor, req types.ReservationsRequest) (Detail, error) {
+	if err := validate(req); err != nil {
+		return Detail{}, err
+	}
+
+	// no-show が続いているユーザーは一時的に予約できない
+	suspended, err := s.policy.IsSuspended(ctx, s.store, actor.UserID)
+	if err != nil {
+		return Detail{}, err
+	}
+	if suspended {
+		return Detail{}, ErrSuspended
+	}
+
+	// 重複チェック (部屋と備品) と登録、変更履歴を同じトランザクションで行う
+	var created Detail
+	err = s.store.InTx(ctx, func(tx store.Store) error {
+		attendees, err := s.checkAttendees(ctx, tx, actor.UserID, req)
+		if err != nil {
+			return err
+		}
+		if err := checkOverlap(ctx, tx, req, 0); err != nil {
+			return err
+		}
+		if created.Equipment, err = checkEquipment(ctx, tx, req, 0); err != nil {
+			return err
+		}
+		result, err := tx.CreateReservation(ctx, db.CreateReservationParams{
+			UserID:      actor.UserID,
+			Title:       req.Title,
+			Description: req.Description,
+			Headcount:   int32(req.Headcount),
+			Visibility:  visibility(req),
+			StartTime:   req.StartTime,
+			EndTime:     req.EndTime,
+		})
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		created.Reservation, err = tx.GetReservationByID(ctx, uint64(id))
+		if err != nil {
+			return err
+		}
+		if created.Attendees, err = syncAttendees(ctx, tx, created.ID, attendees); err != nil {
+			return err
+		}
+		if err := syncEquipment(ctx, tx, created.ID, created.Equipment); err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, actor, audit.ActionCreated, created.ID, nil, &created.Reservation)
+	})
+	if err != nil {
+		return Detail{}, err
+	}
+	s.bus.Publish(events.Event{Type: events.TypeReservationCreated, Reservation: created.Reservation})
+	return created, nil
+}
+
+// Update は actor の予約のタイトル・説明・時間帯・参加予定人数・参加者・公開範囲・備品を変更します (省略した項目は空になります)。
+// 予約が無いか他のユーザーの予約の場合は ErrNotFound、他の予約と重なる場合は ErrConflict、
+// 備品の数が足りない場合は ErrEquipmentUnavailable を返します。
+func (s *Service) Update(ctx context.Context, actor audit.Actor, id uint64, req types.ReservationsRequest) (Detail, error) {
+	if err := validate(req); err != nil {
+		return Detail{}, err
+	}
+
+	var before db.Reservation
+	var previousEquipment []uint64
+	var updated Detail
+	err := s.store.InTx(ctx, func(tx store.Store) error {
+		var err error
+		before, err = lockOwned(ctx, tx, actor, id)
+		if err != nil {
+			return err
+		}
+		if before.Status != "confirmed" {
+			return newError(ErrConflict, "確定していない予約は編集できません")
+		}
+		attendees, err := s.checkAttendees(ctx, tx, actor.UserID, req)
+		if err != nil {
+			return err
+		}
+		if err := checkOverlap(ctx, tx, req, id); err != nil {
+			return err
+		}
+		if updated.Equipment, err = checkEquipment(ctx, tx, req, id); err != nil {
+			return err
+		}
+		equipment, err := listEquipment(ctx, tx, []uint64{id})
+		if err != nil {
+			return err
+		}
+		for _, e := range equipment[id] {
+			previousEquipment = append(previousEquipment, e.EquipmentID)
+		}
+		if err := tx.UpdateReservationByID(ctx, db.UpdateReservationByIDParams{
+			Title:       req.Title,
+			Description: req.Description,
+			Headcount:   int32(req.Headcount),
+			Visibility:  visibility(req),
+			StartTime:   req.StartTime,
+			EndTime:     req.EndTime,
+			ID:          id,
+		}); err != nil {
+			return err
+		}
+		updated.Reservation, err = tx.GetReservationByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if updated.Attendees, err = syncAttendees(ctx, tx, id, attendees); err != nil {
+			return err
+		}
+		if err := syncEquipment(ctx, tx, id, updated.Equipment); err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, actor, audit.ActionUpdated, id, &before, &updated.Reservation)
+	})
+	if err != nil {
+		return Detail{}, err
+	}
+	s.bus.Publish(events.Event{Type: events.TypeReservationUpdated, Reservation: updated.Reservation, Previous: &before, PreviousEquipment: previousEquipment})
+	return updated, nil
+}
+
+// Cancel は actor の予約をキャンセルします。
+// 予約が無いか他のユーザーの予約の場合は ErrNotFound、確定していない予約の場合は ErrConflict を返します。
+func (s *Service) Cancel(ctx context.Context, actor audit.Actor, id uint64) (db.Reservation, error) {
+	var canceled db.Reservation
+	err := s.store.InTx(ctx, func(tx store.Store) error {
+		before, err := lockOwned(ctx, tx, actor, id)
+		if err != nil {
+			return err
+		}
+		if before.Status != "confirmed" {
+			return newError(ErrConflict, "確定していない予約はキャンセルできません")
+		}
+		if err := tx.CanceledReservationByID(ctx, db.CanceledReservationByIDParams{
+			UserID: actor.UserID,
+			ID:     id,
+		}); err != nil {
+			return err
+		}
+		canceled, err = tx.GetReservationByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, actor, audit.ActionCanceled, id, &before, &canceled)
+	})
+	if err != nil {
+		return db.Reservation{}, err
+	}
+	s.bus.Publish(events.Event{Type: events.TypeReservationCanceled, Reservation: canceled})
+	return canceled, nil
+}
+
+// List は q の条件に合う予約を1ページ分返します。予約が無い場合も Items は nil ではなく空のスライスです。
+// 条件が正しくない場合は ErrValidation を返します。
+func (s *Service) List(ctx context.Context, q Query) (Page, error) {
+	params, desc, err := searchParams(q, time.Now())
+	if err != nil {
+		return Page{}, err
+	}
+
+	// 次のページがあるか判定するため1件多く取得する
+	limit := int(params.Limit)
+	params.Limit++
+	var rows []db.SearchReservationsRow
+	if desc {
+		descRows, err := s.store.SearchReservationsDesc(ctx, db.SearchReservationsDescParams(params))
+		if err != nil {
+			return Page{}, err
+		}
+		for _, row := range descRows {
+			rows = append(rows, db.SearchReservationsRow(row))
+		}
+	} else {
+		rows, err = s.store.SearchReservations(ctx, params)
+		if err != nil {
+			return Page{}, err
+		}
+	}
+
+	page := Page{Items: []Item{}}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		last := rows[limit-1]
+		page.NextCursor = encodeCursor(cursor{StartTime: last.StartTime, ID: last.ID, Desc: desc})
+	}
+	ids := make([]uint64, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+	attendees, err := listAttendees(ctx, s.store, ids)
+	if err != nil {
+		return Page{}, err
+	}
+	equipment, err := listEquipment(ctx, s.store, ids)
+	if err != nil {
+		return Page{}, err
+	}
+	for _, row := range rows {
+		item := Item{SearchReservationsRow: row, Attendees: attendees[row.ID], Equipment: equipment[row.ID]}
+		if !canView(row.Visibility, row.UserID, q.ViewerID, item.Attendees) {
+			item.Title = MaskedTitle
+			item.Description = ""
+			item.UserName = ""
+			item.Attendees = nil
+			item.Equipment = nil
+		}
+		if item.Attendees == nil {
+			item.Attendees = []Attendee{}
+		}
+		if item.Equipment == nil {
+			item.Equipment = []ReservedEquipment{}
+		}
+		page.Items = append(page.Items, item)
+	}
+	return page, nil
+}
+
+// searchParams は q を検索クエリのパラメータに変換します。desc は開始時刻の遅い順に並べるかどうかです。
+func searchParams(q Query, now time.Time) (params db.SearchReservationsParams, desc bool, err error) {
+	switch q.Sort {
+	case "", SortStartTime:
+	case SortStartTimeDesc:
+		desc = true
+	default:
+		return params, false, &Error{Kind: ErrValidation, Message: "並び順は start_time または -start_time で指定してください", Field: "sort"}
+	}
+
+	params.Limit = DefaultLimit
+	if q.Limit != 0 {
+		if q.Limit < 1 || q.Limit > MaxLimit {
+			return params, false, &Error{Kind: ErrValidation, Message: "件数は1から200の範囲で指定してください", Field: "limit"}
+		}
+		params.Limit = int32(q.Limit)
+	}
+
+	switch {
+	case q.Status != "":
+		if !slices.Contains(statuses, q.Status) {
+			return params, false, &Error{Kind: ErrValidation, Message: "予約の状態は confirmed、canceled、no_show のいずれかで指定してください", Field: "status"}
+		}
+		params.Status = sql.NullString{String: q.Status, Valid: true}
+	case !q.IncludeCanceled:
+		params.Status = sql.NullString{String: "confirmed", Valid: true}
+	}
+
+	start, end := q.Range.Start, q.Range.End
+	if start.IsZero() && !q.IncludePast {
+		start = now
+	}
+	if !start.IsZero() {
+		params.RangeStart = sql.NullTime{Time: start, Valid: true}
+	}
+	if !end.IsZero() {
+		if !end.After(start) {
+			return params, false, &Error{Kind: ErrValidation, Message: "期間の終了は開始より後にしてください", Field: "to"}
+		}
+		params.RangeEnd = sql.NullTime{Time: end, Valid: true}
+	}
+
+	if q.UserID != 0 {
+		params.UserID = sql.NullInt64{Int64: int64(q.UserID), Valid: true}
+	}
+	if q.MemberID != 0 {
+		params.MemberID = sql.NullInt64{Int64: int64(q.MemberID), Valid: true}
+	}
+	if q.CreatedBy != 0 {
+		params.CreatedBy = sql.NullInt64{Int64: int64(q.CreatedBy), Valid: true}
+	}
+	if title := strings.TrimSpace(q.Title); title != "" {
+		params.Title = sql.NullString{String: title, Valid: true}
+	}
+	if q.ViewerID != 0 {
+		params.ViewerID = sql.NullInt64{Int64: int64(q.ViewerID), Valid: true}
+	}
+
+	if q.Cursor != "" {
+		c, ok := decodeCursor(q.Cursor)
+		if !ok || c.Desc != desc {
+			return params, false, &Error{Kind: ErrValidation, Message: "カーソルが正しくありません", Field: "cursor"}
+		}
+		params.CursorStartTime = sql.NullTime{Time: c.StartTime, Valid: true}
+		params.CursorID = sql.NullInt64{Int64: int64(c.ID), Valid: true}
+	}
+	return params, desc, nil
+}
+
+// cursor は前のページの最後の予約です。次のページはこの予約の次 (開始時刻とIDの順) から始まります。
+type cursor struct {
+	StartTime time.Time `json:"t"`
+	ID        uint64    `json:"id"`
+	Desc      bool      `json:"desc,omitempty"`
+}
+
+// encodeCursor は c を URL にそのまま含められる文字列にします。
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (cursor, bool) {
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || json.Unmarshal(data, &c) != nil || c.ID == 0 || c.StartTime.IsZero() {
+		return cursor{}, false
+	}
+	return c, true
+}
+
+// lockOwned は actor の予約を行ロックして取得します。他のユーザーの予約は存在しないものとして扱います。
+func lockOwned(ctx context.Context, tx store.Store, actor audit.Actor, id uint64) (db.Reservation, error) {
+	reservation, err := tx.GetReservationByIDForUpdate(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && reservation.UserID != actor.UserID) {
+		return db.Reservation{}, ErrNotFound
+	}
+	return reservation, err
+}
+
+// checkOverlap は req の時間帯に確定済みの別の予約がないか確認します。
+func checkOverlap(ctx context.Context, tx store.Store, req types.ReservationsRequest, excludeID uint64) error {
+	overlapping, err := lockOverlapping(ctx, tx, req.StartTime, req.EndTime, excludeID)
+	if err != nil {
+		return err
+	}
+	if overlapping {
+		if excludeID == 0 {
+			metrics.ConflictRejected(metrics.OperationCreate)
+		} else {
+			metrics.ConflictRejected(metrics.OperationUpdate)
+		}
+		return ErrConflict
+	}
+	return nil
+}
+
+// lockOverlapping は start から end の間に確定済みの別の予約があるかを返します。
+// 同じ時間帯の予約を同時に登録しても二重に予約されないよう、部屋の行 (room_locks) をロックしてから、
+// 重なる予約の行もロックして数えます。ロックはトランザクションの終了まで保持されます。
+func lockOverlapping(ctx context.Context, tx store.Store, start, end time.Time, excludeID uint64) (bool, error) {
+	if _, err := tx.LockRoom(ctx, RoomID); err != nil {
+		return false, err
+	}
+	ids, err := tx.ListOverlappingReservationIDsForUpdate(ctx, db.ListOverlappingReservationIDsForUpdateParams{
+		RangeEnd:   end,
+		RangeStart: start,
+		ExcludeID:  excludeID,
+	})
+	return len(ids) > 0, err
+}
+
+// checkAttendees は参加者と参加予定人数が部屋の定員に収まるか検証し、参加者を返します。
+// 予約者本人と重複したIDは取り除きます。存在しないユーザーが含まれる場合は ErrValidation を返します。
+func (s *Service) checkAttendees(ctx context.Context, tx store.Store, ownerID uint64, req types.ReservationsRequest) ([]Attendee, error) {
+	ids := slices.Clone(req.Attendees)
+	slices.Sort(ids)
+	ids = slices.DeleteFunc(slices.Compact(ids), func(id uint64) bool { return id == ownerID })
+
+	if req.Headcount > s.room.Capacity {
+		return nil, &Error{Kind: ErrValidation, Message: "参加予定人数は部屋の定員以内で入力してください", Field: "headcount"}
+	}
+	// 予約者本人も部屋を使うため、参加者に1人を加えて数える
+	if len(ids)+1 > s.room.Capacity {
+		return nil, &Error{Kind: ErrValidation, Message: "参加者が部屋の定員を超えています", Field: "attendees"}
+	}
+	if req.Headcount != 0 && req.Headcount < len(ids)+1 {
+		return nil, &Error{Kind: ErrValidation, Message: "参加予定人数は予約者と参加者の合計以上にしてください", Field: "headcount"}
+	}
+
+	attendees := make([]Attendee, 0, len(ids))
+	for _, id := range ids {
+		user, err := tx.GetUserByID(ctx, id)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && user.DeletedAt.Valid) {
+			return nil, &Error{Kind: ErrValidation, Message: "参加者に存在しないユーザーが含まれています", Field: "attendees"}
+		}
+		if err != nil {
+			return nil, err
+		}
+		attendees = append(attendees, Attendee{UserID: user.ID, Name: user.Name})
+	}
+	return attendees, nil
+}
+
+// syncAttendees は予約の参加者を attendees に合わせ、返答付きの参加者を返します。
+// 引き続き参加者になっているユーザーの返答はそのまま残します。
+func syncAttendees(ctx context.Context, tx store.Store, reservationID uint64, attendees []Attendee) ([]Attendee, error) {
+	current, err := listAttendees(ctx, tx, []uint64{reservationID})
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range current[reservationID] {
+		if slices.ContainsFunc(attendees, func(b Attendee) bool { return b.UserID == a.UserID }) {
+			continue
+		}
+		if err := tx.DeleteReservationAttendee(ctx, db.DeleteReservationAttendeeParams{
+			ReservationID: reservationID,
+			UserID:        a.UserID,
+		}); err != nil {
+			return nil, err
+		}
+	}
+	for _, a := range attendees {
+		if slices.ContainsFunc(current[reservationID], func(b Attendee) bool { return b.UserID == a.UserID }) {
+			continue
+		}
+		if err := tx.AddReservationAttendee(ctx, db.AddReservationAttendeeParams{
+			ReservationID: reservationID,
+			UserID:        a.UserID,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	synced, err := listAttendees(ctx, tx, []uint64{reservationID})
+	if err != nil {
+		return nil, err
+	}
+	if synced[reservationID] == nil {
+		return []Attendee{}, nil
+	}
+	return synced[reservationID], nil
+}
+
+// visibility はリクエストの公開範囲を返します。省略した場合は VisibilityPublic です。
+func visibility(req types.ReservationsRequest) string {
+	if req.Visibility == "" {
+		return VisibilityPublic
+	}
+	return req.Visibility
+}
+
+// listAttendees は予約ごとの参加者を返します。
+func listAttendees(ctx context.Context, st store.ReservationStore, ids []uint64) (map[uint64][]Attendee, error) {
+	attendees := make(map[uint64][]Attendee, len(ids))
+	if len(ids) == 0 {
+		return attendees, nil
+	}
+	rows, err := st.ListReservationAttendees(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		attendees[row.ReservationID] = append(attendees[row.ReservationID], Attendee{
+			UserID:   row.UserID,
+			Name:     row.Name,
+			Response: row.Response,
+		})
+	}
+	return attendees, nil
+}
@@ -0,0 +1,949 @@
+package reservation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"yoyaku/audit"
+	"yoyaku/checkin"
+	"yoyaku/db"
+	"yoyaku/events"
+	"yoyaku/store"
+	"yoyaku/types"
+)
+
+func newTestService(t *testing.T) (*Service, *store.Memory) {
+	t.Helper()
+	s := store.NewMemory()
+	return NewService(s, events.NewMemoryBus(16), checkin.DefaultPolicy(), DefaultRoom()), s
+}
+
+func createUser(t *testing.T, s *store.Memory, name string) audit.Actor {
+	t.Helper()
+	result, err := s.CreateUser(context.Background(), db.CreateUserParams{
+		Name:     name,
+		Email:    name + "@example.com",
+		GoogleID: "google-" + name,
+		Role:     "user",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := result.LastInsertId()
+	return audit.Actor{UserID: uint64(id), Method: audit.MethodSession}
+}
+
+func at(hour int) time.Time {
+	return time.Date(2030, 1, 1, hour, 0, 0, 0, time.UTC)
+}
+
+func request(title string, start, end int) types.ReservationsRequest {
+	return types.ReservationsRequest{Title: title, StartTime: at(start), EndTime: at(end)}
+}
+
+func TestServiceErrors(t *testing.T) {
+	ctx := context.Background()
+	svc, s := newTestService(t)
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+
+	first, err := svc.Create(ctx, alice, request("輪講", 10, 12))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := svc.Create(ctx, alice, request("ゼミ", 13, 14))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		run  func() error
+		want error
+	}{
+		{"create with empty title", func() error {
+			_, err := svc.Create(ctx, bob, request(" ", 15, 16))
+			return err
+		}, ErrValidation},
+		{"create overlapping", func() error {
+			_, err := svc.Create(ctx, bob, request("会議", 11, 13))
+			return err
+		}, ErrConflict},
+		{"update into another reservation", func() error {
+			_, err := svc.Update(ctx, alice, second.ID, request("ゼミ", 11, 14))
+			return err
+		}, ErrConflict},
+		{"update other user's reservation", func() error {
+			_, err := svc.Update(ctx, bob, first.ID, request("乗っ取り", 10, 12))
+			return err
+		}, ErrNotFound},
+		{"update with end before start", func() error {
+			_, err := svc.Update(ctx, alice, first.ID, request("輪講", 12, 10))
+			return err
+		}, ErrValidation},
+		{"cancel other user's reservation", func() error {
+			_, err := svc.Cancel(ctx, bob, first.ID)
+			return err
+		}, ErrNotFound},
+		{"cancel missing reservation", func() error {
+			_, err := svc.Cancel(ctx, alice, 999)
+			return err
+		}, ErrNotFound},
+		{"list with unknown status", func() error {
+			_, err := svc.List(ctx, Query{Status: "deleted"})
+			return err
+		}, ErrValidation},
+		{"list with malformed cursor", func() error {
+			_, err := svc.List(ctx, Query{Cursor: "not-a-cursor"})
+			return err
+		}, ErrValidation},
+		{"list with cursor of another sort", func() error {
+			_, err := svc.List(ctx, Query{Cursor: encodeCursor(cursor{StartTime: at(10), ID: 1}), Sort: SortStartTimeDesc})
+			return err
+		}, ErrValidation},
+		{"create with long description", func() error {
+			req := request("会議", 15, 16)
+			req.Description = strings.Repeat("あ", 2001)
+			_, err := svc.Create(ctx, bob, req)
+			return err
+		}, ErrValidation},
+		{"search without query", func() error {
+			_, err := svc.Search(ctx, SearchQuery{Q: " ", ViewerID: alice.UserID})
+			return err
+		}, ErrValidation},
+		{"search with negative offset", func() error {
+			_, err := svc.Search(ctx, SearchQuery{Q: "輪講", ViewerID: alice.UserID, Offset: -1})
+			return err
+		}, ErrValidation},
+		{"create over capacity", func() error {
+			req := request("会議", 15, 16)
+			req.Headcount = DefaultCapacity + 1
+			_, err := svc.Create(ctx, bob, req)
+			return err
+		}, ErrValidation},
+		{"create with headcount below attendees", func() error {
+			req := request("会議", 15, 16)
+			req.Headcount = 1
+			req.Attendees = []uint64{alice.UserID}
+			_, err := svc.Create(ctx, bob, req)
+			return err
+		}, ErrValidation},
+		{"create with unknown attendee", func() error {
+			req := request("会議", 15, 16)
+			req.Attendees = []uint64{999}
+			_, err := svc.Create(ctx, bob, req)
+			return err
+		}, ErrValidation},
+		{"create with unknown visibility", func() error {
+			req := request("会議", 15, 16)
+			req.Visibility = "secret"
+			_, err := svc.Create(ctx, bob, req)
+			return err
+		}, ErrValidation},
+		{"list with reversed range", func() error {
+			_, err := svc.List(ctx, Query{Range: Range{Start: at(12), End: at(10)}})
+			return err
+		}, ErrValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.run(); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// 自分自身とは重ならないので、時間帯を少しずらす編集はできる
+	if _, err := svc.Update(ctx, alice, first.ID, request("輪講", 9, 11)); err != nil {
+		t.Errorf("Update = %v", err)
+	}
+	if events := s.Events(); len(events) != 3 {
+		t.Errorf("失敗した操作の変更履歴が記録されました: %+v", events)
+	}
+}
+
+func TestServiceList(t *testing.T) {
+	ctx := context.Background()
+	svc, s := newTestService(t)
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+
+	for _, r := range []struct {
+		actor      audit.Actor
+		title      string
+		start, end int
+	}{
+		{alice, "午後 Seminar", 14, 15},
+		{bob, "昼", 12, 13},
+		{alice, "午前", 9, 10},
+		{bob, "夕方", 17, 18},
+	} {
+		if _, err := svc.Create(ctx, r.actor, request(r.title, r.start, r.end)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	evening := mustList(t, svc, Query{Title: "夕方"}).Items[0]
+	if _, err := svc.Cancel(ctx, bob, evening.ID); err != nil {
+		t.Fatal(err)
+	}
+	// 終了した予約は Service からは作れないため Store に直接登録する
+	past := time.Date(2000, 1, 1, 10, 0, 0, 0, time.UTC)
+	if _, err := s.CreateReservation(ctx, db.CreateReservationParams{UserID: alice.UserID, Title: "昔", StartTime: past, EndTime: past.Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+	}{
+		{"upcoming", Query{}, []string{"午前", "昼", "午後 Seminar"}},
+		{"range", Query{Range: Range{Start: at(11), End: at(14)}}, []string{"昼"}},
+		{"user", Query{UserID: alice.UserID}, []string{"午前", "午後 Seminar"}},
+		{"user including past", Query{UserID: alice.UserID, IncludePast: true}, []string{"昔", "午前", "午後 Seminar"}},
+		{"title ignores case", Query{Title: "seminar"}, []string{"午後 Seminar"}},
+		{"created by", Query{CreatedBy: bob.UserID}, []string{"昼"}},
+		{"including canceled", Query{IncludeCanceled: true}, []string{"午前", "昼", "午後 Seminar", "夕方"}},
+		{"status", Query{Status: "canceled"}, []string{"夕方"}},
+		{"descending", Query{Sort: SortStartTimeDesc}, []string{"午後 Seminar", "昼", "午前"}},
+		{"empty", Query{Range: Range{Start: at(20), End: at(23)}}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := mustList(t, svc, tt.query)
+			if page.Items == nil {
+				t.Fatal("nil が返されました")
+			}
+			if got := titles(page.Items); !slices.Equal(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			if page.NextCursor != "" {
+				t.Errorf("NextCursor = %q, want 空", page.NextCursor)
+			}
+		})
+	}
+}
+
+func TestServiceListPages(t *testing.T) {
+	ctx := context.Background()
+	svc, s := newTestService(t)
+	alice := createUser(t, s, "alice")
+
+	var want []string
+	for hour := 8; hour < 13; hour++ {
+		title := fmt.Sprintf("%d時", hour)
+		if _, err := svc.Create(ctx, alice, request(title, hour, hour+1)); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, title)
+	}
+
+	for _, sort := range []string{SortStartTime, SortStartTimeDesc} {
+		t.Run(sort, func(t *testing.T) {
+			var got []string
+			q := Query{Sort: sort, Limit: 2}
+			for pages := 1; ; pages++ {
+				page := mustList(t, svc, q)
+				got = append(got, titles(page.Items)...)
+				if page.NextCursor == "" {
+					if pages != 3 {
+						t.Errorf("%d ページ, want 3", pages)
+					}
+					break
+				}
+				if pages > 3 {
+					t.Fatal("ページが終わりません")
+				}
+				q.Cursor = page.NextCursor
+			}
+
+			expected := slices.Clone(want)
+			if sort == SortStartTimeDesc {
+				slices.Reverse(expected)
+			}
+			if !slices.Equal(got, expected) {
+				t.Errorf("got %v, want %v", got, expected)
+			}
+		})
+	}
+}
+
+func TestServiceVisibility(t *testing.T) {
+	ctx := context.Background()
+	svc, s := newTestService(t)
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+	carol := createUser(t, s, "carol")
+
+	for _, r := range []struct {
+		title      string
+		visibility string
+		start, end int
+	}{
+		{"公開", VisibilityPublic, 9, 10},
+		{"メンバー", VisibilityMembers, 10, 11},
+		{"非公開", VisibilityPrivate, 11, 12},
+	} {
+		req := request(r.title, r.start, r.end)
+		req.Description = r.title + "の説明"
+		req.Headcount = 3
+		// 予約者本人と重複した参加者は取り除かれる
+		req.Attendees = []uint64{carol.UserID, alice.UserID, carol.UserID}
+		req.Visibility = r.visibility
+		created, err := svc.Create(ctx, alice, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(created.Attendees) != 1 || created.Attendees[0] != (Attendee{UserID: carol.UserID, Name: "carol", Response: ResponseNeedsAction}) {
+			t.Errorf("Attendees = %+v, want [carol]", created.Attendees)
+		}
+		if created.Headcount != 3 || created.Visibility != r.visibility {
+			t.Errorf("headcount, visibility = %d, %q, want 3, %q", created.Headcount, created.Visibility, r.visibility)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		viewerID uint64
+		want     []string
+	}{
+		{"anonymous", 0, []string{"公開", MaskedTitle, MaskedTitle}},
+		{"member", bob.UserID, []string{"公開", "メンバー", MaskedTitle}},
+		{"owner", alice.UserID, []string{"公開", "メンバー", "非公開"}},
+		{"attendee", carol.UserID, []string{"公開", "メンバー", "非公開"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := mustList(t, svc, Query{ViewerID: tt.viewerID})
+			if got := titles(page.Items); !slices.Equal(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for _, item := range page.Items {
+				if item.Title != MaskedTitle {
+					if len(item.Attendees) != 1 || item.UserName != "alice" {
+						t.Errorf("%s: attendees, user_name = %+v, %q", item.Title, item.Attendees, item.UserName)
+					}
+					continue
+				}
+				// 時間帯と予約者のID以外は伏せる
+				if item.Description != "" || item.UserName != "" || len(item.Attendees) != 0 || item.Attendees == nil || !item.UpdatedAt.Equal(item.CreatedAt) {
+					t.Errorf("伏せた予約に詳細が含まれています: %+v", item)
+				}
+				if item.UserID != alice.UserID {
+					t.Errorf("UserID = %d, want %d", item.UserID, alice.UserID)
+				}
+			}
+		})
+	}
+
+	// 編集すると参加者を置き換える
+	private := mustList(t, svc, Query{ViewerID: alice.UserID}).Items[2]
+	req := request("非公開", 11, 12)
+	req.Visibility = VisibilityPrivate
+	req.Attendees = []uint64{bob.UserID}
+	if _, err := svc.Update(ctx, alice, private.ID, req); err != nil {
+		t.Fatal(err)
+	}
+	if got := titles(mustList(t, svc, Query{ViewerID: carol.UserID}).Items); got[2] != MaskedTitle {
+		t.Errorf("参加者から外した carol に %q が見えます", got[2])
+	}
+	if got := titles(mustList(t, svc, Query{ViewerID: bob.UserID}).Items); got[2] != "非公開" {
+		t.Errorf("参加者に加えた bob に %q と表示されます", got[2])
+	}
+}
+
+func mustList(t *testing.T, svc *Service, q Query) Page {
+	t.Helper()
+	page, err := svc.List(context.Background(), q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return page
+}
+
+func titles(items []Item) []string {
+	got := []string{}
+	for _, item := range items {
+		got = append(got, item.Title)
+	}
+	return got
+}
+
+func TestServiceInvitations(t *testing.T) {
+	ctx := context.Background()
+	svc, s := newTestService(t)
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+	carol := createUser(t, s, "carol")
+
+	req := request("輪講", 10, 12)
+	req.Attendees = []uint64{bob.UserID, carol.UserID}
+	seminar, err := svc.Create(ctx, alice, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Create(ctx, bob, request("面談", 13, 14)); err != nil {
+		t.Fatal(err)
+	}
+
+	invitations, err := svc.Invitations(ctx, bob.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invitations) != 1 || invitations[0].ID != seminar.ID || invitations[0].Response != ResponseNeedsAction || invitations[0].UserName != "alice" {
+		t.Fatalf("Invitations = %+v, want 未回答の輪講", invitations)
+	}
+	// 未回答の招待は自分の予約一覧に含めない
+	if got := titles(mustList(t, svc, Query{MemberID: bob.UserID}).Items); !slices.Equal(got, []string{"面談"}) {
+		t.Errorf("返答前の一覧 = %v, want [面談]", got)
+	}
+
+	responded, err := svc.Respond(ctx, bob, seminar.ID, ResponseAccepted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if responded.Attendees[0] != (Attendee{UserID: bob.UserID, Name: "bob", Response: ResponseAccepted}) {
+		t.Errorf("Attendees = %+v, want bob が accepted", responded.Attendees)
+	}
+	history := s.Events()
+	if last := history[len(history)-1]; last.Action != audit.ActionResponded || last.ActorUserID.Int64 != int64(bob.UserID) {
+		t.Errorf("返答の変更履歴 = %+v, want bob の responded", last)
+	} else {
+		var before, after db.ListReservationAttendeesRow
+		if err := json.Unmarshal(last.BeforeData, &before); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(last.AfterData, &after); err != nil {
+			t.Fatal(err)
+		}
+		if before.UserID != bob.UserID || before.Response != ResponseNeedsAction || before.RespondedAt.Valid {
+			t.Errorf("返答前 = %+v, want bob の needs-action", before)
+		}
+		if after.UserID != bob.UserID || after.Response != ResponseAccepted || !after.RespondedAt.Valid {
+			t.Errorf("返答後 = %+v, want bob の accepted", after)
+		}
+	}
+	if got := titles(mustList(t, svc, Query{MemberID: bob.UserID}).Items); !slices.Equal(got, []string{"輪講", "面談"}) {
+		t.Errorf("承諾後の一覧 = %v, want [輪講 面談]", got)
+	}
+	if _, err := svc.Respond(ctx, carol, seminar.ID, ResponseDeclined); err != nil {
+		t.Fatal(err)
+	}
+	if got := titles(mustList(t, svc, Query{MemberID: carol.UserID}).Items); !slices.Equal(got, []string{}) {
+		t.Errorf("辞退後の一覧 = %v, want []", got)
+	}
+
+	// 編集しても、引き続き参加者になっているユーザーの返答は残る
+	req.Attendees = []uint64{bob.UserID}
+	updated, err := svc.Update(ctx, alice, seminar.ID, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updated.Attendees) != 1 || updated.Attendees[0].Response != ResponseAccepted {
+		t.Errorf("編集後の Attendees = %+v, want bob (accepted) だけ", updated.Attendees)
+	}
+
+	tests := []struct {
+		name     string
+		actor    audit.Actor
+		id       uint64
+		response string
+		want     error
+	}{
+		{"unknown response", bob, seminar.ID, ResponseNeedsAction, ErrValidation},
+		{"not invited", carol, seminar.ID, ResponseAccepted, ErrNotFound},
+		{"owner", alice, seminar.ID, ResponseAccepted, ErrNotFound},
+		{"missing reservation", bob, 999, ResponseAccepted, ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := svc.Respond(ctx, tt.actor, tt.id, tt.response); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := svc.Cancel(ctx, alice, seminar.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Respond(ctx, bob, seminar.ID, ResponseTentative); !errors.Is(err, ErrConflict) {
+		t.Errorf("キャンセルした予約への返答: err = %v, want %v", err, ErrConflict)
+	}
+	if invitations, _ := svc.Invitations(ctx, bob.UserID); len(invitations) != 0 {
+		t.Errorf("キャンセルした予約が招待に残っています: %+v", invitations)
+	}
+}
+
+func TestServiceEquipment(t *testing.T) {
+	ctx := context.Background()
+	svc, s := newTestService(t)
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+
+	projector, err := svc.CreateEquipment(ctx, types.EquipmentRequest{Name: " プロジェクター ", Quantity: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if projector.Name != "プロジェクター" {
+		t.Errorf("Name = %q, want 前後の空白を取り除いた名前", projector.Name)
+	}
+	markers, err := svc.CreateEquipment(ctx, types.EquipmentRequest{Name: "ホワイトボードマーカー", Quantity: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := request("輪講", 10, 12)
+	req.Equipment = []types.ReservationEquipmentRequest{{EquipmentID: markers.ID, Quantity: 2}, {EquipmentID: projector.ID, Quantity: 1}}
+	seminar, err := svc.Create(ctx, alice, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ReservedEquipment{{projector.ID, "プロジェクター", 1}, {markers.ID, "ホワイトボードマーカー", 2}}
+	if !slices.Equal(seminar.Equipment, want) {
+		t.Errorf("Equipment = %+v, want %+v", seminar.Equipment, want)
+	}
+	// 自分の予約の分は除いて確認するため、編集で全ての数を借りられる
+	req.Equipment = []types.ReservationEquipmentRequest{{EquipmentID: projector.ID, Quantity: 2}}
+	updated, err := svc.Update(ctx, alice, seminar.ID, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updated.Equipment) != 1 || updated.Equipment[0].Quantity != 2 {
+		t.Errorf("編集後の Equipment = %+v, want プロジェクター2台だけ", updated.Equipment)
+	}
+	if page := mustList(t, svc, Query{}); len(page.Items) != 1 || !slices.Equal(page.Items[0].Equipment, updated.Equipment) {
+		t.Errorf("一覧の Equipment = %+v, want %+v", page.Items, updated.Equipment)
+	}
+
+	// 直後の時間帯は別に借りられる
+	next := request("会議", 12, 13)
+	next.Equipment = []types.ReservationEquipmentRequest{{EquipmentID: projector.ID, Quantity: 2}}
+	if _, err := svc.Create(ctx, bob, next); err != nil {
+		t.Fatalf("直後の時間帯の予約: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		equipment []types.ReservationEquipmentRequest
+		want      error
+	}{
+		{"more than quantity", []types.ReservationEquipmentRequest{{EquipmentID: markers.ID, Quantity: 5}}, ErrEquipmentUnavailable},
+		{"zero quantity", []types.ReservationEquipmentRequest{{EquipmentID: markers.ID, Quantity: 0}}, ErrValidation},
+		{"duplicate", []types.ReservationEquipmentRequest{{EquipmentID: markers.ID, Quantity: 1}, {EquipmentID: markers.ID, Quantity: 1}}, ErrValidation},
+		{"unknown", []types.ReservationEquipmentRequest{{EquipmentID: 999, Quantity: 1}}, ErrValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := request("ゼミ", 14, 15)
+			req.Equipment = tt.equipment
+			if _, err := svc.Create(ctx, bob, req); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// 部屋の重複チェックを通らない予約 (取り込んだ予定など) があっても、同時に借りている数の最大値で数える
+	for _, r := range []struct{ start, end int }{{16, 18}, {17, 19}, {19, 20}} {
+		result, err := s.CreateReservation(ctx, db.CreateReservationParams{
+			UserID: bob.UserID, Title: "取り込んだ予定", StartTime: at(r.start), EndTime: at(r.end), Visibility: VisibilityPublic,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, _ := result.LastInsertId()
+		if err := s.AddReservationEquipment(ctx, db.AddReservationEquipmentParams{ReservationID: uint64(id), EquipmentID: projector.ID, Quantity: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	available := func(start, end int) int32 {
+		t.Helper()
+		equipment, err := svc.ListEquipment(ctx, Range{Start: at(start), End: at(end)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return equipment[0].Available
+	}
+	for _, tt := range []struct {
+		start, end int
+		want       int32
+	}{
+		{10, 12, 0}, {15, 16, 2}, {16, 17, 1}, {16, 20, 0}, {18, 20, 1},
+	} {
+		if got := available(tt.start, tt.end); got != tt.want {
+			t.Errorf("%d時〜%d時のプロジェクターの空き = %d, want %d", tt.start, tt.end, got, tt.want)
+		}
+	}
+	if equipment, err := svc.ListEquipment(ctx, Range{}); err != nil || equipment[0].Available != 2 {
+		t.Errorf("時間帯を指定しない ListEquipment = %+v, %v, want 数量と同じ", equipment, err)
+	}
+
+	if _, err := svc.CreateEquipment(ctx, types.EquipmentRequest{Name: "プロジェクター", Quantity: 1}); !errors.Is(err, ErrValidation) {
+		t.Errorf("同じ名前の備品: err = %v, want %v", err, ErrValidation)
+	}
+	if _, err := svc.UpdateEquipment(ctx, 999, types.EquipmentRequest{Name: "スピーカー", Quantity: 1}); !errors.Is(err, ErrEquipmentNotFound) {
+		t.Errorf("存在しない備品の変更: err = %v, want %v", err, ErrEquipmentNotFound)
+	}
+	if renamed, err := svc.UpdateEquipment(ctx, markers.ID, types.EquipmentRequest{Name: "マーカー", Quantity: 6}); err != nil || renamed.Name != "マーカー" || renamed.Quantity != 6 {
+		t.Errorf("UpdateEquipment = %+v, %v", renamed, err)
+	}
+}
+
+// 同じ時間帯の予約を同時に登録した場合、1件だけが登録され、残りは ErrConflict になることを確認します。
+func TestServiceConcurrentCreate(t *testing.T) {
+	backends := []struct {
+		name string
+		open func(t *testing.T) store.Store
+	}{
+		{"memory", func(t *testing.T) store.Store { return store.NewMemory() }},
+		{"sqlite", func(t *testing.T) store.Store {
+			backend, err := store.Open("sqlite:" + filepath.Join(t.TempDir(), "test.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { backend.Close() })
+			migrator, err := backend.Migrator()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := migrator.Up(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			return backend.Store
+		}},
+	}
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := backend.open(t)
+			svc := NewService(s, events.NewMemoryBus(16), checkin.DefaultPolicy(), DefaultRoom())
+
+			const workers = 8
+			actors := make([]audit.Actor, workers)
+			for i := range actors {
+				result, err := s.CreateUser(ctx, db.CreateUserParams{
+					Name:     fmt.Sprintf("user%d", i),
+					Email:    fmt.Sprintf("user%d@example.com", i),
+					GoogleID: fmt.Sprintf("google-%d", i),
+					Role:     "user",
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				id, _ := result.LastInsertId()
+				actors[i] = audit.Actor{UserID: uint64(id), Method: audit.MethodSession}
+			}
+
+			var wg sync.WaitGroup
+			errs := make([]error, workers)
+			for i := range workers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					// 開始・終了をずらして、どの2件も重なるようにする
+					req := request(fmt.Sprintf("予約%d", i), 10, 12)
+					req.StartTime = req.StartTime.Add(time.Duration(i) * time.Minute)
+					_, errs[i] = svc.Create(ctx, actors[i], req)
+				}()
+			}
+			wg.Wait()
+
+			created := 0
+			for _, err := range errs {
+				switch {
+				case err == nil:
+					created++
+				case !errors.Is(err, ErrConflict):
+					t.Errorf("Create = %v, want nil or ErrConflict", err)
+				}
+			}
+			if created != 1 {
+				t.Errorf("登録された予約 = %d件, want 1", created)
+			}
+		})
+	}
+}
+
+func TestServiceUsage(t *testing.T) {
+	ctx := context.Background()
+	svc, s := newTestService(t)
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+
+	// 30分前に始まった利用中の予約と、その1時間後に始まる次の予約
+	now := time.Now().Truncate(time.Second)
+	ongoing, err := svc.Create(ctx, alice, types.ReservationsRequest{Title: "輪講", StartTime: now.Add(-30 * time.Minute), EndTime: now.Add(30 * time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Create(ctx, bob, types.ReservationsRequest{Title: "ゼミ", StartTime: now.Add(90 * time.Minute), EndTime: now.Add(150 * time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		run  func() error
+		want error
+	}{
+		{"extend other's", func() error {
+			_, err := svc.Extend(ctx, bob, ongoing.ID, 30)
+			return err
+		}, ErrNotFound},
+		{"extend by 0 minutes", func() error {
+			_, err := svc.Extend(ctx, alice, ongoing.ID, 0)
+			return err
+		}, ErrValidation},
+		{"extend into next reservation", func() error {
+			_, err := svc.Extend(ctx, alice, ongoing.ID, 61)
+			return err
+		}, ErrConflict},
+		{"end other's", func() error {
+			_, err := svc.EndNow(ctx, bob, ongoing.ID)
+			return err
+		}, ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.run(); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// 延長した時間帯にも借りている備品の数を確認する (備品の数を減らした後は、減らした数で確認する)
+	markers, err := svc.CreateEquipment(ctx, types.EquipmentRequest{Name: "マーカー", Quantity: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	withMarkers := types.ReservationsRequest{Title: "輪講", StartTime: ongoing.StartTime, EndTime: ongoing.EndTime,
+		Equipment: []types.ReservationEquipmentRequest{{EquipmentID: markers.ID, Quantity: 2}}}
+	if _, err := svc.Update(ctx, alice, ongoing.ID, withMarkers); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.UpdateEquipment(ctx, markers.ID, types.EquipmentRequest{Name: "マーカー", Quantity: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Extend(ctx, alice, ongoing.ID, 10); !errors.Is(err, ErrEquipmentUnavailable) {
+		t.Errorf("備品が足りない延長 err = %v, want ErrEquipmentUnavailable", err)
+	}
+	if _, err := svc.UpdateEquipment(ctx, markers.ID, types.EquipmentRequest{Name: "マーカー", Quantity: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	extended, err := svc.Extend(ctx, alice, ongoing.ID, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !extended.EndTime.Equal(ongoing.EndTime.Add(time.Hour)) || !extended.BookedEndTime.Time.Equal(ongoing.EndTime) {
+		t.Errorf("延長後 end_time = %v, booked_end_time = %v", extended.EndTime, extended.BookedEndTime)
+	}
+
+	ended, err := svc.EndNow(ctx, alice, ongoing.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ended.EndTime.After(time.Now()) || !ended.ActualEndTime.Valid {
+		t.Errorf("終了後 end_time = %v, actual_end_time = %v", ended.EndTime, ended.ActualEndTime)
+	}
+	if _, err := svc.EndNow(ctx, alice, ongoing.ID); !errors.Is(err, ErrNotOngoing) {
+		t.Errorf("終了済みの予約を終了 err = %v, want ErrNotOngoing", err)
+	}
+}
+
+// 開始と同じ秒に終了すると終了時刻が開始時刻と同じになるため、ErrNotOngoing にします。
+func TestServiceEndInFirstSecond(t *testing.T) {
+	ctx := context.Background()
+	svc, s := newTestService(t)
+	alice := createUser(t, s, "alice")
+
+	start := time.Now().Add(time.Minute).Truncate(time.Second)
+	r, err := svc.Create(ctx, alice, types.ReservationsRequest{Title: "輪講", StartTime: start, EndTime: start.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.endAt(ctx, alice, r.ID, start.Add(500*time.Millisecond)); !errors.Is(err, ErrNotOngoing) {
+		t.Errorf("開始から0.5秒後に終了 err = %v, want ErrNotOngoing", err)
+	}
+	ended, err := svc.endAt(ctx, alice, r.ID, start.Add(1500*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := start.Add(time.Second); !ended.EndTime.Equal(want) {
+		t.Errorf("EndTime = %v, want %v", ended.EndTime, want)
+	}
+}
+
+func TestServiceCheckIn(t *testing.T) {
+	ctx := context.Background()
+	svc, s := newTestService(t)
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+
+	now := time.Now()
+	r, err := svc.Create(ctx, alice, types.ReservationsRequest{Title: "輪講", StartTime: now.Add(5 * time.Minute), EndTime: now.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.CheckIn(ctx, bob, r.ID); !errors.Is(err, ErrForbidden) {
+		t.Errorf("他のユーザーの予約にチェックイン err = %v, want ErrForbidden", err)
+	}
+	if _, err := svc.CheckinToken(ctx, bob, r.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("他のユーザーの予約のトークン err = %v, want ErrNotFound", err)
+	}
+
+	token, err := svc.CheckinToken(ctx, alice, r.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, err := svc.CheckinToken(ctx, alice, r.ID); err != nil || again != token {
+		t.Errorf("2回目のトークン = %q, %v, want 同じトークン", again, err)
+	}
+	// QRコードからは予約者以外もチェックインできる
+	checkedIn, err := svc.CheckInWithToken(ctx, bob, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !checkedIn.CheckedInAt.Valid {
+		t.Error("チェックインされていません")
+	}
+	if again, err := svc.CheckIn(ctx, alice, r.ID); err != nil || !again.CheckedInAt.Time.Equal(checkedIn.CheckedInAt.Time) {
+		t.Errorf("チェックイン済みの予約にチェックイン = %+v, %v", again, err)
+	}
+	if _, err := svc.CheckInWithToken(ctx, bob, "unknown"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("存在しないトークン err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestServiceCancelNoShow(t *testing.T) {
+	ctx := context.Background()
+	svc, s := newTestService(t)
+	alice := createUser(t, s, "alice")
+
+	var noShow db.Reservation
+	for i := range 3 {
+		r, err := svc.Create(ctx, alice, request("輪講", 10+i, 11+i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.MarkReservationNoShow(ctx, r.ID); err != nil {
+			t.Fatal(err)
+		}
+		noShow = r.Reservation
+	}
+	if _, err := svc.Create(ctx, alice, request("ゼミ", 15, 16)); !errors.Is(err, ErrSuspended) {
+		t.Fatalf("no-show が3回の予約 err = %v, want ErrSuspended", err)
+	}
+
+	// キャンセルしても no-show の回数は減らず、停止も解除されない
+	if _, err := svc.Cancel(ctx, alice, noShow.ID); !errors.Is(err, ErrConflict) {
+		t.Errorf("no-show の予約をキャンセル err = %v, want ErrConflict", err)
+	}
+	if _, err := svc.CancelFromCalendar(ctx, alice, noShow.ID); !errors.Is(err, ErrConflict) {
+		t.Errorf("no-show の予約をカレンダーからキャンセル err = %v, want ErrConflict", err)
+	}
+	if got, err := s.GetReservationByID(ctx, noShow.ID); err != nil || got.Status != "no_show" {
+		t.Errorf("キャンセル後の予約 = %+v, %v, want no_show のまま", got, err)
+	}
+	if _, err := svc.Create(ctx, alice, request("ゼミ", 15, 16)); !errors.Is(err, ErrSuspended) {
+		t.Errorf("キャンセル後の予約 err = %v, want ErrSuspended", err)
+	}
+	if events := s.Events(); len(events) != 3 {
+		t.Errorf("監査ログ = %d件, want 作成の3件", len(events))
+	}
+}
+
+func TestServiceCalendar(t *testing.T) {
+	ctx := context.Background()
+	svc, s := newTestService(t)
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+
+	projector, err := svc.CreateEquipment(ctx, types.EquipmentRequest{Name: "プロジェクター", Quantity: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	withProjector := request("ゼミ", 13, 14)
+	withProjector.Equipment = []types.ReservationEquipmentRequest{{EquipmentID: projector.ID, Quantity: 1}}
+	if _, err := svc.Create(ctx, bob, withProjector); err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := svc.CreateFromCalendar(ctx, alice, CalendarEvent{Title: "輪講", StartTime: at(10), EndTime: at(11), UID: "abc", Name: "abc.ics"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Origin != "caldav" || created.CaldavName.String != "abc.ics" || created.IcalUid.String != "abc" {
+		t.Errorf("CreateFromCalendar = %+v", created)
+	}
+	// カレンダーから作成した予約に備品を追加しておく
+	withEquipment := request("輪講", 10, 11)
+	withEquipment.Equipment = []types.ReservationEquipmentRequest{{EquipmentID: projector.ID, Quantity: 1}}
+	if _, err := svc.Update(ctx, alice, created.ID, withEquipment); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		run  func() error
+		want error
+	}{
+		{"create with empty title", func() error {
+			_, err := svc.CreateFromCalendar(ctx, alice, CalendarEvent{Title: " ", StartTime: at(15), EndTime: at(16), Name: "x.ics"})
+			return err
+		}, ErrValidation},
+		{"create overlapping", func() error {
+			_, err := svc.CreateFromCalendar(ctx, alice, CalendarEvent{Title: "会議", StartTime: at(13), EndTime: at(15), Name: "x.ics"})
+			return err
+		}, ErrConflict},
+		{"update other's", func() error {
+			_, err := svc.UpdateFromCalendar(ctx, bob, created.ID, CalendarEvent{Title: "輪講", StartTime: at(9), EndTime: at(10)})
+			return err
+		}, ErrForbidden},
+		{"update overlapping", func() error {
+			_, err := svc.UpdateFromCalendar(ctx, alice, created.ID, CalendarEvent{Title: "輪講", StartTime: at(12), EndTime: at(14)})
+			return err
+		}, ErrConflict},
+		{"cancel other's", func() error {
+			_, err := svc.CancelFromCalendar(ctx, bob, created.ID)
+			return err
+		}, ErrForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.run(); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	updated, err := svc.UpdateFromCalendar(ctx, alice, created.ID, CalendarEvent{Title: "輪講 (変更)", StartTime: at(8), EndTime: at(9)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Title != "輪講 (変更)" || !updated.StartTime.Equal(at(8)) {
+		t.Errorf("UpdateFromCalendar = %+v", updated)
+	}
+	// カレンダーから編集できない備品はそのまま残る
+	equipment, err := listEquipment(ctx, s, []uint64{created.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := equipment[created.ID]; len(got) != 1 || got[0].EquipmentID != projector.ID {
+		t.Errorf("Equipment = %+v, want プロジェクター", got)
+	}
+	canceled, err := svc.CancelFromCalendar(ctx, alice, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if canceled.Status != "canceled" {
+		t.Errorf("Status = %q, want canceled", canceled.Status)
+	}
+}
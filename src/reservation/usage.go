@@ -0,0 +1,123 @@
+package reservation
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"yoyaku/audit"
+	"yoyaku/db"
+	"yoyaku/events"
+	"yoyaku/metrics"
+	"yoyaku/store"
+)
+
+// MaxExtendMinutes は一度に延長できる最大の分数です。
+const MaxExtendMinutes = 240
+
+// ErrNotOngoing は利用中 (開始から終了までの間) の確定済みの予約ではないため、終了・延長できないことを表します。
+var ErrNotOngoing = errors.New("利用中の予約ではありません")
+
+// EndNow は actor の利用中の予約を今すぐ終了し、部屋を解放します。
+// 予約が無いか他のユーザーの予約の場合は ErrNotFound、利用中ではない場合 (開始から1秒以内を含む) は ErrNotOngoing を返します。
+func (s *Service) EndNow(ctx context.Context, actor audit.Actor, id uint64) (db.Reservation, error) {
+	return s.endAt(ctx, actor, id, time.Now())
+}
+
+func (s *Service) endAt(ctx context.Context, actor audit.Actor, id uint64, now time.Time) (db.Reservation, error) {
+	// TIMESTAMP型は秒単位のため、揃えておく
+	now = now.Truncate(time.Second)
+
+	var before, ended db.Reservation
+	err := s.store.InTx(ctx, func(tx store.Store) error {
+		var err error
+		before, err = lockOngoing(ctx, tx, actor, id, now)
+		if err != nil {
+			return err
+		}
+		// 開始と同じ秒に終了すると終了時刻が開始時刻と同じになり、end_time > start_time の制約に違反する
+		if !now.After(before.StartTime) {
+			return newError(ErrNotOngoing, "開始直後の予約はまだ終了できません。少し待ってからやり直してください")
+		}
+		if err := tx.EndReservationEarly(ctx, db.EndReservationEarlyParams{
+			EndTime:       now,
+			ActualEndTime: sql.NullTime{Time: now, Valid: true},
+			ID:            id,
+		}); err != nil {
+			return err
+		}
+		ended, err = tx.GetReservationByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, actor, audit.ActionEndedEarly, id, &before, &ended)
+	})
+	if err != nil {
+		return db.Reservation{}, err
+	}
+	s.bus.Publish(events.Event{Type: events.TypeReservationUpdated, Reservation: ended, Previous: &before})
+	return ended, nil
+}
+
+// Extend は actor の利用中の予約の終了時刻を minutes 分 (1から MaxExtendMinutes) 延ばします。
+// 予約が無いか他のユーザーの予約の場合は ErrNotFound、利用中ではない場合は ErrNotOngoing、
+// 次の予約と重なる場合は ErrConflict、借りている備品が延長後の時間帯に足りない場合は ErrEquipmentUnavailable を返します。
+func (s *Service) Extend(ctx context.Context, actor audit.Actor, id uint64, minutes int) (db.Reservation, error) {
+	if minutes < 1 || minutes > MaxExtendMinutes {
+		return db.Reservation{}, &Error{Kind: ErrValidation, Message: "minutesは1から240の間で指定してください", Field: "minutes"}
+	}
+	now := time.Now()
+
+	var before, extended db.Reservation
+	err := s.store.InTx(ctx, func(tx store.Store) error {
+		var err error
+		before, err = lockOngoing(ctx, tx, actor, id, now)
+		if err != nil {
+			return err
+		}
+
+		// 延長する時間帯が次の予約と重ならないか確認する
+		newEndTime := before.EndTime.Add(time.Duration(minutes) * time.Minute)
+		overlapping, err := lockOverlapping(ctx, tx, before.EndTime, newEndTime, id)
+		if err != nil {
+			return err
+		}
+		if overlapping {
+			metrics.ConflictRejected(metrics.OperationExtend)
+			return newError(ErrConflict, "次の予約と重なるため延長できません")
+		}
+		if err := checkReservedEquipment(ctx, tx, id, before.StartTime, newEndTime); err != nil {
+			return err
+		}
+
+		if err := tx.ExtendReservation(ctx, db.ExtendReservationParams{
+			EndTime: newEndTime,
+			ID:      id,
+		}); err != nil {
+			return err
+		}
+		extended, err = tx.GetReservationByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, actor, audit.ActionExtended, id, &before, &extended)
+	})
+	if err != nil {
+		return db.Reservation{}, err
+	}
+	s.bus.Publish(events.Event{Type: events.TypeReservationUpdated, Reservation: extended, Previous: &before})
+	return extended, nil
+}
+
+// lockOngoing は actor の利用中の予約を行ロックして取得します。
+func lockOngoing(ctx context.Context, tx store.Store, actor audit.Actor, id uint64, now time.Time) (db.Reservation, error) {
+	reservation, err := lockOwned(ctx, tx, actor, id)
+	if err != nil {
+		return db.Reservation{}, err
+	}
+	if reservation.Status != "confirmed" || now.Before(reservation.StartTime) || !now.Before(reservation.EndTime) {
+		return db.Reservation{}, ErrNotOngoing
+	}
+	return reservation, nil
+}
@@ -0,0 +1,91 @@
+package reservation
+
+import (
+	"context"
+	"slices"
+
+	"yoyaku/db"
+	"yoyaku/store"
+)
+
+// 予約の公開範囲 (reservations.visibility)
+const (
+	// VisibilityPublic はログインしていない人にも詳細を見せます (既定)。
+	VisibilityPublic = "public"
+	// VisibilityMembers はログインしたメンバーにだけ詳細を見せます。
+	VisibilityMembers = "members"
+	// VisibilityPrivate は予約者と参加者にだけ詳細を見せます。
+	VisibilityPrivate = "private"
+)
+
+// MaskedTitle は詳細を見られない予約のタイトルです。時間帯と予約者のIDだけが見えます。
+const MaskedTitle = "予約済み"
+
+// Attendee は予約の参加者です。
+type Attendee struct {
+	UserID uint64 `json:"user_id"`
+	Name   string `json:"name"`
+	// Response は招待への返答 (ResponseNeedsAction など) です。
+	Response string `json:"response"`
+}
+
+// canView は viewerID (ログインしていない場合は0) が予約の詳細を見られるかを返します。
+func canView(visibility string, ownerID, viewerID uint64, attendees []Attendee) bool {
+	switch visibility {
+	case VisibilityMembers:
+		return viewerID != 0
+	case VisibilityPrivate:
+		return viewerID != 0 && (viewerID == ownerID ||
+			slices.ContainsFunc(attendees, func(a Attendee) bool { return a.UserID == viewerID }))
+	}
+	return true
+}
+
+// CanView は viewerID (ログインしていない場合は0) が予約 r の詳細を見られるかを返します。
+// attendeeIDs は r の参加者のユーザーIDです。
+func CanView(r db.Reservation, viewerID uint64, attendeeIDs []uint64) bool {
+	attendees := make([]Attendee, len(attendeeIDs))
+	for i, id := range attendeeIDs {
+		attendees[i] = Attendee{UserID: id}
+	}
+	return canView(r.Visibility, r.UserID, viewerID, attendees)
+}
+
+// Masked は詳細を見られない人に見せる予約を返します。タイトルは MaskedTitle になり、説明は空になります。
+// 詳細が変更されたことも分からないよう、更新日時は作成日時にします。
+func Masked(r db.Reservation) db.Reservation {
+	r.Title = MaskedTitle
+	r.Description = ""
+	r.UpdatedAt = r.CreatedAt
+	return r
+}
+
+// MaskHidden は reservations のうち viewerID が詳細を見られない予約を Masked にして返します。
+// CalDAV のように予約の一覧をそのまま返す入口で使います。
+func MaskHidden(ctx context.Context, s store.ReservationStore, viewerID uint64, reservations []db.Reservation) ([]db.Reservation, error) {
+	var ids []uint64
+	for _, r := range reservations {
+		if r.Visibility == VisibilityPrivate && r.UserID != viewerID {
+			ids = append(ids, r.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return reservations, nil
+	}
+	rows, err := s.ListReservationAttendees(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	attendees := make(map[uint64][]uint64)
+	for _, row := range rows {
+		attendees[row.ReservationID] = append(attendees[row.ReservationID], row.UserID)
+	}
+	masked := make([]db.Reservation, len(reservations))
+	for i, r := range reservations {
+		if !CanView(r, viewerID, attendees[r.ID]) {
+			r = Masked(r)
+		}
+		masked[i] = r
+	}
+	return masked, nil
+}
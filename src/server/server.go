@@ -0,0 +1,203 @@
+// Package server は HTTP サーバーとバックグラウンドの処理 (Googleカレンダーとの同期、no-show の解放など) を
+// まとめて起動し、終了のシグナルを受け取ったときに順番に停止します。
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HTTP サーバーのタイムアウト
+const (
+	// ReadHeaderTimeout はリクエストヘッダーを読み込む時間の上限です (Slowloris 対策)。
+	ReadHeaderTimeout = 10 * time.Second
+	// ReadTimeout はリクエストボディまでを読み込む時間の上限です。
+	ReadTimeout = 30 * time.Second
+	// WriteTimeout はレスポンスを書き込む時間の上限です。
+	// Server-Sent Events のように長く続くレスポンスは、ハンドラーで http.ResponseController を使って解除します。
+	WriteTimeout = 30 * time.Second
+	// IdleTimeout は keep-alive の接続を次のリクエストまで保持する時間です。
+	IdleTimeout = 2 * time.Minute
+	// DefaultShutdownTimeout は処理中のリクエストとバックグラウンドの処理の終了を待つ時間のデフォルトです。
+	DefaultShutdownTimeout = 30 * time.Second
+)
+
+// Server は HTTP サーバーと、その間に動かすバックグラウンドの処理です。
+//
+// 停止するときは次の順に行います。
+//  1. 新しい接続の受け付けをやめ、処理中のリクエストが終わるのを待つ (Draining が閉じられる)
+//  2. バックグラウンドの処理を登録した順と逆の順に1つずつ停止する
+//  3. OnClose で登録した終了処理 (データベースの接続を閉じるなど) を登録した順に実行する
+//
+// リクエストの処理中に発行したイベントをバックグラウンドの処理が受け取れるよう、リクエストを先に終わらせます。
+type Server struct {
+	HTTP *http.Server
+	// ShutdownTimeout は停止するときに待つ時間の上限です。過ぎた場合は残っている接続を切断します。
+	ShutdownTimeout time.Duration
+
+	draining chan struct{}
+	workers  []*worker
+	closers  []closer
+}
+
+type worker struct {
+	name string
+	run  func(ctx context.Context)
+	// exited は run が戻ったことを表します。
+	exited atomic.Bool
+}
+
+type closer struct {
+	name  string
+	close func() error
+}
+
+// New は addr で待ち受ける Server を返します。
+func New(addr string, handler http.Handler) *Server {
+	return &Server{
+		HTTP: &http.Server{
+			Addr:              addr,
+			Handler:           handler,
+			ReadHeaderTimeout: ReadHeaderTimeout,
+			ReadTimeout:       ReadTimeout,
+			WriteTimeout:      WriteTimeout,
+			IdleTimeout:       IdleTimeout,
+		},
+		ShutdownTimeout: DefaultShutdownTimeout,
+		draining:        make(chan struct{}),
+	}
+}
+
+// Draining は停止を始めたときに閉じられるチャネルを返します。
+// Server-Sent Events のように終わらないレスポンスは、これを見て終了してください (終了しないと停止を待ち続けます)。
+func (s *Server) Draining() <-chan struct{} {
+	return s.draining
+}
+
+// Serving は停止を始めていない場合に nil を返します (GET /readyz)。
+// 停止中は新しいリクエストを他のサーバーに振り分けてもらうため、エラーを返します。
+func (s *Server) Serving() error {
+	select {
+	case <-s.draining:
+		return errors.New("停止中です")
+	default:
+		return nil
+	}
+}
+
+// CheckWorkers は全てのバックグラウンドの処理が動いていることを確認します (GET /readyz)。
+// 停止を始める前に終わってしまった処理がある場合はエラーを返します。
+func (s *Server) CheckWorkers() error {
+	var errs []error
+	for _, w := range s.workers {
+		if w.exited.Load() {
+			errs = append(errs, fmt.Errorf("%s が停止しています", w.name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Go はバックグラウンドの処理を登録します。run は Serve を呼んだときに起動し、停止するときに ctx をキャンセルします。
+// run は ctx がキャンセルされたら速やかに戻ってください。
+func (s *Server) Go(name string, run func(ctx context.Context)) {
+	s.workers = append(s.workers, &worker{name: name, run: run})
+}
+
+// OnClose は全てのリクエストとバックグラウンドの処理が終わった後に実行する終了処理を登録します。
+func (s *Server) OnClose(name string, close func() error) {
+	s.closers = append(s.closers, closer{name: name, close: close})
+}
+
+// Run は HTTP.Addr で待ち受け、ctx がキャンセルされるまでリクエストを処理します (Serve を参照)。
+func (s *Server) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.HTTP.Addr)
+	if err != nil {
+		s.close()
+		return err
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve はバックグラウンドの処理を起動して ln で待ち受け、ctx がキャンセルされたら停止します。
+// 停止が ShutdownTimeout 以内に終わらなかった場合や、終了処理に失敗した場合はエラーを返します。
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	stops := make([]func(context.Context) error, len(s.workers))
+	for i, w := range s.workers {
+		stops[i] = start(w)
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.HTTP.Serve(ln)
+	}()
+
+	var errs []error
+	select {
+	case <-ctx.Done():
+		log.Println("サーバーを停止しています")
+	case err := <-serveErr:
+		// 待ち受けに失敗した場合も、起動したバックグラウンドの処理と接続を閉じる
+		errs = append(errs, err)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.ShutdownTimeout)
+	defer cancel()
+
+	close(s.draining)
+	if err := s.HTTP.Shutdown(shutdownCtx); err != nil {
+		errs = append(errs, fmt.Errorf("処理中のリクエストが終わりませんでした: %w", err))
+		s.HTTP.Close()
+	}
+	for i := len(stops) - 1; i >= 0; i-- {
+		if err := stops[i](shutdownCtx); err != nil {
+			errs = append(errs, fmt.Errorf("%s が停止しませんでした: %w", s.workers[i].name, err))
+		}
+	}
+	errs = append(errs, s.close())
+	return errors.Join(errs...)
+}
+
+// start は w を起動し、w を停止して終わるのを待つ関数を返します。
+func start(w *worker) func(context.Context) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer w.exited.Store(true)
+		w.run(ctx)
+	}()
+
+	return func(shutdownCtx context.Context) error {
+		cancel()
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+			return nil
+		case <-shutdownCtx.Done():
+			return shutdownCtx.Err()
+		}
+	}
+}
+
+// close は OnClose で登録した終了処理を登録した順に実行します。
+func (s *Server) close() error {
+	var errs []error
+	for _, c := range s.closers {
+		if err := c.close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s を閉じられませんでした: %w", c.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
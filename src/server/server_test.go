@@ -0,0 +1,257 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"yoyaku/apierror"
+	"yoyaku/checkin"
+	"yoyaku/db"
+	"yoyaku/events"
+	"yoyaku/handler"
+	"yoyaku/reservation"
+	"yoyaku/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/sessions"
+)
+
+// blockingStore は予約のトランザクションを始める前に、release が閉じられるまで待ちます。
+type blockingStore struct {
+	*store.Memory
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingStore) InTx(ctx context.Context, fn func(tx store.Store) error) error {
+	s.entered <- struct{}{}
+	<-s.release
+	return s.Memory.InTx(ctx, fn)
+}
+
+// recorder は停止した順番を記録します。
+type recorder struct {
+	mu    sync.Mutex
+	order []string
+}
+
+func (r *recorder) add(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.order = append(r.order, name)
+}
+
+func (r *recorder) get() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.order)
+}
+
+// 停止のシグナルを受け取った時点で処理中だった予約の作成は最後まで終わり、
+// その後にバックグラウンドの処理を逆の順に停止し、最後にデータベースを閉じます。
+func TestServeDrainsInFlightReservations(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mem := store.NewMemory()
+	result, err := mem.CreateUser(context.Background(), db.CreateUserParams{Name: "alice", Email: "alice@pluslab.org", GoogleID: "alice", Role: "user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	userID, _ := result.LastInsertId()
+
+	st := &blockingStore{Memory: mem, entered: make(chan struct{}), release: make(chan struct{})}
+	svc := reservation.NewService(st, events.NewMemoryBus(16), checkin.DefaultPolicy(), reservation.DefaultRoom())
+	cookies := sessions.NewCookieStore([]byte("test-secret"))
+	r := gin.New()
+	r.Use(apierror.Middleware(handler.MapError), func(c *gin.Context) {
+		c.Set("session_store", cookies)
+		c.Next()
+	})
+	r.POST("/api/reservations", func(c *gin.Context) { handler.Handlereservations(c, svc) })
+
+	var stopped recorder
+	srv := New("", r)
+	srv.ShutdownTimeout = 5 * time.Second
+	for _, name := range []string{"sync", "releaser"} {
+		srv.Go(name, func(ctx context.Context) {
+			<-ctx.Done()
+			stopped.add(name)
+		})
+	}
+	srv.OnClose("database", func() error {
+		stopped.add("database")
+		return nil
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(ctx, ln) }()
+
+	// 予約の作成を始め、トランザクションの手前で止める
+	body, _ := json.Marshal(map[string]any{
+		"title":      "輪講",
+		"start_time": time.Date(2030, 7, 1, 10, 0, 0, 0, time.UTC),
+		"end_time":   time.Date(2030, 7, 1, 12, 0, 0, 0, time.UTC),
+	})
+	req, _ := http.NewRequest(http.MethodPost, "http://"+ln.Addr().String()+"/api/reservations", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(sessionCookie(t, cookies, userID))
+	type response struct {
+		status int
+		err    error
+	}
+	responded := make(chan response, 1)
+	go func() {
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			responded <- response{err: err}
+			return
+		}
+		res.Body.Close()
+		responded <- response{status: res.StatusCode}
+	}()
+	<-st.entered
+
+	// 停止を始める。処理中のリクエストがある間は、バックグラウンドの処理もデータベースも止めない
+	cancel()
+	select {
+	case <-srv.Draining():
+	case <-time.After(5 * time.Second):
+		t.Fatal("停止が始まりません")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := stopped.get(); len(got) != 0 {
+		t.Fatalf("処理中のリクエストがあるのに %v を停止しました", got)
+	}
+	select {
+	case err := <-served:
+		t.Fatalf("処理中のリクエストがあるのに停止しました: %v", err)
+	default:
+	}
+
+	// 停止中は新しい接続を受け付けない
+	if _, err := net.DialTimeout("tcp", ln.Addr().String(), time.Second); err == nil {
+		t.Error("停止中に新しい接続を受け付けました")
+	}
+
+	close(st.release)
+	res := <-responded
+	if res.err != nil || res.status != http.StatusOK {
+		t.Fatalf("処理中の予約が完了しませんでした: status = %d, err = %v", res.status, res.err)
+	}
+	if err := <-served; err != nil {
+		t.Fatal(err)
+	}
+
+	reservations, err := mem.ListReservationsByUserID(context.Background(), uint64(userID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reservations) != 1 || reservations[0].Title != "輪講" {
+		t.Errorf("reservations = %+v", reservations)
+	}
+	if got, want := stopped.get(), []string{"releaser", "sync", "database"}; !slices.Equal(got, want) {
+		t.Errorf("停止した順番 = %v, want %v", got, want)
+	}
+}
+
+// ShutdownTimeout を過ぎても終わらないリクエストは切断し、エラーを返します。
+func TestServeShutdownTimeout(t *testing.T) {
+	entered := make(chan struct{})
+	srv := New("", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-r.Context().Done()
+	}))
+	srv.ShutdownTimeout = 100 * time.Millisecond
+	closed := false
+	srv.OnClose("database", func() error {
+		closed = true
+		return nil
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(ctx, ln) }()
+	go http.Get("http://" + ln.Addr().String())
+	<-entered
+
+	cancel()
+	select {
+	case err := <-served:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("err = %v, want context.DeadlineExceeded", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("停止しません")
+	}
+	if !closed {
+		t.Error("タイムアウトした場合もデータベースを閉じてください")
+	}
+}
+
+func sessionCookie(t *testing.T, cookies *sessions.CookieStore, userID int64) *http.Cookie {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	session, _ := cookies.Get(req, "session-name")
+	session.Values["user_id"] = strconv.FormatInt(userID, 10)
+	if err := session.Save(req, rec); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Result().Cookies()[0]
+}
+
+// 停止を始める前に終わったバックグラウンドの処理と、停止中であることは GET /readyz で準備ができていないことにします。
+func TestReadiness(t *testing.T) {
+	srv := New("", http.NotFoundHandler())
+	exited := make(chan struct{})
+	srv.Go("sync", func(ctx context.Context) { <-ctx.Done() })
+	srv.Go("releaser", func(ctx context.Context) { close(exited) })
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(ctx, ln) }()
+
+	if err := srv.Serving(); err != nil {
+		t.Errorf("Serving = %v", err)
+	}
+	<-exited
+	deadline := time.Now().Add(5 * time.Second)
+	for srv.CheckWorkers() == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := srv.CheckWorkers(); err == nil || !strings.Contains(err.Error(), "releaser") || strings.Contains(err.Error(), "sync") {
+		t.Errorf("CheckWorkers = %v, want releaser が停止したエラー", err)
+	}
+
+	cancel()
+	if err := <-served; err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Serving(); err == nil {
+		t.Error("停止中なのに Serving = nil")
+	}
+}
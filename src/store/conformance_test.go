@@ -0,0 +1,984 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"yoyaku/db"
+)
+
+// 全ての Store が同じ振る舞いをすることを確認します。
+// SQLite は一時ファイルで常に実行し、MySQL は TEST_DATABASE_URL、PostgreSQL は TEST_POSTGRES_URL が設定されている場合のみ実行します。
+// MySQL と PostgreSQL はテーブルの中身が削除されるため、必ずテスト専用のデータベースを指定してください。
+func TestConformance(t *testing.T) {
+	backends := []struct {
+		name string
+		open func(t *testing.T) Store
+	}{
+		{"memory", func(t *testing.T) Store { return NewMemory() }},
+		{"sqlite", func(t *testing.T) Store {
+			return openTestBackend(t, "sqlite:"+filepath.Join(t.TempDir(), "test.db"))
+		}},
+		{"mysql", func(t *testing.T) Store {
+			return openTestBackend(t, os.Getenv("TEST_DATABASE_URL"))
+		}},
+		{"postgres", func(t *testing.T) Store {
+			return openTestBackend(t, os.Getenv("TEST_POSTGRES_URL"))
+		}},
+	}
+	tests := []struct {
+		name string
+		run  func(t *testing.T, s Store)
+	}{
+		{"Users", testUsers},
+		{"CreateAndGetReservation", testCreateAndGetReservation},
+		{"ListReservations", testListReservations},
+		{"SearchReservations", testSearchReservations},
+		{"SearchReservationsFullText", testSearchReservationsFullText},
+		{"Attendees", testAttendees},
+		{"Visibility", testVisibility},
+		{"Equipment", testEquipment},
+		{"Overlap", testOverlap},
+		{"ConcurrentCreate", testConcurrentCreate},
+		{"UpdateAndCancel", testUpdateAndCancel},
+		{"NoShows", testNoShows},
+		{"GoogleCalendar", testGoogleCalendar},
+		{"TxRollback", testTxRollback},
+	}
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					tt.run(t, backend.open(t))
+				})
+			}
+		})
+	}
+}
+
+// openTestBackend はデータベースに接続し、マイグレーションを適用して空の状態の Store を返します。
+func openTestBackend(t *testing.T, databaseURL string) Store {
+	t.Helper()
+	if databaseURL == "" {
+		t.Skip("テスト用のデータベースが設定されていないためスキップします")
+	}
+	backend, err := Open(databaseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { backend.Close() })
+
+	migrator, err := backend.Migrator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := migrator.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range []string{"reservation_equipment", "equipment", "reservation_attendees", "reservation_events", "reservation_checkin_tokens", "reservations", "users", "calendar_sync_states"} {
+		if _, err := backend.DB.Exec("DELETE FROM " + table); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return backend.Store
+}
+
+// 2030-01-01 00:00 (UTC) を基準に、秒単位の時刻を使う
+var base = time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func at(hour int) time.Time {
+	return base.Add(time.Duration(hour) * time.Hour)
+}
+
+func createUser(t *testing.T, s Store, name string) uint64 {
+	t.Helper()
+	result, err := s.CreateUser(context.Background(), db.CreateUserParams{
+		Name:     name,
+		Email:    name + "@example.com",
+		GoogleID: "google-" + name,
+		Role:     "user",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return uint64(id)
+}
+
+func createReservation(t *testing.T, s Store, userID uint64, title string, start, end time.Time) db.Reservation {
+	t.Helper()
+	ctx := context.Background()
+	var reservation db.Reservation
+	err := s.InTx(ctx, func(tx Store) error {
+		result, err := tx.CreateReservation(ctx, db.CreateReservationParams{
+			UserID:     userID,
+			Title:      title,
+			StartTime:  start,
+			EndTime:    end,
+			Visibility: "public",
+		})
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		reservation, err = tx.GetReservationByID(ctx, uint64(id))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reservation
+}
+
+func testUsers(t *testing.T, s Store) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+	if alice == bob {
+		t.Fatalf("同じIDが割り当てられました: %d", alice)
+	}
+
+	user, err := s.GetUserByEmail(ctx, "bob@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != bob || user.Name != "bob" || user.Role != "user" {
+		t.Errorf("GetUserByEmail = %+v", user)
+	}
+	if user, err = s.GetUserByGoogleID(ctx, "google-alice"); err != nil || user.ID != alice {
+		t.Errorf("GetUserByGoogleID = %+v, %v", user, err)
+	}
+	if _, err := s.GetUserByID(ctx, bob+100); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("存在しないユーザーのエラー = %v, want sql.ErrNoRows", err)
+	}
+
+	// メールアドレスは重複できない
+	if _, err := s.CreateUser(ctx, db.CreateUserParams{
+		Name: "alice2", Email: "alice@example.com", GoogleID: "google-alice2", Role: "user",
+	}); err == nil {
+		t.Error("重複したメールアドレスのユーザーが作成されました")
+	}
+
+	users, err := s.ListUsers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 || users[0].ID != alice || users[1].ID != bob {
+		t.Errorf("ListUsers = %+v", users)
+	}
+
+	// 表示言語は未設定 (NULL) から始まる
+	if users[0].Language.Valid {
+		t.Errorf("作成直後の表示言語 = %+v", users[0].Language)
+	}
+	if err := s.SetUserLanguage(ctx, db.SetUserLanguageParams{Language: sql.NullString{String: "en", Valid: true}, ID: alice}); err != nil {
+		t.Fatal(err)
+	}
+	if user, err = s.GetUserByID(ctx, alice); err != nil || user.Language.String != "en" {
+		t.Errorf("SetUserLanguage 後の表示言語 = %+v, %v", user.Language, err)
+	}
+
+	if err := s.SetUserCaldavTokenHash(ctx, db.SetUserCaldavTokenHashParams{CaldavTokenHash: sql.NullString{String: "hash", Valid: true}, ID: bob}); err != nil {
+		t.Fatal(err)
+	}
+	if user, err = s.GetUserByEmail(ctx, "bob@example.com"); err != nil || user.CaldavTokenHash.String != "hash" {
+		t.Errorf("SetUserCaldavTokenHash 後のハッシュ = %+v, %v", user.CaldavTokenHash, err)
+	}
+}
+
+func testCreateAndGetReservation(t *testing.T, s Store) {
+	ctx := context.Background()
+	userID := createUser(t, s, "alice")
+	created := createReservation(t, s, userID, "定例", at(10), at(11))
+
+	got, err := s.GetReservationByID(ctx, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.UserID != userID || got.Title != "定例" || got.Status != "confirmed" || got.Origin != "api" {
+		t.Errorf("GetReservationByID = %+v", got)
+	}
+	if !got.StartTime.Equal(at(10)) || !got.EndTime.Equal(at(11)) {
+		t.Errorf("時刻 = %v - %v, want %v - %v", got.StartTime, got.EndTime, at(10), at(11))
+	}
+	if got.CheckedInAt.Valid || got.ActualEndTime.Valid {
+		t.Errorf("作成直後の予約にチェックインや終了時刻があります: %+v", got)
+	}
+
+	second := createReservation(t, s, userID, "面談", at(12), at(13))
+	if second.ID == created.ID {
+		t.Errorf("同じIDが割り当てられました: %d", second.ID)
+	}
+	if _, err := s.GetReservationByID(ctx, second.ID+100); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("存在しない予約のエラー = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func testListReservations(t *testing.T, s Store) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+	late := createReservation(t, s, alice, "午後", at(14), at(15))
+	early := createReservation(t, s, alice, "午前", at(9), at(10))
+	other := createReservation(t, s, bob, "別の人", at(11), at(12))
+	createReservation(t, s, alice, "翌日", at(33), at(34))
+
+	mine, err := s.ListReservationsByUserID(ctx, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mine) != 3 || mine[0].ID != early.ID || mine[1].ID != late.ID {
+		t.Errorf("ListReservationsByUserID は開始時刻順に自分の予約だけを返すはずです: %+v", mine)
+	}
+
+	// 期間と重なる予約を開始時刻順に、予約者の名前付きで返す
+	rows, err := s.ListReservationsByDate(ctx, db.ListReservationsByDateParams{StartTime: at(24), EndTime: at(0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []uint64
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+	if len(rows) != 3 || ids[0] != early.ID || ids[1] != other.ID || ids[2] != late.ID {
+		t.Fatalf("ListReservationsByDate = %v, want [%d %d %d]", ids, early.ID, other.ID, late.ID)
+	}
+	if rows[1].UserName != "bob" {
+		t.Errorf("user_name = %q, want bob", rows[1].UserName)
+	}
+
+	// キャンセルした予約は CalDAV のカレンダーに含めない
+	if err := s.CanceledReservationByID(ctx, db.CanceledReservationByIDParams{UserID: alice, ID: late.ID}); err != nil {
+		t.Fatal(err)
+	}
+	confirmed, err := s.ListConfirmedReservations(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(confirmed) != 3 || confirmed[0].ID != early.ID || confirmed[1].ID != other.ID {
+		t.Errorf("ListConfirmedReservations は開始時刻順に確定済みの予約だけを返すはずです: %+v", confirmed)
+	}
+}
+
+func testSearchReservations(t *testing.T, s Store) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+	seminar := createReservation(t, s, alice, "輪講 Seminar", at(9), at(10))
+	meeting := createReservation(t, s, bob, "会議", at(9), at(10))
+	interview := createReservation(t, s, alice, "面談", at(11), at(12))
+	canceled := createReservation(t, s, bob, "中止", at(13), at(14))
+	if err := s.CanceledReservationByID(ctx, db.CanceledReservationByIDParams{UserID: bob, ID: canceled.ID}); err != nil {
+		t.Fatal(err)
+	}
+	// 面談は bob が作成した (変更履歴の created の操作者)
+	if err := s.CreateReservationEvent(ctx, db.CreateReservationEventParams{
+		ReservationID: interview.ID,
+		ActorUserID:   sql.NullInt64{Int64: int64(bob), Valid: true},
+		Action:        "created",
+		AuthMethod:    "session",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	confirmed := sql.NullString{String: "confirmed", Valid: true}
+	tests := []struct {
+		name string
+		arg  db.SearchReservationsParams
+		want []uint64
+	}{
+		// 開始時刻が同じ予約はIDの順
+		{"all", db.SearchReservationsParams{}, []uint64{seminar.ID, meeting.ID, interview.ID, canceled.ID}},
+		{"status", db.SearchReservationsParams{Status: confirmed}, []uint64{seminar.ID, meeting.ID, interview.ID}},
+		{"user", db.SearchReservationsParams{UserID: sql.NullInt64{Int64: int64(alice), Valid: true}}, []uint64{seminar.ID, interview.ID}},
+		{"title", db.SearchReservationsParams{Title: sql.NullString{String: "seminar", Valid: true}}, []uint64{seminar.ID}},
+		{"created by", db.SearchReservationsParams{CreatedBy: sql.NullInt64{Int64: int64(bob), Valid: true}}, []uint64{interview.ID}},
+		{"range", db.SearchReservationsParams{
+			RangeStart: sql.NullTime{Time: at(10), Valid: true},
+			RangeEnd:   sql.NullTime{Time: at(13), Valid: true},
+		}, []uint64{seminar.ID, meeting.ID, interview.ID}},
+		{"cursor", db.SearchReservationsParams{
+			CursorStartTime: sql.NullTime{Time: at(9), Valid: true},
+			CursorID:        sql.NullInt64{Int64: int64(seminar.ID), Valid: true},
+		}, []uint64{meeting.ID, interview.ID, canceled.ID}},
+		{"limit", db.SearchReservationsParams{Limit: 2}, []uint64{seminar.ID, meeting.ID}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.arg.Limit == 0 {
+				tt.arg.Limit = 10
+			}
+			rows, err := s.SearchReservations(ctx, tt.arg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []uint64
+			for _, row := range rows {
+				ids = append(ids, row.ID)
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("SearchReservations = %v, want %v", ids, tt.want)
+			}
+
+			// 逆順のクエリはカーソルより前の予約を逆順に返す
+			if tt.arg.CursorStartTime.Valid || tt.arg.Limit != 10 {
+				return
+			}
+			rows2, err := s.SearchReservationsDesc(ctx, db.SearchReservationsDescParams(tt.arg))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids = nil
+			for _, row := range rows2 {
+				ids = append(ids, row.ID)
+			}
+			want := slices.Clone(tt.want)
+			slices.Reverse(want)
+			if !slices.Equal(ids, want) {
+				t.Errorf("SearchReservationsDesc = %v, want %v", ids, want)
+			}
+		})
+	}
+
+	rows, err := s.SearchReservationsDesc(ctx, db.SearchReservationsDescParams{
+		CursorStartTime: sql.NullTime{Time: at(9), Valid: true},
+		CursorID:        sql.NullInt64{Int64: int64(meeting.ID), Valid: true},
+		Limit:           10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].ID != seminar.ID || rows[0].UserName != "alice" {
+		t.Errorf("SearchReservationsDesc のカーソルの後 = %+v, want [%d]", rows, seminar.ID)
+	}
+}
+
+func testSearchReservationsFullText(t *testing.T, s Store) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+	seminar := createReservation(t, s, alice, "輪講", at(9), at(10))
+	meeting := createReservation(t, s, bob, "会議", at(11), at(12))
+	createReservation(t, s, alice, "面談", at(12), at(13))
+	canceled := createReservation(t, s, bob, "輪講の練習", at(13), at(14))
+	if err := s.UpdateReservationByID(ctx, db.UpdateReservationByIDParams{
+		Title:       meeting.Title,
+		Description: "来週の輪講の準備",
+		Visibility:  meeting.Visibility,
+		StartTime:   meeting.StartTime,
+		EndTime:     meeting.EndTime,
+		ID:          meeting.ID,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CanceledReservationByID(ctx, db.CanceledReservationByIDParams{UserID: bob, ID: canceled.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	// MySQL の関連度は FULLTEXT インデックスの値で、部分一致の点数とは順位が異なることがあるため、見つかった予約だけを比べる
+	_, mysql := s.(*mysqlStore)
+	tests := []struct {
+		name string
+		arg  db.SearchReservationsFullTextParams
+		want []uint64
+	}{
+		// タイトルに含む予約が説明に含む予約より先
+		{"title and description", db.SearchReservationsFullTextParams{Query: "輪講", ViewerID: alice}, []uint64{seminar.ID, meeting.ID}},
+		// キャンセルした予約は予約者本人にだけ見つかる
+		{"own canceled", db.SearchReservationsFullTextParams{Query: "輪講", ViewerID: bob}, []uint64{canceled.ID, seminar.ID, meeting.ID}},
+		{"no match", db.SearchReservationsFullTextParams{Query: "懇親会", ViewerID: alice}, nil},
+		{"offset", db.SearchReservationsFullTextParams{Query: "輪講", ViewerID: alice, Offset: 1, Limit: 1}, []uint64{meeting.ID}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if mysql && tt.arg.Offset != 0 {
+				t.Skip("MySQL では順位が決まらない")
+			}
+			if tt.arg.Limit == 0 {
+				tt.arg.Limit = 10
+			}
+			rows, err := s.SearchReservationsFullText(ctx, tt.arg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []uint64
+			for _, row := range rows {
+				if row.Score <= 0 {
+					t.Errorf("reservation %d: Score = %v, want > 0", row.ID, row.Score)
+				}
+				ids = append(ids, row.ID)
+			}
+			want := tt.want
+			if mysql {
+				slices.Sort(ids)
+				want = slices.Sorted(slices.Values(want))
+			}
+			if !slices.Equal(ids, want) {
+				t.Errorf("SearchReservationsFullText = %v, want %v", ids, want)
+			}
+		})
+	}
+}
+
+func testAttendees(t *testing.T, s Store) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+	carol := createUser(t, s, "carol")
+	seminar := createReservation(t, s, alice, "輪講", at(9), at(10))
+	meeting := createReservation(t, s, bob, "会議", at(11), at(12))
+	for _, arg := range []db.AddReservationAttendeeParams{
+		{ReservationID: seminar.ID, UserID: carol},
+		{ReservationID: seminar.ID, UserID: bob},
+		{ReservationID: meeting.ID, UserID: alice},
+	} {
+		if err := s.AddReservationAttendee(ctx, arg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.AddReservationAttendee(ctx, db.AddReservationAttendeeParams{ReservationID: seminar.ID, UserID: bob}); err == nil {
+		t.Error("同じ参加者を2回追加できました")
+	}
+
+	if err := s.UpdateReservationAttendeeResponse(ctx, db.UpdateReservationAttendeeResponseParams{
+		Response:      "accepted",
+		RespondedAt:   sql.NullTime{Time: at(1), Valid: true},
+		ReservationID: seminar.ID,
+		UserID:        carol,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := s.ListReservationAttendees(ctx, []uint64{meeting.ID, seminar.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 予約IDとユーザーIDの順。返答していない参加者は needs_action
+	type attendee struct {
+		reservationID, userID uint64
+		name, response        string
+		responded             bool
+	}
+	want := []attendee{
+		{seminar.ID, bob, "bob", "needs_action", false},
+		{seminar.ID, carol, "carol", "accepted", true},
+		{meeting.ID, alice, "alice", "needs_action", false},
+	}
+	var got []attendee
+	for _, row := range rows {
+		got = append(got, attendee{row.ReservationID, row.UserID, row.Name, row.Response, row.RespondedAt.Valid})
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("ListReservationAttendees = %+v, want %+v", got, want)
+	}
+
+	// 招待の一覧は終了していない確定済みの予約だけ
+	invitations, err := s.ListInvitationsByUserID(ctx, db.ListInvitationsByUserIDParams{UserID: carol, After: at(0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invitations) != 1 || invitations[0].ID != seminar.ID || invitations[0].UserName != "alice" || invitations[0].Response != "accepted" {
+		t.Errorf("ListInvitationsByUserID = %+v, want [輪講]", invitations)
+	}
+	if invitations, err = s.ListInvitationsByUserID(ctx, db.ListInvitationsByUserIDParams{UserID: carol, After: at(11)}); err != nil || len(invitations) != 0 {
+		t.Errorf("終了した予約の ListInvitationsByUserID = %+v, %v, want []", invitations, err)
+	}
+
+	// MemberID は予約者と、参加を承諾した参加者の予約だけ
+	members := func(userID uint64) []uint64 {
+		rows, err := s.SearchReservations(ctx, db.SearchReservationsParams{
+			MemberID: sql.NullInt64{Int64: int64(userID), Valid: true},
+			Limit:    10,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []uint64
+		for _, row := range rows {
+			ids = append(ids, row.ID)
+		}
+		return ids
+	}
+	if got := members(carol); !slices.Equal(got, []uint64{seminar.ID}) {
+		t.Errorf("carol の SearchReservations = %v, want [%d]", got, seminar.ID)
+	}
+	if got := members(bob); !slices.Equal(got, []uint64{meeting.ID}) {
+		t.Errorf("bob の SearchReservations = %v, want [%d] (未回答の招待は含めない)", got, meeting.ID)
+	}
+
+	if err := s.DeleteReservationAttendee(ctx, db.DeleteReservationAttendeeParams{ReservationID: seminar.ID, UserID: bob}); err != nil {
+		t.Fatal(err)
+	}
+	rows, err = s.ListReservationAttendees(ctx, []uint64{seminar.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].UserID != carol {
+		t.Errorf("削除後の ListReservationAttendees = %+v, want [carol]", rows)
+	}
+}
+
+func testEquipment(t *testing.T, s Store) {
+	ctx := context.Background()
+	createEquipment := func(name string, quantity int32) uint64 {
+		t.Helper()
+		result, err := s.CreateEquipment(ctx, db.CreateEquipmentParams{Name: name, Quantity: quantity})
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return uint64(id)
+	}
+	projector := createEquipment("プロジェクター", 2)
+	kit := createEquipment("テレビ会議セット", 1)
+	if _, err := s.CreateEquipment(ctx, db.CreateEquipmentParams{Name: "プロジェクター", Quantity: 1}); err == nil {
+		t.Error("同じ名前の備品を登録できました")
+	}
+	if err := s.UpdateEquipment(ctx, db.UpdateEquipmentParams{Name: "プロジェクター", Quantity: 3, ID: projector}); err != nil {
+		t.Fatal(err)
+	}
+	if e, err := s.GetEquipmentByIDForUpdate(ctx, projector); err != nil || e.Quantity != 3 {
+		t.Errorf("GetEquipmentByIDForUpdate = %+v, %v, want 数量3", e, err)
+	}
+	if _, err := s.GetEquipmentByID(ctx, kit+100); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("存在しない備品のエラー = %v, want sql.ErrNoRows", err)
+	}
+	equipment, err := s.ListEquipment(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(equipment) != 2 || equipment[0].ID != projector || equipment[1].ID != kit {
+		t.Errorf("ListEquipment = %+v, want [プロジェクター テレビ会議セット]", equipment)
+	}
+
+	alice := createUser(t, s, "alice")
+	seminar := createReservation(t, s, alice, "輪講", at(9), at(11))
+	meeting := createReservation(t, s, alice, "会議", at(10), at(12))
+	canceled := createReservation(t, s, alice, "キャンセル", at(9), at(12))
+	for _, arg := range []db.AddReservationEquipmentParams{
+		{ReservationID: seminar.ID, EquipmentID: projector, Quantity: 1},
+		{ReservationID: seminar.ID, EquipmentID: kit, Quantity: 1},
+		{ReservationID: meeting.ID, EquipmentID: projector, Quantity: 2},
+		{ReservationID: canceled.ID, EquipmentID: projector, Quantity: 3},
+	} {
+		if err := s.AddReservationEquipment(ctx, arg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.CanceledReservationByID(ctx, db.CanceledReservationByIDParams{UserID: alice, ID: canceled.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 期間に重なる確定済みの予約だけ (備品ID、開始時刻の順)
+	usage, err := s.ListEquipmentUsage(ctx, db.ListEquipmentUsageParams{RangeStart: at(10), RangeEnd: at(11), ExcludeID: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	type use struct {
+		reservationID, equipmentID uint64
+		quantity                   int32
+	}
+	var got []use
+	for _, row := range usage {
+		got = append(got, use{row.ReservationID, row.EquipmentID, row.Quantity})
+	}
+	want := []use{{seminar.ID, projector, 1}, {meeting.ID, projector, 2}, {seminar.ID, kit, 1}}
+	if !slices.Equal(got, want) {
+		t.Errorf("ListEquipmentUsage = %+v, want %+v", got, want)
+	}
+	if usage, err = s.ListEquipmentUsage(ctx, db.ListEquipmentUsageParams{RangeStart: at(11), RangeEnd: at(13), ExcludeID: meeting.ID}); err != nil || len(usage) != 0 {
+		t.Errorf("終わった予約と除外した予約の ListEquipmentUsage = %+v, %v, want []", usage, err)
+	}
+
+	rows, err := s.ListReservationEquipment(ctx, []uint64{meeting.ID, seminar.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || rows[0].ReservationID != seminar.ID || rows[0].Name != "プロジェクター" || rows[2].ReservationID != meeting.ID || rows[2].Quantity != 2 {
+		t.Errorf("ListReservationEquipment = %+v", rows)
+	}
+	if err := s.DeleteReservationEquipment(ctx, seminar.ID); err != nil {
+		t.Fatal(err)
+	}
+	if rows, err = s.ListReservationEquipment(ctx, []uint64{seminar.ID}); err != nil || len(rows) != 0 {
+		t.Errorf("削除後の ListReservationEquipment = %+v, %v, want []", rows, err)
+	}
+}
+
+func testVisibility(t *testing.T, s Store) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+	carol := createUser(t, s, "carol")
+	public := createReservation(t, s, alice, "輪講 公開", at(9), at(10))
+	members := createReservation(t, s, alice, "輪講 メンバー", at(10), at(11))
+	private := createReservation(t, s, alice, "輪講 非公開", at(11), at(12))
+	for _, r := range []db.Reservation{members, private} {
+		visibility := "members"
+		if r.ID == private.ID {
+			visibility = "private"
+		}
+		if err := s.UpdateReservationByID(ctx, db.UpdateReservationByIDParams{
+			Title: r.Title, Headcount: 2, Visibility: visibility, StartTime: r.StartTime, EndTime: r.EndTime, ID: r.ID,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.AddReservationAttendee(ctx, db.AddReservationAttendeeParams{ReservationID: private.ID, UserID: carol}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.GetReservationByID(ctx, private.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Headcount != 2 || got.Visibility != "private" {
+		t.Errorf("headcount, visibility = %d, %q, want 2, private", got.Headcount, got.Visibility)
+	}
+
+	// タイトルで絞り込む場合は、詳細を見られる予約だけが見つかる (一覧そのものには全ての予約が含まれる)
+	viewer := func(id uint64) sql.NullInt64 { return sql.NullInt64{Int64: int64(id), Valid: id != 0} }
+	tests := []struct {
+		name     string
+		viewerID uint64
+		want     []uint64
+	}{
+		{"anonymous", 0, []uint64{public.ID}},
+		{"member", bob, []uint64{public.ID, members.ID}},
+		{"owner", alice, []uint64{public.ID, members.ID, private.ID}},
+		{"attendee", carol, []uint64{public.ID, members.ID, private.ID}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := s.SearchReservations(ctx, db.SearchReservationsParams{
+				Title:    sql.NullString{String: "輪講", Valid: true},
+				ViewerID: viewer(tt.viewerID),
+				Limit:    10,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []uint64
+			for _, row := range rows {
+				ids = append(ids, row.ID)
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("SearchReservations = %v, want %v", ids, tt.want)
+			}
+
+			all, err := s.SearchReservations(ctx, db.SearchReservationsParams{ViewerID: viewer(tt.viewerID), Limit: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(all) != 3 {
+				t.Errorf("絞り込まない SearchReservations = %d 件, want 3", len(all))
+			}
+
+			// 全文検索はログインしたユーザーだけが使い、非公開の予約は予約者と参加者にだけ見つかる
+			if tt.viewerID == 0 {
+				return
+			}
+			hits, err := s.SearchReservationsFullText(ctx, db.SearchReservationsFullTextParams{Query: "輪講", ViewerID: tt.viewerID, Limit: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids = nil
+			for _, hit := range hits {
+				ids = append(ids, hit.ID)
+			}
+			slices.Sort(ids)
+			want := []uint64{public.ID, members.ID}
+			if tt.viewerID != bob {
+				want = append(want, private.ID)
+			}
+			if !slices.Equal(ids, want) {
+				t.Errorf("SearchReservationsFullText = %v, want %v", ids, want)
+			}
+		})
+	}
+}
+
+func testOverlap(t *testing.T, s Store) {
+	ctx := context.Background()
+	userID := createUser(t, s, "alice")
+	existing := createReservation(t, s, userID, "定例", at(10), at(11))
+
+	tests := []struct {
+		name       string
+		start, end time.Time
+		want       int64
+	}{
+		{"前に隣接", at(9), at(10), 0},
+		{"後に隣接", at(11), at(12), 0},
+		{"一部が重なる", at(10).Add(30 * time.Minute), at(11).Add(30 * time.Minute), 1},
+		{"全体を含む", at(9), at(12), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			busy, err := s.ListBusyIntervals(ctx, db.ListBusyIntervalsParams{RangeEnd: tt.end, RangeStart: tt.start})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if int64(len(busy)) != tt.want {
+				t.Errorf("ListBusyIntervals = %d件, want %d", len(busy), tt.want)
+			}
+		})
+	}
+
+	// 延長などで使う、重なる予約の行をロックして ID を返すクエリ (トランザクション内で実行する)
+	other := createReservation(t, s, userID, "輪講", at(12), at(13))
+	lockTests := []struct {
+		name       string
+		start, end time.Time
+		excludeID  uint64
+		want       []uint64
+	}{
+		{"重ならない", at(11), at(12), 0, nil},
+		{"両方に重なる", at(10), at(13), 0, []uint64{existing.ID, other.ID}},
+		{"自分自身を除く", at(10), at(13), existing.ID, []uint64{other.ID}},
+	}
+	for _, tt := range lockTests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ids []uint64
+			err := s.InTx(ctx, func(tx Store) error {
+				var err error
+				ids, err = tx.ListOverlappingReservationIDsForUpdate(ctx, db.ListOverlappingReservationIDsForUpdateParams{
+					RangeEnd: tt.end, RangeStart: tt.start, ExcludeID: tt.excludeID,
+				})
+				return err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("ListOverlappingReservationIDsForUpdate = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+// testConcurrentCreate は reservation.Service と同じ手順 (部屋の行をロック → 重なる予約を確認 → 登録) で
+// 同じ時間帯の予約を同時に登録し、1件だけが登録されることを確認します。
+func testConcurrentCreate(t *testing.T, s Store) {
+	ctx := context.Background()
+	userID := createUser(t, s, "alice")
+
+	const workers = 8
+	var created atomic.Int32
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- s.InTx(ctx, func(tx Store) error {
+				if _, err := tx.LockRoom(ctx, "room-401"); err != nil {
+					return err
+				}
+				ids, err := tx.ListOverlappingReservationIDsForUpdate(ctx, db.ListOverlappingReservationIDsForUpdateParams{
+					RangeEnd: at(11), RangeStart: at(10),
+				})
+				if err != nil || len(ids) > 0 {
+					return err
+				}
+				if _, err := tx.CreateReservation(ctx, db.CreateReservationParams{
+					UserID: userID, Title: fmt.Sprintf("予約%d", i), Visibility: "public", StartTime: at(10), EndTime: at(11),
+				}); err != nil {
+					return err
+				}
+				created.Add(1)
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := created.Load(); n != 1 {
+		t.Errorf("登録された予約 = %d件, want 1", n)
+	}
+	busy, err := s.ListBusyIntervals(ctx, db.ListBusyIntervalsParams{RangeEnd: at(11), RangeStart: at(10)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(busy) != 1 {
+		t.Errorf("保存された予約 = %d件, want 1", len(busy))
+	}
+}
+
+func testUpdateAndCancel(t *testing.T, s Store) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice")
+	bob := createUser(t, s, "bob")
+	reservation := createReservation(t, s, alice, "定例", at(10), at(11))
+
+	if err := s.UpdateReservationByID(ctx, db.UpdateReservationByIDParams{
+		Title: "定例 (変更)", Visibility: "public", StartTime: at(13), EndTime: at(15), ID: reservation.ID,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := s.GetReservationByID(ctx, reservation.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Title != "定例 (変更)" || !updated.StartTime.Equal(at(13)) || !updated.EndTime.Equal(at(15)) {
+		t.Errorf("更新後の予約 = %+v", updated)
+	}
+
+	// 他人の予約はキャンセルできない
+	if err := s.CanceledReservationByID(ctx, db.CanceledReservationByIDParams{UserID: bob, ID: reservation.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.GetReservationByID(ctx, reservation.ID); got.Status != "confirmed" {
+		t.Errorf("他人がキャンセルできました: status = %q", got.Status)
+	}
+
+	if err := s.CanceledReservationByID(ctx, db.CanceledReservationByIDParams{UserID: alice, ID: reservation.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.GetReservationByID(ctx, reservation.ID); got.Status != "canceled" {
+		t.Errorf("status = %q, want canceled", got.Status)
+	}
+	// キャンセルした予約は一覧と重複チェックの対象外
+	if mine, _ := s.ListReservationsByUserID(ctx, alice); len(mine) != 0 {
+		t.Errorf("キャンセルした予約が一覧に含まれています: %+v", mine)
+	}
+	if busy, _ := s.ListBusyIntervals(ctx, db.ListBusyIntervalsParams{RangeEnd: at(15), RangeStart: at(13)}); len(busy) != 0 {
+		t.Errorf("キャンセルした予約と重複しています: %+v", busy)
+	}
+}
+
+func testNoShows(t *testing.T, s Store) {
+	ctx := context.Background()
+	userID := createUser(t, s, "alice")
+	count, err := s.CountNoShowsByUserID(ctx, db.CountNoShowsByUserIDParams{UserID: userID, StartTime: at(0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("CountNoShowsByUserID = %d, want 0", count)
+	}
+}
+
+func testGoogleCalendar(t *testing.T, s Store) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice")
+	eventID := sql.NullString{String: "event-1", Valid: true}
+
+	result, err := s.CreateReservationFromCalendar(ctx, db.CreateReservationFromCalendarParams{
+		UserID: alice, Title: "取り込み", StartTime: at(10), EndTime: at(11), GoogleEventID: eventID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, err := s.GetReservationByGoogleEventID(ctx, eventID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported.ID != uint64(id) || imported.Origin != "google_calendar" || imported.Status != "confirmed" || imported.Visibility != "public" {
+		t.Errorf("GetReservationByGoogleEventID = %+v", imported)
+	}
+
+	fromAPI := createReservation(t, s, alice, "API", at(12), at(13))
+	if err := s.SetReservationGoogleEventID(ctx, db.SetReservationGoogleEventIDParams{
+		GoogleEventID: sql.NullString{String: "event-2", Valid: true}, ID: fromAPI.ID,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.GetReservationByGoogleEventID(ctx, sql.NullString{String: "event-2", Valid: true})
+	if err != nil || got.ID != fromAPI.ID {
+		t.Errorf("SetReservationGoogleEventID 後の予約 = %+v, %v", got, err)
+	}
+	// キャンセルした予約も google_event_id で見つかる (カレンダーからの削除を二重に取り込まないため)
+	if err := s.CanceledReservationByID(ctx, db.CanceledReservationByIDParams{UserID: alice, ID: fromAPI.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.GetReservationByGoogleEventID(ctx, sql.NullString{String: "event-2", Valid: true}); err != nil || got.Status != "canceled" {
+		t.Errorf("キャンセルした予約 = %+v, %v", got, err)
+	}
+	if _, err := s.GetReservationByGoogleEventID(ctx, sql.NullString{String: "missing", Valid: true}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("存在しない予定のエラー = %v, want sql.ErrNoRows", err)
+	}
+
+	if _, err := s.GetCalendarSyncToken(ctx, "room401"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("同期前のトークンのエラー = %v, want sql.ErrNoRows", err)
+	}
+	for _, token := range []string{"token-1", "token-2"} {
+		if err := s.UpsertCalendarSyncToken(ctx, db.UpsertCalendarSyncTokenParams{
+			CalendarID: "room401", SyncToken: sql.NullString{String: token, Valid: true},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if token, err := s.GetCalendarSyncToken(ctx, "room401"); err != nil || token.String != "token-2" {
+		t.Errorf("GetCalendarSyncToken = %+v, %v, want token-2", token, err)
+	}
+}
+
+func testTxRollback(t *testing.T, s Store) {
+	ctx := context.Background()
+	userID := createUser(t, s, "alice")
+	kept := createReservation(t, s, userID, "残る予約", at(9), at(10))
+
+	errRollback := errors.New("rollback")
+	var rolledBack db.Reservation
+	err := s.InTx(ctx, func(tx Store) error {
+		result, err := tx.CreateReservation(ctx, db.CreateReservationParams{
+			UserID: userID, Title: "取り消す予約", StartTime: at(10), EndTime: at(11), Visibility: "public",
+		})
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		if rolledBack, err = tx.GetReservationByID(ctx, uint64(id)); err != nil {
+			return err
+		}
+		if err := tx.CanceledReservationByID(ctx, db.CanceledReservationByIDParams{UserID: userID, ID: kept.ID}); err != nil {
+			return err
+		}
+		if err := tx.CreateReservationEvent(ctx, db.CreateReservationEventParams{
+			ReservationID: rolledBack.ID, Action: "created", AuthMethod: "system",
+		}); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("InTx = %v, want %v", err, errRollback)
+	}
+
+	if _, err := s.GetReservationByID(ctx, rolledBack.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("取り消した予約のエラー = %v, want sql.ErrNoRows", err)
+	}
+	if got, err := s.GetReservationByID(ctx, kept.ID); err != nil || got.Status != "confirmed" {
+		t.Errorf("取り消したキャンセルが残っています: %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,1020 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"yoyaku/db"
+)
+
+// Memory はメモリ上にデータを保持する Store です。テストで MySQL の代わりに使います。
+// 重複チェックや一覧の期間の条件は db/query.sql のクエリと同じ比較を行います。
+// 実装しているのはユーザー・予約・備品・Googleカレンダーの同期の基本的なクエリ
+// (UserStore と ReservationStore と EquipmentStore と CalendarSyncStore) のみで、それ以外のクエリを呼ぶと panic します。
+type Memory struct {
+	// db.Querier は未実装のクエリを埋めるためのもので、常に nil です。
+	db.Querier
+
+	// txMu はトランザクションを1つずつ実行するためのロックです。
+	txMu sync.Mutex
+
+	mu           sync.Mutex
+	users        map[uint64]db.User
+	reservations map[uint64]db.Reservation
+	// attendees は予約IDごとの参加者です (ユーザーID順)。
+	attendees map[uint64][]db.ReservationAttendee
+	equipment map[uint64]db.Equipment
+	// reservationEquipment は予約IDごとに借りる備品です (備品ID順)。
+	reservationEquipment map[uint64][]db.ReservationEquipment
+	// checkinTokens は予約IDごとのチェックイントークンです。
+	checkinTokens map[uint64]string
+	// syncTokens はカレンダーIDごとの Googleカレンダーの同期トークンです。
+	syncTokens      map[string]sql.NullString
+	events          []db.CreateReservationEventParams
+	nextUserID      uint64
+	nextID          uint64
+	nextEquipmentID uint64
+}
+
+// NewMemory は空の Memory を返します。
+func NewMemory() *Memory {
+	return &Memory{
+		users:                map[uint64]db.User{},
+		reservations:         map[uint64]db.Reservation{},
+		attendees:            map[uint64][]db.ReservationAttendee{},
+		equipment:            map[uint64]db.Equipment{},
+		reservationEquipment: map[uint64][]db.ReservationEquipment{},
+		checkinTokens:        map[uint64]string{},
+		syncTokens:           map[string]sql.NullString{},
+	}
+}
+
+var _ Store = (*Memory)(nil)
+
+// ErrDuplicate は UNIQUE 制約に違反したことを表します。
+var ErrDuplicate = errors.New("store: duplicate entry")
+
+// InTx は fn を他のトランザクションと排他的に実行し、fn がエラーを返した場合は変更を元に戻します。
+func (m *Memory) InTx(ctx context.Context, fn func(tx Store) error) error {
+	m.txMu.Lock()
+	defer m.txMu.Unlock()
+
+	m.mu.Lock()
+	users := copyMap(m.users)
+	reservations := copyMap(m.reservations)
+	attendees := copyMap(m.attendees)
+	equipment, reservationEquipment := copyMap(m.equipment), copyMap(m.reservationEquipment)
+	checkinTokens, syncTokens := copyMap(m.checkinTokens), copyMap(m.syncTokens)
+	events := len(m.events)
+	nextUserID, nextID, nextEquipmentID := m.nextUserID, m.nextID, m.nextEquipmentID
+	m.mu.Unlock()
+
+	if err := fn(memoryTx{m}); err != nil {
+		m.mu.Lock()
+		m.users, m.reservations, m.attendees, m.events = users, reservations, attendees, m.events[:events]
+		m.equipment, m.reservationEquipment, m.checkinTokens, m.syncTokens = equipment, reservationEquipment, checkinTokens, syncTokens
+		m.nextUserID, m.nextID, m.nextEquipmentID = nextUserID, nextID, nextEquipmentID
+		m.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// memoryTx はトランザクション内で fn に渡す Store です。
+type memoryTx struct {
+	*Memory
+}
+
+func (tx memoryTx) InTx(ctx context.Context, fn func(tx Store) error) error {
+	return fn(tx)
+}
+
+// Events はこれまでに追加された変更履歴を返します。
+func (m *Memory) Events() []db.CreateReservationEventParams {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]db.CreateReservationEventParams(nil), m.events...)
+}
+
+func (m *Memory) CreateUser(ctx context.Context, arg db.CreateUserParams) (sql.Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range m.users {
+		if u.Email == arg.Email || u.GoogleID == arg.GoogleID {
+			return nil, ErrDuplicate
+		}
+	}
+	m.nextUserID++
+	now := time.Now()
+	m.users[m.nextUserID] = db.User{
+		ID:        m.nextUserID,
+		Name:      arg.Name,
+		Email:     arg.Email,
+		GoogleID:  arg.GoogleID,
+		AvatarUrl: arg.AvatarUrl,
+		Role:      arg.Role,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	return result{id: int64(m.nextUserID)}, nil
+}
+
+func (m *Memory) GetUserByID(ctx context.Context, id uint64) (db.User, error) {
+	return m.findUser(func(u db.User) bool { return u.ID == id })
+}
+
+func (m *Memory) GetUserByEmail(ctx context.Context, email string) (db.User, error) {
+	return m.findUser(func(u db.User) bool { return u.Email == email })
+}
+
+func (m *Memory) GetUserByGoogleID(ctx context.Context, googleID string) (db.User, error) {
+	return m.findUser(func(u db.User) bool { return u.GoogleID == googleID })
+}
+
+func (m *Memory) ListUsers(ctx context.Context) ([]db.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var users []db.User
+	for _, u := range m.users {
+		if !u.DeletedAt.Valid {
+			users = append(users, u)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users, nil
+}
+
+func (m *Memory) SetUserLanguage(ctx context.Context, arg db.SetUserLanguageParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if u, ok := m.users[arg.ID]; ok {
+		u.Language = arg.Language
+		u.UpdatedAt = time.Now()
+		m.users[arg.ID] = u
+	}
+	return nil
+}
+
+func (m *Memory) SetUserCaldavTokenHash(ctx context.Context, arg db.SetUserCaldavTokenHashParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if u, ok := m.users[arg.ID]; ok {
+		u.CaldavTokenHash = arg.CaldavTokenHash
+		u.UpdatedAt = time.Now()
+		m.users[arg.ID] = u
+	}
+	return nil
+}
+
+func (m *Memory) findUser(match func(db.User) bool) (db.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range m.users {
+		if !u.DeletedAt.Valid && match(u) {
+			return u, nil
+		}
+	}
+	return db.User{}, sql.ErrNoRows
+}
+
+func (m *Memory) CreateReservation(ctx context.Context, arg db.CreateReservationParams) (sql.Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[arg.UserID]; !ok {
+		return nil, errors.New("store: user not found")
+	}
+	m.nextID++
+	now := time.Now()
+	m.reservations[m.nextID] = db.Reservation{
+		ID:          m.nextID,
+		UserID:      arg.UserID,
+		Title:       arg.Title,
+		Description: arg.Description,
+		Headcount:   arg.Headcount,
+		Visibility:  arg.Visibility,
+		StartTime:   arg.StartTime,
+		EndTime:     arg.EndTime,
+		Status:      "confirmed",
+		Origin:      "api",
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	return result{id: int64(m.nextID)}, nil
+}
+
+func (m *Memory) GetReservationByID(ctx context.Context, id uint64) (db.Reservation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, ok := m.reservations[id]
+	if !ok {
+		return db.Reservation{}, sql.ErrNoRows
+	}
+	return r, nil
+}
+
+// GetReservationByIDForUpdate は InTx がトランザクションを1つずつ実行するため、行ロックは取りません。
+func (m *Memory) GetReservationByIDForUpdate(ctx context.Context, id uint64) (db.Reservation, error) {
+	return m.GetReservationByID(ctx, id)
+}
+
+func (m *Memory) ListReservationsByUserID(ctx context.Context, userID uint64) ([]db.Reservation, error) {
+	return m.filterReservations(func(r db.Reservation) bool {
+		return r.Status == "confirmed" && r.UserID == userID
+	}), nil
+}
+
+func (m *Memory) ListConfirmedReservations(ctx context.Context) ([]db.Reservation, error) {
+	return m.filterReservations(func(r db.Reservation) bool { return r.Status == "confirmed" }), nil
+}
+
+func (m *Memory) ListReservationsByDate(ctx context.Context, arg db.ListReservationsByDateParams) ([]db.ListReservationsByDateRow, error) {
+	// r.start_time < ? AND r.end_time >= ?
+	return m.listWithUserName(func(r db.Reservation) bool {
+		return r.StartTime.Before(arg.StartTime) && !r.EndTime.Before(arg.EndTime)
+	}), nil
+}
+
+func (m *Memory) SearchReservations(ctx context.Context, arg db.SearchReservationsParams) ([]db.SearchReservationsRow, error) {
+	var rows []db.SearchReservationsRow
+	for _, row := range m.search(arg, false) {
+		rows = append(rows, db.SearchReservationsRow(row))
+	}
+	return rows, nil
+}
+
+func (m *Memory) SearchReservationsDesc(ctx context.Context, arg db.SearchReservationsDescParams) ([]db.SearchReservationsDescRow, error) {
+	var rows []db.SearchReservationsDescRow
+	for _, row := range m.search(db.SearchReservationsParams(arg), true) {
+		rows = append(rows, db.SearchReservationsDescRow(row))
+	}
+	return rows, nil
+}
+
+// search は SearchReservations と SearchReservationsDesc のクエリと同じ条件で予約を絞り込み、開始時刻とIDの順 (desc の場合は逆順) に返します。
+func (m *Memory) search(arg db.SearchReservationsParams, desc bool) []db.ListReservationsByDateRow {
+	m.mu.Lock()
+	createdBy := map[uint64]bool{}
+	for _, e := range m.events {
+		if e.Action == "created" && arg.CreatedBy.Valid && e.ActorUserID == arg.CreatedBy {
+			createdBy[e.ReservationID] = true
+		}
+	}
+	attending := m.attending(uint64(arg.ViewerID.Int64))
+	memberOf := m.attending(uint64(arg.MemberID.Int64))
+	m.mu.Unlock()
+
+	// タイトルで絞り込む場合は、閲覧者がタイトルを見られる予約だけを対象にする
+	titleVisible := func(r db.Reservation) bool {
+		viewer := uint64(arg.ViewerID.Int64)
+		return r.Visibility == "public" ||
+			(arg.ViewerID.Valid && (r.Visibility == "members" || r.UserID == viewer || attending[r.ID] != ""))
+	}
+	// 予約者か、参加を承諾 (未定を含む) した参加者の予約
+	isMember := func(r db.Reservation) bool {
+		response := memberOf[r.ID]
+		return r.UserID == uint64(arg.MemberID.Int64) || response == "accepted" || response == "tentative"
+	}
+
+	// 開始時刻とIDを合わせて比べ、カーソルより後 (desc の場合は前) の予約だけを残す
+	afterCursor := func(r db.Reservation) bool {
+		if !arg.CursorStartTime.Valid {
+			return true
+		}
+		order := r.StartTime.Compare(arg.CursorStartTime.Time)
+		if order == 0 {
+			order = cmp.Compare(r.ID, uint64(arg.CursorID.Int64))
+		}
+		if desc {
+			return order < 0
+		}
+		return order > 0
+	}
+	reservations := m.filterReservations(func(r db.Reservation) bool {
+		return (!arg.UserID.Valid || r.UserID == uint64(arg.UserID.Int64)) &&
+			(!arg.MemberID.Valid || isMember(r)) &&
+			(!arg.Status.Valid || r.Status == arg.Status.String) &&
+			(!arg.Title.Valid || strings.Contains(strings.ToLower(r.Title), strings.ToLower(arg.Title.String)) && titleVisible(r)) &&
+			(!arg.CreatedBy.Valid || createdBy[r.ID]) &&
+			(!arg.RangeStart.Valid || !r.EndTime.Before(arg.RangeStart.Time)) &&
+			(!arg.RangeEnd.Valid || r.StartTime.Before(arg.RangeEnd.Time)) &&
+			afterCursor(r)
+	})
+	if desc {
+		for i, j := 0, len(reservations)-1; i < j; i, j = i+1, j-1 {
+			reservations[i], reservations[j] = reservations[j], reservations[i]
+		}
+	}
+
+	rows := m.withUserName(reservations)
+	if len(rows) > int(arg.Limit) {
+		rows = rows[:arg.Limit]
+	}
+	return rows
+}
+
+// SearchReservationsFullText は SQLite と PostgreSQL のクエリと同じく部分一致で検索します (タイトルは2点、説明は1点)。
+// MySQL の FULLTEXT インデックスの関連度とは値が異なります。
+func (m *Memory) SearchReservationsFullText(ctx context.Context, arg db.SearchReservationsFullTextParams) ([]db.SearchReservationsFullTextRow, error) {
+	query := strings.ToLower(arg.Query)
+	score := func(r db.ListReservationsByDateRow) float64 {
+		var score float64
+		if strings.Contains(strings.ToLower(r.Title), query) {
+			score += 2
+		}
+		if strings.Contains(strings.ToLower(r.Description), query) {
+			score += 1
+		}
+		return score
+	}
+
+	m.mu.Lock()
+	attending := m.attending(arg.ViewerID)
+	m.mu.Unlock()
+
+	var rows []db.SearchReservationsFullTextRow
+	for _, r := range m.withUserName(m.filterReservations(func(r db.Reservation) bool {
+		own := r.UserID == arg.ViewerID
+		return (r.Status != "canceled" || own) && (r.Visibility != "private" || own || attending[r.ID] != "")
+	})) {
+		if s := score(r); s > 0 {
+			rows = append(rows, db.SearchReservationsFullTextRow{
+				ID:            r.ID,
+				UserID:        r.UserID,
+				Title:         r.Title,
+				StartTime:     r.StartTime,
+				EndTime:       r.EndTime,
+				Status:        r.Status,
+				CheckedInAt:   r.CheckedInAt,
+				ActualEndTime: r.ActualEndTime,
+				BookedEndTime: r.BookedEndTime,
+				Origin:        r.Origin,
+				GoogleEventID: r.GoogleEventID,
+				IcalUid:       r.IcalUid,
+				CaldavName:    r.CaldavName,
+				CreatedAt:     r.CreatedAt,
+				UpdatedAt:     r.UpdatedAt,
+				Description:   r.Description,
+				Headcount:     r.Headcount,
+				Visibility:    r.Visibility,
+				UserName:      r.UserName,
+				Score:         s,
+			})
+		}
+	}
+	// ORDER BY score DESC, r.start_time DESC, r.id DESC
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].Score != rows[j].Score {
+			return rows[i].Score > rows[j].Score
+		}
+		if !rows[i].StartTime.Equal(rows[j].StartTime) {
+			return rows[i].StartTime.After(rows[j].StartTime)
+		}
+		return rows[i].ID > rows[j].ID
+	})
+	if int(arg.Offset) >= len(rows) {
+		return nil, nil
+	}
+	rows = rows[arg.Offset:]
+	if len(rows) > int(arg.Limit) {
+		rows = rows[:arg.Limit]
+	}
+	return rows, nil
+}
+
+func (m *Memory) UpdateReservationByID(ctx context.Context, arg db.UpdateReservationByIDParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if r, ok := m.reservations[arg.ID]; ok {
+		r.Title = arg.Title
+		r.Description = arg.Description
+		r.Headcount = arg.Headcount
+		r.Visibility = arg.Visibility
+		r.StartTime = arg.StartTime
+		r.EndTime = arg.EndTime
+		r.UpdatedAt = time.Now()
+		m.reservations[arg.ID] = r
+	}
+	return nil
+}
+
+func (m *Memory) CanceledReservationByID(ctx context.Context, arg db.CanceledReservationByIDParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// user_id = ? AND id = ? AND status = 'confirmed'
+	if r, ok := m.reservations[arg.ID]; ok && r.UserID == arg.UserID && r.Status == "confirmed" {
+		r.Status = "canceled"
+		r.UpdatedAt = time.Now()
+		m.reservations[arg.ID] = r
+	}
+	return nil
+}
+
+func (m *Memory) CheckInReservation(ctx context.Context, id uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// status = 'confirmed' AND checked_in_at IS NULL
+	if r, ok := m.reservations[id]; ok && r.Status == "confirmed" && !r.CheckedInAt.Valid {
+		now := time.Now()
+		r.CheckedInAt = sql.NullTime{Time: now, Valid: true}
+		r.UpdatedAt = now
+		m.reservations[id] = r
+	}
+	return nil
+}
+
+func (m *Memory) MarkReservationNoShow(ctx context.Context, id uint64) (sql.Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// status = 'confirmed' AND checked_in_at IS NULL AND actual_end_time IS NULL
+	r, ok := m.reservations[id]
+	if !ok || r.Status != "confirmed" || r.CheckedInAt.Valid || r.ActualEndTime.Valid {
+		return driver.RowsAffected(0), nil
+	}
+	r.Status = "no_show"
+	r.UpdatedAt = time.Now()
+	m.reservations[id] = r
+	return driver.RowsAffected(1), nil
+}
+
+func (m *Memory) EndReservationEarly(ctx context.Context, arg db.EndReservationEarlyParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if r, ok := m.reservations[arg.ID]; ok {
+		if !r.BookedEndTime.Valid {
+			r.BookedEndTime = sql.NullTime{Time: r.EndTime, Valid: true}
+		}
+		r.EndTime = arg.EndTime
+		r.ActualEndTime = arg.ActualEndTime
+		r.UpdatedAt = time.Now()
+		m.reservations[arg.ID] = r
+	}
+	return nil
+}
+
+func (m *Memory) ExtendReservation(ctx context.Context, arg db.ExtendReservationParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if r, ok := m.reservations[arg.ID]; ok {
+		if !r.BookedEndTime.Valid {
+			r.BookedEndTime = sql.NullTime{Time: r.EndTime, Valid: true}
+		}
+		r.EndTime = arg.EndTime
+		r.UpdatedAt = time.Now()
+		m.reservations[arg.ID] = r
+	}
+	return nil
+}
+
+func (m *Memory) CreateReservationFromCaldav(ctx context.Context, arg db.CreateReservationFromCaldavParams) (sql.Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[arg.UserID]; !ok {
+		return nil, errors.New("store: user not found")
+	}
+	m.nextID++
+	now := time.Now()
+	m.reservations[m.nextID] = db.Reservation{
+		ID:         m.nextID,
+		UserID:     arg.UserID,
+		Title:      arg.Title,
+		Visibility: "public",
+		StartTime:  arg.StartTime,
+		EndTime:    arg.EndTime,
+		Status:     "confirmed",
+		Origin:     "caldav",
+		IcalUid:    arg.IcalUid,
+		CaldavName: arg.CaldavName,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	return result{id: int64(m.nextID)}, nil
+}
+
+func (m *Memory) GetReservationByCaldavName(ctx context.Context, caldavName sql.NullString) (db.Reservation, error) {
+	// status = 'confirmed' AND caldav_name = ?
+	for _, r := range m.filterReservations(func(r db.Reservation) bool {
+		return r.Status == "confirmed" && caldavName.Valid && r.CaldavName == caldavName
+	}) {
+		return r, nil
+	}
+	return db.Reservation{}, sql.ErrNoRows
+}
+
+func (m *Memory) CreateReservationFromCalendar(ctx context.Context, arg db.CreateReservationFromCalendarParams) (sql.Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[arg.UserID]; !ok {
+		return nil, errors.New("store: user not found")
+	}
+	m.nextID++
+	now := time.Now()
+	m.reservations[m.nextID] = db.Reservation{
+		ID:            m.nextID,
+		UserID:        arg.UserID,
+		Title:         arg.Title,
+		Visibility:    "public",
+		StartTime:     arg.StartTime,
+		EndTime:       arg.EndTime,
+		Status:        "confirmed",
+		Origin:        "google_calendar",
+		GoogleEventID: arg.GoogleEventID,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	return result{id: int64(m.nextID)}, nil
+}
+
+func (m *Memory) GetReservationByGoogleEventID(ctx context.Context, googleEventID sql.NullString) (db.Reservation, error) {
+	// google_event_id = ? (状態は問わない)
+	for _, r := range m.filterReservations(func(r db.Reservation) bool {
+		return googleEventID.Valid && r.GoogleEventID == googleEventID
+	}) {
+		return r, nil
+	}
+	return db.Reservation{}, sql.ErrNoRows
+}
+
+// SetReservationGoogleEventID はクエリと同じく updated_at を変更しません。
+func (m *Memory) SetReservationGoogleEventID(ctx context.Context, arg db.SetReservationGoogleEventIDParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if r, ok := m.reservations[arg.ID]; ok {
+		r.GoogleEventID = arg.GoogleEventID
+		m.reservations[arg.ID] = r
+	}
+	return nil
+}
+
+func (m *Memory) GetCalendarSyncToken(ctx context.Context, calendarID string) (sql.NullString, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token, ok := m.syncTokens[calendarID]
+	if !ok {
+		return sql.NullString{}, sql.ErrNoRows
+	}
+	return token, nil
+}
+
+func (m *Memory) UpsertCalendarSyncToken(ctx context.Context, arg db.UpsertCalendarSyncTokenParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.syncTokens[arg.CalendarID] = arg.SyncToken
+	return nil
+}
+
+func (m *Memory) CreateCheckinToken(ctx context.Context, arg db.CreateCheckinTokenParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.checkinTokens[arg.ReservationID]; ok {
+		return ErrDuplicate
+	}
+	for _, token := range m.checkinTokens {
+		if token == arg.Token {
+			return ErrDuplicate
+		}
+	}
+	m.checkinTokens[arg.ReservationID] = arg.Token
+	return nil
+}
+
+func (m *Memory) GetCheckinTokenByReservationID(ctx context.Context, reservationID uint64) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token, ok := m.checkinTokens[reservationID]
+	if !ok {
+		return "", sql.ErrNoRows
+	}
+	return token, nil
+}
+
+func (m *Memory) GetReservationByCheckinToken(ctx context.Context, token string) (db.Reservation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, t := range m.checkinTokens {
+		if t == token {
+			if r, ok := m.reservations[id]; ok {
+				return r, nil
+			}
+		}
+	}
+	return db.Reservation{}, sql.ErrNoRows
+}
+
+func (m *Memory) LockRoom(ctx context.Context, id string) (string, error) {
+	// InTx がトランザクションを1つずつ実行するため、ロックは不要です。
+	return id, nil
+}
+
+func (m *Memory) ListBusyIntervals(ctx context.Context, arg db.ListBusyIntervalsParams) ([]db.ListBusyIntervalsRow, error) {
+	// status = 'confirmed' AND start_time < range_end AND end_time > range_start ORDER BY start_time
+	var rows []db.ListBusyIntervalsRow
+	for _, r := range m.filterReservations(func(r db.Reservation) bool {
+		return r.Status == "confirmed" && r.StartTime.Before(arg.RangeEnd) && r.EndTime.After(arg.RangeStart)
+	}) {
+		rows = append(rows, db.ListBusyIntervalsRow{StartTime: r.StartTime, EndTime: r.EndTime})
+	}
+	return rows, nil
+}
+
+func (m *Memory) ListOverlappingReservationIDsForUpdate(ctx context.Context, arg db.ListOverlappingReservationIDsForUpdateParams) ([]uint64, error) {
+	// status = 'confirmed' AND start_time < range_end AND end_time > range_start AND id <> exclude_id ORDER BY id
+	// InTx がトランザクションを1つずつ実行するため、行ロックは取りません。
+	var ids []uint64
+	for _, r := range m.filterReservations(func(r db.Reservation) bool {
+		return r.Status == "confirmed" && r.StartTime.Before(arg.RangeEnd) && r.EndTime.After(arg.RangeStart) && r.ID != arg.ExcludeID
+	}) {
+		ids = append(ids, r.ID)
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+func (m *Memory) CountNoShowsByUserID(ctx context.Context, arg db.CountNoShowsByUserIDParams) (int64, error) {
+	// user_id = ? AND status = 'no_show' AND start_time >= ?
+	return int64(len(m.filterReservations(func(r db.Reservation) bool {
+		return r.UserID == arg.UserID && r.Status == "no_show" && !r.StartTime.Before(arg.StartTime)
+	}))), nil
+}
+
+func (m *Memory) CreateReservationEvent(ctx context.Context, arg db.CreateReservationEventParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.events = append(m.events, arg)
+	return nil
+}
+
+func (m *Memory) AddReservationAttendee(ctx context.Context, arg db.AddReservationAttendeeParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.reservations[arg.ReservationID]; !ok {
+		return errors.New("store: reservation not found")
+	}
+	if _, ok := m.users[arg.UserID]; !ok {
+		return errors.New("store: user not found")
+	}
+	attendees := m.attendees[arg.ReservationID]
+	if slices.ContainsFunc(attendees, func(a db.ReservationAttendee) bool { return a.UserID == arg.UserID }) {
+		return ErrDuplicate
+	}
+	// InTx で元に戻せるよう、スライスは共有せずに作り直す
+	attendees = append(slices.Clone(attendees), db.ReservationAttendee{
+		ReservationID: arg.ReservationID,
+		UserID:        arg.UserID,
+		CreatedAt:     time.Now(),
+		Response:      "needs_action",
+	})
+	slices.SortFunc(attendees, func(a, b db.ReservationAttendee) int { return cmp.Compare(a.UserID, b.UserID) })
+	m.attendees[arg.ReservationID] = attendees
+	return nil
+}
+
+func (m *Memory) DeleteReservationAttendee(ctx context.Context, arg db.DeleteReservationAttendeeParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.attendees[arg.ReservationID] = slices.DeleteFunc(slices.Clone(m.attendees[arg.ReservationID]), func(a db.ReservationAttendee) bool {
+		return a.UserID == arg.UserID
+	})
+	return nil
+}
+
+func (m *Memory) UpdateReservationAttendeeResponse(ctx context.Context, arg db.UpdateReservationAttendeeResponseParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	attendees := slices.Clone(m.attendees[arg.ReservationID])
+	for i, a := range attendees {
+		if a.UserID == arg.UserID {
+			attendees[i].Response = arg.Response
+			attendees[i].RespondedAt = arg.RespondedAt
+		}
+	}
+	m.attendees[arg.ReservationID] = attendees
+	return nil
+}
+
+func (m *Memory) ListReservationAttendees(ctx context.Context, reservationIds []uint64) ([]db.ListReservationAttendeesRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := slices.Clone(reservationIds)
+	slices.Sort(ids)
+	var rows []db.ListReservationAttendeesRow
+	for _, id := range slices.Compact(ids) {
+		for _, a := range m.attendees[id] {
+			u, ok := m.users[a.UserID]
+			if !ok || u.DeletedAt.Valid {
+				continue
+			}
+			rows = append(rows, db.ListReservationAttendeesRow{
+				ReservationID: id,
+				UserID:        a.UserID,
+				Name:          u.Name,
+				Response:      a.Response,
+				RespondedAt:   a.RespondedAt,
+			})
+		}
+	}
+	return rows, nil
+}
+
+func (m *Memory) ListInvitationsByUserID(ctx context.Context, arg db.ListInvitationsByUserIDParams) ([]db.ListInvitationsByUserIDRow, error) {
+	m.mu.Lock()
+	responses := map[uint64]db.ReservationAttendee{}
+	for id, attendees := range m.attendees {
+		for _, a := range attendees {
+			if a.UserID == arg.UserID {
+				responses[id] = a
+			}
+		}
+	}
+	m.mu.Unlock()
+
+	var rows []db.ListInvitationsByUserIDRow
+	for _, r := range m.withUserName(m.filterReservations(func(r db.Reservation) bool {
+		_, invited := responses[r.ID]
+		return invited && r.Status == "confirmed" && !r.EndTime.Before(arg.After)
+	})) {
+		a := responses[r.ID]
+		rows = append(rows, db.ListInvitationsByUserIDRow{
+			ID:            r.ID,
+			UserID:        r.UserID,
+			Title:         r.Title,
+			StartTime:     r.StartTime,
+			EndTime:       r.EndTime,
+			Status:        r.Status,
+			CheckedInAt:   r.CheckedInAt,
+			ActualEndTime: r.ActualEndTime,
+			BookedEndTime: r.BookedEndTime,
+			Origin:        r.Origin,
+			GoogleEventID: r.GoogleEventID,
+			IcalUid:       r.IcalUid,
+			CaldavName:    r.CaldavName,
+			CreatedAt:     r.CreatedAt,
+			UpdatedAt:     r.UpdatedAt,
+			Description:   r.Description,
+			Headcount:     r.Headcount,
+			Visibility:    r.Visibility,
+			UserName:      r.UserName,
+			Response:      a.Response,
+			RespondedAt:   a.RespondedAt,
+		})
+	}
+	return rows, nil
+}
+
+func (m *Memory) CreateEquipment(ctx context.Context, arg db.CreateEquipmentParams) (sql.Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, e := range m.equipment {
+		if e.Name == arg.Name {
+			return nil, ErrDuplicate
+		}
+	}
+	m.nextEquipmentID++
+	now := time.Now()
+	m.equipment[m.nextEquipmentID] = db.Equipment{
+		ID:        m.nextEquipmentID,
+		Name:      arg.Name,
+		Quantity:  arg.Quantity,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	return result{id: int64(m.nextEquipmentID)}, nil
+}
+
+func (m *Memory) UpdateEquipment(ctx context.Context, arg db.UpdateEquipmentParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, e := range m.equipment {
+		if e.Name == arg.Name && e.ID != arg.ID {
+			return ErrDuplicate
+		}
+	}
+	if e, ok := m.equipment[arg.ID]; ok {
+		e.Name = arg.Name
+		e.Quantity = arg.Quantity
+		e.UpdatedAt = time.Now()
+		m.equipment[arg.ID] = e
+	}
+	return nil
+}
+
+func (m *Memory) GetEquipmentByID(ctx context.Context, id uint64) (db.Equipment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.equipment[id]
+	if !ok {
+		return db.Equipment{}, sql.ErrNoRows
+	}
+	return e, nil
+}
+
+func (m *Memory) GetEquipmentByIDForUpdate(ctx context.Context, id uint64) (db.Equipment, error) {
+	return m.GetEquipmentByID(ctx, id)
+}
+
+func (m *Memory) ListEquipment(ctx context.Context) ([]db.Equipment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var equipment []db.Equipment
+	for _, e := range m.equipment {
+		equipment = append(equipment, e)
+	}
+	slices.SortFunc(equipment, func(a, b db.Equipment) int { return cmp.Compare(a.ID, b.ID) })
+	return equipment, nil
+}
+
+func (m *Memory) ListEquipmentUsage(ctx context.Context, arg db.ListEquipmentUsageParams) ([]db.ListEquipmentUsageRow, error) {
+	// status = 'confirmed' AND start_time < range_end AND end_time > range_start AND id <> exclude_id
+	reservations := m.filterReservations(func(r db.Reservation) bool {
+		return r.Status == "confirmed" && r.StartTime.Before(arg.RangeEnd) && r.EndTime.After(arg.RangeStart) && r.ID != arg.ExcludeID
+	})
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var rows []db.ListEquipmentUsageRow
+	for _, r := range reservations {
+		for _, re := range m.reservationEquipment[r.ID] {
+			rows = append(rows, db.ListEquipmentUsageRow{
+				ReservationID: r.ID,
+				EquipmentID:   re.EquipmentID,
+				Quantity:      re.Quantity,
+				StartTime:     r.StartTime,
+				EndTime:       r.EndTime,
+			})
+		}
+	}
+	// reservations は開始時刻順なので、備品IDで安定ソートすればクエリと同じ順になる
+	slices.SortStableFunc(rows, func(a, b db.ListEquipmentUsageRow) int { return cmp.Compare(a.EquipmentID, b.EquipmentID) })
+	return rows, nil
+}
+
+func (m *Memory) AddReservationEquipment(ctx context.Context, arg db.AddReservationEquipmentParams) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.reservations[arg.ReservationID]; !ok {
+		return errors.New("store: reservation not found")
+	}
+	if _, ok := m.equipment[arg.EquipmentID]; !ok {
+		return errors.New("store: equipment not found")
+	}
+	equipment := m.reservationEquipment[arg.ReservationID]
+	if slices.ContainsFunc(equipment, func(re db.ReservationEquipment) bool { return re.EquipmentID == arg.EquipmentID }) {
+		return ErrDuplicate
+	}
+	// InTx で元に戻せるよう、スライスは共有せずに作り直す
+	equipment = append(slices.Clone(equipment), db.ReservationEquipment(arg))
+	slices.SortFunc(equipment, func(a, b db.ReservationEquipment) int { return cmp.Compare(a.EquipmentID, b.EquipmentID) })
+	m.reservationEquipment[arg.ReservationID] = equipment
+	return nil
+}
+
+func (m *Memory) DeleteReservationEquipment(ctx context.Context, reservationID uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.reservationEquipment, reservationID)
+	return nil
+}
+
+func (m *Memory) ListReservationEquipment(ctx context.Context, reservationIds []uint64) ([]db.ListReservationEquipmentRow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := slices.Clone(reservationIds)
+	slices.Sort(ids)
+	var rows []db.ListReservationEquipmentRow
+	for _, id := range slices.Compact(ids) {
+		for _, re := range m.reservationEquipment[id] {
+			rows = append(rows, db.ListReservationEquipmentRow{
+				ReservationID: id,
+				EquipmentID:   re.EquipmentID,
+				Name:          m.equipment[re.EquipmentID].Name,
+				Quantity:      re.Quantity,
+			})
+		}
+	}
+	return rows, nil
+}
+
+// attending は userID が参加者になっている予約のIDと、その返答を返します。m.mu をロックしてから呼んでください。
+func (m *Memory) attending(userID uint64) map[uint64]string {
+	responses := map[uint64]string{}
+	for id, attendees := range m.attendees {
+		for _, a := range attendees {
+			if a.UserID == userID {
+				responses[id] = a.Response
+			}
+		}
+	}
+	return responses
+}
+
+// filterReservations は条件に合う予約を開始時刻順に返します。
+func (m *Memory) filterReservations(match func(db.Reservation) bool) []db.Reservation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var reservations []db.Reservation
+	for _, r := range m.reservations {
+		if match(r) {
+			reservations = append(reservations, r)
+		}
+	}
+	sort.Slice(reservations, func(i, j int) bool {
+		if !reservations[i].StartTime.Equal(reservations[j].StartTime) {
+			return reservations[i].StartTime.Before(reservations[j].StartTime)
+		}
+		return reservations[i].ID < reservations[j].ID
+	})
+	return reservations
+}
+
+// listWithUserName は一覧のクエリと同じく、確定済みで削除されていないユーザーの予約に予約者の名前を付けて返します。
+func (m *Memory) listWithUserName(match func(db.Reservation) bool) []db.ListReservationsByDateRow {
+	return m.withUserName(m.filterReservations(func(r db.Reservation) bool {
+		return r.Status == "confirmed" && match(r)
+	}))
+}
+
+// withUserName は削除されていないユーザーの予約に予約者の名前を付けて返します。
+func (m *Memory) withUserName(reservations []db.Reservation) []db.ListReservationsByDateRow {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var rows []db.ListReservationsByDateRow
+	for _, r := range reservations {
+		u, ok := m.users[r.UserID]
+		if !ok || u.DeletedAt.Valid {
+			continue
+		}
+		rows = append(rows, db.ListReservationsByDateRow{
+			ID:            r.ID,
+			UserID:        r.UserID,
+			Title:         r.Title,
+			StartTime:     r.StartTime,
+			EndTime:       r.EndTime,
+			Status:        r.Status,
+			CheckedInAt:   r.CheckedInAt,
+			ActualEndTime: r.ActualEndTime,
+			BookedEndTime: r.BookedEndTime,
+			Origin:        r.Origin,
+			GoogleEventID: r.GoogleEventID,
+			IcalUid:       r.IcalUid,
+			CaldavName:    r.CaldavName,
+			CreatedAt:     r.CreatedAt,
+			UpdatedAt:     r.UpdatedAt,
+			Description:   r.Description,
+			Headcount:     r.Headcount,
+			Visibility:    r.Visibility,
+			UserName:      u.Name,
+		})
+	}
+	return rows
+}
+
+func copyMap[K comparable, V any](src map[K]V) map[K]V {
+	dst := make(map[K]V, len(src))
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
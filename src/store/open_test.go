@@ -0,0 +1,43 @@
+package store
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMySQLDSN(t *testing.T) {
+	tests := []struct {
+		name    string
+		dsn     string
+		want    string
+		wantErr string
+	}{
+		{"loc defaults to UTC", "user:pass@tcp(db:3306)/app?parseTime=true",
+			"user:pass@tcp(db:3306)/app?parseTime=true&time_zone=%27%2B00%3A00%27", ""},
+		{"time_zone follows loc", "user:pass@tcp(db:3306)/app?parseTime=true&loc=Asia%2FTokyo",
+			"user:pass@tcp(db:3306)/app?loc=Asia%2FTokyo&parseTime=true&time_zone=%27Asia%2FTokyo%27", ""},
+		{"explicit time_zone", "user:pass@tcp(db:3306)/app?parseTime=true&time_zone=%27%2B09%3A00%27&loc=Asia%2FTokyo",
+			"user:pass@tcp(db:3306)/app?loc=Asia%2FTokyo&parseTime=true&time_zone=%27%2B09%3A00%27", ""},
+		{"missing parseTime", "user:pass@tcp(db:3306)/app", "", "parseTime=true"},
+		{"parseTime=false", "user:pass@tcp(db:3306)/app?parseTime=false", "", "parseTime=true"},
+		{"local loc", "user:pass@tcp(db:3306)/app?parseTime=true&loc=Local", "", "Local"},
+		{"unknown loc", "user:pass@tcp(db:3306)/app?parseTime=true&loc=Mars%2FOlympus", "", "形式"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mysqlDSN(tt.dsn)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q を含むエラー", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
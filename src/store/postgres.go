@@ -0,0 +1,358 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"yoyaku/db"
+	"yoyaku/db/pgdb"
+)
+
+// postgresStore は PostgreSQL 用に生成したクエリ (db/pgdb) を Store として使えるようにしたものです。
+// 生成された構造体は db パッケージと同じ形なので、型変換だけで受け渡しします。
+type postgresStore struct {
+	q *pgdb.Queries
+	// sqlDB はトランザクション内では nil です。
+	sqlDB *sql.DB
+}
+
+// NewPostgres は PostgreSQL に読み書きする Store を返します。
+func NewPostgres(sqlDB *sql.DB) Store {
+	return &postgresStore{q: pgdb.New(sqlDB), sqlDB: sqlDB}
+}
+
+func (s *postgresStore) InTx(ctx context.Context, fn func(tx Store) error) error {
+	if s.sqlDB == nil {
+		return fn(s)
+	}
+	return runInTx(ctx, s.sqlDB, func(tx *sql.Tx) error {
+		return fn(&postgresStore{q: pgdb.New(tx)})
+	})
+}
+
+func (s *postgresStore) AddReservationAttendee(ctx context.Context, arg db.AddReservationAttendeeParams) error {
+	return s.q.AddReservationAttendee(ctx, pgdb.AddReservationAttendeeParams(arg))
+}
+
+func (s *postgresStore) AddReservationEquipment(ctx context.Context, arg db.AddReservationEquipmentParams) error {
+	return s.q.AddReservationEquipment(ctx, pgdb.AddReservationEquipmentParams(arg))
+}
+
+func (s *postgresStore) CanceledReservationByID(ctx context.Context, arg db.CanceledReservationByIDParams) error {
+	return s.q.CanceledReservationByID(ctx, pgdb.CanceledReservationByIDParams(arg))
+}
+
+func (s *postgresStore) CheckInReservation(ctx context.Context, id uint64) error {
+	return s.q.CheckInReservation(ctx, id)
+}
+
+func (s *postgresStore) CountNoShowsByUserID(ctx context.Context, arg db.CountNoShowsByUserIDParams) (int64, error) {
+	return s.q.CountNoShowsByUserID(ctx, pgdb.CountNoShowsByUserIDParams(arg))
+}
+
+func (s *postgresStore) CreateCheckinToken(ctx context.Context, arg db.CreateCheckinTokenParams) error {
+	return s.q.CreateCheckinToken(ctx, pgdb.CreateCheckinTokenParams(arg))
+}
+
+func (s *postgresStore) CreateEquipment(ctx context.Context, arg db.CreateEquipmentParams) (sql.Result, error) {
+	id, err := s.q.CreateEquipment(ctx, pgdb.CreateEquipmentParams(arg))
+	if err != nil {
+		return nil, err
+	}
+	return result{id: int64(id)}, nil
+}
+
+func (s *postgresStore) CreateReservation(ctx context.Context, arg db.CreateReservationParams) (sql.Result, error) {
+	id, err := s.q.CreateReservation(ctx, pgdb.CreateReservationParams(arg))
+	if err != nil {
+		return nil, err
+	}
+	return result{id: int64(id)}, nil
+}
+
+func (s *postgresStore) CreateReservationEvent(ctx context.Context, arg db.CreateReservationEventParams) error {
+	return s.q.CreateReservationEvent(ctx, pgdb.CreateReservationEventParams(arg))
+}
+
+func (s *postgresStore) CreateReservationFromCaldav(ctx context.Context, arg db.CreateReservationFromCaldavParams) (sql.Result, error) {
+	id, err := s.q.CreateReservationFromCaldav(ctx, pgdb.CreateReservationFromCaldavParams(arg))
+	if err != nil {
+		return nil, err
+	}
+	return result{id: int64(id)}, nil
+}
+
+func (s *postgresStore) CreateReservationFromCalendar(ctx context.Context, arg db.CreateReservationFromCalendarParams) (sql.Result, error) {
+	id, err := s.q.CreateReservationFromCalendar(ctx, pgdb.CreateReservationFromCalendarParams(arg))
+	if err != nil {
+		return nil, err
+	}
+	return result{id: int64(id)}, nil
+}
+
+func (s *postgresStore) CreateUser(ctx context.Context, arg db.CreateUserParams) (sql.Result, error) {
+	id, err := s.q.CreateUser(ctx, pgdb.CreateUserParams(arg))
+	if err != nil {
+		return nil, err
+	}
+	return result{id: int64(id)}, nil
+}
+
+func (s *postgresStore) DeleteReservationAttendee(ctx context.Context, arg db.DeleteReservationAttendeeParams) error {
+	return s.q.DeleteReservationAttendee(ctx, pgdb.DeleteReservationAttendeeParams(arg))
+}
+
+func (s *postgresStore) DeleteReservationEquipment(ctx context.Context, reservationID uint64) error {
+	return s.q.DeleteReservationEquipment(ctx, reservationID)
+}
+
+func (s *postgresStore) EndReservationEarly(ctx context.Context, arg db.EndReservationEarlyParams) error {
+	return s.q.EndReservationEarly(ctx, pgdb.EndReservationEarlyParams(arg))
+}
+
+func (s *postgresStore) ExtendReservation(ctx context.Context, arg db.ExtendReservationParams) error {
+	return s.q.ExtendReservation(ctx, pgdb.ExtendReservationParams(arg))
+}
+
+func (s *postgresStore) GetCalendarSyncToken(ctx context.Context, calendarID string) (sql.NullString, error) {
+	return s.q.GetCalendarSyncToken(ctx, calendarID)
+}
+
+func (s *postgresStore) GetCheckinTokenByReservationID(ctx context.Context, reservationID uint64) (string, error) {
+	return s.q.GetCheckinTokenByReservationID(ctx, reservationID)
+}
+
+func (s *postgresStore) GetEquipmentByID(ctx context.Context, id uint64) (db.Equipment, error) {
+	r, err := s.q.GetEquipmentByID(ctx, id)
+	return db.Equipment(r), err
+}
+
+func (s *postgresStore) GetEquipmentByIDForUpdate(ctx context.Context, id uint64) (db.Equipment, error) {
+	r, err := s.q.GetEquipmentByIDForUpdate(ctx, id)
+	return db.Equipment(r), err
+}
+
+func (s *postgresStore) GetReservationByCaldavName(ctx context.Context, caldavName sql.NullString) (db.Reservation, error) {
+	r, err := s.q.GetReservationByCaldavName(ctx, caldavName)
+	return db.Reservation(r), err
+}
+
+func (s *postgresStore) GetReservationByCheckinToken(ctx context.Context, token string) (db.Reservation, error) {
+	r, err := s.q.GetReservationByCheckinToken(ctx, token)
+	return db.Reservation(r), err
+}
+
+func (s *postgresStore) GetReservationByGoogleEventID(ctx context.Context, googleEventID sql.NullString) (db.Reservation, error) {
+	r, err := s.q.GetReservationByGoogleEventID(ctx, googleEventID)
+	return db.Reservation(r), err
+}
+
+func (s *postgresStore) GetReservationByID(ctx context.Context, id uint64) (db.Reservation, error) {
+	r, err := s.q.GetReservationByID(ctx, id)
+	return db.Reservation(r), err
+}
+
+func (s *postgresStore) GetReservationByIDForUpdate(ctx context.Context, id uint64) (db.Reservation, error) {
+	r, err := s.q.GetReservationByIDForUpdate(ctx, id)
+	return db.Reservation(r), err
+}
+
+func (s *postgresStore) GetUserByEmail(ctx context.Context, email string) (db.User, error) {
+	r, err := s.q.GetUserByEmail(ctx, email)
+	return db.User(r), err
+}
+
+func (s *postgresStore) GetUserByGoogleID(ctx context.Context, googleID string) (db.User, error) {
+	r, err := s.q.GetUserByGoogleID(ctx, googleID)
+	return db.User(r), err
+}
+
+func (s *postgresStore) GetUserByID(ctx context.Context, id uint64) (db.User, error) {
+	r, err := s.q.GetUserByID(ctx, id)
+	return db.User(r), err
+}
+
+func (s *postgresStore) ListBusyIntervals(ctx context.Context, arg db.ListBusyIntervalsParams) ([]db.ListBusyIntervalsRow, error) {
+	rows, err := s.q.ListBusyIntervals(ctx, pgdb.ListBusyIntervalsParams(arg))
+	return convertAll(rows, func(r pgdb.ListBusyIntervalsRow) db.ListBusyIntervalsRow { return db.ListBusyIntervalsRow(r) }), err
+}
+
+func (s *postgresStore) ListConfirmedReservations(ctx context.Context) ([]db.Reservation, error) {
+	rows, err := s.q.ListConfirmedReservations(ctx)
+	return convertAll(rows, func(r pgdb.Reservation) db.Reservation { return db.Reservation(r) }), err
+}
+
+func (s *postgresStore) ListEquipment(ctx context.Context) ([]db.Equipment, error) {
+	rows, err := s.q.ListEquipment(ctx)
+	return convertAll(rows, func(r pgdb.Equipment) db.Equipment { return db.Equipment(r) }), err
+}
+
+func (s *postgresStore) ListEquipmentUsage(ctx context.Context, arg db.ListEquipmentUsageParams) ([]db.ListEquipmentUsageRow, error) {
+	rows, err := s.q.ListEquipmentUsage(ctx, pgdb.ListEquipmentUsageParams(arg))
+	return convertAll(rows, func(r pgdb.ListEquipmentUsageRow) db.ListEquipmentUsageRow { return db.ListEquipmentUsageRow(r) }), err
+}
+
+func (s *postgresStore) ListInvitationsByUserID(ctx context.Context, arg db.ListInvitationsByUserIDParams) ([]db.ListInvitationsByUserIDRow, error) {
+	rows, err := s.q.ListInvitationsByUserID(ctx, pgdb.ListInvitationsByUserIDParams(arg))
+	return convertAll(rows, func(r pgdb.ListInvitationsByUserIDRow) db.ListInvitationsByUserIDRow {
+		return db.ListInvitationsByUserIDRow(r)
+	}), err
+}
+
+func (s *postgresStore) ListNoShowCandidates(ctx context.Context, arg db.ListNoShowCandidatesParams) ([]db.Reservation, error) {
+	rows, err := s.q.ListNoShowCandidates(ctx, pgdb.ListNoShowCandidatesParams(arg))
+	return convertAll(rows, func(r pgdb.Reservation) db.Reservation { return db.Reservation(r) }), err
+}
+
+func (s *postgresStore) LockRoom(ctx context.Context, id string) (string, error) {
+	return s.q.LockRoom(ctx, id)
+}
+
+func (s *postgresStore) ListOverlappingReservationIDsForUpdate(ctx context.Context, arg db.ListOverlappingReservationIDsForUpdateParams) ([]uint64, error) {
+	return s.q.ListOverlappingReservationIDsForUpdate(ctx, pgdb.ListOverlappingReservationIDsForUpdateParams(arg))
+}
+
+func (s *postgresStore) ListReservationAttendees(ctx context.Context, reservationIds []uint64) ([]db.ListReservationAttendeesRow, error) {
+	// pgdb のクエリは lib/pq を使わないよう、IDをカンマ区切りの文字列で受け取る
+	ids := make([]string, len(reservationIds))
+	for i, id := range reservationIds {
+		ids[i] = strconv.FormatUint(id, 10)
+	}
+	rows, err := s.q.ListReservationAttendees(ctx, strings.Join(ids, ","))
+	return convertAll(rows, func(r pgdb.ListReservationAttendeesRow) db.ListReservationAttendeesRow {
+		return db.ListReservationAttendeesRow(r)
+	}), err
+}
+
+func (s *postgresStore) ListReservationEquipment(ctx context.Context, reservationIds []uint64) ([]db.ListReservationEquipmentRow, error) {
+	// pgdb のクエリは lib/pq を使わないよう、IDをカンマ区切りの文字列で受け取る
+	ids := make([]string, len(reservationIds))
+	for i, id := range reservationIds {
+		ids[i] = strconv.FormatUint(id, 10)
+	}
+	rows, err := s.q.ListReservationEquipment(ctx, strings.Join(ids, ","))
+	return convertAll(rows, func(r pgdb.ListReservationEquipmentRow) db.ListReservationEquipmentRow {
+		return db.ListReservationEquipmentRow(r)
+	}), err
+}
+
+func (s *postgresStore) ListReservationEventsByReservationID(ctx context.Context, reservationID uint64) ([]db.ListReservationEventsByReservationIDRow, error) {
+	rows, err := s.q.ListReservationEventsByReservationID(ctx, reservationID)
+	return convertAll(rows, func(r pgdb.ListReservationEventsByReservationIDRow) db.ListReservationEventsByReservationIDRow {
+		return db.ListReservationEventsByReservationIDRow(r)
+	}), err
+}
+
+func (s *postgresStore) ListReservationsByDate(ctx context.Context, arg db.ListReservationsByDateParams) ([]db.ListReservationsByDateRow, error) {
+	rows, err := s.q.ListReservationsByDate(ctx, pgdb.ListReservationsByDateParams(arg))
+	return convertAll(rows, func(r pgdb.ListReservationsByDateRow) db.ListReservationsByDateRow {
+		return db.ListReservationsByDateRow(r)
+	}), err
+}
+
+func (s *postgresStore) ListReservationsByUserID(ctx context.Context, userID uint64) ([]db.Reservation, error) {
+	rows, err := s.q.ListReservationsByUserID(ctx, userID)
+	return convertAll(rows, func(r pgdb.Reservation) db.Reservation { return db.Reservation(r) }), err
+}
+
+func (s *postgresStore) ListUsers(ctx context.Context) ([]db.User, error) {
+	rows, err := s.q.ListUsers(ctx)
+	return convertAll(rows, func(r pgdb.User) db.User { return db.User(r) }), err
+}
+
+func (s *postgresStore) MarkReservationNoShow(ctx context.Context, id uint64) (sql.Result, error) {
+	return s.q.MarkReservationNoShow(ctx, id)
+}
+
+func (s *postgresStore) SearchReservationEvents(ctx context.Context, arg db.SearchReservationEventsParams) ([]db.SearchReservationEventsRow, error) {
+	rows, err := s.q.SearchReservationEvents(ctx, pgdb.SearchReservationEventsParams{
+		ActorUserID:   arg.ActorUserID,
+		ReservationID: arg.ReservationID,
+		Action:        arg.Action,
+		AuthMethod:    arg.AuthMethod,
+		CreatedFrom:   arg.CreatedFrom,
+		CreatedTo:     arg.CreatedTo,
+		Limit:         arg.Limit,
+		Offset:        arg.Offset,
+	})
+	return convertAll(rows, func(r pgdb.SearchReservationEventsRow) db.SearchReservationEventsRow {
+		return db.SearchReservationEventsRow(r)
+	}), err
+}
+
+func (s *postgresStore) SearchReservations(ctx context.Context, arg db.SearchReservationsParams) ([]db.SearchReservationsRow, error) {
+	rows, err := s.q.SearchReservations(ctx, postgresSearchParams(arg))
+	return convertAll(rows, func(r pgdb.SearchReservationsRow) db.SearchReservationsRow {
+		return db.SearchReservationsRow(r)
+	}), err
+}
+
+func (s *postgresStore) SearchReservationsDesc(ctx context.Context, arg db.SearchReservationsDescParams) ([]db.SearchReservationsDescRow, error) {
+	rows, err := s.q.SearchReservationsDesc(ctx, pgdb.SearchReservationsDescParams(postgresSearchParams(db.SearchReservationsParams(arg))))
+	return convertAll(rows, func(r pgdb.SearchReservationsDescRow) db.SearchReservationsDescRow {
+		return db.SearchReservationsDescRow(r)
+	}), err
+}
+
+func (s *postgresStore) SearchReservationsFullText(ctx context.Context, arg db.SearchReservationsFullTextParams) ([]db.SearchReservationsFullTextRow, error) {
+	rows, err := s.q.SearchReservationsFullText(ctx, pgdb.SearchReservationsFullTextParams{
+		Query:    arg.Query,
+		ViewerID: arg.ViewerID,
+		Offset:   arg.Offset,
+		Limit:    arg.Limit,
+	})
+	return convertAll(rows, func(r pgdb.SearchReservationsFullTextRow) db.SearchReservationsFullTextRow {
+		return db.SearchReservationsFullTextRow(r)
+	}), err
+}
+
+// postgresSearchParams は予約の検索条件を変換します。cursor_id は cursor_start_time が NULL の場合は使われないため0にします。
+func postgresSearchParams(arg db.SearchReservationsParams) pgdb.SearchReservationsParams {
+	return pgdb.SearchReservationsParams{
+		UserID:          arg.UserID,
+		MemberID:        arg.MemberID,
+		Status:          arg.Status,
+		Title:           arg.Title,
+		ViewerID:        arg.ViewerID,
+		CreatedBy:       arg.CreatedBy,
+		RangeStart:      arg.RangeStart,
+		RangeEnd:        arg.RangeEnd,
+		CursorStartTime: arg.CursorStartTime,
+		CursorID:        uint64(arg.CursorID.Int64),
+		Limit:           arg.Limit,
+	}
+}
+
+func (s *postgresStore) SetReservationGoogleEventID(ctx context.Context, arg db.SetReservationGoogleEventIDParams) error {
+	return s.q.SetReservationGoogleEventID(ctx, pgdb.SetReservationGoogleEventIDParams(arg))
+}
+
+func (s *postgresStore) SetUserCaldavTokenHash(ctx context.Context, arg db.SetUserCaldavTokenHashParams) error {
+	return s.q.SetUserCaldavTokenHash(ctx, pgdb.SetUserCaldavTokenHashParams(arg))
+}
+
+func (s *postgresStore) SetUserLanguage(ctx context.Context, arg db.SetUserLanguageParams) error {
+	return s.q.SetUserLanguage(ctx, pgdb.SetUserLanguageParams(arg))
+}
+
+func (s *postgresStore) SoftDeleteUser(ctx context.Context, id uint64) error {
+	return s.q.SoftDeleteUser(ctx, id)
+}
+
+func (s *postgresStore) UpdateEquipment(ctx context.Context, arg db.UpdateEquipmentParams) error {
+	return s.q.UpdateEquipment(ctx, pgdb.UpdateEquipmentParams(arg))
+}
+
+func (s *postgresStore) UpdateReservationAttendeeResponse(ctx context.Context, arg db.UpdateReservationAttendeeResponseParams) error {
+	return s.q.UpdateReservationAttendeeResponse(ctx, pgdb.UpdateReservationAttendeeResponseParams(arg))
+}
+
+func (s *postgresStore) UpdateReservationByID(ctx context.Context, arg db.UpdateReservationByIDParams) error {
+	return s.q.UpdateReservationByID(ctx, pgdb.UpdateReservationByIDParams(arg))
+}
+
+func (s *postgresStore) UpsertCalendarSyncToken(ctx context.Context, arg db.UpsertCalendarSyncTokenParams) error {
+	return s.q.UpsertCalendarSyncToken(ctx, pgdb.UpsertCalendarSyncTokenParams(arg))
+}
@@ -0,0 +1,379 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"yoyaku/db"
+	"yoyaku/db/sqlitedb"
+)
+
+// sqliteStore は SQLite 用に生成したクエリ (db/sqlitedb) を Store として使えるようにしたものです。
+// 生成された構造体は db パッケージと同じ形なので、型変換だけで受け渡しします。
+type sqliteStore struct {
+	q *sqlitedb.Queries
+	// sqlDB はトランザクション内では nil です。
+	sqlDB *sql.DB
+}
+
+// NewSQLite は SQLite に読み書きする Store を返します。
+func NewSQLite(sqlDB *sql.DB) Store {
+	return &sqliteStore{q: sqlitedb.New(utcDB{sqlDB}), sqlDB: sqlDB}
+}
+
+func (s *sqliteStore) InTx(ctx context.Context, fn func(tx Store) error) error {
+	if s.sqlDB == nil {
+		return fn(s)
+	}
+	return runInTx(ctx, s.sqlDB, func(tx *sql.Tx) error {
+		return fn(&sqliteStore{q: sqlitedb.New(utcDB{tx})})
+	})
+}
+
+// utcDB は SQLite に渡す時刻を全て UTC に揃えます。
+// SQLite では時刻を文字列で保存して比較するため、タイムゾーンが混ざると前後関係が正しく比較できません。
+type utcDB struct {
+	sqlitedb.DBTX
+}
+
+func (u utcDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return u.DBTX.ExecContext(ctx, query, toUTC(args)...)
+}
+
+func (u utcDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return u.DBTX.QueryContext(ctx, query, toUTC(args)...)
+}
+
+func (u utcDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return u.DBTX.QueryRowContext(ctx, query, toUTC(args)...)
+}
+
+func toUTC(args []interface{}) []interface{} {
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case time.Time:
+			args[i] = v.UTC()
+		case sql.NullTime:
+			if v.Valid {
+				args[i] = sql.NullTime{Time: v.Time.UTC(), Valid: true}
+			}
+		}
+	}
+	return args
+}
+
+func (s *sqliteStore) AddReservationAttendee(ctx context.Context, arg db.AddReservationAttendeeParams) error {
+	return s.q.AddReservationAttendee(ctx, sqlitedb.AddReservationAttendeeParams(arg))
+}
+
+func (s *sqliteStore) AddReservationEquipment(ctx context.Context, arg db.AddReservationEquipmentParams) error {
+	return s.q.AddReservationEquipment(ctx, sqlitedb.AddReservationEquipmentParams(arg))
+}
+
+func (s *sqliteStore) CanceledReservationByID(ctx context.Context, arg db.CanceledReservationByIDParams) error {
+	return s.q.CanceledReservationByID(ctx, sqlitedb.CanceledReservationByIDParams(arg))
+}
+
+func (s *sqliteStore) CheckInReservation(ctx context.Context, id uint64) error {
+	return s.q.CheckInReservation(ctx, id)
+}
+
+func (s *sqliteStore) CountNoShowsByUserID(ctx context.Context, arg db.CountNoShowsByUserIDParams) (int64, error) {
+	return s.q.CountNoShowsByUserID(ctx, sqlitedb.CountNoShowsByUserIDParams(arg))
+}
+
+func (s *sqliteStore) CreateCheckinToken(ctx context.Context, arg db.CreateCheckinTokenParams) error {
+	return s.q.CreateCheckinToken(ctx, sqlitedb.CreateCheckinTokenParams(arg))
+}
+
+func (s *sqliteStore) CreateEquipment(ctx context.Context, arg db.CreateEquipmentParams) (sql.Result, error) {
+	id, err := s.q.CreateEquipment(ctx, sqlitedb.CreateEquipmentParams(arg))
+	if err != nil {
+		return nil, err
+	}
+	return result{id: int64(id)}, nil
+}
+
+func (s *sqliteStore) CreateReservation(ctx context.Context, arg db.CreateReservationParams) (sql.Result, error) {
+	id, err := s.q.CreateReservation(ctx, sqlitedb.CreateReservationParams(arg))
+	if err != nil {
+		return nil, err
+	}
+	return result{id: int64(id)}, nil
+}
+
+func (s *sqliteStore) CreateReservationEvent(ctx context.Context, arg db.CreateReservationEventParams) error {
+	return s.q.CreateReservationEvent(ctx, sqlitedb.CreateReservationEventParams(arg))
+}
+
+func (s *sqliteStore) CreateReservationFromCaldav(ctx context.Context, arg db.CreateReservationFromCaldavParams) (sql.Result, error) {
+	id, err := s.q.CreateReservationFromCaldav(ctx, sqlitedb.CreateReservationFromCaldavParams(arg))
+	if err != nil {
+		return nil, err
+	}
+	return result{id: int64(id)}, nil
+}
+
+func (s *sqliteStore) CreateReservationFromCalendar(ctx context.Context, arg db.CreateReservationFromCalendarParams) (sql.Result, error) {
+	id, err := s.q.CreateReservationFromCalendar(ctx, sqlitedb.CreateReservationFromCalendarParams(arg))
+	if err != nil {
+		return nil, err
+	}
+	return result{id: int64(id)}, nil
+}
+
+func (s *sqliteStore) CreateUser(ctx context.Context, arg db.CreateUserParams) (sql.Result, error) {
+	id, err := s.q.CreateUser(ctx, sqlitedb.CreateUserParams(arg))
+	if err != nil {
+		return nil, err
+	}
+	return result{id: int64(id)}, nil
+}
+
+func (s *sqliteStore) DeleteReservationAttendee(ctx context.Context, arg db.DeleteReservationAttendeeParams) error {
+	return s.q.DeleteReservationAttendee(ctx, sqlitedb.DeleteReservationAttendeeParams(arg))
+}
+
+func (s *sqliteStore) DeleteReservationEquipment(ctx context.Context, reservationID uint64) error {
+	return s.q.DeleteReservationEquipment(ctx, reservationID)
+}
+
+func (s *sqliteStore) EndReservationEarly(ctx context.Context, arg db.EndReservationEarlyParams) error {
+	return s.q.EndReservationEarly(ctx, sqlitedb.EndReservationEarlyParams(arg))
+}
+
+func (s *sqliteStore) ExtendReservation(ctx context.Context, arg db.ExtendReservationParams) error {
+	return s.q.ExtendReservation(ctx, sqlitedb.ExtendReservationParams(arg))
+}
+
+func (s *sqliteStore) GetCalendarSyncToken(ctx context.Context, calendarID string) (sql.NullString, error) {
+	return s.q.GetCalendarSyncToken(ctx, calendarID)
+}
+
+func (s *sqliteStore) GetCheckinTokenByReservationID(ctx context.Context, reservationID uint64) (string, error) {
+	return s.q.GetCheckinTokenByReservationID(ctx, reservationID)
+}
+
+func (s *sqliteStore) GetEquipmentByID(ctx context.Context, id uint64) (db.Equipment, error) {
+	r, err := s.q.GetEquipmentByID(ctx, id)
+	return db.Equipment(r), err
+}
+
+func (s *sqliteStore) GetEquipmentByIDForUpdate(ctx context.Context, id uint64) (db.Equipment, error) {
+	r, err := s.q.GetEquipmentByIDForUpdate(ctx, id)
+	return db.Equipment(r), err
+}
+
+func (s *sqliteStore) GetReservationByCaldavName(ctx context.Context, caldavName sql.NullString) (db.Reservation, error) {
+	r, err := s.q.GetReservationByCaldavName(ctx, caldavName)
+	return db.Reservation(r), err
+}
+
+func (s *sqliteStore) GetReservationByCheckinToken(ctx context.Context, token string) (db.Reservation, error) {
+	r, err := s.q.GetReservationByCheckinToken(ctx, token)
+	return db.Reservation(r), err
+}
+
+func (s *sqliteStore) GetReservationByGoogleEventID(ctx context.Context, googleEventID sql.NullString) (db.Reservation, error) {
+	r, err := s.q.GetReservationByGoogleEventID(ctx, googleEventID)
+	return db.Reservation(r), err
+}
+
+func (s *sqliteStore) GetReservationByID(ctx context.Context, id uint64) (db.Reservation, error) {
+	r, err := s.q.GetReservationByID(ctx, id)
+	return db.Reservation(r), err
+}
+
+func (s *sqliteStore) GetReservationByIDForUpdate(ctx context.Context, id uint64) (db.Reservation, error) {
+	r, err := s.q.GetReservationByIDForUpdate(ctx, id)
+	return db.Reservation(r), err
+}
+
+func (s *sqliteStore) GetUserByEmail(ctx context.Context, email string) (db.User, error) {
+	r, err := s.q.GetUserByEmail(ctx, email)
+	return db.User(r), err
+}
+
+func (s *sqliteStore) GetUserByGoogleID(ctx context.Context, googleID string) (db.User, error) {
+	r, err := s.q.GetUserByGoogleID(ctx, googleID)
+	return db.User(r), err
+}
+
+func (s *sqliteStore) GetUserByID(ctx context.Context, id uint64) (db.User, error) {
+	r, err := s.q.GetUserByID(ctx, id)
+	return db.User(r), err
+}
+
+func (s *sqliteStore) ListBusyIntervals(ctx context.Context, arg db.ListBusyIntervalsParams) ([]db.ListBusyIntervalsRow, error) {
+	rows, err := s.q.ListBusyIntervals(ctx, sqlitedb.ListBusyIntervalsParams(arg))
+	return convertAll(rows, func(r sqlitedb.ListBusyIntervalsRow) db.ListBusyIntervalsRow { return db.ListBusyIntervalsRow(r) }), err
+}
+
+func (s *sqliteStore) ListConfirmedReservations(ctx context.Context) ([]db.Reservation, error) {
+	rows, err := s.q.ListConfirmedReservations(ctx)
+	return convertAll(rows, func(r sqlitedb.Reservation) db.Reservation { return db.Reservation(r) }), err
+}
+
+func (s *sqliteStore) ListEquipment(ctx context.Context) ([]db.Equipment, error) {
+	rows, err := s.q.ListEquipment(ctx)
+	return convertAll(rows, func(r sqlitedb.Equipment) db.Equipment { return db.Equipment(r) }), err
+}
+
+func (s *sqliteStore) ListEquipmentUsage(ctx context.Context, arg db.ListEquipmentUsageParams) ([]db.ListEquipmentUsageRow, error) {
+	rows, err := s.q.ListEquipmentUsage(ctx, sqlitedb.ListEquipmentUsageParams(arg))
+	return convertAll(rows, func(r sqlitedb.ListEquipmentUsageRow) db.ListEquipmentUsageRow { return db.ListEquipmentUsageRow(r) }), err
+}
+
+func (s *sqliteStore) ListInvitationsByUserID(ctx context.Context, arg db.ListInvitationsByUserIDParams) ([]db.ListInvitationsByUserIDRow, error) {
+	rows, err := s.q.ListInvitationsByUserID(ctx, sqlitedb.ListInvitationsByUserIDParams(arg))
+	return convertAll(rows, func(r sqlitedb.ListInvitationsByUserIDRow) db.ListInvitationsByUserIDRow {
+		return db.ListInvitationsByUserIDRow(r)
+	}), err
+}
+
+func (s *sqliteStore) ListNoShowCandidates(ctx context.Context, arg db.ListNoShowCandidatesParams) ([]db.Reservation, error) {
+	rows, err := s.q.ListNoShowCandidates(ctx, sqlitedb.ListNoShowCandidatesParams(arg))
+	return convertAll(rows, func(r sqlitedb.Reservation) db.Reservation { return db.Reservation(r) }), err
+}
+
+func (s *sqliteStore) LockRoom(ctx context.Context, id string) (string, error) {
+	return s.q.LockRoom(ctx, id)
+}
+
+func (s *sqliteStore) ListOverlappingReservationIDsForUpdate(ctx context.Context, arg db.ListOverlappingReservationIDsForUpdateParams) ([]uint64, error) {
+	return s.q.ListOverlappingReservationIDsForUpdate(ctx, sqlitedb.ListOverlappingReservationIDsForUpdateParams(arg))
+}
+
+func (s *sqliteStore) ListReservationAttendees(ctx context.Context, reservationIds []uint64) ([]db.ListReservationAttendeesRow, error) {
+	rows, err := s.q.ListReservationAttendees(ctx, reservationIds)
+	return convertAll(rows, func(r sqlitedb.ListReservationAttendeesRow) db.ListReservationAttendeesRow {
+		return db.ListReservationAttendeesRow(r)
+	}), err
+}
+
+func (s *sqliteStore) ListReservationEquipment(ctx context.Context, reservationIds []uint64) ([]db.ListReservationEquipmentRow, error) {
+	rows, err := s.q.ListReservationEquipment(ctx, reservationIds)
+	return convertAll(rows, func(r sqlitedb.ListReservationEquipmentRow) db.ListReservationEquipmentRow {
+		return db.ListReservationEquipmentRow(r)
+	}), err
+}
+
+func (s *sqliteStore) ListReservationEventsByReservationID(ctx context.Context, reservationID uint64) ([]db.ListReservationEventsByReservationIDRow, error) {
+	rows, err := s.q.ListReservationEventsByReservationID(ctx, reservationID)
+	return convertAll(rows, func(r sqlitedb.ListReservationEventsByReservationIDRow) db.ListReservationEventsByReservationIDRow {
+		return db.ListReservationEventsByReservationIDRow(r)
+	}), err
+}
+
+func (s *sqliteStore) ListReservationsByDate(ctx context.Context, arg db.ListReservationsByDateParams) ([]db.ListReservationsByDateRow, error) {
+	rows, err := s.q.ListReservationsByDate(ctx, sqlitedb.ListReservationsByDateParams(arg))
+	return convertAll(rows, func(r sqlitedb.ListReservationsByDateRow) db.ListReservationsByDateRow {
+		return db.ListReservationsByDateRow(r)
+	}), err
+}
+
+func (s *sqliteStore) ListReservationsByUserID(ctx context.Context, userID uint64) ([]db.Reservation, error) {
+	rows, err := s.q.ListReservationsByUserID(ctx, userID)
+	return convertAll(rows, func(r sqlitedb.Reservation) db.Reservation { return db.Reservation(r) }), err
+}
+
+func (s *sqliteStore) ListUsers(ctx context.Context) ([]db.User, error) {
+	rows, err := s.q.ListUsers(ctx)
+	return convertAll(rows, func(r sqlitedb.User) db.User { return db.User(r) }), err
+}
+
+func (s *sqliteStore) MarkReservationNoShow(ctx context.Context, id uint64) (sql.Result, error) {
+	return s.q.MarkReservationNoShow(ctx, id)
+}
+
+func (s *sqliteStore) SearchReservationEvents(ctx context.Context, arg db.SearchReservationEventsParams) ([]db.SearchReservationEventsRow, error) {
+	rows, err := s.q.SearchReservationEvents(ctx, sqlitedb.SearchReservationEventsParams{
+		ActorUserID:   arg.ActorUserID,
+		ReservationID: arg.ReservationID,
+		Action:        arg.Action,
+		AuthMethod:    arg.AuthMethod,
+		CreatedFrom:   arg.CreatedFrom,
+		CreatedTo:     arg.CreatedTo,
+		Limit:         int64(arg.Limit),
+		Offset:        int64(arg.Offset),
+	})
+	return convertAll(rows, func(r sqlitedb.SearchReservationEventsRow) db.SearchReservationEventsRow {
+		return db.SearchReservationEventsRow(r)
+	}), err
+}
+
+func (s *sqliteStore) SearchReservations(ctx context.Context, arg db.SearchReservationsParams) ([]db.SearchReservationsRow, error) {
+	rows, err := s.q.SearchReservations(ctx, sqliteSearchParams(arg))
+	return convertAll(rows, func(r sqlitedb.SearchReservationsRow) db.SearchReservationsRow {
+		return db.SearchReservationsRow(r)
+	}), err
+}
+
+func (s *sqliteStore) SearchReservationsDesc(ctx context.Context, arg db.SearchReservationsDescParams) ([]db.SearchReservationsDescRow, error) {
+	rows, err := s.q.SearchReservationsDesc(ctx, sqlitedb.SearchReservationsDescParams(sqliteSearchParams(db.SearchReservationsParams(arg))))
+	return convertAll(rows, func(r sqlitedb.SearchReservationsDescRow) db.SearchReservationsDescRow {
+		return db.SearchReservationsDescRow(r)
+	}), err
+}
+
+func (s *sqliteStore) SearchReservationsFullText(ctx context.Context, arg db.SearchReservationsFullTextParams) ([]db.SearchReservationsFullTextRow, error) {
+	rows, err := s.q.SearchReservationsFullText(ctx, sqlitedb.SearchReservationsFullTextParams{
+		Query:    arg.Query,
+		ViewerID: arg.ViewerID,
+		Offset:   int64(arg.Offset),
+		Limit:    int64(arg.Limit),
+	})
+	return convertAll(rows, func(r sqlitedb.SearchReservationsFullTextRow) db.SearchReservationsFullTextRow {
+		return db.SearchReservationsFullTextRow(r)
+	}), err
+}
+
+// sqliteSearchParams は予約の検索条件を変換します。cursor_id は cursor_start_time が NULL の場合は使われないため0にします。
+func sqliteSearchParams(arg db.SearchReservationsParams) sqlitedb.SearchReservationsParams {
+	return sqlitedb.SearchReservationsParams{
+		UserID:          arg.UserID,
+		MemberID:        arg.MemberID,
+		Status:          arg.Status,
+		Title:           arg.Title,
+		ViewerID:        arg.ViewerID,
+		CreatedBy:       arg.CreatedBy,
+		RangeStart:      arg.RangeStart,
+		RangeEnd:        arg.RangeEnd,
+		CursorStartTime: arg.CursorStartTime,
+		CursorID:        uint64(arg.CursorID.Int64),
+		Limit:           int64(arg.Limit),
+	}
+}
+
+func (s *sqliteStore) SetReservationGoogleEventID(ctx context.Context, arg db.SetReservationGoogleEventIDParams) error {
+	return s.q.SetReservationGoogleEventID(ctx, sqlitedb.SetReservationGoogleEventIDParams(arg))
+}
+
+func (s *sqliteStore) SetUserCaldavTokenHash(ctx context.Context, arg db.SetUserCaldavTokenHashParams) error {
+	return s.q.SetUserCaldavTokenHash(ctx, sqlitedb.SetUserCaldavTokenHashParams(arg))
+}
+
+func (s *sqliteStore) SetUserLanguage(ctx context.Context, arg db.SetUserLanguageParams) error {
+	return s.q.SetUserLanguage(ctx, sqlitedb.SetUserLanguageParams(arg))
+}
+
+func (s *sqliteStore) SoftDeleteUser(ctx context.Context, id uint64) error {
+	return s.q.SoftDeleteUser(ctx, id)
+}
+
+func (s *sqliteStore) UpdateEquipment(ctx context.Context, arg db.UpdateEquipmentParams) error {
+	return s.q.UpdateEquipment(ctx, sqlitedb.UpdateEquipmentParams(arg))
+}
+
+func (s *sqliteStore) UpdateReservationAttendeeResponse(ctx context.Context, arg db.UpdateReservationAttendeeResponseParams) error {
+	return s.q.UpdateReservationAttendeeResponse(ctx, sqlitedb.UpdateReservationAttendeeResponseParams(arg))
+}
+
+func (s *sqliteStore) UpdateReservationByID(ctx context.Context, arg db.UpdateReservationByIDParams) error {
+	return s.q.UpdateReservationByID(ctx, sqlitedb.UpdateReservationByIDParams(arg))
+}
+
+func (s *sqliteStore) UpsertCalendarSyncToken(ctx context.Context, arg db.UpsertCalendarSyncTokenParams) error {
+	return s.q.UpsertCalendarSyncToken(ctx, sqlitedb.UpsertCalendarSyncTokenParams(arg))
+}
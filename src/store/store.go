@@ -0,0 +1,153 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"yoyaku/db"
+)
+
+// UserStore はユーザーの読み書きを行います。*db.Queries がそのまま実装しています。
+type UserStore interface {
+	CreateUser(ctx context.Context, arg db.CreateUserParams) (sql.Result, error)
+	GetUserByID(ctx context.Context, id uint64) (db.User, error)
+	GetUserByEmail(ctx context.Context, email string) (db.User, error)
+	GetUserByGoogleID(ctx context.Context, googleID string) (db.User, error)
+	ListUsers(ctx context.Context) ([]db.User, error)
+	SetUserLanguage(ctx context.Context, arg db.SetUserLanguageParams) error
+	SetUserCaldavTokenHash(ctx context.Context, arg db.SetUserCaldavTokenHashParams) error
+}
+
+// ReservationStore は予約とその変更履歴の読み書きを行います。*db.Queries がそのまま実装しています。
+// 引数の構造体は sqlc が生成したものをそのまま使うため、パラメータ名と意味が入れ替わっているものがあります
+// (例: ListReservationsByDateParams の StartTime は「この時刻より前に始まる」、EndTime は「この時刻以降に終わる」)。
+type ReservationStore interface {
+	CreateReservation(ctx context.Context, arg db.CreateReservationParams) (sql.Result, error)
+	GetReservationByID(ctx context.Context, id uint64) (db.Reservation, error)
+	GetReservationByIDForUpdate(ctx context.Context, id uint64) (db.Reservation, error)
+	ListReservationsByUserID(ctx context.Context, userID uint64) ([]db.Reservation, error)
+	ListConfirmedReservations(ctx context.Context) ([]db.Reservation, error)
+	ListReservationsByDate(ctx context.Context, arg db.ListReservationsByDateParams) ([]db.ListReservationsByDateRow, error)
+	SearchReservations(ctx context.Context, arg db.SearchReservationsParams) ([]db.SearchReservationsRow, error)
+	SearchReservationsDesc(ctx context.Context, arg db.SearchReservationsDescParams) ([]db.SearchReservationsDescRow, error)
+	SearchReservationsFullText(ctx context.Context, arg db.SearchReservationsFullTextParams) ([]db.SearchReservationsFullTextRow, error)
+	UpdateReservationByID(ctx context.Context, arg db.UpdateReservationByIDParams) error
+	CanceledReservationByID(ctx context.Context, arg db.CanceledReservationByIDParams) error
+	CheckInReservation(ctx context.Context, id uint64) error
+	EndReservationEarly(ctx context.Context, arg db.EndReservationEarlyParams) error
+	ExtendReservation(ctx context.Context, arg db.ExtendReservationParams) error
+	CreateReservationFromCaldav(ctx context.Context, arg db.CreateReservationFromCaldavParams) (sql.Result, error)
+	CreateReservationFromCalendar(ctx context.Context, arg db.CreateReservationFromCalendarParams) (sql.Result, error)
+	GetReservationByGoogleEventID(ctx context.Context, googleEventID sql.NullString) (db.Reservation, error)
+	SetReservationGoogleEventID(ctx context.Context, arg db.SetReservationGoogleEventIDParams) error
+	GetReservationByCaldavName(ctx context.Context, caldavName sql.NullString) (db.Reservation, error)
+	CreateCheckinToken(ctx context.Context, arg db.CreateCheckinTokenParams) error
+	GetCheckinTokenByReservationID(ctx context.Context, reservationID uint64) (string, error)
+	GetReservationByCheckinToken(ctx context.Context, token string) (db.Reservation, error)
+	ListBusyIntervals(ctx context.Context, arg db.ListBusyIntervalsParams) ([]db.ListBusyIntervalsRow, error)
+	ListOverlappingReservationIDsForUpdate(ctx context.Context, arg db.ListOverlappingReservationIDsForUpdateParams) ([]uint64, error)
+	LockRoom(ctx context.Context, id string) (string, error)
+	CountNoShowsByUserID(ctx context.Context, arg db.CountNoShowsByUserIDParams) (int64, error)
+	CreateReservationEvent(ctx context.Context, arg db.CreateReservationEventParams) error
+	AddReservationAttendee(ctx context.Context, arg db.AddReservationAttendeeParams) error
+	DeleteReservationAttendee(ctx context.Context, arg db.DeleteReservationAttendeeParams) error
+	ListReservationAttendees(ctx context.Context, reservationIds []uint64) ([]db.ListReservationAttendeesRow, error)
+	UpdateReservationAttendeeResponse(ctx context.Context, arg db.UpdateReservationAttendeeResponseParams) error
+	ListInvitationsByUserID(ctx context.Context, arg db.ListInvitationsByUserIDParams) ([]db.ListInvitationsByUserIDRow, error)
+}
+
+// CalendarSyncStore は Googleカレンダーとの同期の状態の読み書きを行います。*db.Queries がそのまま実装しています。
+type CalendarSyncStore interface {
+	GetCalendarSyncToken(ctx context.Context, calendarID string) (sql.NullString, error)
+	UpsertCalendarSyncToken(ctx context.Context, arg db.UpsertCalendarSyncTokenParams) error
+}
+
+// EquipmentStore は備品と、予約ごとに借りる備品の読み書きを行います。*db.Queries がそのまま実装しています。
+type EquipmentStore interface {
+	CreateEquipment(ctx context.Context, arg db.CreateEquipmentParams) (sql.Result, error)
+	UpdateEquipment(ctx context.Context, arg db.UpdateEquipmentParams) error
+	GetEquipmentByID(ctx context.Context, id uint64) (db.Equipment, error)
+	GetEquipmentByIDForUpdate(ctx context.Context, id uint64) (db.Equipment, error)
+	ListEquipment(ctx context.Context) ([]db.Equipment, error)
+	ListEquipmentUsage(ctx context.Context, arg db.ListEquipmentUsageParams) ([]db.ListEquipmentUsageRow, error)
+	AddReservationEquipment(ctx context.Context, arg db.AddReservationEquipmentParams) error
+	DeleteReservationEquipment(ctx context.Context, reservationID uint64) error
+	ListReservationEquipment(ctx context.Context, reservationIds []uint64) ([]db.ListReservationEquipmentRow, error)
+}
+
+// Store はアプリケーションが使う全てのクエリとトランザクションです。
+// DATABASE_URL に応じて NewMySQL / NewSQLite / NewPostgres のいずれかを使い、テストでは NewMemory を使います。
+type Store interface {
+	db.Querier
+
+	// SearchReservationsFullText は sqlc が生成していない (db.Querier に含まれない) クエリです (db/fulltext.go)。
+	SearchReservationsFullText(ctx context.Context, arg db.SearchReservationsFullTextParams) ([]db.SearchReservationsFullTextRow, error)
+
+	// InTx は fn をトランザクション内で実行します。fn がエラーを返した場合は全ての変更を取り消します。
+	// fn の中では引数の tx を使って読み書きしてください。
+	InTx(ctx context.Context, fn func(tx Store) error) error
+}
+
+var (
+	_ UserStore         = (*db.Queries)(nil)
+	_ ReservationStore  = (*db.Queries)(nil)
+	_ EquipmentStore    = (*db.Queries)(nil)
+	_ CalendarSyncStore = (*db.Queries)(nil)
+	_ Store             = (*mysqlStore)(nil)
+	_ Store             = (*sqliteStore)(nil)
+	_ Store             = (*postgresStore)(nil)
+)
+
+// mysqlStore は *db.Queries に MySQL のトランザクションを組み合わせた Store です。
+type mysqlStore struct {
+	*db.Queries
+	// sqlDB はトランザクション内では nil です。
+	sqlDB *sql.DB
+}
+
+// NewMySQL は MySQL に読み書きする Store を返します。
+func NewMySQL(sqlDB *sql.DB) Store {
+	return &mysqlStore{Queries: db.New(sqlDB), sqlDB: sqlDB}
+}
+
+func (s *mysqlStore) InTx(ctx context.Context, fn func(tx Store) error) error {
+	if s.sqlDB == nil {
+		return fn(s)
+	}
+	return db.RunInTx(ctx, s.sqlDB, func(qtx *db.Queries) error {
+		return fn(&mysqlStore{Queries: qtx})
+	})
+}
+
+// runInTx は fn をトランザクション内で実行し、fn がエラーを返した場合はロールバックします。
+func runInTx(ctx context.Context, sqlDB *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// result は作成した行のIDを sql.Result として返します (LastInsertId が使えないドライバー用)。
+type result struct {
+	id int64
+}
+
+func (r result) LastInsertId() (int64, error) { return r.id, nil }
+func (r result) RowsAffected() (int64, error) { return 1, nil }
+
+func convertAll[T, U any](rows []T, convert func(T) U) []U {
+	if rows == nil {
+		return nil
+	}
+	converted := make([]U, len(rows))
+	for i, row := range rows {
+		converted[i] = convert(row)
+	}
+	return converted
+}
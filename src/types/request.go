@@ -5,7 +5,44 @@ import (
 )
 
 type ReservationsRequest struct {
-	Title     string    `json:"title"`
-	StartTime time.Time `json:"start_time"`
-	EndTime   time.Time `json:"end_time"`
+	Title string `json:"title"`
+	// Description は予約の説明 (任意、2000文字まで) で、タイトルとともに全文検索の対象になります。
+	Description string    `json:"description,omitempty"`
+	StartTime   time.Time `json:"start_time"`
+	EndTime     time.Time `json:"end_time"`
+	// Headcount は参加予定人数 (任意、予約者を含む) で、部屋の定員以内です。0 は未指定を表します。
+	Headcount int `json:"headcount,omitempty"`
+	// Attendees は参加者のユーザーID (任意) です。予約者本人と重複は取り除きます。
+	Attendees []uint64 `json:"attendees,omitempty"`
+	// Visibility は公開範囲 ("public"、"members"、"private") で、省略した場合は "public" です。
+	Visibility string `json:"visibility,omitempty"`
+	// Equipment は部屋と一緒に借りる備品 (任意) です。同じ時間帯の他の予約と合わせて、備品の数以内で借りられます。
+	Equipment []ReservationEquipmentRequest `json:"equipment,omitempty"`
+}
+
+// ReservationEquipmentRequest は予約で借りる備品と数です。
+type ReservationEquipmentRequest struct {
+	EquipmentID uint64 `json:"equipment_id"`
+	// Quantity は借りる数 (1〜10000) です。
+	Quantity int `json:"quantity"`
+}
+
+// EquipmentRequest は備品の登録・変更のリクエストです (管理者のみ)。
+type EquipmentRequest struct {
+	// Name は備品の名前 (100文字まで) で、他の備品と重複できません。
+	Name string `json:"name"`
+	// Quantity はこのシステムで貸し出せる数 (0〜10000) です。
+	Quantity int `json:"quantity"`
+}
+
+// SetLanguageRequest は表示言語の変更リクエストです。
+type SetLanguageRequest struct {
+	// "ja" または "en"。空文字列の場合は選択を解除して Accept-Language に従う
+	Language string `json:"language"`
+}
+
+// InvitationResponseRequest は招待への返答のリクエストです。
+type InvitationResponseRequest struct {
+	// "accepted"、"declined"、"tentative" のいずれか
+	Response string `json:"response"`
 }
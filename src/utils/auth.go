@@ -1,24 +1,27 @@
 package utils
 
 import (
-	"log"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"yoyaku/apierror"
+	"yoyaku/db"
+	"yoyaku/i18n"
+	"yoyaku/store"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/sessions"
 )
 
 // GetUserIDFromSession は、Ginのコンテキストからセッション情報を取得し、ユーザーIDを返します。
-// 処理中にエラーが発生した場合は、自動的にクライアントにエラーレスポンスを返し、falseを返します。
+// 処理中にエラーが発生した場合は、apierror.Abort でエラーを登録してfalseを返します。
 // 成功した場合は、ユーザーID(uint64)とtrueを返します。
 func GetUserIDFromSession(c *gin.Context) (uint64, bool) {
 	// c.MustGetからセッションストアを取得
 	store, ok := c.MustGet("session_store").(*sessions.CookieStore)
 	if !ok {
-		log.Println("セッションストアの取得に失敗しました")
-		c.JSON(http.StatusInternalServerError, gin.H{"status": "error", "message": "サーバー内部エラーが発生しました"})
-		c.Abort() // 処理を中断
+		apierror.Abort(c, apierror.Internal("サーバー内部エラーが発生しました", errors.New("セッションストアの取得に失敗しました")))
 		return 0, false
 	}
 
@@ -27,27 +30,78 @@ func GetUserIDFromSession(c *gin.Context) (uint64, bool) {
 	// セッションからuser_idを取得
 	userIDStr, ok := session.Values["user_id"].(string)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "ログイン情報が見つかりません"})
-		c.Abort()
+		apierror.Abort(c, apierror.Unauthorized("ログイン情報が見つかりません"))
 		return 0, false
 	}
 
 	// 文字列のIDをint64に変換
 	userID, err := strconv.ParseInt(userIDStr, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"status": "error", "message": "ユーザーIDが不正です"})
-		c.Abort()
+		apierror.Abort(c, apierror.Unauthorized("ユーザーIDが不正です"))
 		return 0, false
 	}
 
 	// ユーザーIDが負でないことを確認
 	if userID < 0 {
-		log.Println("不正なユーザーID（負の値）:", userID)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "不正なユーザーIDです"})
-		c.Abort()
+		apierror.Abort(c, apierror.Internal("不正なユーザーIDです", fmt.Errorf("不正なユーザーID（負の値）: %d", userID)))
 		return 0, false
 	}
 
 	// uint64にキャストして返す
 	return uint64(userID), true
 }
+
+// GetAdminFromSession は、ログインユーザーが管理者 (role = 'admin') であることを確認し、そのユーザーを返します。
+// 管理者でない場合は、apierror.Abort でエラーを登録してfalseを返します。
+func GetAdminFromSession(c *gin.Context, users store.UserStore) (db.User, bool) {
+	userID, ok := GetUserIDFromSession(c)
+	if !ok {
+		return db.User{}, false
+	}
+
+	user, err := users.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("サーバー内部エラーが発生しました", err))
+		return db.User{}, false
+	}
+	if user.Role != "admin" {
+		apierror.Abort(c, apierror.New(http.StatusForbidden, apierror.CodeAdminOnly, "管理者のみ利用できます"))
+		return db.User{}, false
+	}
+	return user, true
+}
+
+// OptionalUserIDFromSession はログインしている場合にそのユーザーIDを返します。
+// GetUserIDFromSession と違い、ログインしていない場合もエラーにせず false を返します (ログインしなくても使えるAPI向け)。
+func OptionalUserIDFromSession(c *gin.Context) (uint64, bool) {
+	store, ok := c.MustGet("session_store").(*sessions.CookieStore)
+	if !ok {
+		return 0, false
+	}
+	session, _ := store.Get(c.Request, "session-name")
+	userIDStr, ok := session.Values["user_id"].(string)
+	if !ok {
+		return 0, false
+	}
+	userID, err := strconv.ParseUint(userIDStr, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return userID, true
+}
+
+// SetLanguageFromSession は、ログイン中のユーザーが表示言語を選んでいる場合に、それをリクエストの言語として設定します。
+// 選んでいない場合やログインしていない場合は何もせず、Accept-Language から言語を決めます。
+// main.go でセッションストアを設定した後にミドルウェアとして登録します。
+func SetLanguageFromSession(c *gin.Context) {
+	store, ok := c.MustGet("session_store").(*sessions.CookieStore)
+	if ok {
+		session, _ := store.Get(c.Request, "session-name")
+		if value, ok := session.Values["language"].(string); ok {
+			if lang, ok := i18n.Parse(value); ok {
+				i18n.Set(c, lang)
+			}
+		}
+	}
+	c.Next()
+}
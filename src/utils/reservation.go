@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"yoyaku/types"
+)
+
+// FieldError は入力項目 (JSON のフィールド名) ごとの検証エラーです。
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string { return e.Message }
+
+// MaxDescriptionLength は予約の説明の最大文字数です (reservations.description の長さ)。
+const MaxDescriptionLength = 2000
+
+// ValidateReservation は予約のタイトル・説明・時間帯・参加予定人数・公開範囲が正しいか検証します。
+// 部屋の定員と参加者は reservation.Service が検証します。
+// HTTP API と CalDAV など、予約を書き込む全ての経路で同じ検証を行うために使用します。
+// エラーは *FieldError です。
+func ValidateReservation(req types.ReservationsRequest) error {
+	if strings.TrimSpace(req.Title) == "" {
+		return &FieldError{Field: "title", Message: "タイトルを入力してください"}
+	}
+	if utf8.RuneCountInString(req.Description) > MaxDescriptionLength {
+		return &FieldError{Field: "description", Message: "説明は2000文字以内で入力してください"}
+	}
+	if req.Headcount < 0 {
+		return &FieldError{Field: "headcount", Message: "参加予定人数は0以上で入力してください"}
+	}
+	switch req.Visibility {
+	case "", "public", "members", "private":
+	default:
+		return &FieldError{Field: "visibility", Message: "公開範囲は public、members、private のいずれかで指定してください"}
+	}
+	for _, e := range req.Equipment {
+		if e.Quantity < 1 || e.Quantity > 10000 {
+			return &FieldError{Field: "equipment", Message: "借りる備品の数は1から10000の範囲で入力してください"}
+		}
+	}
+	if req.StartTime.IsZero() {
+		return &FieldError{Field: "start_time", Message: "開始時刻と終了時刻を指定してください"}
+	}
+	if req.EndTime.IsZero() {
+		return &FieldError{Field: "end_time", Message: "開始時刻と終了時刻を指定してください"}
+	}
+	if !req.EndTime.After(req.StartTime) {
+		return &FieldError{Field: "end_time", Message: "終了時刻は開始時刻より後にしてください"}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+	_ "time/tzdata" // コンテナにタイムゾーンデータが無くても tz を読み込めるようにする
+	"yoyaku/apierror"
+	"yoyaku/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetLocation は loc をリクエストのタイムゾーンとして設定するミドルウェアを返します。
+// main.go で設定 (config.Config.Location) のタイムゾーンを全てのリクエストに登録します。
+func SetLocation(loc *time.Location) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("location", loc)
+		c.Next()
+	}
+}
+
+// RequestLocation はリクエストの日付を解釈するタイムゾーンを返します。
+// tz クエリパラメータ (例: tz=America/New_York) があればそれを、無ければ SetLocation で設定したタイムゾーンを使います。
+// tz が正しくない場合は apierror.BadRequest を返します。
+func RequestLocation(c *gin.Context) (*time.Location, error) {
+	if name := c.Query("tz"); name != "" {
+		loc, err := loadLocation(name)
+		if err != nil {
+			return nil, apierror.BadRequest("tzのタイムゾーンが正しくありません (例: Asia/Tokyo)")
+		}
+		return loc, nil
+	}
+	if loc, ok := c.Value("location").(*time.Location); ok {
+		return loc, nil
+	}
+	return time.LoadLocation(config.DefaultTimezone)
+}
+
+// loadLocation は IANA のタイムゾーン名を読み込みます。
+// サーバーの設定に依存しないよう、"Local" と空の名前は受け付けません。
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+	return time.LoadLocation(name)
+}